@@ -0,0 +1,212 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// Limits of the canonical ABI on the number of flattened parameters and
+// results. Values that don't fit are passed through linear memory.
+const (
+	maxFlatParams  = 16
+	maxFlatResults = 1
+)
+
+// An ABIProblem is a way in which a core module does not meet the
+// expectations of the component model canonical ABI.
+type ABIProblem struct {
+	// Name is the name of the export or import the problem relates to, or
+	// empty if the problem concerns the module as a whole.
+	Name string
+
+	// Message describes the problem.
+	Message string
+}
+
+func (p ABIProblem) String() string {
+	if p.Name == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", p.Name, p.Message)
+}
+
+// CheckCanonicalABI checks whether m can be wrapped in a component, as done by
+// wasm-tools component new, and returns the problems found. An empty result
+// means that the module has the exports the canonical ABI relies on and that
+// all exported and imported functions can be lifted or lowered.
+//
+// The following is checked:
+//
+//   - the module has a single memory, exported as "memory"
+//   - cabi_realloc is exported with type (i32, i32, i32, i32) -> (i32)
+//   - _initialize and _start, if exported, have type () -> ()
+//   - every cabi_post_<name> export has a matching function export <name>
+//     and takes its results as parameters
+//   - functions only use i32, i64, f32 and f64 values, take at most 16
+//     parameters and return at most one result
+func CheckCanonicalABI(m *wasm.Module) []ABIProblem {
+	x := newIndex(m)
+	var problems []ABIProblem
+	report := func(name, format string, args ...interface{}) {
+		problems = append(problems, ABIProblem{Name: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	exports := make(map[string]wasm.ExportEntry)
+	var memories []string
+	for _, e := range x.exports {
+		exports[e.Field] = e
+		if e.Kind == wasm.ExtKindMemory {
+			memories = append(memories, e.Field)
+		}
+	}
+
+	switch {
+	case x.numMemories == 0:
+		report("", "module has no memory; strings and lists are passed through linear memory")
+	case x.numMemories > 1:
+		report("", "module has %d memories; the canonical ABI uses a single memory", x.numMemories)
+	}
+	if x.numMemories > 0 {
+		if e, ok := exports["memory"]; !ok {
+			if len(memories) > 0 {
+				report("memory", "memory is exported as %s instead of \"memory\"", quoteAll(memories))
+			} else {
+				report("memory", "memory is not exported")
+			}
+		} else if e.Kind != wasm.ExtKindMemory {
+			report("memory", "export is a %s, expected a memory", kindName(e.Kind))
+		}
+	}
+
+	realloc := &wasm.FuncType{Params: []wasm.ValueType{wasm.I32, wasm.I32, wasm.I32, wasm.I32}, ReturnTypes: []wasm.ValueType{wasm.I32}}
+	initialize := &wasm.FuncType{}
+	if _, ok := exports["cabi_realloc"]; !ok {
+		report("cabi_realloc", "function is not exported; it's required to pass strings and lists into the module")
+	}
+
+	var names []string
+	for name := range exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		e := exports[name]
+		switch name {
+		case "cabi_realloc":
+			checkExportType(x, e, realloc, report)
+			continue
+		case "_initialize", "_start":
+			checkExportType(x, e, initialize, report)
+			continue
+		}
+		if e.Kind != wasm.ExtKindFunction {
+			continue
+		}
+		t := x.funcType(e.Index)
+		if t == nil {
+			report(name, "function %d has no type", e.Index)
+			continue
+		}
+
+		if strings.HasPrefix(name, "cabi_post_") {
+			target := strings.TrimPrefix(name, "cabi_post_")
+			f, ok := exports[target]
+			if !ok || f.Kind != wasm.ExtKindFunction {
+				report(name, "no function is exported as %q", target)
+				continue
+			}
+			if ft := x.funcType(f.Index); ft != nil {
+				checkExportType(x, e, &wasm.FuncType{Params: ft.ReturnTypes}, report)
+			}
+			continue
+		}
+
+		checkFlat(name, t, report)
+	}
+
+	fi := uint32(0)
+	for _, e := range x.imports {
+		if e.Kind != wasm.ExtKindFunction {
+			continue
+		}
+		if t := x.funcType(fi); t != nil {
+			checkFlat(e.Module+"."+e.Field, t, report)
+		}
+		fi++
+	}
+
+	return problems
+}
+
+// checkExportType reports a problem if e is not a function of type want.
+func checkExportType(x *index, e wasm.ExportEntry, want *wasm.FuncType, report func(name, format string, args ...interface{})) {
+	if e.Kind != wasm.ExtKindFunction {
+		report(e.Field, "export is a %s, expected a function", kindName(e.Kind))
+		return
+	}
+	t := x.funcType(e.Index)
+	if t == nil {
+		report(e.Field, "function %d has no type", e.Index)
+		return
+	}
+	if !sameTypes(t.Params, want.Params) || !sameTypes(t.ReturnTypes, want.ReturnTypes) {
+		report(e.Field, "type is %s, expected %s", t, want)
+	}
+}
+
+// checkFlat reports a problem if the function type can't be used by a lifted
+// or lowered function.
+func checkFlat(name string, t *wasm.FuncType, report func(name, format string, args ...interface{})) {
+	for _, v := range append(append([]wasm.ValueType(nil), t.Params...), t.ReturnTypes...) {
+		switch v {
+		case wasm.I32, wasm.I64, wasm.F32, wasm.F64:
+		default:
+			report(name, "type %s uses a value type that the canonical ABI does not produce", t)
+			return
+		}
+	}
+	if len(t.Params) > maxFlatParams {
+		report(name, "takes %d parameters; the canonical ABI passes more than %d through memory as a single i32", len(t.Params), maxFlatParams)
+	}
+	if len(t.ReturnTypes) > maxFlatResults {
+		report(name, "returns %d values; the canonical ABI returns more than %d through memory", len(t.ReturnTypes), maxFlatResults)
+	}
+}
+
+func sameTypes(a, b []wasm.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func kindName(k wasm.ExternalKind) string {
+	switch k {
+	case wasm.ExtKindFunction:
+		return "function"
+	case wasm.ExtKindTable:
+		return "table"
+	case wasm.ExtKindMemory:
+		return "memory"
+	case wasm.ExtKindGlobal:
+		return "global"
+	}
+	return fmt.Sprintf("kind %d", k)
+}
+
+func quoteAll(s []string) string {
+	q := make([]string, len(s))
+	for i, v := range s {
+		q[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(q, ", ")
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func TestCheckCanonicalABI(t *testing.T) {
+	i32 := wasm.I32
+	types := &wasm.SectionType{Entries: []wasm.FuncType{
+		{Form: 0x60}, // () -> ()
+		{Form: 0x60, Params: []wasm.ValueType{i32, i32, i32, i32}, ReturnTypes: []wasm.ValueType{i32}}, // realloc
+		{Form: 0x60, Params: []wasm.ValueType{i32}, ReturnTypes: []wasm.ValueType{i32}},
+		{Form: 0x60, ReturnTypes: []wasm.ValueType{i32, i32}},
+		{Form: 0x60, Params: []wasm.ValueType{i32}},
+	}}
+	memory := &wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 1}}}}
+
+	tt := []struct {
+		name     string
+		funcs    []uint32
+		exports  []wasm.ExportEntry
+		expected []string
+	}{
+		{
+			name:  "valid",
+			funcs: []uint32{1, 0, 2, 4},
+			exports: []wasm.ExportEntry{
+				{Field: "memory", Kind: wasm.ExtKindMemory},
+				{Field: "cabi_realloc", Kind: wasm.ExtKindFunction, Index: 0},
+				{Field: "_initialize", Kind: wasm.ExtKindFunction, Index: 1},
+				{Field: "greet", Kind: wasm.ExtKindFunction, Index: 2},
+				{Field: "cabi_post_greet", Kind: wasm.ExtKindFunction, Index: 3},
+			},
+		},
+		{
+			name:  "missing exports",
+			funcs: []uint32{2},
+			exports: []wasm.ExportEntry{
+				{Field: "mem", Kind: wasm.ExtKindMemory},
+				{Field: "run", Kind: wasm.ExtKindFunction, Index: 0},
+			},
+			expected: []string{
+				`memory: memory is exported as "mem" instead of "memory"`,
+				"cabi_realloc: function is not exported; it's required to pass strings and lists into the module",
+			},
+		},
+		{
+			name:  "wrong types",
+			funcs: []uint32{0, 2, 3},
+			exports: []wasm.ExportEntry{
+				{Field: "memory", Kind: wasm.ExtKindMemory},
+				{Field: "cabi_realloc", Kind: wasm.ExtKindFunction, Index: 0},
+				{Field: "_start", Kind: wasm.ExtKindFunction, Index: 1},
+				{Field: "cabi_post_missing", Kind: wasm.ExtKindFunction, Index: 0},
+				{Field: "pair", Kind: wasm.ExtKindFunction, Index: 2},
+			},
+			expected: []string{
+				"_start: type is (i32) -> (i32), expected () -> ()",
+				"cabi_post_missing: no function is exported as \"missing\"",
+				"cabi_realloc: type is () -> (), expected (i32, i32, i32, i32) -> (i32)",
+				"pair: returns 2 values; the canonical ABI returns more than 1 through memory",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			mod := &wasm.Module{Sections: []wasm.Section{
+				types,
+				&wasm.SectionFunction{Types: tc.funcs},
+				memory,
+				&wasm.SectionExport{Entries: tc.exports},
+			}}
+
+			var actual []string
+			for _, p := range CheckCanonicalABI(mod) {
+				actual = append(actual, p.String())
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("Problems do not match; expected\n%q\nactual\n%q", tc.expected, actual)
+			}
+		})
+	}
+}
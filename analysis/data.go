@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// A DataLocation is a position in the contents of a data segment.
+type DataLocation struct {
+	// Segment is the index of the segment in the data section.
+	Segment int
+
+	// Offset is the offset in the data of the segment.
+	Offset int
+
+	// Address is the address of the position in memory. It's only set if
+	// Mapped is set.
+	Address uint64
+
+	// Mapped is set if the address of the segment is known, see MapMemory.
+	Mapped bool
+}
+
+// A DataString is a string found in a data segment.
+type DataString struct {
+	DataLocation
+	Text string
+}
+
+// A DataIndex finds contents in the data segments of a module and maps it to
+// the addresses in memory, which are only known for segments placed in memory
+// 0 with offsets that MapMemory can evaluate.
+type DataIndex struct {
+	data  []wasm.DataSegment
+	mm    *MemoryMap
+	addrs map[int]uint64
+}
+
+// NewDataIndex returns a DataIndex of the data segments of m. The values of
+// the imported globals read by the segment offsets are given in globals, as
+// for MapMemory.
+func NewDataIndex(m *wasm.Module, globals []interface{}) *DataIndex {
+	d := &DataIndex{addrs: make(map[int]uint64)}
+	if s := m.DataSection(); s != nil {
+		d.data = s.Entries
+	}
+	if maps, _ := MapMemory(m, globals); len(maps) > 0 {
+		d.mm = &maps[0]
+		for _, s := range d.mm.Segments {
+			d.addrs[s.Index] = s.Start
+		}
+	}
+	return d
+}
+
+func (d *DataIndex) location(segment, offset int) DataLocation {
+	l := DataLocation{Segment: segment, Offset: offset}
+	if addr, ok := d.addrs[segment]; ok {
+		l.Address = addr + uint64(offset)
+		l.Mapped = true
+	}
+	return l
+}
+
+// Strings returns the runs of at least min printable UTF-8 characters in the
+// data segments, like the strings tool. Spaces and tabs are printable,
+// newlines and other control characters end a string. Strings don't span
+// segments.
+func (d *DataIndex) Strings(min int) []DataString {
+	var strs []DataString
+	for i, seg := range d.data {
+		b := seg.Data
+		start, n := -1, 0
+		flush := func(end int) {
+			if start >= 0 && n >= min {
+				strs = append(strs, DataString{d.location(i, start), string(b[start:end])})
+			}
+			start, n = -1, 0
+		}
+		for off := 0; off < len(b); {
+			r, size := utf8.DecodeRune(b[off:])
+			if r == utf8.RuneError || !unicode.IsPrint(r) && r != '\t' {
+				flush(off)
+			} else {
+				if start < 0 {
+					start = off
+				}
+				n++
+			}
+			off += size
+		}
+		flush(len(b))
+	}
+	return strs
+}
+
+// Find returns the locations of every occurrence of pattern in the data
+// segments, including overlapping ones. Occurrences that span segments aren't
+// found.
+func (d *DataIndex) Find(pattern []byte) []DataLocation {
+	if len(pattern) == 0 {
+		return nil
+	}
+	var locs []DataLocation
+	for i, seg := range d.data {
+		for off := 0; ; off++ {
+			j := bytes.Index(seg.Data[off:], pattern)
+			if j < 0 {
+				break
+			}
+			off += j
+			locs = append(locs, d.location(i, off))
+		}
+	}
+	return locs
+}
+
+// Lookup returns the location of the data at the address addr in memory 0. If
+// several segments write to the address, the last one, which overwrites the
+// others when the module is instantiated, is returned. It returns false if no
+// segment with a known address contains addr.
+func (d *DataIndex) Lookup(addr uint64) (DataLocation, bool) {
+	if d.mm == nil {
+		return DataLocation{}, false
+	}
+	found := -1
+	for _, s := range d.mm.Segments {
+		if s.Start > addr {
+			break
+		}
+		if addr < s.End && s.Index > found {
+			found = s.Index
+		}
+	}
+	if found < 0 {
+		return DataLocation{}, false
+	}
+	return d.location(found, int(addr-d.addrs[found])), true
+}
@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func dataModule() *wasm.Module {
+	return &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "base", Kind: wasm.ExtKindGlobal, GlobalType: &wasm.GlobalType{ContentType: 0x7f}},
+		}},
+		&wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 1}}}},
+		&wasm.SectionData{Entries: []wasm.DataSegment{
+			{Offset: []byte{0x41, 0x10, 0x0b}, Data: []byte("\x00\x01hello, wörld\nab\x00panic: \t")},
+			{Offset: []byte{0x41, 0x20, 0x0b}, Data: []byte("override")},
+			{Offset: []byte{0x23, 0x00, 0x0b}, Data: []byte("\xffhello")},
+		}},
+	}}
+}
+
+func TestDataStrings(t *testing.T) {
+	strs := NewDataIndex(dataModule(), nil).Strings(4)
+	expected := []DataString{
+		{DataLocation{Segment: 0, Offset: 2, Address: 0x12, Mapped: true}, "hello, wörld"},
+		{DataLocation{Segment: 0, Offset: 19, Address: 0x23, Mapped: true}, "panic: \t"},
+		{DataLocation{Segment: 1, Offset: 0, Address: 0x20, Mapped: true}, "override"},
+		{DataLocation{Segment: 2, Offset: 1}, "hello"},
+	}
+	if !reflect.DeepEqual(strs, expected) {
+		t.Errorf("Strings do not match\nexpected %+v\nactual   %+v", expected, strs)
+	}
+}
+
+func TestDataFind(t *testing.T) {
+	d := NewDataIndex(dataModule(), []interface{}{int32(0x100)})
+	locs := d.Find([]byte("hello"))
+	expected := []DataLocation{
+		{Segment: 0, Offset: 2, Address: 0x12, Mapped: true},
+		{Segment: 2, Offset: 1, Address: 0x101, Mapped: true},
+	}
+	if !reflect.DeepEqual(locs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, locs)
+	}
+	if locs := d.Find([]byte("aa")); locs != nil {
+		t.Errorf("Expected no match, got %+v", locs)
+	}
+
+	tests := []struct {
+		addr    uint64
+		segment int
+		offset  int
+		ok      bool
+	}{
+		{0x0f, 0, 0, false},
+		{0x10, 0, 0, true},
+		{0x1f, 0, 15, true},
+		{0x20, 1, 0, true}, // segment 1 overwrites segment 0
+		{0x2a, 0, 26, true},
+		{0x105, 2, 5, true},
+		{0x106, 0, 0, false},
+	}
+	for _, tt := range tests {
+		loc, ok := d.Lookup(tt.addr)
+		if ok != tt.ok || ok && (loc.Segment != tt.segment || loc.Offset != tt.offset || loc.Address != tt.addr) {
+			t.Errorf("0x%x: expected segment %d offset %d (%v), got %+v (%v)", tt.addr, tt.segment, tt.offset, tt.ok, loc, ok)
+		}
+	}
+}
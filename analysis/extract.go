@@ -0,0 +1,345 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// Op codes with immediates that refer to functions, globals or types.
+const (
+	opBlock        wasm.Opcode = 0x02
+	opLoop         wasm.Opcode = 0x03
+	opIf           wasm.Opcode = 0x04
+	opCall         wasm.Opcode = 0x10
+	opCallIndirect wasm.Opcode = 0x11
+)
+
+// usesTable reports whether the op code accesses a table.
+func usesTable(op wasm.Opcode) bool {
+	return op == opCallIndirect || op == 0x25 || op == 0x26 || op >= 0xfc0c && op <= 0xfc11
+}
+
+// ExtractFunction returns a new module that contains the function with index
+// fi and everything it depends on: the functions it may call, the globals it
+// uses, and the types of both. The function is exported under its name from
+// the name section or its export name, or as "func<fi>" if it has neither.
+//
+// If any of the functions use a table, the tables and all element segments
+// are included, along with the functions in them. If any of the functions
+// access memory, the memory and all data segments are included. Imports are
+// kept for the imported functions, globals, tables and memories that are
+// used. The extracted functions keep their names in the name section.
+//
+// The result is meant for reduced test cases and benchmarks of a single
+// function from a large module.
+func ExtractFunction(m *wasm.Module, fi uint32) (*wasm.Module, error) {
+	x := newIndex(m)
+	if int(fi) >= len(x.funcs) {
+		return nil, fmt.Errorf("function index %d out of range", fi)
+	}
+	if x.body(fi) == nil {
+		return nil, fmt.Errorf("function %d is imported", fi)
+	}
+
+	var (
+		funcs   = make(map[uint32]bool)
+		globals = make(map[uint32]bool)
+		types   = make(map[uint32]bool)
+		work    []uint32
+		exprs   [][]byte // init expressions to scan for globals
+		memory  bool
+		tables  bool
+	)
+	markFunc := func(fi uint32) {
+		if !funcs[fi] && int(fi) < len(x.funcs) {
+			funcs[fi] = true
+			work = append(work, fi)
+		}
+	}
+	markFunc(fi)
+
+	for len(work) > 0 {
+		fi := work[len(work)-1]
+		work = work[:len(work)-1]
+		types[x.funcs[fi]] = true
+
+		body := x.body(fi)
+		if body == nil {
+			continue
+		}
+		ins, err := wasm.Disassemble(body.Code)
+		if err != nil {
+			return nil, fmt.Errorf("function %d: %v", fi, err)
+		}
+		for _, in := range ins {
+			switch {
+			case in.Op == opCall || in.Op == opRefFunc:
+				markFunc(uint32(in.Immediates[0]))
+			case in.Op == opGlobalGet || in.Op == opGlobalSet:
+				globals[uint32(in.Immediates[0])] = true
+			case in.Op == opBlock || in.Op == opLoop || in.Op == opIf:
+				if t := int64(in.Immediates[0]); t >= 0 {
+					types[uint32(t)] = true
+				}
+			case usesMemory(in.Op):
+				memory = true
+			}
+			if in.Op == opCallIndirect {
+				types[uint32(in.Immediates[0])] = true
+			}
+			if usesTable(in.Op) && !tables {
+				tables = true
+				for _, e := range x.elements {
+					for _, fi := range e.Elems {
+						markFunc(fi)
+					}
+					exprs = append(exprs, e.Offset)
+				}
+			}
+		}
+	}
+
+	if memory {
+		for _, d := range x.data {
+			exprs = append(exprs, d.Offset)
+		}
+	}
+	// Globals may be initialized from other globals.
+	for gi := range globals {
+		if int(gi) >= x.importedGlobals && int(gi) < x.numGlobals {
+			exprs = append(exprs, x.globals[int(gi)-x.importedGlobals].Init)
+		}
+	}
+	for len(exprs) > 0 {
+		expr := exprs[0]
+		exprs = exprs[1:]
+		ins, err := wasm.Disassemble(expr)
+		if err != nil {
+			return nil, fmt.Errorf("init expression: %v", err)
+		}
+		for _, in := range ins {
+			if in.Op != opGlobalGet || globals[uint32(in.Immediates[0])] {
+				continue
+			}
+			gi := uint32(in.Immediates[0])
+			globals[gi] = true
+			if int(gi) >= x.importedGlobals && int(gi) < x.numGlobals {
+				exprs = append(exprs, x.globals[int(gi)-x.importedGlobals].Init)
+			}
+		}
+	}
+
+	// Number the kept items in the order of the original index spaces, so
+	// the imports come first.
+	typeMap := renumber(types)
+	funcMap := renumber(funcs)
+	globalMap := renumber(globals)
+	for gi := range globals {
+		if int(gi) >= x.numGlobals {
+			return nil, fmt.Errorf("global index %d out of range", gi)
+		}
+	}
+	for ti := range types {
+		if int(ti) >= len(x.types) {
+			return nil, fmt.Errorf("type index %d out of range", ti)
+		}
+	}
+
+	out := &wasm.Module{}
+	typeSec := &wasm.SectionType{}
+	for _, ti := range sortedKeys(types) {
+		typeSec.Entries = append(typeSec.Entries, x.types[ti])
+	}
+	out.Sections = append(out.Sections, typeSec)
+
+	importSec := &wasm.SectionImport{}
+	nf, ng := uint32(0), uint32(0)
+	for _, e := range x.imports {
+		keep := false
+		switch e.Kind {
+		case wasm.ExtKindFunction:
+			if keep = funcs[nf]; keep {
+				e.FunctionType = &wasm.FunctionType{Index: typeMap[e.FunctionType.Index]}
+			}
+			nf++
+		case wasm.ExtKindGlobal:
+			keep = globals[ng]
+			ng++
+		case wasm.ExtKindTable:
+			keep = tables
+		case wasm.ExtKindMemory:
+			keep = memory
+		}
+		if keep {
+			importSec.Entries = append(importSec.Entries, e)
+		}
+	}
+	if len(importSec.Entries) > 0 {
+		out.Sections = append(out.Sections, importSec)
+	}
+
+	funcSec := &wasm.SectionFunction{}
+	codeSec := &wasm.SectionCode{}
+	for _, fi := range sortedKeys(funcs) {
+		body := x.body(fi)
+		if body == nil {
+			continue
+		}
+		code, err := remapCode(body.Code, funcMap, globalMap, typeMap)
+		if err != nil {
+			return nil, fmt.Errorf("function %d: %v", fi, err)
+		}
+		funcSec.Types = append(funcSec.Types, typeMap[x.funcs[fi]])
+		codeSec.Bodies = append(codeSec.Bodies, wasm.FunctionBody{Locals: body.Locals, Code: code})
+	}
+	out.Sections = append(out.Sections, funcSec)
+
+	for _, s := range m.Sections {
+		switch s := s.(type) {
+		case *wasm.SectionTable:
+			if tables {
+				out.Sections = append(out.Sections, s)
+			}
+		case *wasm.SectionMemory:
+			if memory {
+				out.Sections = append(out.Sections, s)
+			}
+		}
+	}
+
+	if len(globalMap) > 0 {
+		globalSec := &wasm.SectionGlobal{}
+		for _, gi := range sortedKeys(globals) {
+			if int(gi) < x.importedGlobals {
+				continue
+			}
+			g := x.globals[int(gi)-x.importedGlobals]
+			init, err := remapCode(g.Init, funcMap, globalMap, typeMap)
+			if err != nil {
+				return nil, fmt.Errorf("global %d: %v", gi, err)
+			}
+			globalSec.Globals = append(globalSec.Globals, wasm.GlobalVariable{Type: g.Type, Init: init})
+		}
+		if len(globalSec.Globals) > 0 {
+			out.Sections = append(out.Sections, globalSec)
+		}
+	}
+
+	out.Sections = append(out.Sections, &wasm.SectionExport{Entries: []wasm.ExportEntry{
+		{Field: funcName(m, x, fi), Kind: wasm.ExtKindFunction, Index: funcMap[fi]},
+	}})
+
+	if tables && len(x.elements) > 0 {
+		elemSec := &wasm.SectionElement{}
+		for _, e := range x.elements {
+			offset, err := remapCode(e.Offset, funcMap, globalMap, typeMap)
+			if err != nil {
+				return nil, fmt.Errorf("element segment: %v", err)
+			}
+			elems := make([]uint32, len(e.Elems))
+			for i, fi := range e.Elems {
+				elems[i] = funcMap[fi]
+			}
+			elemSec.Entries = append(elemSec.Entries, wasm.ElemSegment{Index: e.Index, Offset: offset, Elems: elems})
+		}
+		out.Sections = append(out.Sections, elemSec)
+	}
+
+	out.Sections = append(out.Sections, codeSec)
+
+	if memory && len(x.data) > 0 {
+		dataSec := &wasm.SectionData{}
+		for _, d := range x.data {
+			offset, err := remapCode(d.Offset, funcMap, globalMap, typeMap)
+			if err != nil {
+				return nil, fmt.Errorf("data segment: %v", err)
+			}
+			dataSec.Entries = append(dataSec.Entries, wasm.DataSegment{Index: d.Index, Offset: offset, Data: d.Data})
+		}
+		out.Sections = append(out.Sections, dataSec)
+	}
+
+	if names := m.NameSection(); names != nil && names.Functions != nil {
+		nameSec := &wasm.SectionName{SectionName: "name", Functions: &wasm.NameMap{}}
+		for _, n := range names.Functions.Names {
+			if funcs[n.Index] {
+				nameSec.Functions.Names = append(nameSec.Functions.Names, wasm.Naming{Index: funcMap[n.Index], Name: n.Name})
+			}
+		}
+		sort.Slice(nameSec.Functions.Names, func(i, j int) bool {
+			return nameSec.Functions.Names[i].Index < nameSec.Functions.Names[j].Index
+		})
+		out.Sections = append(out.Sections, nameSec)
+	}
+
+	return out, nil
+}
+
+// funcName returns the name the extracted function fi is exported as.
+func funcName(m *wasm.Module, x *index, fi uint32) string {
+	if names := m.NameSection(); names != nil && names.Functions != nil {
+		for _, n := range names.Functions.Names {
+			if n.Index == fi && n.Name != "" {
+				return n.Name
+			}
+		}
+	}
+	for _, e := range x.exports {
+		if e.Kind == wasm.ExtKindFunction && e.Index == fi {
+			return e.Field
+		}
+	}
+	return fmt.Sprintf("func%d", fi)
+}
+
+// renumber maps the indices in set to consecutive indices in the same order.
+func renumber(set map[uint32]bool) map[uint32]uint32 {
+	m := make(map[uint32]uint32, len(set))
+	for i, v := range sortedKeys(set) {
+		m[v] = uint32(i)
+	}
+	return m
+}
+
+func sortedKeys(set map[uint32]bool) []uint32 {
+	keys := make([]uint32, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// remapCode returns a copy of code, which is a function body or an init
+// expression, with the function, global and type indices replaced using the
+// maps.
+func remapCode(code []byte, funcs, globals, types map[uint32]uint32) ([]byte, error) {
+	ins, err := wasm.Disassemble(code)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(code))
+	for i, in := range ins {
+		end := len(code)
+		if i+1 < len(ins) {
+			end = ins[i+1].Offset
+		}
+		switch {
+		case in.Op == opCall || in.Op == opRefFunc:
+			out = leb128.AppendUint(append(out, byte(in.Op)), uint64(funcs[uint32(in.Immediates[0])]))
+		case in.Op == opGlobalGet || in.Op == opGlobalSet:
+			out = leb128.AppendUint(append(out, byte(in.Op)), uint64(globals[uint32(in.Immediates[0])]))
+		case in.Op == opCallIndirect:
+			out = leb128.AppendUint(append(out, byte(in.Op)), uint64(types[uint32(in.Immediates[0])]))
+			out = leb128.AppendUint(out, in.Immediates[1])
+		case (in.Op == opBlock || in.Op == opLoop || in.Op == opIf) && int64(in.Immediates[0]) >= 0:
+			out = leb128.AppendInt(append(out, byte(in.Op)), int64(types[uint32(in.Immediates[0])]))
+		default:
+			out = append(out, code[in.Offset:end]...)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,154 @@
+package analysis
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/exec/interp"
+)
+
+func TestExtractFunction(t *testing.T) {
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{
+			{Form: 0x60, ReturnTypes: []wasm.ValueType{wasm.I32}},
+			{Form: 0x60, Params: []wasm.ValueType{wasm.I32}},
+			{Form: 0x60},
+		}},
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "log", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{Index: 1}},
+			{Module: "env", Field: "exit", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{Index: 2}},
+		}},
+		&wasm.SectionFunction{Types: []uint32{0, 0, 2}},
+		&wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 1}}}},
+		&wasm.SectionGlobal{Globals: []wasm.GlobalVariable{
+			{Type: wasm.GlobalType{ContentType: wasm.I32}, Init: []byte{0x41, 0xe4, 0x00, 0x0b}},
+			{Type: wasm.GlobalType{ContentType: wasm.I32}, Init: []byte{0x41, 0x0a, 0x0b}},
+		}},
+		&wasm.SectionExport{Entries: []wasm.ExportEntry{
+			{Field: "compute", Kind: wasm.ExtKindFunction, Index: 2},
+			{Field: "stop", Kind: wasm.ExtKindFunction, Index: 4},
+		}},
+		&wasm.SectionCode{Bodies: []wasm.FunctionBody{
+			// 2: global.get 1 + call 3 + i32.load 0
+			{Code: []byte{0x23, 0x01, 0x10, 0x03, 0x6a, 0x41, 0x00, 0x28, 0x02, 0x00, 0x6a, 0x0b}},
+			// 3: call 0 (5), i32.const 7
+			{Code: []byte{0x41, 0x05, 0x10, 0x00, 0x41, 0x07, 0x0b}},
+			// 4: call 1
+			{Code: []byte{0x10, 0x01, 0x0b}},
+		}},
+		&wasm.SectionData{Entries: []wasm.DataSegment{{Offset: []byte{0x41, 0x00, 0x0b}, Data: []byte{3, 0, 0, 0}}}},
+		&wasm.SectionName{SectionName: "name", Functions: &wasm.NameMap{Names: []wasm.Naming{
+			{Index: 2, Name: "compute"},
+			{Index: 3, Name: "helper"},
+			{Index: 4, Name: "stop"},
+		}}},
+	}}
+
+	ext, err := ExtractFunction(mod, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := wasm.Encode(&buf, ext); err != nil {
+		t.Fatal(err)
+	}
+	ext, err = wasm.ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(ext.TypeSection().Entries); n != 2 {
+		t.Errorf("Expected 2 types, got %d", n)
+	}
+	if imports := ext.ImportSection().Entries; len(imports) != 1 || imports[0].Field != "log" {
+		t.Errorf("Expected only the log import, got %v", imports)
+	}
+	if n := len(ext.FunctionSection().Types); n != 2 {
+		t.Errorf("Expected 2 functions, got %d", n)
+	}
+	if n := len(ext.GlobalSection().Globals); n != 1 {
+		t.Errorf("Expected 1 global, got %d", n)
+	}
+	expected := []wasm.ExportEntry{{Field: "compute", Kind: wasm.ExtKindFunction, Index: 1}}
+	if exports := ext.ExportSection().Entries; !reflect.DeepEqual(exports, expected) {
+		t.Errorf("Expected exports %v, got %v", expected, exports)
+	}
+	expectedNames := []wasm.Naming{{Index: 1, Name: "compute"}, {Index: 2, Name: "helper"}}
+	if names := ext.NameSection().Functions.Names; !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Expected names %v, got %v", expectedNames, names)
+	}
+
+	// The extracted function behaves like the original.
+	var logged []int32
+	imports := interp.Imports{"env": {
+		"log": interp.HostFunc(func(_ *interp.Instance, args []uint64) ([]uint64, error) {
+			logged = append(logged, interp.AsI32(args[0]))
+			return nil, nil
+		}),
+	}}
+	inst, err := interp.Instantiate(ext, imports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inst.Call("compute")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := interp.AsI32(res[0]); got != 20 {
+		t.Errorf("Expected compute() = 20, got %d", got)
+	}
+	if !reflect.DeepEqual(logged, []int32{5}) {
+		t.Errorf("Expected log(5), got %v", logged)
+	}
+
+	if _, err := ExtractFunction(mod, 0); err == nil {
+		t.Error("Expected error for imported function")
+	}
+	if _, err := ExtractFunction(mod, 5); err == nil {
+		t.Error("Expected error for function out of range")
+	}
+}
+
+func TestExtractFunctionTable(t *testing.T) {
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{
+			{Form: 0x60},
+			{Form: 0x60, ReturnTypes: []wasm.ValueType{wasm.I32}},
+		}},
+		&wasm.SectionFunction{Types: []uint32{0, 1, 1}},
+		&wasm.SectionTable{Entries: []wasm.TableType{{ElemType: 0x70, Limits: wasm.ResizableLimits{Initial: 1}}}},
+		&wasm.SectionCode{Bodies: []wasm.FunctionBody{
+			{Code: []byte{0x0b}},
+			// 1: call_indirect type 1 at 0
+			{Code: []byte{0x41, 0x00, 0x11, 0x01, 0x00, 0x0b}},
+			{Code: []byte{0x41, 0x2a, 0x0b}},
+		}},
+		&wasm.SectionElement{Entries: []wasm.ElemSegment{{Offset: []byte{0x41, 0x00, 0x0b}, Elems: []uint32{2}}}},
+	}}
+
+	ext, err := ExtractFunction(mod, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ext.FunctionSection().Types); n != 2 {
+		t.Errorf("Expected 2 functions, got %d", n)
+	}
+	if elems := ext.ElementSection().Entries[0].Elems; !reflect.DeepEqual(elems, []uint32{1}) {
+		t.Errorf("Expected elements [1], got %v", elems)
+	}
+
+	inst, err := interp.Instantiate(ext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inst.Call("func1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := interp.AsI32(res[0]); got != 42 {
+		t.Errorf("Expected func1() = 42, got %d", got)
+	}
+}
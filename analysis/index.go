@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	wasm "github.com/akupila/go-wasm"
+)
+
+// index collects the sections of a module that are needed by the analyses and
+// resolves the index spaces, which consist of the imports followed by the
+// definitions in the module.
+type index struct {
+	types    []wasm.FuncType
+	imports  []wasm.ImportEntry
+	exports  []wasm.ExportEntry
+	globals  []wasm.GlobalVariable
+	elements []wasm.ElemSegment
+	data     []wasm.DataSegment
+	bodies   []wasm.FunctionBody
+	start    *wasm.SectionStart
+
+	// funcs contains the type index of every function in the function
+	// index space.
+	funcs []uint32
+
+	// tables, memories and globalTypes contain the types in the table,
+	// memory and global index spaces.
+	tables      []wasm.TableType
+	memories    []wasm.MemoryType
+	globalTypes []wasm.GlobalType
+
+	importedFuncs   int
+	importedGlobals int
+	numGlobals      int
+	numTables       int
+	numMemories     int
+}
+
+func newIndex(m *wasm.Module) *index {
+	x := &index{}
+	for _, s := range m.Sections {
+		switch s := s.(type) {
+		case *wasm.SectionType:
+			x.types = s.Entries
+		case *wasm.SectionImport:
+			x.imports = s.Entries
+			for _, e := range s.Entries {
+				switch e.Kind {
+				case wasm.ExtKindFunction:
+					x.funcs = append(x.funcs, e.FunctionType.Index)
+					x.importedFuncs++
+				case wasm.ExtKindGlobal:
+					x.globalTypes = append(x.globalTypes, *e.GlobalType)
+					x.importedGlobals++
+					x.numGlobals++
+				case wasm.ExtKindTable:
+					x.tables = append(x.tables, *e.TableType)
+					x.numTables++
+				case wasm.ExtKindMemory:
+					x.memories = append(x.memories, *e.MemoryType)
+					x.numMemories++
+				}
+			}
+		case *wasm.SectionFunction:
+			x.funcs = append(x.funcs, s.Types...)
+		case *wasm.SectionTable:
+			x.tables = append(x.tables, s.Entries...)
+			x.numTables += len(s.Entries)
+		case *wasm.SectionMemory:
+			x.memories = append(x.memories, s.Entries...)
+			x.numMemories += len(s.Entries)
+		case *wasm.SectionGlobal:
+			x.globals = s.Globals
+			for _, g := range s.Globals {
+				x.globalTypes = append(x.globalTypes, g.Type)
+			}
+			x.numGlobals += len(s.Globals)
+		case *wasm.SectionExport:
+			x.exports = s.Entries
+		case *wasm.SectionStart:
+			x.start = s
+		case *wasm.SectionElement:
+			x.elements = s.Entries
+		case *wasm.SectionCode:
+			x.bodies = s.Bodies
+		case *wasm.SectionData:
+			x.data = s.Entries
+		}
+	}
+	return x
+}
+
+// funcType returns the type of the function with index fi, or nil if the
+// function or its type is not defined.
+func (x *index) funcType(fi uint32) *wasm.FuncType {
+	if int(fi) >= len(x.funcs) || int(x.funcs[fi]) >= len(x.types) {
+		return nil
+	}
+	return &x.types[x.funcs[fi]]
+}
+
+// body returns the body of the function with index fi, or nil if the function
+// is imported or not defined.
+func (x *index) body(fi uint32) *wasm.FunctionBody {
+	i := int(fi) - x.importedFuncs
+	if i < 0 || i >= len(x.bodies) {
+		return nil
+	}
+	return &x.bodies[i]
+}
+
+// importedFunc returns the import entry of the function with index fi, or nil
+// if the function is not imported.
+func (x *index) importedFunc(fi uint32) *wasm.ImportEntry {
+	n := uint32(0)
+	for i, e := range x.imports {
+		if e.Kind != wasm.ExtKindFunction {
+			continue
+		}
+		if n == fi {
+			return &x.imports[i]
+		}
+		n++
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// Interface returns a canonical description of the interface of m to its host:
+// every import and export with its type, one per line. Functions are described
+// by their signature, tables and memories by their limits and globals by their
+// type and mutability:
+//
+//	export func run (i32) -> ()
+//	export memory memory min 17
+//	import func env.log (i32, i32) -> ()
+//	import global env.stack_pointer mut i32
+//	import table env.table funcref min 1 max 10
+//
+// The lines are sorted, so the description doesn't depend on the order of the
+// imports and exports in the module. The description is meant to be checked in
+// with the code that embeds the module, and compared with the interface of
+// later builds using CompareInterface.
+func Interface(m *wasm.Module) ([]string, error) {
+	imports, err := Imports(m)
+	if err != nil {
+		return nil, err
+	}
+	exports, err := Exports(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, e := range imports {
+		lines = append(lines, fmt.Sprintf("import %s %s.%s %s", kindKeyword(e.Kind), e.Module, e.Name, e.Type))
+	}
+	for _, e := range exports {
+		lines = append(lines, fmt.Sprintf("export %s %s %s", kindKeyword(e.Kind), e.Name, e.Type))
+	}
+	sort.Strings(lines)
+	return lines, nil
+}
+
+// An Extern is an import or an export of a module.
+type Extern struct {
+	// Module is the module name of an import, and empty for an export.
+	Module string
+
+	// Name is the field name of an import or the name of an export.
+	Name string
+
+	// Kind is the kind of the import or export.
+	Kind wasm.ExternalKind
+
+	// Index is the index of the imported or exported item in the index space
+	// of its kind.
+	Index uint32
+
+	// Type describes the type of the item as in Interface, such as
+	// "(i32) -> ()" for a function or "min 17" for a memory.
+	Type string
+}
+
+// Imports returns the imports of m in the order they are declared, with their
+// types.
+func Imports(m *wasm.Module) ([]Extern, error) {
+	x := newIndex(m)
+
+	var imports []Extern
+	var counts [4]uint32
+	for _, e := range x.imports {
+		if int(e.Kind) >= len(counts) {
+			return nil, fmt.Errorf("import %s.%s: unknown kind %d", e.Module, e.Field, e.Kind)
+		}
+		i := counts[e.Kind]
+		counts[e.Kind]++
+		var desc string
+		switch e.Kind {
+		case wasm.ExtKindFunction:
+			t := x.funcType(i)
+			if t == nil {
+				return nil, fmt.Errorf("import %s.%s: type %d out of range", e.Module, e.Field, e.FunctionType.Index)
+			}
+			desc = t.String()
+		case wasm.ExtKindTable:
+			desc = tableDesc(x.tables[i])
+		case wasm.ExtKindMemory:
+			desc = limitsDesc(x.memories[i].Limits)
+		case wasm.ExtKindGlobal:
+			desc = globalDesc(x.globalTypes[i])
+		}
+		imports = append(imports, Extern{Module: e.Module, Name: e.Field, Kind: e.Kind, Index: i, Type: desc})
+	}
+	return imports, nil
+}
+
+// Exports returns the exports of m in the order they are declared, with their
+// types.
+func Exports(m *wasm.Module) ([]Extern, error) {
+	x := newIndex(m)
+
+	var exports []Extern
+	for _, e := range x.exports {
+		var desc string
+		var ok bool
+		switch e.Kind {
+		case wasm.ExtKindFunction:
+			if t := x.funcType(e.Index); t != nil {
+				desc, ok = t.String(), true
+			}
+		case wasm.ExtKindTable:
+			if int(e.Index) < len(x.tables) {
+				desc, ok = tableDesc(x.tables[e.Index]), true
+			}
+		case wasm.ExtKindMemory:
+			if int(e.Index) < len(x.memories) {
+				desc, ok = limitsDesc(x.memories[e.Index].Limits), true
+			}
+		case wasm.ExtKindGlobal:
+			if int(e.Index) < len(x.globalTypes) {
+				desc, ok = globalDesc(x.globalTypes[e.Index]), true
+			}
+		default:
+			return nil, fmt.Errorf("export %s: unknown kind %d", e.Field, e.Kind)
+		}
+		if !ok {
+			return nil, fmt.Errorf("export %s: %s index %d out of range", e.Field, kindName(e.Kind), e.Index)
+		}
+		exports = append(exports, Extern{Name: e.Field, Kind: e.Kind, Index: e.Index, Type: desc})
+	}
+	return exports, nil
+}
+
+// CompareInterface compares the interface description frozen, as returned by
+// Interface at some point, with the current description actual. It returns the
+// lines that were removed from frozen prefixed with "- ", followed by the lines
+// that were added prefixed with "+ ". The result is empty if the interfaces
+// match.
+//
+// Empty lines and lines starting with # in frozen are ignored, so the frozen
+// description may contain comments.
+func CompareInterface(frozen, actual []string) []string {
+	have := make(map[string]bool)
+	for _, l := range actual {
+		have[l] = true
+	}
+	want := make(map[string]bool)
+	var diff []string
+	for _, l := range frozen {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		want[l] = true
+		if !have[l] {
+			diff = append(diff, "- "+l)
+		}
+	}
+	for _, l := range actual {
+		if !want[l] {
+			diff = append(diff, "+ "+l)
+		}
+	}
+	return diff
+}
+
+func kindKeyword(k wasm.ExternalKind) string {
+	if k == wasm.ExtKindFunction {
+		return "func"
+	}
+	return kindName(k)
+}
+
+func tableDesc(t wasm.TableType) string {
+	return t.ElemType.String() + " " + limitsDesc(t.Limits)
+}
+
+func limitsDesc(l wasm.ResizableLimits) string {
+	desc := fmt.Sprintf("min %d", l.Initial)
+	if l.HasMaximum {
+		desc += fmt.Sprintf(" max %d", l.Maximum)
+	}
+	if l.Shared {
+		desc += " shared"
+	}
+	return desc
+}
+
+func globalDesc(g wasm.GlobalType) string {
+	if g.Mutable {
+		return "mut " + g.ContentType.String()
+	}
+	return g.ContentType.String()
+}
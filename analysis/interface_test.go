@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func interfaceModule() *wasm.Module {
+	i32 := wasm.I32
+	return &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{
+			{Form: 0x60, Params: []wasm.ValueType{i32, i32}},
+			{Form: 0x60, Params: []wasm.ValueType{i32}, ReturnTypes: []wasm.ValueType{i32}},
+		}},
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "log", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{Index: 0}},
+			{Module: "env", Field: "sp", Kind: wasm.ExtKindGlobal, GlobalType: &wasm.GlobalType{ContentType: i32, Mutable: true}},
+			{Module: "env", Field: "table", Kind: wasm.ExtKindTable, TableType: &wasm.TableType{ElemType: 0x70, Limits: wasm.ResizableLimits{Initial: 1, Maximum: 10, HasMaximum: true}}},
+		}},
+		&wasm.SectionFunction{Types: []uint32{1}},
+		&wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 17}}}},
+		&wasm.SectionGlobal{Globals: []wasm.GlobalVariable{{Type: wasm.GlobalType{ContentType: 0x7e}}}},
+		&wasm.SectionExport{Entries: []wasm.ExportEntry{
+			{Field: "run", Kind: wasm.ExtKindFunction, Index: 1},
+			{Field: "memory", Kind: wasm.ExtKindMemory, Index: 0},
+			{Field: "version", Kind: wasm.ExtKindGlobal, Index: 1},
+			{Field: "table", Kind: wasm.ExtKindTable, Index: 0},
+		}},
+	}}
+}
+
+func TestInterface(t *testing.T) {
+	mod := interfaceModule()
+	lines, err := Interface(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"export func run (i32) -> (i32)",
+		"export global version i64",
+		"export memory memory min 17",
+		"export table table funcref min 1 max 10",
+		"import func env.log (i32, i32) -> ()",
+		"import global env.sp mut i32",
+		"import table env.table funcref min 1 max 10",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected\n%q\ngot\n%q", expected, lines)
+	}
+
+	mod.Sections[5].(*wasm.SectionExport).Entries[0].Index = 5
+	if _, err := Interface(mod); err == nil {
+		t.Error("Expected error for export out of range")
+	}
+}
+
+func TestImportsExports(t *testing.T) {
+	mod := interfaceModule()
+	imports, err := Imports(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Extern{
+		{Module: "env", Name: "log", Kind: wasm.ExtKindFunction, Index: 0, Type: "(i32, i32) -> ()"},
+		{Module: "env", Name: "sp", Kind: wasm.ExtKindGlobal, Index: 0, Type: "mut i32"},
+		{Module: "env", Name: "table", Kind: wasm.ExtKindTable, Index: 0, Type: "funcref min 1 max 10"},
+	}
+	if !reflect.DeepEqual(imports, expected) {
+		t.Errorf("Expected imports\n%+v\ngot\n%+v", expected, imports)
+	}
+
+	exports, err := Exports(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []Extern{
+		{Name: "run", Kind: wasm.ExtKindFunction, Index: 1, Type: "(i32) -> (i32)"},
+		{Name: "memory", Kind: wasm.ExtKindMemory, Index: 0, Type: "min 17"},
+		{Name: "version", Kind: wasm.ExtKindGlobal, Index: 1, Type: "i64"},
+		{Name: "table", Kind: wasm.ExtKindTable, Index: 0, Type: "funcref min 1 max 10"},
+	}
+	if !reflect.DeepEqual(exports, expected) {
+		t.Errorf("Expected exports\n%+v\ngot\n%+v", expected, exports)
+	}
+}
+
+func TestCompareInterface(t *testing.T) {
+	frozen := []string{
+		"# comment",
+		"export func run (i32) -> ()",
+		"",
+		"import func env.log (i32) -> ()",
+	}
+	actual := []string{
+		"export func run (i32) -> ()",
+		"import func env.log (i32, i32) -> ()",
+	}
+
+	if diff := CompareInterface(frozen, []string{frozen[1], frozen[3]}); diff != nil {
+		t.Errorf("Expected no difference, got %q", diff)
+	}
+
+	diff := CompareInterface(frozen, actual)
+	expected := []string{
+		"- import func env.log (i32) -> ()",
+		"+ import func env.log (i32, i32) -> ()",
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("Expected %q, got %q", expected, diff)
+	}
+}
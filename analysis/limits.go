@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"fmt"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// HostLimits describes the memories and tables a host provides for the imports
+// of a module, keyed by the import name in the form module.field.
+type HostLimits struct {
+	Memories map[string]wasm.ResizableLimits
+	Tables   map[string]wasm.ResizableLimits
+}
+
+// A LimitsProblem is a memory or table import that the host can't satisfy.
+type LimitsProblem struct {
+	// Name is the name of the import, module.field.
+	Name string
+
+	// Message describes the problem.
+	Message string
+}
+
+func (p LimitsProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Name, p.Message)
+}
+
+// CheckImportLimits checks whether the memories and tables offered by the host
+// satisfy the limits of the memories and tables imported by m, and returns the
+// problems found. An empty result means that instantiating m with them won't
+// fail because of their limits.
+//
+// An import is satisfied if the host provides a memory or table for it that is
+// at least as large as the initial size of the import, and that has a maximum
+// no larger than the maximum of the import if it has one. Imported memories
+// must be shared if and only if the host memory is shared.
+func CheckImportLimits(m *wasm.Module, host HostLimits) []LimitsProblem {
+	var problems []LimitsProblem
+	for _, e := range newIndex(m).imports {
+		name := e.Module + "." + e.Field
+		var msgs []string
+		switch e.Kind {
+		case wasm.ExtKindMemory:
+			offered, ok := host.Memories[name]
+			if !ok {
+				msgs = []string{"host provides no memory"}
+				break
+			}
+			msgs = CheckLimits(e.MemoryType.Limits, offered, "pages")
+		case wasm.ExtKindTable:
+			offered, ok := host.Tables[name]
+			if !ok {
+				msgs = []string{"host provides no table"}
+				break
+			}
+			msgs = CheckLimits(e.TableType.Limits, offered, "elements")
+		}
+		for _, msg := range msgs {
+			problems = append(problems, LimitsProblem{Name: name, Message: msg})
+		}
+	}
+	return problems
+}
+
+// CheckLimits checks whether a memory or table with the limits offered satisfies
+// an import with the limits required, as described in CheckImportLimits. It
+// returns a description of every mismatch, using unit for the sizes.
+func CheckLimits(required, offered wasm.ResizableLimits, unit string) []string {
+	var msgs []string
+
+	switch {
+	case offered.HasMaximum && required.Initial > offered.Maximum:
+		msgs = append(msgs, fmt.Sprintf("requires at least %d %s, more than the host maximum of %d", required.Initial, unit, offered.Maximum))
+	case offered.Initial < required.Initial:
+		msgs = append(msgs, fmt.Sprintf("requires at least %d %s, host provides %d", required.Initial, unit, offered.Initial))
+	}
+
+	if required.HasMaximum {
+		switch {
+		case !offered.HasMaximum:
+			msgs = append(msgs, fmt.Sprintf("requires a maximum of at most %d %s, host has no maximum", required.Maximum, unit))
+		case offered.Maximum > required.Maximum:
+			msgs = append(msgs, fmt.Sprintf("requires a maximum of at most %d %s, host allows up to %d", required.Maximum, unit, offered.Maximum))
+		}
+	}
+
+	switch {
+	case required.Shared && !offered.Shared:
+		msgs = append(msgs, "requires shared memory, host memory is not shared")
+	case !required.Shared && offered.Shared:
+		msgs = append(msgs, "requires unshared memory, host memory is shared")
+	}
+
+	return msgs
+}
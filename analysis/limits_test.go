@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func TestCheckLimits(t *testing.T) {
+	tt := []struct {
+		name              string
+		required, offered wasm.ResizableLimits
+		expected          []string
+	}{
+		{
+			name:     "exact",
+			required: wasm.ResizableLimits{Initial: 2, Maximum: 10, HasMaximum: true},
+			offered:  wasm.ResizableLimits{Initial: 2, Maximum: 10, HasMaximum: true},
+		},
+		{
+			name:     "larger without maximum",
+			required: wasm.ResizableLimits{Initial: 2},
+			offered:  wasm.ResizableLimits{Initial: 4},
+		},
+		{
+			name:     "too small",
+			required: wasm.ResizableLimits{Initial: 4},
+			offered:  wasm.ResizableLimits{Initial: 2, Maximum: 8, HasMaximum: true},
+			expected: []string{"requires at least 4 pages, host provides 2"},
+		},
+		{
+			name:     "above host maximum",
+			required: wasm.ResizableLimits{Initial: 16},
+			offered:  wasm.ResizableLimits{Initial: 2, Maximum: 8, HasMaximum: true},
+			expected: []string{"requires at least 16 pages, more than the host maximum of 8"},
+		},
+		{
+			name:     "no host maximum",
+			required: wasm.ResizableLimits{Initial: 1, Maximum: 8, HasMaximum: true},
+			offered:  wasm.ResizableLimits{Initial: 1},
+			expected: []string{"requires a maximum of at most 8 pages, host has no maximum"},
+		},
+		{
+			name:     "required maximum 0",
+			required: wasm.ResizableLimits{Initial: 0, Maximum: 0, HasMaximum: true},
+			offered:  wasm.ResizableLimits{Initial: 0},
+			expected: []string{"requires a maximum of at most 0 pages, host has no maximum"},
+		},
+		{
+			name:     "host maximum 0",
+			required: wasm.ResizableLimits{Initial: 1},
+			offered:  wasm.ResizableLimits{Initial: 1, Maximum: 0, HasMaximum: true},
+			expected: []string{"requires at least 1 pages, more than the host maximum of 0"},
+		},
+		{
+			name:     "host maximum too large",
+			required: wasm.ResizableLimits{Initial: 1, Maximum: 8, HasMaximum: true},
+			offered:  wasm.ResizableLimits{Initial: 1, Maximum: 9, HasMaximum: true},
+			expected: []string{"requires a maximum of at most 8 pages, host allows up to 9"},
+		},
+		{
+			name:     "shared",
+			required: wasm.ResizableLimits{Initial: 1, Maximum: 8, HasMaximum: true, Shared: true},
+			offered:  wasm.ResizableLimits{Initial: 1, Maximum: 8, HasMaximum: true},
+			expected: []string{"requires shared memory, host memory is not shared"},
+		},
+		{
+			name:     "unshared",
+			required: wasm.ResizableLimits{Initial: 1, Maximum: 8, HasMaximum: true},
+			offered:  wasm.ResizableLimits{Initial: 1, Maximum: 8, HasMaximum: true, Shared: true},
+			expected: []string{"requires unshared memory, host memory is shared"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			msgs := CheckLimits(tc.required, tc.offered, "pages")
+			if !reflect.DeepEqual(msgs, tc.expected) {
+				t.Errorf("Expected %q, got %q", tc.expected, msgs)
+			}
+		})
+	}
+}
+
+func TestCheckImportLimits(t *testing.T) {
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "memory", Kind: wasm.ExtKindMemory, MemoryType: &wasm.MemoryType{Limits: wasm.ResizableLimits{Initial: 17}}},
+			{Module: "env", Field: "table", Kind: wasm.ExtKindTable, TableType: &wasm.TableType{ElemType: 0x70, Limits: wasm.ResizableLimits{Initial: 4, Maximum: 4, HasMaximum: true}}},
+			{Module: "env", Field: "other", Kind: wasm.ExtKindMemory, MemoryType: &wasm.MemoryType{Limits: wasm.ResizableLimits{Initial: 1}}},
+		}},
+	}}
+
+	problems := CheckImportLimits(mod, HostLimits{
+		Memories: map[string]wasm.ResizableLimits{
+			"env.memory": {Initial: 16, Maximum: 32, HasMaximum: true},
+		},
+		Tables: map[string]wasm.ResizableLimits{
+			"env.table": {Initial: 4, Maximum: 4, HasMaximum: true},
+		},
+	})
+	expected := []LimitsProblem{
+		{Name: "env.memory", Message: "requires at least 17 pages, host provides 16"},
+		{Name: "env.other", Message: "host provides no memory"},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Expected %v, got %v", expected, problems)
+	}
+}
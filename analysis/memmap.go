@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// pageSize is the size of a page of linear memory.
+const pageSize = 65536
+
+// A MemoryMap describes where the data segments of a module are placed in one
+// of its memories.
+type MemoryMap struct {
+	// Memory is the index of the memory.
+	Memory uint32
+
+	// Limits are the limits of the memory, in pages.
+	Limits wasm.ResizableLimits
+
+	// Imported is set if the memory is imported. The host may then provide
+	// a memory larger than Limits.Initial.
+	Imported bool
+
+	// Segments contains the segments placed in the memory, sorted by their
+	// start address.
+	Segments []MappedSegment
+
+	// Overlaps contains the ranges written by more than one segment. Later
+	// segments overwrite earlier ones when the module is instantiated.
+	Overlaps []Overlap
+
+	// Gaps contains the ranges between the first and the last segment that
+	// no segment writes to.
+	Gaps []Range
+}
+
+// Size returns the initial size of the memory in bytes.
+func (mm *MemoryMap) Size() uint64 {
+	return uint64(mm.Limits.Initial) * pageSize
+}
+
+// A Range is a range of addresses, [Start, End).
+type Range struct {
+	Start uint64
+	End   uint64
+}
+
+// Len returns the number of bytes in the range.
+func (r Range) Len() uint64 {
+	return r.End - r.Start
+}
+
+// A MappedSegment is a data segment placed in memory.
+type MappedSegment struct {
+	Range
+
+	// Index is the index of the segment in the data section.
+	Index int
+
+	// OutOfBounds is set if the segment ends past the initial size of the
+	// memory, which makes instantiation fail.
+	OutOfBounds bool
+}
+
+// An Overlap is a range written by two data segments.
+type Overlap struct {
+	Range
+
+	// First and Second are the indices of the segments in the data section.
+	First  int
+	Second int
+}
+
+// An UnresolvedSegment is a data segment whose address can't be determined.
+type UnresolvedSegment struct {
+	// Index is the index of the segment in the data section.
+	Index int
+
+	// Err describes why the address can't be determined.
+	Err error
+}
+
+// MapMemory evaluates the offsets of the data segments of m with wasm.Eval
+// and returns the layout of every memory of the module, in the order of the
+// memory index space, along with the segments that couldn't be placed.
+//
+// An offset may read an imported global. The values of the imported globals
+// are given in globals, indexed by the global index, as for wasm.Eval.
+// Segments with offsets that read a global not in globals are unresolved.
+func MapMemory(m *wasm.Module, globals []interface{}) ([]MemoryMap, []UnresolvedSegment) {
+	x := newIndex(m)
+	maps := make([]MemoryMap, len(x.memories))
+	importedMemories := 0
+	for _, e := range x.imports {
+		if e.Kind == wasm.ExtKindMemory {
+			importedMemories++
+		}
+	}
+	for i, mem := range x.memories {
+		maps[i] = MemoryMap{Memory: uint32(i), Limits: mem.Limits, Imported: i < importedMemories}
+	}
+
+	var unresolved []UnresolvedSegment
+	for i, d := range x.data {
+		if int(d.Index) >= len(maps) {
+			unresolved = append(unresolved, UnresolvedSegment{i, fmt.Errorf("memory %d not defined", d.Index)})
+			continue
+		}
+		v, err := wasm.Eval(d.Offset, globals)
+		if err != nil {
+			unresolved = append(unresolved, UnresolvedSegment{i, fmt.Errorf("evaluate offset: %v", err)})
+			continue
+		}
+		offset, ok := v.(int32)
+		if !ok {
+			unresolved = append(unresolved, UnresolvedSegment{i, fmt.Errorf("offset is %T, expected int32", v)})
+			continue
+		}
+
+		mm := &maps[d.Index]
+		start := uint64(uint32(offset))
+		s := MappedSegment{Range: Range{start, start + uint64(len(d.Data))}, Index: i}
+		s.OutOfBounds = s.End > mm.Size()
+		mm.Segments = append(mm.Segments, s)
+	}
+
+	for i := range maps {
+		maps[i].layout()
+	}
+	return maps, unresolved
+}
+
+// layout sorts the segments and finds the overlaps and gaps between them.
+func (mm *MemoryMap) layout() {
+	segs := mm.Segments
+	sort.SliceStable(segs, func(i, j int) bool {
+		return segs[i].Start < segs[j].Start
+	})
+
+	var end uint64 // end of the range covered so far
+	for i, s := range segs {
+		if i > 0 && s.Start > end {
+			mm.Gaps = append(mm.Gaps, Range{end, s.Start})
+		}
+		if s.End > end {
+			end = s.End
+		}
+
+		// The segments are sorted, so only the following ones that start
+		// before this one ends can overlap with it.
+		for _, t := range segs[i+1:] {
+			if t.Start >= s.End {
+				break
+			}
+			if t.Len() == 0 {
+				continue
+			}
+			o := Overlap{Range: Range{t.Start, minUint64(s.End, t.End)}, First: s.Index, Second: t.Index}
+			if o.First > o.Second {
+				o.First, o.Second = o.Second, o.First
+			}
+			mm.Overlaps = append(mm.Overlaps, o)
+		}
+	}
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func TestMapMemory(t *testing.T) {
+	i32 := func(v byte) []byte { return []byte{0x41, v, 0x0b} }
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "base", Kind: wasm.ExtKindGlobal, GlobalType: &wasm.GlobalType{ContentType: 0x7f}},
+		}},
+		&wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 1, Maximum: 2, HasMaximum: true}}}},
+		&wasm.SectionData{Entries: []wasm.DataSegment{
+			{Offset: i32(0x30), Data: make([]byte, 16)},
+			{Offset: i32(0x10), Data: make([]byte, 8)},
+			{Offset: i32(0x38), Data: make([]byte, 16)},
+			{Offset: []byte{0x23, 0x00, 0x0b}, Data: make([]byte, 4)},
+			{Offset: []byte{0x42, 0x00, 0x0b}},
+			{Index: 1, Offset: i32(0)},
+		}},
+	}}
+
+	maps, unresolved := MapMemory(mod, nil)
+	if len(maps) != 1 {
+		t.Fatalf("Expected 1 memory, got %d", len(maps))
+	}
+	mm := maps[0]
+	if mm.Size() != 65536 || mm.Imported {
+		t.Errorf("Unexpected memory %+v", mm)
+	}
+	expected := []MappedSegment{
+		{Range: Range{0x10, 0x18}, Index: 1},
+		{Range: Range{0x30, 0x40}, Index: 0},
+		{Range: Range{0x38, 0x48}, Index: 2},
+	}
+	if !reflect.DeepEqual(mm.Segments, expected) {
+		t.Errorf("Segments do not match\nexpected %+v\nactual   %+v", expected, mm.Segments)
+	}
+	if expected := []Overlap{{Range: Range{0x38, 0x40}, First: 0, Second: 2}}; !reflect.DeepEqual(mm.Overlaps, expected) {
+		t.Errorf("Expected overlaps %+v, got %+v", expected, mm.Overlaps)
+	}
+	if expected := []Range{{0x18, 0x30}}; !reflect.DeepEqual(mm.Gaps, expected) {
+		t.Errorf("Expected gaps %+v, got %+v", expected, mm.Gaps)
+	}
+	var indices []int
+	for _, u := range unresolved {
+		indices = append(indices, u.Index)
+	}
+	if !reflect.DeepEqual(indices, []int{3, 4, 5}) {
+		t.Errorf("Expected segments 3, 4 and 5 unresolved, got %v", unresolved)
+	}
+
+	// With the value of the global the segment is placed past the end of
+	// the memory.
+	maps, unresolved = MapMemory(mod, []interface{}{int32(65534)})
+	if len(unresolved) != 2 {
+		t.Errorf("Expected 2 unresolved segments, got %v", unresolved)
+	}
+	segs := maps[0].Segments
+	if s := segs[len(segs)-1]; s.Index != 3 || s.Start != 65534 || !s.OutOfBounds {
+		t.Errorf("Expected segment 3 out of bounds, got %+v", s)
+	}
+	if len(maps[0].Gaps) != 2 {
+		t.Errorf("Expected 2 gaps, got %+v", maps[0].Gaps)
+	}
+}
@@ -0,0 +1,177 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// Path returns a shortest call path from the exported function from to the
+// imported function to, or nil if the import can't be reached from the export.
+//
+// The path starts with the index of the exported function and ends with the
+// index of the imported function; every function in the path calls the next
+// one. Calls through call_indirect are followed to every function in the
+// table with a matching signature, as in Reachable.
+//
+// The import is named module.field, or just field if that is unambiguous.
+func Path(m *wasm.Module, from, to string) ([]uint32, error) {
+	g, start, target, err := resolvePath(m, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Breadth first search from the export, recording where every function
+	// was reached from.
+	prev := make(map[uint32]uint32)
+	seen := map[uint32]bool{start: true}
+	queue := []uint32{start}
+	for len(queue) > 0 {
+		fi := queue[0]
+		queue = queue[1:]
+		if fi == target {
+			path := []uint32{fi}
+			for fi != start {
+				fi = prev[fi]
+				path = append(path, fi)
+			}
+			reverse(path)
+			return path, nil
+		}
+		for _, c := range callees(g, fi) {
+			if !seen[c] {
+				seen[c] = true
+				prev[c] = fi
+				queue = append(queue, c)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Paths returns the call paths from the exported function from to the imported
+// function to that don't visit a function more than once. At most limit paths
+// are returned, or all paths if limit is 0. The number of paths may grow
+// exponentially with the size of the module, so a limit should be used for
+// large modules.
+//
+// The paths are described in Path.
+func Paths(m *wasm.Module, from, to string, limit int) ([][]uint32, error) {
+	g, start, target, err := resolvePath(m, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only functions that can reach the target need to be searched.
+	reaches := make([]bool, len(g.Funcs))
+	callers := make([][]uint32, len(g.Funcs))
+	for _, n := range g.Funcs {
+		for _, c := range callees(g, n.Index) {
+			if int(c) < len(callers) {
+				callers[c] = append(callers[c], n.Index)
+			}
+		}
+	}
+	work := []uint32{target}
+	reaches[target] = true
+	for len(work) > 0 {
+		fi := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, c := range callers[fi] {
+			if !reaches[c] {
+				reaches[c] = true
+				work = append(work, c)
+			}
+		}
+	}
+
+	var paths [][]uint32
+	var path []uint32
+	onPath := make(map[uint32]bool)
+	var visit func(fi uint32) bool
+	visit = func(fi uint32) bool {
+		if !reaches[fi] || onPath[fi] {
+			return true
+		}
+		path = append(path, fi)
+		defer func() { path = path[:len(path)-1] }()
+		if fi == target {
+			paths = append(paths, append([]uint32(nil), path...))
+			return limit == 0 || len(paths) < limit
+		}
+		onPath[fi] = true
+		defer delete(onPath, fi)
+		for _, c := range callees(g, fi) {
+			if !visit(c) {
+				return false
+			}
+		}
+		return true
+	}
+	visit(start)
+
+	return paths, nil
+}
+
+// resolvePath builds the call graph of m and resolves the export from and the
+// import to to function indices.
+func resolvePath(m *wasm.Module, from, to string) (g *wasm.CallGraph, start, target uint32, err error) {
+	g, err = m.CallGraph()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	x := newIndex(m)
+
+	found := false
+	for _, e := range x.exports {
+		if e.Kind == wasm.ExtKindFunction && e.Field == from {
+			start, found = e.Index, true
+			break
+		}
+	}
+	if !found {
+		return nil, 0, 0, fmt.Errorf("no exported function %q", from)
+	}
+	if int(start) >= len(g.Funcs) {
+		return nil, 0, 0, fmt.Errorf("export %q: function index %d out of range", from, start)
+	}
+
+	var matches []uint32
+	var names []string
+	fi := uint32(0)
+	for _, e := range x.imports {
+		if e.Kind != wasm.ExtKindFunction {
+			continue
+		}
+		name := e.Module + "." + e.Field
+		if name == to || e.Field == to {
+			matches = append(matches, fi)
+			names = append(names, name)
+		}
+		fi++
+	}
+	switch {
+	case len(matches) == 0:
+		return nil, 0, 0, fmt.Errorf("no imported function %q", to)
+	case len(matches) > 1:
+		return nil, 0, 0, fmt.Errorf("%q matches multiple imports: %s", to, strings.Join(names, ", "))
+	}
+
+	return g, start, matches[0], nil
+}
+
+// callees returns the functions that fi may call, directly or indirectly.
+func callees(g *wasm.CallGraph, fi uint32) []uint32 {
+	n := g.Funcs[fi]
+	if len(n.IndirectCalls) == 0 {
+		return n.Calls
+	}
+	return append(append([]uint32(nil), n.Calls...), n.IndirectCalls...)
+}
+
+func reverse(s []uint32) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
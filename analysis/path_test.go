@@ -0,0 +1,81 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func TestPath(t *testing.T) {
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{{Form: 0x60}}},
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "sock_connect", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{}},
+			{Module: "env", Field: "log", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{}},
+		}},
+		&wasm.SectionFunction{Types: []uint32{0, 0, 0, 0, 0}},
+		&wasm.SectionExport{Entries: []wasm.ExportEntry{
+			{Field: "handle_request", Kind: wasm.ExtKindFunction, Index: 2},
+			{Field: "init", Kind: wasm.ExtKindFunction, Index: 6},
+		}},
+		&wasm.SectionCode{Bodies: []wasm.FunctionBody{
+			{Code: []byte{0x10, 0x03, 0x10, 0x04, 0x0b}}, // 2: call 3, call 4
+			{Code: []byte{0x10, 0x05, 0x0b}},             // 3: call 5
+			{Code: []byte{0x10, 0x00, 0x10, 0x02, 0x0b}}, // 4: call 0, call 2
+			{Code: []byte{0x10, 0x00, 0x0b}},             // 5: call 0
+			{Code: []byte{0x10, 0x01, 0x0b}},             // 6: call 1
+		}},
+	}}
+
+	path, err := Path(mod, "handle_request", "env.sock_connect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(path, []uint32{2, 4, 0}) {
+		t.Errorf("Expected shortest path [2 4 0], got %v", path)
+	}
+
+	paths, err := Paths(mod, "handle_request", "sock_connect", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := [][]uint32{{2, 3, 5, 0}, {2, 4, 0}}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected paths %v, got %v", expected, paths)
+	}
+
+	paths, err = Paths(mod, "handle_request", "sock_connect", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 {
+		t.Errorf("Expected 1 path with limit 1, got %d", len(paths))
+	}
+
+	path, err = Path(mod, "init", "sock_connect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != nil {
+		t.Errorf("Expected no path from init, got %v", path)
+	}
+
+	if _, err := Path(mod, "missing", "sock_connect"); err == nil {
+		t.Error("Expected error for missing export")
+	}
+	if _, err := Path(mod, "init", "env.missing"); err == nil {
+		t.Error("Expected error for missing import")
+	}
+}
+
+func TestPathGo(t *testing.T) {
+	mod := parse(t, "helloworld.wasm")
+
+	path, err := Path(mod, "run", "go.runtime.wasmWrite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) < 2 || path[0] != 864 || path[len(path)-1] != 2 {
+		t.Errorf("Unexpected path from run to runtime.wasmWrite: %v", path)
+	}
+}
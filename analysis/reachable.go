@@ -0,0 +1,217 @@
+// Package analysis contains analyses of parsed WebAssembly modules.
+package analysis
+
+import (
+	"fmt"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// Op codes that reference other parts of the module.
+const (
+	opGlobalGet wasm.Opcode = 0x23
+	opGlobalSet wasm.Opcode = 0x24
+	opRefFunc   wasm.Opcode = 0xd2
+)
+
+// usesMemory reports whether the op code accesses linear memory.
+func usesMemory(op wasm.Opcode) bool {
+	return op >= 0x28 && op <= 0x40 || op >= 0xfc08 && op <= 0xfc0b
+}
+
+// Reachability describes which parts of a module are reachable from its
+// entry points: the exports and the start function.
+//
+// A function is reachable if it's an entry point or it may be called by a
+// reachable function, directly or through a table. Globals are reachable if
+// they are exported or used by reachable code. Data segments are reachable if
+// the memory is exported or accessed by reachable code.
+type Reachability struct {
+	// Funcs reports for every function in the function index space whether
+	// it is reachable.
+	Funcs []bool
+
+	// Globals reports for every global in the global index space whether it
+	// is reachable.
+	Globals []bool
+
+	// Elements reports for every element in every element segment whether
+	// it is reachable, indexed by segment and element.
+	Elements [][]bool
+
+	// Data reports for every data segment whether it is reachable.
+	Data []bool
+}
+
+// Reachable computes which functions, globals, table elements and data
+// segments in m are reachable.
+func Reachable(m *wasm.Module) (*Reachability, error) {
+	g, err := m.CallGraph()
+	if err != nil {
+		return nil, err
+	}
+	x := newIndex(m)
+
+	r := &Reachability{
+		Funcs:    make([]bool, len(x.funcs)),
+		Globals:  make([]bool, x.numGlobals),
+		Elements: make([][]bool, len(x.elements)),
+		Data:     make([]bool, len(x.data)),
+	}
+	for i, e := range x.elements {
+		r.Elements[i] = make([]bool, len(e.Elems))
+	}
+
+	var (
+		work      []uint32
+		memory    bool
+		tables    bool
+		indirect  = make(map[uint32]bool)
+		exprs     [][]byte // init expressions to scan for globals
+		markFunc  func(fi uint32)
+		markTable func()
+	)
+	markFunc = func(fi uint32) {
+		if int(fi) < len(r.Funcs) && !r.Funcs[fi] {
+			r.Funcs[fi] = true
+			work = append(work, fi)
+		}
+	}
+	markTable = func() {
+		if tables {
+			return
+		}
+		tables = true
+		for _, e := range x.elements {
+			for _, fi := range e.Elems {
+				markFunc(fi)
+			}
+		}
+	}
+
+	for _, e := range x.exports {
+		switch e.Kind {
+		case wasm.ExtKindFunction:
+			markFunc(e.Index)
+		case wasm.ExtKindGlobal:
+			if int(e.Index) < len(r.Globals) {
+				r.Globals[e.Index] = true
+			}
+		case wasm.ExtKindTable:
+			markTable()
+		case wasm.ExtKindMemory:
+			memory = true
+		}
+	}
+	if x.start != nil {
+		markFunc(x.start.Index)
+	}
+
+	for len(work) > 0 {
+		fi := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if int(fi) >= len(g.Funcs) {
+			continue
+		}
+		n := g.Funcs[fi]
+		for _, c := range n.Calls {
+			markFunc(c)
+		}
+		for _, c := range n.IndirectCalls {
+			indirect[c] = true
+			markFunc(c)
+		}
+
+		body := x.body(fi)
+		if body == nil {
+			continue
+		}
+		ins, err := wasm.Disassemble(body.Code)
+		if err != nil {
+			return nil, fmt.Errorf("function %d: %v", fi, err)
+		}
+		for _, in := range ins {
+			switch {
+			case in.Op == opGlobalGet || in.Op == opGlobalSet:
+				if int(in.Immediates[0]) < len(r.Globals) {
+					r.Globals[in.Immediates[0]] = true
+				}
+			case in.Op == opRefFunc:
+				markFunc(uint32(in.Immediates[0]))
+			case usesMemory(in.Op):
+				memory = true
+			}
+		}
+	}
+
+	for i, e := range x.elements {
+		used := false
+		for j, fi := range e.Elems {
+			if tables || indirect[fi] {
+				r.Elements[i][j] = true
+				used = true
+			}
+		}
+		if used {
+			exprs = append(exprs, e.Offset)
+		}
+	}
+
+	if memory {
+		for i, d := range x.data {
+			r.Data[i] = true
+			exprs = append(exprs, d.Offset)
+		}
+	}
+
+	// Globals may be initialized from imported globals, and segment offsets
+	// may be read from globals.
+	for i := range r.Globals {
+		if !r.Globals[i] || i < x.importedGlobals {
+			continue
+		}
+		exprs = append(exprs, x.globals[i-x.importedGlobals].Init)
+	}
+	for _, expr := range exprs {
+		ins, err := wasm.Disassemble(expr)
+		if err != nil {
+			return nil, fmt.Errorf("init expression: %v", err)
+		}
+		for _, in := range ins {
+			if in.Op == opGlobalGet && int(in.Immediates[0]) < len(r.Globals) {
+				r.Globals[in.Immediates[0]] = true
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// UnreachableFuncs returns the indices of the functions that are not
+// reachable, in ascending order.
+func (r *Reachability) UnreachableFuncs() []uint32 {
+	return unset(r.Funcs)
+}
+
+// UnreachableGlobals returns the indices of the globals that are not
+// reachable, in ascending order.
+func (r *Reachability) UnreachableGlobals() []uint32 {
+	return unset(r.Globals)
+}
+
+// UnreachableData returns the indices of the data segments that are not
+// reachable, in ascending order.
+func (r *Reachability) UnreachableData() []uint32 {
+	return unset(r.Data)
+}
+
+func unset(s []bool) []uint32 {
+	var idx []uint32
+	for i, v := range s {
+		if !v {
+			idx = append(idx, uint32(i))
+		}
+	}
+	return idx
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func parse(t *testing.T, name string) *wasm.Module {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mod, err := wasm.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mod
+}
+
+func TestReachable(t *testing.T) {
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{{Form: 0x60}}},
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "used", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{}},
+			{Module: "env", Field: "unused", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{}},
+		}},
+		&wasm.SectionFunction{Types: []uint32{0, 0, 0, 0}},
+		&wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 1}}}},
+		&wasm.SectionGlobal{Globals: []wasm.GlobalVariable{
+			{Type: wasm.GlobalType{ContentType: 0x7f}, Init: []byte{0x41, 0x00, 0x0b}},
+			{Type: wasm.GlobalType{ContentType: 0x7f}, Init: []byte{0x41, 0x00, 0x0b}},
+		}},
+		&wasm.SectionExport{Entries: []wasm.ExportEntry{{Field: "main", Kind: wasm.ExtKindFunction, Index: 2}}},
+		&wasm.SectionCode{Bodies: []wasm.FunctionBody{
+			{Code: []byte{0x10, 0x03, 0x0b}},                         // 2: call 3
+			{Code: []byte{0x10, 0x00, 0x23, 0x01, 0x1a, 0x0b}},       // 3: call 0, global.get 1, drop
+			{Code: []byte{0x10, 0x05, 0x0b}},                         // 4: call 5
+			{Code: []byte{0x41, 0x00, 0x28, 0x02, 0x00, 0x1a, 0x0b}}, // 5: i32.load
+		}},
+		&wasm.SectionData{Entries: []wasm.DataSegment{{Offset: []byte{0x41, 0x00, 0x0b}, Data: []byte("x")}}},
+	}}
+
+	r, err := Reachable(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.UnreachableFuncs(); !reflect.DeepEqual(got, []uint32{1, 4, 5}) {
+		t.Errorf("Expected unreachable functions [1 4 5], got %v", got)
+	}
+	if got := r.UnreachableGlobals(); !reflect.DeepEqual(got, []uint32{0}) {
+		t.Errorf("Expected unreachable globals [0], got %v", got)
+	}
+	if got := r.UnreachableData(); !reflect.DeepEqual(got, []uint32{0}) {
+		t.Errorf("Expected unreachable data [0], got %v", got)
+	}
+}
+
+func TestReachableGo(t *testing.T) {
+	mod := parse(t, "helloworld.wasm")
+
+	r, err := Reachable(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Go runtime dispatches through the table, which keeps almost
+	// everything alive.
+	if n := len(r.UnreachableFuncs()); n == 0 || n > 100 {
+		t.Errorf("Unexpected number of unreachable functions: %d", n)
+	}
+	if len(r.UnreachableData()) != 0 {
+		t.Errorf("Expected all data to be reachable as memory is exported")
+	}
+}
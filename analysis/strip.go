@@ -0,0 +1,228 @@
+package analysis
+
+import (
+	"fmt"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// trap is the body given to unreachable functions that have to be kept.
+var trap = []byte{0x00, 0x0b} // unreachable
+
+// Strip returns a copy of m without the functions, globals and data segments
+// that r, as computed by Reachable for m, reports as unreachable. Imported
+// functions and globals that aren't reachable are removed from the imports.
+// The remaining functions and globals are renumbered in order, and the
+// references to them are rebased: in the function bodies, init expressions,
+// element segments, exports, the start function and the name section.
+//
+// Element segments are kept as they are, since removing an element would move
+// the others in the table. A function that is only referenced by a segment,
+// but can't be called through the table, keeps its place in the function
+// index space with a body that traps. Custom sections other than the name
+// section are not copied, and neither are the subsections of the name section
+// other than the function and local names.
+func Strip(m *wasm.Module, r *Reachability) (*wasm.Module, error) {
+	x := newIndex(m)
+	if len(r.Funcs) != len(x.funcs) || len(r.Globals) != x.numGlobals || len(r.Data) != len(x.data) {
+		return nil, fmt.Errorf("reachability doesn't match the module")
+	}
+
+	var (
+		funcs   = make(map[uint32]bool)
+		globals = make(map[uint32]bool)
+		exprs   [][]byte // init expressions to scan for globals
+	)
+	for fi, ok := range r.Funcs {
+		if ok {
+			funcs[uint32(fi)] = true
+		}
+	}
+	for gi, ok := range r.Globals {
+		if ok {
+			globals[uint32(gi)] = true
+		}
+	}
+	for _, e := range x.elements {
+		for _, fi := range e.Elems {
+			if int(fi) >= len(x.funcs) {
+				return nil, fmt.Errorf("function index %d out of range", fi)
+			}
+			funcs[fi] = true
+		}
+		exprs = append(exprs, e.Offset)
+	}
+	// The offsets of segments without reachable elements may use globals
+	// that aren't otherwise reachable.
+	for len(exprs) > 0 {
+		expr := exprs[0]
+		exprs = exprs[1:]
+		ins, err := wasm.Disassemble(expr)
+		if err != nil {
+			return nil, fmt.Errorf("init expression: %v", err)
+		}
+		for _, in := range ins {
+			if in.Op != opGlobalGet || globals[uint32(in.Immediates[0])] {
+				continue
+			}
+			gi := uint32(in.Immediates[0])
+			if int(gi) >= x.numGlobals {
+				return nil, fmt.Errorf("global index %d out of range", gi)
+			}
+			globals[gi] = true
+			if int(gi) >= x.importedGlobals {
+				exprs = append(exprs, x.globals[int(gi)-x.importedGlobals].Init)
+			}
+		}
+	}
+
+	funcMap := renumber(funcs)
+	globalMap := renumber(globals)
+	typeMap := make(map[uint32]uint32, len(x.types))
+	for ti := range x.types {
+		typeMap[uint32(ti)] = uint32(ti)
+	}
+
+	out := &wasm.Module{}
+	for _, s := range m.Sections {
+		switch s := s.(type) {
+		case *wasm.SectionImport:
+			sec := &wasm.SectionImport{}
+			nf, ng := uint32(0), uint32(0)
+			for _, e := range s.Entries {
+				keep := true
+				switch e.Kind {
+				case wasm.ExtKindFunction:
+					keep = funcs[nf]
+					nf++
+				case wasm.ExtKindGlobal:
+					keep = globals[ng]
+					ng++
+				}
+				if keep {
+					sec.Entries = append(sec.Entries, e)
+				}
+			}
+			out.Sections = append(out.Sections, sec)
+
+		case *wasm.SectionFunction:
+			sec := &wasm.SectionFunction{}
+			for i, ti := range s.Types {
+				if funcs[uint32(x.importedFuncs+i)] {
+					sec.Types = append(sec.Types, ti)
+				}
+			}
+			out.Sections = append(out.Sections, sec)
+
+		case *wasm.SectionGlobal:
+			sec := &wasm.SectionGlobal{}
+			for i, g := range s.Globals {
+				gi := uint32(x.importedGlobals + i)
+				if !globals[gi] {
+					continue
+				}
+				init, err := remapCode(g.Init, funcMap, globalMap, typeMap)
+				if err != nil {
+					return nil, fmt.Errorf("global %d: %v", gi, err)
+				}
+				sec.Globals = append(sec.Globals, wasm.GlobalVariable{Type: g.Type, Init: init})
+			}
+			out.Sections = append(out.Sections, sec)
+
+		case *wasm.SectionExport:
+			sec := &wasm.SectionExport{Entries: make([]wasm.ExportEntry, len(s.Entries))}
+			for i, e := range s.Entries {
+				switch e.Kind {
+				case wasm.ExtKindFunction:
+					e.Index = funcMap[e.Index]
+				case wasm.ExtKindGlobal:
+					e.Index = globalMap[e.Index]
+				}
+				sec.Entries[i] = e
+			}
+			out.Sections = append(out.Sections, sec)
+
+		case *wasm.SectionStart:
+			out.Sections = append(out.Sections, &wasm.SectionStart{Index: funcMap[s.Index]})
+
+		case *wasm.SectionElement:
+			sec := &wasm.SectionElement{}
+			for _, e := range s.Entries {
+				offset, err := remapCode(e.Offset, funcMap, globalMap, typeMap)
+				if err != nil {
+					return nil, fmt.Errorf("element segment: %v", err)
+				}
+				elems := make([]uint32, len(e.Elems))
+				for i, fi := range e.Elems {
+					elems[i] = funcMap[fi]
+				}
+				sec.Entries = append(sec.Entries, wasm.ElemSegment{Index: e.Index, ExplicitIndex: e.ExplicitIndex, Offset: offset, Elems: elems})
+			}
+			out.Sections = append(out.Sections, sec)
+
+		case *wasm.SectionCode:
+			sec := &wasm.SectionCode{}
+			for i, body := range s.Bodies {
+				fi := uint32(x.importedFuncs + i)
+				switch {
+				case !funcs[fi]:
+					continue
+				case !r.Funcs[fi]:
+					sec.Bodies = append(sec.Bodies, wasm.FunctionBody{Code: trap})
+					continue
+				}
+				code, err := remapCode(body.Code, funcMap, globalMap, typeMap)
+				if err != nil {
+					return nil, fmt.Errorf("function %d: %v", fi, err)
+				}
+				sec.Bodies = append(sec.Bodies, wasm.FunctionBody{Locals: body.Locals, Code: code})
+			}
+			out.Sections = append(out.Sections, sec)
+
+		case *wasm.SectionData:
+			// Data segments are either all reachable or none are, so the
+			// data indices of memory.init and data.drop don't change.
+			sec := &wasm.SectionData{}
+			for i, d := range s.Entries {
+				if !r.Data[i] {
+					continue
+				}
+				offset, err := remapCode(d.Offset, funcMap, globalMap, typeMap)
+				if err != nil {
+					return nil, fmt.Errorf("data segment %d: %v", i, err)
+				}
+				sec.Entries = append(sec.Entries, wasm.DataSegment{Index: d.Index, Offset: offset, Data: d.Data})
+			}
+			if len(sec.Entries) > 0 {
+				out.Sections = append(out.Sections, sec)
+			}
+
+		case *wasm.SectionName:
+			sec := &wasm.SectionName{SectionName: s.SectionName, Module: s.Module}
+			if s.Functions != nil {
+				sec.Functions = &wasm.NameMap{}
+				for _, n := range s.Functions.Names {
+					if funcs[n.Index] {
+						sec.Functions.Names = append(sec.Functions.Names, wasm.Naming{Index: funcMap[n.Index], Name: n.Name})
+					}
+				}
+			}
+			if s.Locals != nil {
+				sec.Locals = &wasm.Locals{}
+				for _, l := range s.Locals.Funcs {
+					if int(l.Index) < len(r.Funcs) && r.Funcs[l.Index] {
+						sec.Locals.Funcs = append(sec.Locals.Funcs, wasm.LocalName{Index: funcMap[l.Index], LocalMap: l.LocalMap})
+					}
+				}
+			}
+			out.Sections = append(out.Sections, sec)
+
+		case *wasm.SectionCustom:
+			// Indices in custom sections can't be rebased.
+
+		default:
+			out.Sections = append(out.Sections, s)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,119 @@
+package analysis
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/exec/interp"
+)
+
+func TestStrip(t *testing.T) {
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{
+			{Form: 0x60, ReturnTypes: []wasm.ValueType{wasm.I32}},
+			{Form: 0x60},
+		}},
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "used", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{Index: 1}},
+			{Module: "env", Field: "unused", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{Index: 1}},
+		}},
+		&wasm.SectionFunction{Types: []uint32{0, 0, 0, 0, 1}},
+		&wasm.SectionTable{Entries: []wasm.TableType{{ElemType: 0x70, Limits: wasm.ResizableLimits{Initial: 1}}}},
+		&wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 1}}}},
+		&wasm.SectionGlobal{Globals: []wasm.GlobalVariable{
+			{Type: wasm.GlobalType{ContentType: wasm.I32}, Init: []byte{0x41, 0x01, 0x0b}},
+			{Type: wasm.GlobalType{ContentType: wasm.I32}, Init: []byte{0x41, 0x28, 0x0b}},
+		}},
+		&wasm.SectionExport{Entries: []wasm.ExportEntry{{Field: "main", Kind: wasm.ExtKindFunction, Index: 2}}},
+		&wasm.SectionElement{Entries: []wasm.ElemSegment{{Offset: []byte{0x41, 0x00, 0x0b}, Elems: []uint32{5}}}},
+		&wasm.SectionCode{Bodies: []wasm.FunctionBody{
+			{Code: []byte{0x10, 0x00, 0x23, 0x01, 0x10, 0x03, 0x6a, 0x0b}}, // 2: call 0, global.get 1 + call 3
+			{Code: []byte{0x41, 0x02, 0x0b}},                               // 3: i32.const 2
+			{Code: []byte{0x10, 0x01, 0x41, 0x00, 0x0b}},                   // 4: call 1, i32.const 0
+			{Code: []byte{0x41, 0x07, 0x0b}},                               // 5: in the table, i32.const 7
+			{Code: []byte{0x0b}},                                           // 6
+		}},
+		&wasm.SectionData{Entries: []wasm.DataSegment{{Offset: []byte{0x41, 0x00, 0x0b}, Data: []byte("x")}}},
+		&wasm.SectionName{SectionName: "name", Functions: &wasm.NameMap{Names: []wasm.Naming{
+			{Index: 2, Name: "main"},
+			{Index: 3, Name: "helper"},
+			{Index: 4, Name: "dead"},
+			{Index: 5, Name: "tabled"},
+		}}},
+	}}
+
+	r, err := Reachable(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := Strip(mod, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := wasm.Encode(&buf, stripped); err != nil {
+		t.Fatal(err)
+	}
+	stripped, err = wasm.ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if imports := stripped.ImportSection().Entries; len(imports) != 1 || imports[0].Field != "used" {
+		t.Errorf("Expected only the used import, got %v", imports)
+	}
+	if types := stripped.FunctionSection().Types; !reflect.DeepEqual(types, []uint32{0, 0, 0}) {
+		t.Errorf("Expected function types [0 0 0], got %v", types)
+	}
+	if globals := stripped.GlobalSection().Globals; len(globals) != 1 || !bytes.Equal(globals[0].Init, []byte{0x41, 0x28, 0x0b}) {
+		t.Errorf("Expected only global 1, got %v", globals)
+	}
+	expected := []wasm.ExportEntry{{Field: "main", Kind: wasm.ExtKindFunction, Index: 1}}
+	if exports := stripped.ExportSection().Entries; !reflect.DeepEqual(exports, expected) {
+		t.Errorf("Expected exports %v, got %v", expected, exports)
+	}
+	if elems := stripped.ElementSection().Entries[0].Elems; !reflect.DeepEqual(elems, []uint32{3}) {
+		t.Errorf("Expected elements [3], got %v", elems)
+	}
+	bodies := stripped.CodeSection().Bodies
+	if code := bodies[0].Code; !bytes.Equal(code, []byte{0x10, 0x00, 0x23, 0x00, 0x10, 0x02, 0x6a, 0x0b}) {
+		t.Errorf("Expected main to be rebased, got % x", code)
+	}
+	if code := bodies[2].Code; !bytes.Equal(code, trap) {
+		t.Errorf("Expected the function in the table to trap, got % x", code)
+	}
+	if stripped.DataSection() != nil {
+		t.Error("Expected the data section to be removed")
+	}
+	expectedNames := []wasm.Naming{{Index: 1, Name: "main"}, {Index: 2, Name: "helper"}, {Index: 3, Name: "tabled"}}
+	if names := stripped.NameSection().Functions.Names; !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Expected names %v, got %v", expectedNames, names)
+	}
+
+	// The stripped module behaves like the original.
+	var calls int
+	imports := interp.Imports{"env": {
+		"used": interp.HostFunc(func(_ *interp.Instance, args []uint64) ([]uint64, error) {
+			calls++
+			return nil, nil
+		}),
+	}}
+	inst, err := interp.Instantiate(stripped, imports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inst.Call("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := interp.AsI32(res[0]); got != 42 || calls != 1 {
+		t.Errorf("Expected main() = 42 with 1 call to used, got %d with %d", got, calls)
+	}
+
+	if _, err := Strip(mod, &Reachability{}); err == nil {
+		t.Error("Expected error for reachability of another module")
+	}
+}
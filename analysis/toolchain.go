@@ -0,0 +1,185 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// A HostABI is the interface a module expects from the host through its
+// imports.
+type HostABI string
+
+// Host ABIs.
+const (
+	ABIUnknown HostABI = ""
+	ABINone    HostABI = "none" // no imports
+
+	// ABIGoJS is the interface of the JavaScript glue of Go and TinyGo,
+	// wasm_exec.js. Go and TinyGo ship different versions of it.
+	ABIGoJS HostABI = "js"
+
+	ABIWASIPreview1 HostABI = "wasi_snapshot_preview1"
+	ABIWASIUnstable HostABI = "wasi_unstable"
+)
+
+// A BuildMode describes how a module is meant to be run.
+type BuildMode string
+
+// Build modes.
+const (
+	ModeUnknown BuildMode = ""
+
+	// ModeCommand is a program that runs main when started and exits, such
+	// as a module built with GOOS=js or GOOS=wasip1.
+	ModeCommand BuildMode = "command"
+
+	// ModeReactor is a library that is initialized once, after which the
+	// host calls its exports, such as a module built with
+	// -buildmode=c-shared.
+	ModeReactor BuildMode = "reactor"
+)
+
+// Toolchain describes the compiler that produced a module and what the module
+// needs from the host.
+type Toolchain struct {
+	// Name is "Go" or "TinyGo", or empty if the module wasn't recognized
+	// as compiled from Go.
+	Name string
+
+	// Version is the version of the toolchain, if known.
+	Version string
+
+	// BuildID is the build ID from the go.buildid section.
+	BuildID string
+
+	Mode BuildMode
+	ABI  HostABI
+
+	// Evidence lists the properties of the module the result is based on.
+	Evidence []string
+}
+
+// goVersion matches the version of Go embedded in the runtime, such as
+// go1.21.3.
+var goVersion = regexp.MustCompile(`go1\.[0-9]+(\.[0-9]+)?((beta|rc)[0-9]+)?`)
+
+// DetectToolchain recognizes modules compiled by Go and TinyGo from their
+// custom sections, imports and exports.
+//
+// Go writes a go.buildid section and embeds its version in the data. Modules
+// for GOOS=js import from "go" (before Go 1.21) or "gojs" and export run.
+// TinyGo is recognized by its producers section or by the runtime functions it
+// imports. The host ABI is derived from the import modules, and
+// the build mode from the exports: _start or run for commands, _initialize
+// for reactors.
+func DetectToolchain(m *wasm.Module) (*Toolchain, error) {
+	tc := &Toolchain{}
+	evidence := func(format string, args ...interface{}) {
+		tc.Evidence = append(tc.Evidence, fmt.Sprintf(format, args...))
+	}
+
+	producers, err := m.Producers()
+	if err != nil {
+		return nil, fmt.Errorf("producers section: %v", err)
+	}
+	for _, f := range producers {
+		for _, v := range f.Values {
+			switch {
+			case strings.EqualFold(v.Name, "TinyGo"):
+				tc.Name, tc.Version = "TinyGo", v.Version
+				evidence("producers section lists %s %s", v.Name, v.Version)
+			case f.Name == "language" && v.Name == "Go" && tc.Name == "":
+				tc.Name, tc.Version = "Go", v.Version
+				evidence("producers section lists language Go")
+			}
+		}
+	}
+
+	if ss := m.CustomSections("go.buildid"); len(ss) > 0 {
+		// The section contains the ID in quotes, as in the build ID
+		// note of Go executables: \xff Go build ID: "..."\n \xff
+		id := string(ss[0].Payload)
+		if i, j := strings.IndexByte(id, '"'), strings.LastIndexByte(id, '"'); i < j {
+			id = id[i+1 : j]
+		}
+		tc.BuildID = id
+		if tc.Name == "" {
+			tc.Name = "Go"
+		}
+		evidence("go.buildid section")
+	}
+
+	modules := make(map[string]bool)
+	tinygoImports := false
+	if s := m.ImportSection(); s != nil {
+		for _, e := range s.Entries {
+			modules[e.Module] = true
+			// The runtime of TinyGo, unlike the one of Go, relies on
+			// the host for timers.
+			if e.Field == "runtime.ticks" || e.Field == "runtime.sleepTicks" {
+				tinygoImports = true
+			}
+		}
+	}
+	switch {
+	case modules["go"] || modules["gojs"]:
+		tc.ABI = ABIGoJS
+		if modules["go"] {
+			evidence("imports from \"go\"")
+		} else {
+			evidence("imports from \"gojs\"")
+		}
+		if tc.Name == "" && !tinygoImports {
+			tc.Name = "Go"
+		}
+	case tinygoImports:
+		tc.ABI = ABIGoJS
+	case modules["wasi_snapshot_preview1"]:
+		tc.ABI = ABIWASIPreview1
+		evidence("imports from \"wasi_snapshot_preview1\"")
+	case modules["wasi_unstable"]:
+		tc.ABI = ABIWASIUnstable
+		evidence("imports from \"wasi_unstable\"")
+	case len(modules) == 0:
+		tc.ABI = ABINone
+	}
+	if tinygoImports {
+		tc.Name = "TinyGo"
+		evidence("imports TinyGo runtime functions")
+	}
+
+	exports := make(map[string]bool)
+	if s := m.ExportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind == wasm.ExtKindFunction {
+				exports[e.Field] = true
+			}
+		}
+	}
+	switch {
+	case exports["_initialize"]:
+		tc.Mode = ModeReactor
+		evidence("exports _initialize")
+	case exports["_start"]:
+		tc.Mode = ModeCommand
+		evidence("exports _start")
+	case exports["run"] && tc.ABI == ABIGoJS:
+		tc.Mode = ModeCommand
+		evidence("exports run")
+	}
+
+	if tc.Name == "Go" && tc.Version == "" {
+		if s := m.DataSection(); s != nil {
+			for _, d := range s.Entries {
+				if v := goVersion.Find(d.Data); v != nil {
+					tc.Version = string(v)
+					break
+				}
+			}
+		}
+	}
+	return tc, nil
+}
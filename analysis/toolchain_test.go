@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func TestDetectToolchainGo(t *testing.T) {
+	tc, err := DetectToolchain(parse(t, "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.Name != "Go" || tc.Version != "go1.11beta1" || tc.ABI != ABIGoJS || tc.Mode != ModeCommand {
+		t.Errorf("Unexpected toolchain %+v", tc)
+	}
+	if expected := "pNodMFG5L5bqbn71Aq2J/LSt4lfeNQW5shiqJcKOC/0jWLUWRAD0CkoxU4LakJ/9eEzGEI9wQYgqPBojx1Z"; tc.BuildID != expected {
+		t.Errorf("Expected build ID %s, got %s", expected, tc.BuildID)
+	}
+}
+
+func TestDetectToolchain(t *testing.T) {
+	imports := func(module string, fields ...string) *wasm.SectionImport {
+		s := &wasm.SectionImport{}
+		for _, f := range fields {
+			s.Entries = append(s.Entries, wasm.ImportEntry{Module: module, Field: f, Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{}})
+		}
+		return s
+	}
+	exports := func(names ...string) *wasm.SectionExport {
+		s := &wasm.SectionExport{}
+		for _, n := range names {
+			s.Entries = append(s.Entries, wasm.ExportEntry{Field: n, Kind: wasm.ExtKindFunction})
+		}
+		return s
+	}
+	buildID := &wasm.SectionCustom{SectionName: "go.buildid", Payload: []byte("id")}
+	version := &wasm.SectionData{Entries: []wasm.DataSegment{{Data: []byte("\x00go1.21.3\x00")}}}
+
+	tt := []struct {
+		name     string
+		sections []wasm.Section
+		expected Toolchain
+	}{
+		{
+			name:     "go js",
+			sections: []wasm.Section{buildID, imports("gojs", "runtime.wasmExit"), exports("run", "resume"), version},
+			expected: Toolchain{Name: "Go", Version: "go1.21.3", BuildID: "id", ABI: ABIGoJS, Mode: ModeCommand},
+		},
+		{
+			name:     "go wasip1",
+			sections: []wasm.Section{buildID, imports("wasi_snapshot_preview1", "fd_write"), exports("_start")},
+			expected: Toolchain{Name: "Go", BuildID: "id", ABI: ABIWASIPreview1, Mode: ModeCommand},
+		},
+		{
+			name:     "go c-shared",
+			sections: []wasm.Section{buildID, imports("wasi_snapshot_preview1", "fd_write"), exports("_initialize", "add")},
+			expected: Toolchain{Name: "Go", BuildID: "id", ABI: ABIWASIPreview1, Mode: ModeReactor},
+		},
+		{
+			name:     "tinygo js",
+			sections: []wasm.Section{imports("env", "runtime.ticks", "syscall/js.valueGet"), exports("_start", "resume")},
+			expected: Toolchain{Name: "TinyGo", ABI: ABIGoJS, Mode: ModeCommand},
+		},
+		{
+			name:     "unknown",
+			sections: []wasm.Section{imports("env", "print"), exports("main")},
+			expected: Toolchain{},
+		},
+		{
+			name:     "no imports",
+			sections: []wasm.Section{exports("add")},
+			expected: Toolchain{ABI: ABINone},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := DetectToolchain(&wasm.Module{Sections: tc.sections})
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual.Evidence = nil
+			if !reflect.DeepEqual(*actual, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, *actual)
+			}
+		})
+	}
+}
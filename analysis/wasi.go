@@ -0,0 +1,191 @@
+package analysis
+
+import (
+	"sort"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// A WASICapability is a kind of access to the host that WASI functions give
+// a module.
+type WASICapability string
+
+// WASI capabilities.
+const (
+	// CapIO is reading and writing file descriptors the module is given,
+	// such as standard input and output.
+	CapIO WASICapability = "io"
+
+	// CapFilesystem is opening, reading and modifying files and directories
+	// in the preopened directories.
+	CapFilesystem WASICapability = "filesystem"
+
+	CapClock       WASICapability = "clock"
+	CapRandom      WASICapability = "random"
+	CapSockets     WASICapability = "sockets"
+	CapEnvironment WASICapability = "environment" // arguments and environment variables
+	CapProcess     WASICapability = "process"     // exiting, raising signals and yielding
+	CapPoll        WASICapability = "poll"        // waiting for timers and file descriptors
+
+	// CapUnknown is a function of a WASI module that isn't part of WASI
+	// preview 1.
+	CapUnknown WASICapability = "unknown"
+)
+
+// Description returns a short description of the access the capability gives.
+func (c WASICapability) Description() string {
+	switch c {
+	case CapIO:
+		return "read and write open file descriptors, such as stdin and stdout"
+	case CapFilesystem:
+		return "access files and directories"
+	case CapClock:
+		return "read the time"
+	case CapRandom:
+		return "read random data"
+	case CapSockets:
+		return "use network connections"
+	case CapEnvironment:
+		return "read command line arguments and environment variables"
+	case CapProcess:
+		return "exit, raise signals and yield"
+	case CapPoll:
+		return "wait for timers and file descriptors"
+	}
+	return "unknown WASI function"
+}
+
+// wasiCapabilities maps the functions of wasi_snapshot_preview1 and
+// wasi_unstable to the capability they need.
+var wasiCapabilities = map[string]WASICapability{
+	"args_get":          CapEnvironment,
+	"args_sizes_get":    CapEnvironment,
+	"environ_get":       CapEnvironment,
+	"environ_sizes_get": CapEnvironment,
+
+	"clock_res_get":  CapClock,
+	"clock_time_get": CapClock,
+
+	"fd_close":            CapIO,
+	"fd_fdstat_get":       CapIO,
+	"fd_fdstat_set_flags": CapIO,
+	"fd_read":             CapIO,
+	"fd_renumber":         CapIO,
+	"fd_seek":             CapIO,
+	"fd_tell":             CapIO,
+	"fd_write":            CapIO,
+
+	"fd_advise":               CapFilesystem,
+	"fd_allocate":             CapFilesystem,
+	"fd_datasync":             CapFilesystem,
+	"fd_fdstat_set_rights":    CapFilesystem,
+	"fd_filestat_get":         CapFilesystem,
+	"fd_filestat_set_size":    CapFilesystem,
+	"fd_filestat_set_times":   CapFilesystem,
+	"fd_pread":                CapFilesystem,
+	"fd_prestat_dir_name":     CapFilesystem,
+	"fd_prestat_get":          CapFilesystem,
+	"fd_pwrite":               CapFilesystem,
+	"fd_readdir":              CapFilesystem,
+	"fd_sync":                 CapFilesystem,
+	"path_create_directory":   CapFilesystem,
+	"path_filestat_get":       CapFilesystem,
+	"path_filestat_set_times": CapFilesystem,
+	"path_link":               CapFilesystem,
+	"path_open":               CapFilesystem,
+	"path_readlink":           CapFilesystem,
+	"path_remove_directory":   CapFilesystem,
+	"path_rename":             CapFilesystem,
+	"path_symlink":            CapFilesystem,
+	"path_unlink_file":        CapFilesystem,
+
+	"poll_oneoff": CapPoll,
+
+	"proc_exit":   CapProcess,
+	"proc_raise":  CapProcess,
+	"sched_yield": CapProcess,
+
+	"random_get": CapRandom,
+
+	"sock_accept":   CapSockets,
+	"sock_recv":     CapSockets,
+	"sock_send":     CapSockets,
+	"sock_shutdown": CapSockets,
+}
+
+// A WASIImport is a function imported from a WASI module.
+type WASIImport struct {
+	// Index is the index of the function in the function index space.
+	Index uint32
+
+	Module     string
+	Name       string
+	Capability WASICapability
+}
+
+// WASIReport lists the WASI functions a module imports and the capabilities
+// they need.
+type WASIReport struct {
+	// Versions contains the WASI modules imported from:
+	// ABIWASIPreview1, ABIWASIUnstable or both.
+	Versions []HostABI
+
+	// Imports contains the functions imported from WASI modules, in the
+	// order they are imported.
+	Imports []WASIImport
+
+	// Capabilities contains the capabilities the imports need, sorted by
+	// name.
+	Capabilities []WASICapability
+}
+
+// Functions returns the names of the imported functions that need
+// capability c.
+func (r *WASIReport) Functions(c WASICapability) []string {
+	var names []string
+	for _, imp := range r.Imports {
+		if imp.Capability == c {
+			names = append(names, imp.Name)
+		}
+	}
+	return names
+}
+
+// WASICapabilities reports which WASI functions m imports from
+// wasi_snapshot_preview1 and wasi_unstable and what they give access to, which
+// tells what a module can do before it's deployed. A module that imports only
+// fd_write, for example, can print but can't open files.
+//
+// The report reflects the imports only: a module may import functions it
+// never calls.
+func WASICapabilities(m *wasm.Module) *WASIReport {
+	r := &WASIReport{}
+	versions := make(map[HostABI]bool)
+	caps := make(map[WASICapability]bool)
+	var fi uint32
+	for _, e := range newIndex(m).imports {
+		if e.Kind != wasm.ExtKindFunction {
+			continue
+		}
+		if abi := HostABI(e.Module); abi == ABIWASIPreview1 || abi == ABIWASIUnstable {
+			c, ok := wasiCapabilities[e.Field]
+			if !ok {
+				c = CapUnknown
+			}
+			r.Imports = append(r.Imports, WASIImport{Index: fi, Module: e.Module, Name: e.Field, Capability: c})
+			if !versions[abi] {
+				versions[abi] = true
+				r.Versions = append(r.Versions, abi)
+			}
+			caps[c] = true
+		}
+		fi++
+	}
+	for c := range caps {
+		r.Capabilities = append(r.Capabilities, c)
+	}
+	sort.Slice(r.Capabilities, func(i, j int) bool {
+		return r.Capabilities[i] < r.Capabilities[j]
+	})
+	return r
+}
@@ -0,0 +1,48 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func TestWASICapabilities(t *testing.T) {
+	imp := func(module, field string) wasm.ImportEntry {
+		return wasm.ImportEntry{Module: module, Field: field, Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{}}
+	}
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			imp("wasi_snapshot_preview1", "fd_write"),
+			{Module: "env", Field: "memory", Kind: wasm.ExtKindMemory, MemoryType: &wasm.MemoryType{}},
+			imp("env", "log"),
+			imp("wasi_snapshot_preview1", "path_open"),
+			imp("wasi_snapshot_preview1", "random_get"),
+			imp("wasi_unstable", "fd_read"),
+			imp("wasi_snapshot_preview1", "fd_prestat_get"),
+			imp("wasi_snapshot_preview1", "custom_extension"),
+		}},
+	}}
+
+	r := WASICapabilities(mod)
+	if expected := []HostABI{ABIWASIPreview1, ABIWASIUnstable}; !reflect.DeepEqual(r.Versions, expected) {
+		t.Errorf("Expected versions %v, got %v", expected, r.Versions)
+	}
+	if expected := []WASICapability{CapFilesystem, CapIO, CapRandom, CapUnknown}; !reflect.DeepEqual(r.Capabilities, expected) {
+		t.Errorf("Expected capabilities %v, got %v", expected, r.Capabilities)
+	}
+	if expected := []string{"path_open", "fd_prestat_get"}; !reflect.DeepEqual(r.Functions(CapFilesystem), expected) {
+		t.Errorf("Expected filesystem functions %v, got %v", expected, r.Functions(CapFilesystem))
+	}
+	if len(r.Imports) != 6 {
+		t.Fatalf("Expected 6 imports, got %+v", r.Imports)
+	}
+	if expected := (WASIImport{Index: 2, Module: "wasi_snapshot_preview1", Name: "path_open", Capability: CapFilesystem}); r.Imports[1] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, r.Imports[1])
+	}
+
+	r = WASICapabilities(parse(t, "helloworld.wasm"))
+	if len(r.Versions) != 0 || len(r.Imports) != 0 || len(r.Capabilities) != 0 {
+		t.Errorf("Expected no WASI imports, got %+v", r)
+	}
+}
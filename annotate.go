@@ -0,0 +1,139 @@
+package wasm
+
+import (
+	"io"
+)
+
+// AnnotationVersion is the version of the annotation map format. It's
+// incremented when the format changes in a way that is not backwards
+// compatible.
+const AnnotationVersion = 1
+
+// An AnnotationMap labels the byte ranges of a file with the way the parser
+// interprets them. It's meant to be serialized to JSON for tools that don't
+// link Go code, such as hex editors and binary diff viewers:
+//
+//	{
+//		"version": 1,
+//		"size": 1234,
+//		"annotations": [
+//			{
+//				"offset": 0,
+//				"size": 8,
+//				"label": "preamble",
+//				"children": [
+//					{"offset": 0, "size": 4, "label": "uint32", "value": "1836278016"},
+//					{"offset": 4, "size": 4, "label": "uint32", "value": "1"}
+//				]
+//			},
+//			{
+//				"offset": 8,
+//				"size": 120,
+//				"label": "section",
+//				"children": [
+//					{"offset": 8, "size": 1, "label": "varuint7", "value": "0"},
+//					...
+//				]
+//			}
+//		]
+//	}
+//
+// Annotations are nested: the children of an annotation lie within its range
+// and don't overlap. An annotation either has children, in which case it's a
+// structure such as a section ("section"), the payload of a section ("Type",
+// "Code", ...), an entry in a section ("entry 3") or limits ("limits"), or it's
+// a single value. Values are labelled with their encoding, such as
+// "varuint32", "value type", "bytes" or "init expr", and have the decoded value
+// formatted as a string. Names are formatted as quoted strings and other long
+// byte strings by their length, for example "1024 bytes".
+//
+// If the file could not be parsed, Error is set. The annotations cover the
+// file up to the point of the failure, and the bytes consumed by the failing
+// step are labelled "error".
+type AnnotationMap struct {
+	// Version is the version of the format, AnnotationVersion.
+	Version int `json:"version"`
+
+	// Size is the number of bytes covered by the annotations.
+	Size int `json:"size"`
+
+	// Error is the parse error, if any.
+	Error string `json:"error,omitempty"`
+
+	// Annotations contains the top level annotations in the order they
+	// appear in the file.
+	Annotations []*Annotation `json:"annotations"`
+}
+
+// An Annotation labels a range of bytes.
+type Annotation struct {
+	// Offset is the position of the first byte in the file.
+	Offset int `json:"offset"`
+
+	// Size is the number of bytes.
+	Size int `json:"size"`
+
+	// Label describes the bytes.
+	Label string `json:"label"`
+
+	// Value is the decoded value, if the annotation is a single value.
+	Value string `json:"value,omitempty"`
+
+	// Children contains the annotations of the contents of a structure.
+	Children []*Annotation `json:"children,omitempty"`
+}
+
+// Annotate parses the input and returns the annotation map of the file.
+//
+// If the input is not a valid module, the map of the part that could be
+// parsed is returned along with the error.
+func Annotate(r io.Reader) (*AnnotationMap, error) {
+	m := &AnnotationMap{Version: AnnotationVersion}
+
+	var open []*Annotation
+	siblings := func() *[]*Annotation {
+		if len(open) == 0 {
+			return &m.Annotations
+		}
+		return &open[len(open)-1].Children
+	}
+	add := func(a *Annotation) {
+		s := siblings()
+		*s = append(*s, a)
+	}
+
+	_, err := ParseWithOptions(r, ParseOptions{
+		Trace: func(e TraceEvent) {
+			switch e.Kind {
+			case TraceBegin:
+				a := &Annotation{Offset: e.Offset, Label: e.Name}
+				add(a)
+				open = append(open, a)
+			case TraceEnd:
+				a := open[len(open)-1]
+				a.Size = e.Offset - a.Offset
+				open = open[:len(open)-1]
+				if a.Size == 0 && len(a.Children) == 0 {
+					// The parser starts a section before it
+					// finds the end of the input.
+					s := siblings()
+					*s = (*s)[:len(*s)-1]
+				}
+			case TraceRead:
+				a := &Annotation{Offset: e.Offset, Size: len(e.Bytes), Label: e.Name}
+				if e.Value != nil {
+					a.Value = formatTraceValue(e.Value)
+				}
+				add(a)
+			}
+			if end := e.Offset + len(e.Bytes); end > m.Size {
+				m.Size = end
+			}
+		},
+	})
+	if err != nil {
+		m.Error = err.Error()
+		return m, err
+	}
+	return m, nil
+}
@@ -0,0 +1,78 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnnotate(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Annotate(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Size != len(b) {
+		t.Errorf("Size does not match; expected %d, actual %d", len(b), m.Size)
+	}
+	if len(m.Annotations) != 13 {
+		t.Fatalf("Expected preamble and 12 sections, got %d annotations", len(m.Annotations))
+	}
+	checkAnnotations(t, m.Annotations, 0, len(b))
+
+	limits := m.Annotations[5].Children[2].Children[1].Children[1]
+	if limits.Label != "limits" || limits.Offset != 0x873 || limits.Size != 3 {
+		t.Errorf("Unexpected table limits annotation: %+v", limits)
+	}
+	if v := limits.Children[1]; v.Label != "varuint32" || v.Value != "5682" {
+		t.Errorf("Unexpected table initial size annotation: %+v", v)
+	}
+
+	if _, err := json.Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkAnnotations checks that the annotations cover the range [start, end)
+// without gaps or overlaps.
+func checkAnnotations(t *testing.T, as []*Annotation, start, end int) {
+	t.Helper()
+	offset := start
+	for _, a := range as {
+		if a.Offset != offset {
+			t.Fatalf("%s at 0x%x: expected offset 0x%x", a.Label, a.Offset, offset)
+		}
+		if len(a.Children) > 0 {
+			checkAnnotations(t, a.Children, a.Offset, a.Offset+a.Size)
+		}
+		offset += a.Size
+	}
+	if offset != end {
+		t.Fatalf("Annotations end at 0x%x, expected 0x%x", offset, end)
+	}
+}
+
+func TestAnnotateError(t *testing.T) {
+	b := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x85}
+
+	m, err := Annotate(bytes.NewReader(b))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if m.Error != err.Error() {
+		t.Errorf("Error does not match; expected %q, actual %q", err, m.Error)
+	}
+	checkAnnotations(t, m.Annotations, 0, len(b))
+
+	last := m.Annotations[1].Children[1]
+	if last.Label != "error" || last.Offset != 9 || last.Size != 1 {
+		t.Errorf("Unexpected error annotation: %+v", last)
+	}
+}
@@ -2,91 +2,125 @@ package wasm
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
+	"reflect"
+
+	"github.com/akupila/go-wasm/internal/leb128"
 )
 
 func read(r io.Reader, v interface{}) error {
-	return binary.Read(r, binary.LittleEndian, v)
+	if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+		return err
+	}
+	if r := traced(r); r != nil {
+		v := reflect.Indirect(reflect.ValueOf(v)).Interface()
+		name := fmt.Sprintf("%T", v)
+		if _, ok := v.([]byte); ok {
+			name = "bytes"
+		}
+		r.emit(TraceRead, name, v)
+	}
+	return nil
 }
 
 func readByte(r io.Reader) (byte, error) {
-	b := make([]byte, 1)
-	if _, err := r.Read(b); err != nil {
+	if br, ok := r.(io.ByteReader); ok {
+		return br.ReadByte()
+	}
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
 		return 0, err
 	}
 	return b[0], nil
 }
 
-func readUntil(r io.Reader, delim byte, v *[]byte) error {
-	for {
-		b, err := readByte(r)
-		if err != nil {
-			return err
-		}
-		*v = append(*v, b)
-		if b == byte(delim) {
-			break
-		}
+// readValueType reads a value type. Value types are encoded as single bytes,
+// the byte is stored as is.
+func readValueType(r io.Reader, v *ValueType) error {
+	b, err := readByte(r)
+	if err != nil {
+		return err
 	}
+	*v = ValueType(b)
+	traceValue(r, "value type", v.String())
 	return nil
 }
 
 func readVarUint1(r io.Reader, v *uint8) error {
-	return read(r, v)
+	n, err := leb128.ReadUint(r, 1)
+	if err != nil {
+		return err
+	}
+	*v = uint8(n)
+	traceValue(r, "varuint1", *v)
+	return nil
 }
 
 func readVarUint7(r io.Reader, v *uint8) error {
-	return read(r, v)
+	n, err := leb128.ReadUint(r, 7)
+	if err != nil {
+		return err
+	}
+	*v = uint8(n)
+	traceValue(r, "varuint7", *v)
+	return nil
 }
 
 func readVarUint32(r io.Reader, v *uint32) error {
-	var shift uint32
-	for {
-		b, err := readByte(r)
-		if err != nil {
-			return err
-		}
-		*v |= uint32(b&0x7F) << shift
-		if (b & 0x80) == 0 {
-			break
-		}
-		shift += 7
+	rd, _ := r.(*reader)
+	var start int
+	if rd != nil {
+		start = rd.i
+	}
+	n, err := leb128.ReadUint(r, 32)
+	if err != nil {
+		return err
 	}
+	*v = uint32(n)
+	if rd != nil && rd.sec != nil {
+		rd.sec.recordUint(*v, rd.i-start)
+	}
+	traceValue(r, "varuint32", *v)
+	return nil
+}
 
+func readVarUint64(r io.Reader, v *uint64) error {
+	n, err := leb128.ReadUint(r, 64)
+	if err != nil {
+		return err
+	}
+	*v = n
+	traceValue(r, "varuint64", *v)
 	return nil
 }
 
 func readVarInt7(r io.Reader, v *int8) error {
-	if err := read(r, v); err != nil {
+	n, err := leb128.ReadInt(r, 7)
+	if err != nil {
 		return err
 	}
-	*v &= 0x7F
+	*v = int8(n)
+	traceValue(r, "varint7", *v)
 	return nil
 }
 
 func readVarInt32(r io.Reader, v *int32) error {
-	var shift uint32
-	for {
-		b, err := readByte(r)
-		if err != nil {
-			return err
-		}
-		*v |= int32(b&0x7F) << shift
-		if (b & 0x80) == 0 {
-			break
-		}
-		shift += 7
+	n, err := leb128.ReadInt(r, 32)
+	if err != nil {
+		return err
 	}
-
+	*v = int32(n)
+	traceValue(r, "varint32", *v)
 	return nil
 }
 
-// varUint32Size returns the size in bytes of a varuint32
-func varUint32Size(v uint32) int {
-	s := 0
-	for v > 0 {
-		s++
-		v = v >> 8
+func readVarInt64(r io.Reader, v *int64) error {
+	n, err := leb128.ReadInt(r, 64)
+	if err != nil {
+		return err
 	}
-	return s
+	*v = n
+	traceValue(r, "varint64", *v)
+	return nil
 }
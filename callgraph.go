@@ -0,0 +1,183 @@
+package wasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	opCall         = 0x10
+	opCallIndirect = 0x11
+)
+
+// A CallGraph describes the calls between the functions in a module.
+type CallGraph struct {
+	// Funcs contains a node for every function in the function index space,
+	// indexed by the function index. Imported functions come first, followed
+	// by the functions defined in the module.
+	Funcs []CallNode
+}
+
+// A CallNode is a function in a CallGraph.
+type CallNode struct {
+	// Index is the index of the function in the function index space.
+	Index uint32
+
+	// Name is the name of the function from the name section. Imported
+	// functions without a name are named module.field.
+	Name string
+
+	// Imported is true if the function is imported. Imported functions do
+	// not call other functions.
+	Imported bool
+
+	// Calls contains the indices of the functions called directly by the
+	// function, in ascending order.
+	Calls []uint32
+
+	// IndirectCalls contains the indices of the functions that may be called
+	// with call_indirect, in ascending order. These are the functions
+	// referenced by the element segments with a signature matching the call.
+	IndirectCalls []uint32
+}
+
+// CallGraph decodes the calls in all function bodies and returns the call
+// graph of the module.
+func (m *Module) CallGraph() (*CallGraph, error) {
+	var (
+		types    []FuncType
+		funcs    []uint32 // type index of every function
+		code     *SectionCode
+		names    *NameMap
+		elements *SectionElement
+	)
+
+	var imported int
+	var fnames []string
+	for _, s := range m.Sections {
+		switch s := s.(type) {
+		case *SectionType:
+			types = s.Entries
+		case *SectionImport:
+			for _, e := range s.Entries {
+				if e.Kind != ExtKindFunction {
+					continue
+				}
+				funcs = append(funcs, e.FunctionType.Index)
+				fnames = append(fnames, e.Module+"."+e.Field)
+				imported++
+			}
+		case *SectionFunction:
+			funcs = append(funcs, s.Types...)
+		case *SectionCode:
+			code = s
+		case *SectionElement:
+			elements = s
+		case *SectionName:
+			if s.Functions != nil {
+				names = s.Functions
+			}
+		}
+	}
+
+	g := &CallGraph{Funcs: make([]CallNode, len(funcs))}
+	for i := range g.Funcs {
+		g.Funcs[i].Index = uint32(i)
+		g.Funcs[i].Imported = i < imported
+		if i < imported {
+			g.Funcs[i].Name = fnames[i]
+		}
+	}
+	if names != nil {
+		for _, n := range names.Names {
+			if int(n.Index) < len(g.Funcs) {
+				g.Funcs[n.Index].Name = n.Name
+			}
+		}
+	}
+
+	// Functions in the table that may be called with call_indirect, by
+	// signature.
+	indirect := make(map[string][]uint32)
+	if elements != nil {
+		seen := make(map[uint32]bool)
+		for _, e := range elements.Entries {
+			for _, fi := range e.Elems {
+				if seen[fi] || int(fi) >= len(funcs) || int(funcs[fi]) >= len(types) {
+					continue
+				}
+				seen[fi] = true
+				sig := signature(types[funcs[fi]])
+				indirect[sig] = append(indirect[sig], fi)
+			}
+		}
+	}
+
+	if code == nil {
+		return g, nil
+	}
+	if len(code.Bodies) != len(funcs)-imported {
+		return nil, fmt.Errorf("number of function bodies (%d) does not match number of functions (%d)", len(code.Bodies), len(funcs)-imported)
+	}
+
+	for i, b := range code.Bodies {
+		n := &g.Funcs[imported+i]
+		calls := make(map[uint32]bool)
+		sigs := make(map[string]bool)
+		err := decodeCode(b.Code, func(in Instruction) error {
+			switch in.Op {
+			case opCall:
+				calls[uint32(in.Immediates[0])] = true
+			case opCallIndirect:
+				if int(in.Immediates[0]) >= len(types) {
+					return fmt.Errorf("0x%x: type index %d out of range", in.Offset, in.Immediates[0])
+				}
+				sigs[signature(types[in.Immediates[0]])] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("function %d: %v", n.Index, err)
+		}
+
+		for fi := range calls {
+			n.Calls = append(n.Calls, fi)
+		}
+		sortIndices(n.Calls)
+
+		for sig := range sigs {
+			n.IndirectCalls = append(n.IndirectCalls, indirect[sig]...)
+		}
+		sortIndices(n.IndirectCalls)
+	}
+
+	return g, nil
+}
+
+// Callers returns the indices of the functions that call the function with
+// index fi, directly or indirectly, in ascending order.
+func (g *CallGraph) Callers(fi uint32) []uint32 {
+	var callers []uint32
+	for _, n := range g.Funcs {
+		if containsIndex(n.Calls, fi) || containsIndex(n.IndirectCalls, fi) {
+			callers = append(callers, n.Index)
+		}
+	}
+	return callers
+}
+
+// signature returns a string that is equal for all function types with the
+// same parameters and results.
+func signature(t FuncType) string {
+	return fmt.Sprintf("%v%v", t.Params, t.ReturnTypes)
+}
+
+func sortIndices(s []uint32) {
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+}
+
+// containsIndex reports whether the sorted slice s contains v.
+func containsIndex(s []uint32, v uint32) bool {
+	i := sort.Search(len(s), func(i int) bool { return s[i] >= v })
+	return i < len(s) && s[i] == v
+}
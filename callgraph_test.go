@@ -0,0 +1,47 @@
+package wasm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallGraph(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := mod.CallGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.Funcs) != 1600 {
+		t.Fatalf("Expected 1600 functions, got %d", len(g.Funcs))
+	}
+
+	imp := g.Funcs[2]
+	if !imp.Imported || imp.Name != "go.runtime.wasmWrite" {
+		t.Errorf("Expected function 2 to be imported go.runtime.wasmWrite, got %+v", imp)
+	}
+
+	n := g.Funcs[20]
+	if n.Name != "sync_atomic.AddInt32" {
+		t.Errorf("Expected function 20 to be named sync_atomic.AddInt32, got %q", n.Name)
+	}
+	if !reflect.DeepEqual(n.Calls, []uint32{37}) {
+		t.Errorf("Expected sync_atomic.AddInt32 to call [37], got %v", n.Calls)
+	}
+
+	callers := g.Callers(37)
+	if len(callers) == 0 || callers[0] != 20 {
+		t.Errorf("Expected function 20 to be the first caller of 37, got %v", callers)
+	}
+
+	if len(g.Funcs[864].IndirectCalls) == 0 {
+		t.Errorf("Expected %s to have indirect calls", g.Funcs[864].Name)
+	}
+}
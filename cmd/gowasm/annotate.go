@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func runAnnotate(args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	indent := fs.Bool("indent", false, "indent the JSON output")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("open file: %v", err)
+	}
+	defer f.Close()
+
+	// The map of a file that fails to parse is still written; the error is
+	// included in it.
+	m, parseErr := wasm.Annotate(f)
+
+	enc := json.NewEncoder(os.Stdout)
+	if *indent {
+		enc.SetIndent("", "\t")
+	}
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	return parseErr
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runCanonicalABI(args []string) error {
+	fs := flag.NewFlagSet("cabi", flag.ExitOnError)
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+
+	problems := analysis.CheckCanonicalABI(mod)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	fmt.Println("ok")
+	return nil
+}
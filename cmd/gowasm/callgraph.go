@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func runCallGraph(args []string) error {
+	fs := flag.NewFlagSet("callgraph", flag.ExitOnError)
+	indirect := fs.Bool("indirect", false, "include possible call_indirect targets as dashed edges")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+
+	g, err := mod.CallGraph()
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	writeDOT(w, g, *indirect)
+	return w.Flush()
+}
+
+// writeDOT writes the call graph in the graphviz DOT format.
+func writeDOT(w *bufio.Writer, g *wasm.CallGraph, indirect bool) {
+	fmt.Fprintln(w, "digraph callgraph {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for _, n := range g.Funcs {
+		name := n.Name
+		if name == "" {
+			name = fmt.Sprintf("func[%d]", n.Index)
+		}
+		attrs := ""
+		if n.Imported {
+			attrs = ", style=dashed"
+		}
+		fmt.Fprintf(w, "\tf%d [label=%s%s];\n", n.Index, strconv.Quote(name), attrs)
+	}
+	for _, n := range g.Funcs {
+		for _, c := range n.Calls {
+			fmt.Fprintf(w, "\tf%d -> f%d;\n", n.Index, c)
+		}
+		if !indirect {
+			continue
+		}
+		for _, c := range n.IndirectCalls {
+			fmt.Fprintf(w, "\tf%d -> f%d [style=dashed];\n", n.Index, c)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runDeadCode(args []string) error {
+	fs := flag.NewFlagSet("deadcode", flag.ExitOnError)
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+
+	r, err := analysis.Reachable(mod)
+	if err != nil {
+		return err
+	}
+	g, err := mod.CallGraph()
+	if err != nil {
+		return err
+	}
+
+	var bodies []wasm.FunctionBody
+	if s := mod.CodeSection(); s != nil {
+		bodies = s.Bodies
+	}
+	imported := len(g.Funcs) - len(bodies)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	funcs := r.UnreachableFuncs()
+	var size int
+	fmt.Fprintf(w, "Unreachable functions: %d\n", len(funcs))
+	for _, fi := range funcs {
+		n := g.Funcs[fi]
+		if n.Imported {
+			fmt.Fprintf(w, "  %d\t%s\timported\n", fi, n.Name)
+			continue
+		}
+		b := bodies[int(fi)-imported]
+		size += len(b.Code)
+		fmt.Fprintf(w, "  %d\t%s\t%d bytes\n", fi, n.Name, len(b.Code))
+	}
+	fmt.Fprintf(w, "Unreachable code: %d bytes\n", size)
+
+	fmt.Fprintf(w, "Unreachable globals: %v\n", r.UnreachableGlobals())
+
+	var elems int
+	for _, seg := range r.Elements {
+		for _, ok := range seg {
+			if !ok {
+				elems++
+			}
+		}
+	}
+	fmt.Fprintf(w, "Unreachable table elements: %d\n", elems)
+	fmt.Fprintf(w, "Unreachable data segments: %v\n", r.UnreachableData())
+	return w.Flush()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	code := fs.Bool("d", false, "disassemble the function bodies")
+	data := fs.Bool("s", false, "print the contents of the data segments and custom sections")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	return wasm.WriteDump(os.Stdout, mod, wasm.DumpOptions{Code: *code, Data: *data})
+}
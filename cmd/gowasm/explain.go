@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// sectionPurpose describes what each section does, keyed by section name.
+var sectionPurpose = map[string]string{
+	"Custom":   "Custom sections carry data that is not needed to run the module, such as debug information or metadata added by the compiler.",
+	"Type":     "The type section declares the function signatures used in the module. Functions and imports refer to a signature by its index.",
+	"Import":   "The import section lists everything the host must provide before the module can be instantiated.",
+	"Function": "The function section declares the signature of every function defined in the module. The bodies are in the code section.",
+	"Table":    "The table section declares tables of function references, used to call functions indirectly (e.g. function pointers or virtual methods).",
+	"Memory":   "The memory section declares the linear memory of the module: a contiguous, byte addressable array that the module reads and writes.",
+	"Global":   "The global section declares global variables and their initial values.",
+	"Export":   "The export section lists what the module makes available to the host.",
+	"Start":    "The start section names a function that is called automatically when the module is instantiated.",
+	"Element":  "The element section initializes tables with function references.",
+	"Code":     "The code section contains the bytecode of every function defined in the module. This is where the program lives.",
+	"Data":     "The data section contains data that is copied into linear memory when the module is instantiated, such as string constants.",
+}
+
+var kindNames = map[wasm.ExternalKind]string{
+	wasm.ExtKindFunction: "function",
+	wasm.ExtKindTable:    "table",
+	wasm.ExtKindMemory:   "memory",
+	wasm.ExtKindGlobal:   "global",
+}
+
+func runExplain(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	top := fs.Int("top", 5, "number of notable entries to show per section")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *top < 0 {
+		fs.Usage()
+		return fmt.Errorf("-top must not be negative")
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	e := &explainer{w: w, mod: mod, top: *top}
+	if err := e.explain(file); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+type explainer struct {
+	w   io.Writer
+	mod *wasm.Module
+	top int
+
+	types   []wasm.FuncType
+	imports []wasm.ImportEntry
+	graph   *wasm.CallGraph
+}
+
+func (e *explainer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(e.w, format, args...)
+}
+
+func (e *explainer) explain(file string) error {
+	for _, s := range e.mod.Sections {
+		switch s := s.(type) {
+		case *wasm.SectionType:
+			e.types = s.Entries
+		case *wasm.SectionImport:
+			e.imports = s.Entries
+		}
+	}
+
+	g, err := e.mod.CallGraph()
+	if err != nil {
+		return err
+	}
+	e.graph = g
+
+	e.printf("%s is a WebAssembly module with %d sections.\n", file, len(e.mod.Sections))
+	e.printf("A module is a sequence of sections; each has an id, a size and a payload.\n")
+
+	var size uint32
+	for i, s := range e.mod.Sections {
+		size += s.Size()
+		e.printf("\n== Section %d: %s (%d bytes) ==\n\n", i, s.Name(), s.Size())
+		if p, ok := sectionPurpose[s.Name()]; ok {
+			e.printf("%s\n\n", p)
+		}
+		e.section(s)
+	}
+
+	e.summary(size)
+	return nil
+}
+
+func (e *explainer) section(s wasm.Section) {
+	switch s := s.(type) {
+	case *wasm.SectionCustom:
+		e.printf("This custom section is named %q and holds %d bytes of data.\n", s.SectionName, len(s.Payload))
+	case *wasm.SectionName:
+		e.printf("This is the name section, which maps indices to human readable names for debugging.\n")
+		if s.Functions != nil {
+			e.printf("It names %d functions, which is why the function names below are readable.\n", len(s.Functions.Names))
+		}
+	case *wasm.SectionType:
+		e.printf("The module uses %d distinct signatures.\n", len(s.Entries))
+	case *wasm.SectionImport:
+		e.explainImports(s)
+	case *wasm.SectionFunction:
+		e.printf("The module defines %d functions of its own.\n", len(s.Types))
+	case *wasm.SectionTable:
+		for i, t := range s.Entries {
+			kind := "functions"
+			if t.ElemType == wasm.ExternRef {
+				kind = "external references"
+			}
+			e.printf("Table %d of %s: %s.\n", i, kind, limits(t.Limits, "elements"))
+		}
+	case *wasm.SectionMemory:
+		for i, m := range s.Entries {
+			e.printf("Memory %d: %s, each page is 64KiB (%s initially).\n", i, limits(m.Limits, "pages"), bytesize(uint64(m.Limits.Initial)*65536))
+		}
+	case *wasm.SectionGlobal:
+		mut := 0
+		for _, g := range s.Globals {
+			if g.Type.Mutable {
+				mut++
+			}
+		}
+		e.printf("There are %d globals, %d of them mutable. Compilers typically use mutable globals for the stack pointer.\n", len(s.Globals), mut)
+	case *wasm.SectionExport:
+		for _, x := range s.Entries {
+			e.printf("  - %s %q", kindNames[x.Kind], x.Field)
+			if x.Kind == wasm.ExtKindFunction {
+				e.printf(" (function %s)", e.funcName(x.Index))
+			}
+			e.printf("\n")
+		}
+	case *wasm.SectionStart:
+		e.printf("Function %s runs when the module is instantiated.\n", e.funcName(s.Index))
+	case *wasm.SectionElement:
+		n := 0
+		for _, el := range s.Entries {
+			n += len(el.Elems)
+		}
+		e.printf("%d segments place %d function references in tables.\n", len(s.Entries), n)
+	case *wasm.SectionCode:
+		e.explainCode(s)
+	case *wasm.SectionData:
+		e.explainData(s)
+	}
+}
+
+func (e *explainer) explainImports(s *wasm.SectionImport) {
+	byModule := make(map[string][]wasm.ImportEntry)
+	var modules []string
+	for _, imp := range s.Entries {
+		if _, ok := byModule[imp.Module]; !ok {
+			modules = append(modules, imp.Module)
+		}
+		byModule[imp.Module] = append(byModule[imp.Module], imp)
+	}
+
+	e.printf("The module needs %d imports from %d host module(s):\n", len(s.Entries), len(modules))
+	for _, m := range modules {
+		e.printf("\n  %q provides %d imports:\n", m, len(byModule[m]))
+		for _, imp := range byModule[m] {
+			e.printf("    - %s %s", kindNames[imp.Kind], imp.Field)
+			if imp.Kind == wasm.ExtKindFunction && int(imp.FunctionType.Index) < len(e.types) {
+				e.printf(" %s", e.types[imp.FunctionType.Index])
+			}
+			e.printf("\n")
+		}
+	}
+	switch {
+	case byModule["go"] != nil:
+		e.printf("\nImports from \"go\" are provided by wasm_exec.js, which ships with the Go distribution.\n")
+	case byModule["wasi_snapshot_preview1"] != nil || byModule["wasi_unstable"] != nil:
+		e.printf("\nImports from \"wasi_*\" are provided by any WASI compatible runtime.\n")
+	}
+}
+
+func (e *explainer) explainCode(s *wasm.SectionCode) {
+	imported := len(e.graph.Funcs) - len(s.Bodies)
+
+	idx := make([]int, len(s.Bodies))
+	var total int
+	for i := range s.Bodies {
+		idx[i] = i
+		total += len(s.Bodies[i].Code)
+	}
+	if len(s.Bodies) == 0 {
+		return
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return len(s.Bodies[idx[a]].Code) > len(s.Bodies[idx[b]].Code)
+	})
+
+	e.printf("%d function bodies, %s of bytecode in total (%s on average).\n", len(s.Bodies), bytesize(uint64(total)), bytesize(uint64(total/len(s.Bodies))))
+	e.printf("Function indices start at %d because imported functions come first in the index space.\n", imported)
+	e.printf("\nThe largest functions are:\n")
+	for _, i := range idx[:min(e.top, len(idx))] {
+		fi := uint32(imported + i)
+		e.printf("  - %s: %s\n", e.funcName(fi), bytesize(uint64(len(s.Bodies[i].Code))))
+	}
+
+	callers := make(map[uint32]int)
+	for _, n := range e.graph.Funcs {
+		for _, c := range n.Calls {
+			callers[c]++
+		}
+	}
+	called := make([]uint32, 0, len(callers))
+	for fi := range callers {
+		called = append(called, fi)
+	}
+	sort.Slice(called, func(a, b int) bool {
+		if callers[called[a]] != callers[called[b]] {
+			return callers[called[a]] > callers[called[b]]
+		}
+		return called[a] < called[b]
+	})
+	if len(called) > 0 {
+		e.printf("\nThe functions called from the most places are:\n")
+		for _, fi := range called[:min(e.top, len(called))] {
+			e.printf("  - %s: called by %d functions\n", e.funcName(fi), callers[fi])
+		}
+	}
+}
+
+func (e *explainer) explainData(s *wasm.SectionData) {
+	var total int
+	for _, d := range s.Entries {
+		total += len(d.Data)
+	}
+	e.printf("%d segments with %s of data in total.\n", len(s.Entries), bytesize(uint64(total)))
+	for i, d := range s.Entries {
+		if i == e.top {
+			e.printf("  ... and %d more\n", len(s.Entries)-i)
+			break
+		}
+		e.printf("  - segment %d: %s", i, bytesize(uint64(len(d.Data))))
+		if v, err := wasm.Eval(d.Offset, nil); err == nil {
+			if off, ok := v.(int32); ok {
+				e.printf(" placed at address 0x%x-0x%x", uint32(off), uint32(off)+uint32(len(d.Data)))
+			}
+		}
+		e.printf("\n")
+	}
+}
+
+func (e *explainer) summary(size uint32) {
+	e.printf("\n== Summary ==\n\n")
+	var code, data uint32
+	for _, s := range e.mod.Sections {
+		switch s.(type) {
+		case *wasm.SectionCode:
+			code += s.Size()
+		case *wasm.SectionData:
+			data += s.Size()
+		}
+	}
+	if size > 0 {
+		e.printf("Code makes up %d%% and data %d%% of the section payloads.\n", 100*uint64(code)/uint64(size), 100*uint64(data)/uint64(size))
+	}
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, imp := range e.imports {
+		if !seen[imp.Module] {
+			seen[imp.Module] = true
+			hosts = append(hosts, fmt.Sprintf("%q", imp.Module))
+		}
+	}
+	if len(hosts) > 0 {
+		e.printf("To run it, a host must provide the %s import module(s).\n", strings.Join(hosts, ", "))
+	} else {
+		e.printf("The module has no imports and can run in any WebAssembly runtime.\n")
+	}
+}
+
+func (e *explainer) funcName(fi uint32) string {
+	if int(fi) < len(e.graph.Funcs) && e.graph.Funcs[fi].Name != "" {
+		return fmt.Sprintf("%d %q", fi, e.graph.Funcs[fi].Name)
+	}
+	return fmt.Sprintf("%d", fi)
+}
+
+func limits(l wasm.ResizableLimits, unit string) string {
+	if !l.HasMaximum {
+		return fmt.Sprintf("%d %s initially, no maximum", l.Initial, unit)
+	}
+	return fmt.Sprintf("%d %s initially, at most %d", l.Initial, unit, l.Maximum)
+}
+
+// bytesize formats n bytes in a human readable form.
+func bytesize(n uint64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
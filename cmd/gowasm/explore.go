@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// maxIndent limits the indentation of nested blocks in the code of a
+// function, which is deep in the dispatch loop of Go functions.
+const maxIndent = 16
+
+const exploreHelp = "↑↓ move  enter open  ← back  / search  n next  g go to function  s symbols  q quit"
+
+func runExplore(args []string) error {
+	fs := flag.NewFlagSet("explore", flag.ExitOnError)
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("open file: %v", err)
+	}
+	mod, err := wasm.ParseBytes(b)
+	if err != nil {
+		return err
+	}
+	e, err := newExplorer(file, mod, b)
+	if err != nil {
+		return err
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	// Switch to the alternate screen and hide the cursor while exploring.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	in := bufio.NewReader(os.Stdin)
+	out := bufio.NewWriter(os.Stdout)
+	for {
+		width, height := terminalSize()
+		e.render(out, width, height)
+		if err := out.Flush(); err != nil {
+			return err
+		}
+		key, err := readKey(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !e.handle(key) {
+			return nil
+		}
+	}
+}
+
+// An exploreLine is a line of a view of the explorer. Pressing enter on it
+// opens the view returned by open, if it's set.
+type exploreLine struct {
+	text string
+	open func() *exploreView
+}
+
+// An exploreView is a screen of the explorer, such as the list of sections or
+// the code of a function. Lines are created as they're displayed or
+// searched, so large sections can be viewed as a hex dump.
+type exploreView struct {
+	title string
+	count int
+	line  func(i int) exploreLine
+
+	cursor int
+	top    int // first line on the screen
+}
+
+func listView(title string, lines []exploreLine) *exploreView {
+	return &exploreView{
+		title: title,
+		count: len(lines),
+		line:  func(i int) exploreLine { return lines[i] },
+	}
+}
+
+// explorer is the state of gowasm explore: the stack of open views, with
+// the current view last, and the prompt being typed, if any.
+type explorer struct {
+	mod   *wasm.Module
+	data  []byte // the file, for hex dumps
+	graph *wasm.CallGraph
+
+	types    []wasm.FuncType
+	funcs    []uint32 // type index of every function
+	imported uint32
+	exports  map[uint32][]string // export names of every function
+
+	views  []*exploreView
+	rows   int // lines of the view on the screen
+	status string
+	search string
+
+	// prompt is shown instead of the status while input is typed; when
+	// enter is pressed, the input is passed to submit.
+	prompt string
+	input  string
+	submit func(s string)
+}
+
+func newExplorer(file string, mod *wasm.Module, data []byte) (*explorer, error) {
+	g, err := mod.CallGraph()
+	if err != nil {
+		return nil, err
+	}
+	e := &explorer{mod: mod, data: data, graph: g, exports: make(map[uint32][]string), status: exploreHelp}
+	for _, s := range mod.Sections {
+		switch s := s.(type) {
+		case *wasm.SectionType:
+			e.types = s.Entries
+		case *wasm.SectionImport:
+			for _, imp := range s.Entries {
+				if imp.Kind == wasm.ExtKindFunction {
+					e.funcs = append(e.funcs, imp.FunctionType.Index)
+					e.imported++
+				}
+			}
+		case *wasm.SectionFunction:
+			e.funcs = append(e.funcs, s.Types...)
+		case *wasm.SectionExport:
+			for _, exp := range s.Entries {
+				if exp.Kind == wasm.ExtKindFunction {
+					e.exports[exp.Index] = append(e.exports[exp.Index], exp.Field)
+				}
+			}
+		}
+	}
+	e.views = []*exploreView{e.sectionsView(file)}
+	return e, nil
+}
+
+// handle updates the state for a key press. It returns false if the explorer
+// should quit.
+func (e *explorer) handle(key string) bool {
+	if e.submit != nil {
+		switch key {
+		case "enter":
+			submit := e.submit
+			e.submit = nil
+			submit(e.input)
+		case "esc", "ctrl-c":
+			e.submit = nil
+			e.status = exploreHelp
+		case "backspace":
+			if e.input != "" {
+				_, n := utf8.DecodeLastRuneInString(e.input)
+				e.input = e.input[:len(e.input)-n]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				e.input += key
+			}
+		}
+		return true
+	}
+
+	v := e.view()
+	e.status = exploreHelp
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "up", "k":
+		v.cursor--
+	case "down", "j":
+		v.cursor++
+	case "pgup":
+		v.cursor -= e.rows
+	case "pgdn", " ":
+		v.cursor += e.rows
+	case "home":
+		v.cursor = 0
+	case "end":
+		v.cursor = v.count - 1
+	case "enter", "right", "l":
+		if v.count > 0 {
+			if l := v.line(v.cursor); l.open != nil {
+				if next := l.open(); next != nil {
+					e.views = append(e.views, next)
+				}
+			}
+		}
+	case "left", "backspace", "h", "esc":
+		if len(e.views) > 1 {
+			e.views = e.views[:len(e.views)-1]
+		}
+	case "/":
+		e.ask("/", func(s string) {
+			if s != "" {
+				e.search = s
+			}
+			e.find()
+		})
+	case "n":
+		e.find()
+	case "g":
+		e.ask("go to function: ", func(s string) {
+			fi, err := findFunction(e.mod, s)
+			if err != nil {
+				e.status = err.Error()
+				return
+			}
+			if v := e.funcView(fi); v != nil {
+				e.views = append(e.views, v)
+			}
+		})
+	case "s":
+		e.views = append(e.views, e.symbolsView())
+	}
+	if v.cursor >= v.count {
+		v.cursor = v.count - 1
+	}
+	if v.cursor < 0 {
+		v.cursor = 0
+	}
+	return true
+}
+
+func (e *explorer) view() *exploreView {
+	return e.views[len(e.views)-1]
+}
+
+// ask shows a prompt and calls submit with the input.
+func (e *explorer) ask(prompt string, submit func(s string)) {
+	e.prompt, e.input, e.submit = prompt, "", submit
+}
+
+// find moves the cursor to the next line of the view that contains the
+// search text, ignoring case.
+func (e *explorer) find() {
+	v := e.view()
+	if e.search == "" || v.count == 0 {
+		return
+	}
+	q := strings.ToLower(e.search)
+	for i := 1; i <= v.count; i++ {
+		j := (v.cursor + i) % v.count
+		if strings.Contains(strings.ToLower(v.line(j).text), q) {
+			v.cursor = j
+			e.status = fmt.Sprintf("/%s: line %d of %d", e.search, j+1, v.count)
+			return
+		}
+	}
+	e.status = fmt.Sprintf("/%s: not found", e.search)
+}
+
+// render draws the title, the visible lines of the current view and the
+// status line.
+func (e *explorer) render(w *bufio.Writer, width, height int) {
+	v := e.view()
+	e.rows = height - 2
+	if v.cursor < v.top {
+		v.top = v.cursor
+	}
+	if v.cursor >= v.top+e.rows {
+		v.top = v.cursor - e.rows + 1
+	}
+
+	titles := make([]string, len(e.views))
+	for i, v := range e.views {
+		titles[i] = v.title
+	}
+	w.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(w, "\x1b[7m%s\x1b[0m\r\n", pad(strings.Join(titles, " > "), width))
+	for i := v.top; i < v.top+e.rows; i++ {
+		if i < v.count {
+			text := pad(v.line(i).text, width)
+			if i == v.cursor {
+				text = "\x1b[7m" + text + "\x1b[0m"
+			}
+			w.WriteString(text)
+		}
+		w.WriteString("\r\n")
+	}
+	if e.submit != nil {
+		w.WriteString(pad(e.prompt+e.input, width))
+	} else {
+		w.WriteString(pad(e.status, width))
+	}
+}
+
+// pad truncates or pads s with spaces to width characters.
+func pad(s string, width int) string {
+	s = strings.Replace(s, "\t", " ", -1)
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// funcName returns the name of function fi in angle brackets, or an empty
+// string if it has none.
+func (e *explorer) funcName(fi uint32) string {
+	if int(fi) < len(e.graph.Funcs) && e.graph.Funcs[fi].Name != "" {
+		return "<" + e.graph.Funcs[fi].Name + ">"
+	}
+	if names := e.exports[fi]; len(names) > 0 {
+		return "<" + names[0] + ">"
+	}
+	return ""
+}
+
+// typeString returns the signature of type ti, which may not exist in a
+// malformed module.
+func (e *explorer) typeString(ti uint32) string {
+	if int(ti) >= len(e.types) {
+		return fmt.Sprintf("<invalid type %d>", ti)
+	}
+	return e.types[ti].String()
+}
+
+// openFunc returns a function that opens the view of function fi.
+func (e *explorer) openFunc(fi uint32) func() *exploreView {
+	return func() *exploreView { return e.funcView(fi) }
+}
+
+func (e *explorer) sectionsView(file string) *exploreView {
+	var lines []exploreLine
+	for _, s := range e.mod.Sections {
+		s := s
+		text := fmt.Sprintf("%-10s start=0x%08x size=%-9d %s", s.Name(), s.Offset(), s.Size(), sectionSummary(s))
+		lines = append(lines, exploreLine{text: text, open: func() *exploreView { return e.sectionView(s) }})
+	}
+	return listView(file, lines)
+}
+
+// sectionSummary returns the name of a custom section or the number of
+// entries of another section.
+func sectionSummary(s wasm.Section) string {
+	switch s := s.(type) {
+	case *wasm.SectionCustom:
+		return fmt.Sprintf("%q", s.SectionName)
+	case *wasm.SectionName:
+		return fmt.Sprintf("%q", s.SectionName)
+	case *wasm.SectionType:
+		return fmt.Sprintf("%d types", len(s.Entries))
+	case *wasm.SectionImport:
+		return fmt.Sprintf("%d imports", len(s.Entries))
+	case *wasm.SectionFunction:
+		return fmt.Sprintf("%d functions", len(s.Types))
+	case *wasm.SectionTable:
+		return fmt.Sprintf("%d tables", len(s.Entries))
+	case *wasm.SectionMemory:
+		return fmt.Sprintf("%d memories", len(s.Entries))
+	case *wasm.SectionGlobal:
+		return fmt.Sprintf("%d globals", len(s.Globals))
+	case *wasm.SectionExport:
+		return fmt.Sprintf("%d exports", len(s.Entries))
+	case *wasm.SectionStart:
+		return fmt.Sprintf("function %d", s.Index)
+	case *wasm.SectionElement:
+		return fmt.Sprintf("%d segments", len(s.Entries))
+	case *wasm.SectionCode:
+		return fmt.Sprintf("%d bodies", len(s.Bodies))
+	case *wasm.SectionData:
+		return fmt.Sprintf("%d segments", len(s.Entries))
+	}
+	return ""
+}
+
+// sectionView lists the entries of a section. The first line opens a hex
+// dump of the whole section.
+func (e *explorer) sectionView(s wasm.Section) *exploreView {
+	end := s.PayloadOffset() + int64(s.Size())
+	lines := []exploreLine{{
+		text: fmt.Sprintf("[hex dump, %d bytes]", end-s.Offset()),
+		open: func() *exploreView {
+			return hexView(s.Name()+" hex", e.data[s.Offset():end], s.Offset())
+		},
+	}}
+	add := func(open func() *exploreView, format string, args ...interface{}) {
+		lines = append(lines, exploreLine{text: fmt.Sprintf(format, args...), open: open})
+	}
+
+	switch s := s.(type) {
+	case *wasm.SectionType:
+		for i, t := range s.Entries {
+			add(nil, "type[%d] %s", i, t)
+		}
+	case *wasm.SectionImport:
+		var fi uint32
+		for _, imp := range s.Entries {
+			if imp.Kind == wasm.ExtKindFunction {
+				add(nil, "func[%d] %s.%s %s", fi, imp.Module, imp.Field, e.typeString(imp.FunctionType.Index))
+				fi++
+			} else {
+				add(nil, "%s %s.%s", kindNames[imp.Kind], imp.Module, imp.Field)
+			}
+		}
+	case *wasm.SectionFunction:
+		for i, ti := range s.Types {
+			fi := e.imported + uint32(i)
+			add(e.openFunc(fi), "func[%d] %s %s", fi, e.typeString(ti), e.funcName(fi))
+		}
+	case *wasm.SectionTable:
+		for i, t := range s.Entries {
+			add(nil, "table[%d] %s %s", i, t.ElemType, limits(t.Limits, "elements"))
+		}
+	case *wasm.SectionMemory:
+		for i, m := range s.Entries {
+			add(nil, "memory[%d] %s", i, limits(m.Limits, "pages"))
+		}
+	case *wasm.SectionGlobal:
+		for i, g := range s.Globals {
+			add(nil, "global[%d] %s mutable=%v init % x", i, g.Type.ContentType, g.Type.Mutable, g.Init)
+		}
+	case *wasm.SectionExport:
+		for _, exp := range s.Entries {
+			if exp.Kind == wasm.ExtKindFunction {
+				add(e.openFunc(exp.Index), "%q -> func[%d] %s", exp.Field, exp.Index, e.funcName(exp.Index))
+			} else {
+				add(nil, "%q -> %s[%d]", exp.Field, kindNames[exp.Kind], exp.Index)
+			}
+		}
+	case *wasm.SectionStart:
+		add(e.openFunc(s.Index), "start func[%d] %s", s.Index, e.funcName(s.Index))
+	case *wasm.SectionElement:
+		for i, seg := range s.Entries {
+			add(nil, "segment[%d] table=%d count=%d", i, seg.Index, len(seg.Elems))
+			for j, fi := range seg.Elems {
+				add(e.openFunc(fi), "  elem[%d] = func[%d] %s", j, fi, e.funcName(fi))
+			}
+		}
+	case *wasm.SectionCode:
+		for i, body := range s.Bodies {
+			fi := e.imported + uint32(i)
+			add(e.openFunc(fi), "func[%d] size=%d %s", fi, len(body.Code), e.funcName(fi))
+		}
+	case *wasm.SectionData:
+		for i, seg := range s.Entries {
+			seg := seg
+			title := fmt.Sprintf("segment[%d]", i)
+			add(func() *exploreView { return hexView(title, seg.Data, 0) },
+				"%s memory=%d size=%d offset % x", title, seg.Index, len(seg.Data), seg.Offset)
+		}
+	case *wasm.SectionName:
+		if s.Functions != nil {
+			for _, n := range s.Functions.Names {
+				add(e.openFunc(n.Index), "func[%d] <%s>", n.Index, n.Name)
+			}
+		}
+	}
+	return listView(s.Name(), lines)
+}
+
+// symbolsView lists every function with its name, import and exports, to
+// search them.
+func (e *explorer) symbolsView() *exploreView {
+	var lines []exploreLine
+	for _, f := range e.graph.Funcs {
+		text := fmt.Sprintf("func[%d] %s", f.Index, e.funcName(f.Index))
+		if f.Imported {
+			text += " (imported)"
+		}
+		for _, name := range e.exports[f.Index] {
+			text += fmt.Sprintf(" (export %q)", name)
+		}
+		lines = append(lines, exploreLine{text: text, open: e.openFunc(f.Index)})
+	}
+	return listView("Symbols", lines)
+}
+
+// funcView shows the code of function fi, with the offset and bytes of every
+// instruction next to its disassembly. Calls open the view of the callee.
+func (e *explorer) funcView(fi uint32) *exploreView {
+	title := strings.TrimSpace(fmt.Sprintf("func[%d] %s", fi, e.funcName(fi)))
+	if int(fi) >= len(e.funcs) {
+		e.status = fmt.Sprintf("function %d out of range", fi)
+		return nil
+	}
+	code := e.mod.CodeSection()
+	if fi < e.imported || code == nil || int(fi-e.imported) >= len(code.Bodies) {
+		e.status = fmt.Sprintf("%s is imported or has no body", title)
+		return nil
+	}
+	body := code.Bodies[fi-e.imported]
+
+	lines := []exploreLine{{text: "type " + e.typeString(e.funcs[fi])}}
+	for _, l := range body.Locals {
+		lines = append(lines, exploreLine{text: fmt.Sprintf("%d locals of type %s", l.Count, l.Type)})
+	}
+	depth := 0
+	r := wasm.NewCodeReader(body.Code)
+	for {
+		in, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			lines = append(lines, exploreLine{text: fmt.Sprintf("%08x: error: %v", body.CodeOffset()+int64(r.Offset()), err)})
+			break
+		}
+		switch in.Op.String() {
+		case "end", "else":
+			depth--
+		}
+		raw := body.Code[in.Offset:r.Offset()]
+		hex := fmt.Sprintf("% x", raw)
+		if len(raw) > 8 {
+			hex = fmt.Sprintf("% x ...", raw[:8])
+		}
+		l := exploreLine{text: fmt.Sprintf("%08x: %-27s | %s%s", body.CodeOffset()+int64(in.Offset), hex, strings.Repeat("  ", min(maxInt(depth, 0), maxIndent)), in.Format(wasm.TextCurrent))}
+		switch in.Op.String() {
+		case "call", "return_call", "ref.func":
+			callee := uint32(in.Immediates[0])
+			l.text += " " + e.funcName(callee)
+			l.open = e.openFunc(callee)
+		case "block", "loop", "if", "else":
+			depth++
+		}
+		lines = append(lines, l)
+	}
+	return listView(title, lines)
+}
+
+// hexView shows b with 16 bytes per line, starting at offset base.
+func hexView(title string, b []byte, base int64) *exploreView {
+	return &exploreView{
+		title: title,
+		count: (len(b) + 15) / 16,
+		line: func(i int) exploreLine {
+			line := b[i*16:]
+			if len(line) > 16 {
+				line = line[:16]
+			}
+			var sb strings.Builder
+			fmt.Fprintf(&sb, "%08x: ", base+int64(i*16))
+			for j := 0; j < 16; j++ {
+				if j < len(line) {
+					fmt.Fprintf(&sb, "%02x ", line[j])
+				} else {
+					sb.WriteString("   ")
+				}
+			}
+			sb.WriteString(" ")
+			for _, c := range line {
+				if c < 0x20 || c >= 0x7f {
+					c = '.'
+				}
+				sb.WriteByte(c)
+			}
+			return exploreLine{text: sb.String()}
+		},
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runExtract(args []string) error {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	fi := fs.Uint("func", 0, "index of the function to extract")
+	out := fs.String("o", "", "file to write the extracted module to")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		fs.Usage()
+		return fmt.Errorf("-o is required")
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	ext, err := analysis.ExtractFunction(mod, uint32(*fi))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := wasm.Encode(&buf, ext); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*out, buf.Bytes(), 0644)
+}
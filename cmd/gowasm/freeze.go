@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runFreeze(args []string) error {
+	fs := flag.NewFlagSet("freeze", flag.ExitOnError)
+	out := fs.String("f", "", "interface file to write or verify; written to stdout if not set")
+	verify := fs.Bool("verify", false, "verify that the module matches the interface file instead of writing it")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *verify && *out == "" {
+		fs.Usage()
+		return fmt.Errorf("-verify requires -f")
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	lines, err := analysis.Interface(mod)
+	if err != nil {
+		return err
+	}
+
+	if *verify {
+		b, err := ioutil.ReadFile(*out)
+		if err != nil {
+			return err
+		}
+		diff := analysis.CompareInterface(strings.Split(string(b), "\n"), lines)
+		if len(diff) > 0 {
+			for _, l := range diff {
+				fmt.Println(l)
+			}
+			return fmt.Errorf("interface of %s does not match %s", file, *out)
+		}
+		fmt.Println("ok")
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Interface of %s, verify with gowasm freeze -verify.\n", filepath.Base(file))
+	for _, l := range lines {
+		fmt.Fprintln(&buf, l)
+	}
+	if *out == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(*out, buf.Bytes(), 0644)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func runHash(args []string) error {
+	fs := flag.NewFlagSet("hash", flag.ExitOnError)
+	ignoreCustom := fs.Bool("ignore-custom", false, "leave out all custom sections, including the name section")
+	ignore := fs.String("ignore", "", "comma separated names of custom sections to leave out, such as name,producers")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	opts := wasm.HashOptions{IgnoreCustom: *ignoreCustom}
+	if *ignore != "" {
+		for _, name := range strings.Split(*ignore, ",") {
+			opts.Ignore = append(opts.Ignore, strings.TrimSpace(name))
+		}
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	h, err := mod.Hash(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%x  %s\n", h, file)
+	return nil
+}
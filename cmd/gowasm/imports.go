@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runImports(args []string) error {
+	return runExterns("imports", args, analysis.Imports)
+}
+
+func runExports(args []string) error {
+	return runExterns("exports", args, analysis.Exports)
+}
+
+// runExterns prints the imports or exports returned by list.
+func runExterns(name string, args []string, list func(*wasm.Module) ([]analysis.Extern, error)) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	format := fs.String("format", "table", "output format: table, json or csv")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	externs, err := list(mod)
+	if err != nil {
+		return err
+	}
+
+	// Exports have no module name, so the column is left out.
+	withModule := name == "imports"
+	switch *format {
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		if withModule {
+			fmt.Fprint(w, "Module\t")
+		}
+		fmt.Fprintf(w, "Name\tKind\tIndex\tType\n")
+		for _, e := range externs {
+			if withModule {
+				fmt.Fprintf(w, "%s\t", e.Module)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", e.Name, externKinds[e.Kind], e.Index, e.Type)
+		}
+		return w.Flush()
+
+	case "json":
+		type jsonExtern struct {
+			Module string `json:"module,omitempty"`
+			Name   string `json:"name"`
+			Kind   string `json:"kind"`
+			Index  uint32 `json:"index"`
+			Type   string `json:"type"`
+		}
+		out := make([]jsonExtern, len(externs))
+		for i, e := range externs {
+			out[i] = jsonExtern{e.Module, e.Name, externKinds[e.Kind], e.Index, e.Type}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(out)
+
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		header := []string{"name", "kind", "index", "type"}
+		if withModule {
+			header = append([]string{"module"}, header...)
+		}
+		w.Write(header)
+		for _, e := range externs {
+			record := []string{e.Name, externKinds[e.Kind], strconv.FormatUint(uint64(e.Index), 10), e.Type}
+			if withModule {
+				record = append([]string{e.Module}, record...)
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	fs.Usage()
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+// externKinds contains the names of the kinds of imports and exports, as used
+// in the text format.
+var externKinds = map[wasm.ExternalKind]string{
+	wasm.ExtKindFunction: "func",
+	wasm.ExtKindTable:    "table",
+	wasm.ExtKindMemory:   "memory",
+	wasm.ExtKindGlobal:   "global",
+}
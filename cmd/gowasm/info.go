@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runInfo(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	tc, err := analysis.DetectToolchain(mod)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	name := tc.Name
+	if name == "" {
+		name = "unknown, not compiled from Go"
+	}
+	fmt.Fprintf(w, "Toolchain:\t%s\n", strings.TrimSpace(name+" "+tc.Version))
+	if tc.BuildID != "" {
+		fmt.Fprintf(w, "Build ID:\t%s\n", tc.BuildID)
+	}
+	mode := string(tc.Mode)
+	if mode == "" {
+		mode = "unknown"
+	}
+	fmt.Fprintf(w, "Build mode:\t%s\n", mode)
+	fmt.Fprintf(w, "Host:\t%s\n", hostDescription(tc))
+	if len(tc.Evidence) > 0 {
+		fmt.Fprintf(w, "Based on:\t%s\n", strings.Join(tc.Evidence, ", "))
+	}
+	return w.Flush()
+}
+
+// hostDescription describes what the host must provide to run the module.
+func hostDescription(tc *analysis.Toolchain) string {
+	switch tc.ABI {
+	case analysis.ABIGoJS:
+		if tc.Name == "TinyGo" {
+			return "JavaScript, with wasm_exec.js from the TinyGo distribution (targets/wasm_exec.js)"
+		}
+		return "JavaScript, with wasm_exec.js from the Go distribution of the same version (misc/wasm, or lib/wasm since Go 1.24)"
+	case analysis.ABIWASIPreview1:
+		return "a WASI preview 1 runtime, such as wasmtime or wazero"
+	case analysis.ABIWASIUnstable:
+		return "a runtime supporting the legacy wasi_unstable interface"
+	case analysis.ABINone:
+		return "nothing, the module has no imports"
+	}
+	return "unknown, see gowasm imports"
+}
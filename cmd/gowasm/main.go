@@ -3,14 +3,61 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"sort"
 	"text/tabwriter"
 
 	wasm "github.com/akupila/go-wasm"
 )
 
+// A command is a gowasm subcommand.
+type command struct {
+	// usage is a one line description of the command.
+	usage string
+
+	// run runs the command with the arguments following the command name.
+	run func(args []string) error
+}
+
+var commands = map[string]command{
+	"annotate":  {"write a JSON map of the byte ranges in the file and their meaning", runAnnotate},
+	"cabi":      {"check that the module can be wrapped in a component", runCanonicalABI},
+	"callgraph": {"print the call graph in DOT format", runCallGraph},
+	"deadcode":  {"report functions, globals and data unreachable from the exports", runDeadCode},
+	"dump":      {"print the sections and their entries, like wasm-objdump -x", runDump},
+	"explain":   {"print an annotated walkthrough of the module", runExplain},
+	"explore":   {"browse the sections, functions and bytes of the module interactively", runExplore},
+	"exports":   {"list the exports with their types", runExports},
+	"extract":   {"write a function and its dependencies to a new module", runExtract},
+	"freeze":    {"write the imports and exports to a file, or verify them against it", runFreeze},
+	"hash":      {"print a SHA-256 hash of the module, optionally without custom sections", runHash},
+	"imports":   {"list the imports with their types", runImports},
+	"info":      {"tell which toolchain produced the module and what host it needs", runInfo},
+	"memmap":    {"print where the data segments are placed in memory", runMemMap},
+	"nm":        {"list the symbols of a relocatable object file", runNm},
+	"path":      {"print the call path from an export to an import", runPath},
+	"stats":     {"print the section sizes, op code frequencies and largest functions", runStats},
+	"trace":     {"print every value read by the parser with its offset", runTrace},
+	"validate":  {"check that the module is valid, exiting with status 1 if it isn't", runValidate},
+	"wasi":      {"list the WASI functions the module imports and the capabilities they need", runWASI},
+	"wat":       {"print the module in the text format", runWat},
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	file := flag.String("file", "", "file to parse (.wasm)")
+	embedded := flag.Bool("embedded", false, "find base64 or hex encoded modules embedded in the file, such as JavaScript or HTML")
+	flag.Usage = usage
 	flag.Parse()
 
 	if *file == "" {
@@ -18,25 +65,21 @@ func main() {
 		os.Exit(2)
 	}
 
-	f, err := os.Open(*file)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "open file: %v", err)
-		os.Exit(1)
+	if *embedded {
+		if err := printEmbedded(*file); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-	defer f.Close()
 
-	mod, err := wasm.Parse(f)
+	mod, err := parseFile(*file)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
-	fmt.Fprintf(w, "Index\tName\tSize (bytes)\n")
-	for i, s := range mod.Sections {
-		fmt.Fprintf(w, "%d\t%s\t%d\n", i, s.Name(), s.Size())
-	}
-	w.Flush()
+	printSections(mod)
 
 	// Much more information is available by type asserting the section:
 	// switch section := s.(type) {
@@ -44,3 +87,81 @@ func main() {
 	//         // can now read function bytecode from section.
 	// }
 }
+
+func printSections(mod *wasm.Module) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintf(w, "Index\tName\tOffset\tSize (bytes)\n")
+	for i, s := range mod.Sections {
+		fmt.Fprintf(w, "%d\t%s\t0x%06x\t%d\n", i, s.Name(), s.Offset(), s.Size())
+	}
+	w.Flush()
+}
+
+// printEmbedded prints the sections of the modules embedded in file.
+func printEmbedded(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	modules := wasm.FindEmbedded(b)
+	if len(modules) == 0 {
+		return fmt.Errorf("no embedded modules found in %s", file)
+	}
+	for i, m := range modules {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Module %d: %s at offset %d, %d bytes\n", i, m.Encoding, m.Offset, len(m.Data))
+		if m.Err != nil {
+			fmt.Println(m.Err)
+			continue
+		}
+		printSections(m.Module)
+	}
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage:\n  gowasm -file <file.wasm>\n  gowasm -embedded -file <file.js>\n  gowasm <command> [flags] <file.wasm>\n\nFlags:\n")
+	flag.PrintDefaults()
+
+	fmt.Fprintf(out, "\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", name, commands[name].usage)
+	}
+	w.Flush()
+}
+
+// parseFlags parses the flags of a command. The command takes a single
+// argument, the file to parse, which is returned.
+func parseFlags(fs *flag.FlagSet, args []string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", fmt.Errorf("expected a single file, got %d arguments", fs.NArg())
+	}
+	return fs.Arg(0), nil
+}
+
+func parseFile(name string) (*wasm.Module, error) {
+	return parseFileWithOptions(name, wasm.ParseOptions{})
+}
+
+func parseFileWithOptions(name string, opts wasm.ParseOptions) (*wasm.Module, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %v", err)
+	}
+	defer f.Close()
+
+	return wasm.ParseWithOptions(f, opts)
+}
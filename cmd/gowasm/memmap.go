@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runMemMap(args []string) error {
+	fs := flag.NewFlagSet("memmap", flag.ExitOnError)
+	globalsFlag := fs.String("globals", "", "comma separated values of the imported i32 globals, for data offsets that read them")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var globals []interface{}
+	if *globalsFlag != "" {
+		for _, s := range strings.Split(*globalsFlag, ",") {
+			v, err := strconv.ParseInt(strings.TrimSpace(s), 0, 32)
+			if err != nil {
+				return fmt.Errorf("-globals: %v", err)
+			}
+			globals = append(globals, int32(v))
+		}
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	maps, unresolved := analysis.MapMemory(mod, globals)
+	for i, mm := range maps {
+		if i > 0 {
+			fmt.Println()
+		}
+		printMemoryMap(mm)
+	}
+	for _, u := range unresolved {
+		fmt.Printf("data[%d]: %v\n", u.Index, u.Err)
+	}
+	return nil
+}
+
+// printMemoryMap prints the segments and gaps of a memory in address order,
+// followed by the problems found.
+func printMemoryMap(mm analysis.MemoryMap) {
+	fmt.Printf("Memory %d: %d pages (0x%x bytes)", mm.Memory, mm.Limits.Initial, mm.Size())
+	if mm.Limits.HasMaximum {
+		fmt.Printf(", max %d pages", mm.Limits.Maximum)
+	}
+	if mm.Imported {
+		fmt.Printf(", imported")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintf(w, "Start\tEnd\tSize (bytes)\tContents\n")
+	gaps := mm.Gaps
+	for _, s := range mm.Segments {
+		for len(gaps) > 0 && gaps[0].Start < s.Start {
+			fmt.Fprintf(w, "0x%08x\t0x%08x\t%d\t(gap)\n", gaps[0].Start, gaps[0].End, gaps[0].Len())
+			gaps = gaps[1:]
+		}
+		fmt.Fprintf(w, "0x%08x\t0x%08x\t%d\tdata[%d]\n", s.Start, s.End, s.Len(), s.Index)
+	}
+	w.Flush()
+
+	for _, o := range mm.Overlaps {
+		fmt.Printf("data[%d] and data[%d] overlap at 0x%08x-0x%08x (%d bytes)\n", o.First, o.Second, o.Start, o.End, o.Len())
+	}
+	for _, s := range mm.Segments {
+		if s.OutOfBounds {
+			fmt.Printf("data[%d] ends at 0x%08x, past the end of the memory at 0x%08x\n", s.Index, s.End, mm.Size())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func runNm(args []string) error {
+	fs := flag.NewFlagSet("nm", flag.ExitOnError)
+	undefinedOnly := fs.Bool("undefined-only", false, "only list undefined symbols")
+	definedOnly := fs.Bool("defined-only", false, "only list defined symbols")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	syms, err := mod.Symbols()
+	if err != nil {
+		return err
+	}
+	if syms == nil {
+		return fmt.Errorf("%s: no linking section; not a relocatable object file", file)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	for _, s := range syms {
+		if *undefinedOnly && !s.Undefined() || *definedOnly && s.Undefined() {
+			continue
+		}
+		value := ""
+		switch {
+		case s.Undefined():
+		case s.Kind == wasm.SymbolData:
+			value = fmt.Sprintf("%08x", s.Offset)
+		default:
+			value = fmt.Sprintf("%08x", s.Index)
+		}
+		name := s.Name
+		if s.Module != "" && s.Module != "env" {
+			name = s.Module + "." + name
+		}
+		fmt.Fprintf(w, "%8s\t%c\t%s\t%s\t%s\n", value, symbolType(s), s.Binding(), s.Visibility(), name)
+	}
+	return w.Flush()
+}
+
+// symbolType returns the type letter of a symbol, as printed by nm: U for
+// undefined symbols, w for undefined weak symbols, W for defined weak symbols
+// and otherwise T for functions, D for data, G for globals, N for sections,
+// E for tags and B for tables. The letter is in lower case if the symbol is
+// local.
+func symbolType(s wasm.Symbol) rune {
+	switch {
+	case s.Undefined() && s.Binding() == "weak":
+		return 'w'
+	case s.Undefined():
+		return 'U'
+	case s.Binding() == "weak":
+		return 'W'
+	}
+	c := map[wasm.SymbolKind]string{
+		wasm.SymbolFunction: "T",
+		wasm.SymbolData:     "D",
+		wasm.SymbolGlobal:   "G",
+		wasm.SymbolSection:  "N",
+		wasm.SymbolTag:      "E",
+		wasm.SymbolTable:    "B",
+	}[s.Kind]
+	if c == "" {
+		c = "?"
+	}
+	if s.Binding() == "local" {
+		c = strings.ToLower(c)
+	}
+	return rune(c[0])
+}
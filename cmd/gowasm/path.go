@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runPath(args []string) error {
+	fs := flag.NewFlagSet("path", flag.ExitOnError)
+	from := fs.String("from", "", "name of the exported function to start from")
+	to := fs.String("to", "", "name of the imported function to reach (module.field or field)")
+	all := fs.Bool("all", false, "print all paths instead of a shortest one")
+	limit := fs.Int("limit", 100, "maximum number of paths to print with -all, 0 for no limit")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return fmt.Errorf("-from and -to are required")
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	g, err := mod.CallGraph()
+	if err != nil {
+		return err
+	}
+
+	var paths [][]uint32
+	if *all {
+		paths, err = analysis.Paths(mod, *from, *to, *limit)
+	} else {
+		var path []uint32
+		path, err = analysis.Path(mod, *from, *to)
+		if path != nil {
+			paths = append(paths, path)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("%s can't reach %s", *from, *to)
+	}
+
+	for _, path := range paths {
+		fmt.Println(formatPath(g, path))
+	}
+	return nil
+}
+
+// formatPath formats a call path as the function names separated by arrows.
+// Calls that may only happen through a table are marked with a star.
+func formatPath(g *wasm.CallGraph, path []uint32) string {
+	parts := make([]string, len(path))
+	for i, fi := range path {
+		n := g.Funcs[fi]
+		name := n.Name
+		if name == "" {
+			name = fmt.Sprintf("func[%d]", fi)
+		}
+		if i > 0 && !contains(g.Funcs[path[i-1]].Calls, fi) {
+			name = "*" + name
+		}
+		parts[i] = name
+	}
+	return strings.Join(parts, " -> ")
+}
+
+func contains(s []uint32, v uint32) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
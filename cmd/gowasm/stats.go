@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write the statistics as JSON")
+	numOps := fs.Int("ops", 20, "number of most frequent op codes to print")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	st, err := mod.Stats()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(st)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintf(w, "Section\tSize (bytes)\t%%\n")
+	for _, s := range st.Sections {
+		fmt.Fprintf(w, "%s\t%d\t%.1f\n", s.Name, s.Size, percent(s.Size, st.Size))
+	}
+	fmt.Fprintf(w, "Total\t%d\t\n", st.Size)
+	w.Flush()
+
+	fmt.Println()
+	fmt.Fprintf(w, "Types\t%d\n", st.Types)
+	fmt.Fprintf(w, "Imports\t%d (%d functions)\n", st.Imports, st.ImportedFunctions)
+	fmt.Fprintf(w, "Functions\t%d\n", st.Functions)
+	fmt.Fprintf(w, "Exports\t%d\n", st.Exports)
+	fmt.Fprintf(w, "Globals\t%d\n", st.Globals)
+	fmt.Fprintf(w, "Data segments\t%d\n", st.DataSegments)
+	fmt.Fprintf(w, "Instructions\t%d\n", st.Instructions)
+	fmt.Fprintf(w, "Average body size\t%.1f bytes\n", st.AverageBodySize)
+	w.Flush()
+
+	if len(st.Opcodes) > 0 {
+		fmt.Println()
+		fmt.Fprintf(w, "Op code\tCount\t%%\n")
+		for i, op := range st.Opcodes {
+			if i == *numOps {
+				break
+			}
+			fmt.Fprintf(w, "%s\t%d\t%.1f\n", op.Name, op.Count, percent(op.Count, st.Instructions))
+		}
+		w.Flush()
+	}
+
+	if len(st.LargestFunctions) > 0 {
+		fmt.Println()
+		fmt.Fprintf(w, "Function\tName\tSize (bytes)\n")
+		for _, f := range st.LargestFunctions {
+			fmt.Fprintf(w, "%d\t%s\t%d\n", f.Index, f.Name, f.Size)
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// stty runs stty on the terminal of the standard input and returns its
+// output.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// rawTerminal switches the terminal to raw mode, in which every key press is
+// read as it's typed and not echoed, and returns a function that restores
+// the previous mode.
+func rawTerminal() (restore func(), err error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("standard input is not a terminal")
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("set terminal to raw mode: %v", err)
+	}
+	return func() { stty(state) }, nil
+}
+
+// terminalSize returns the number of columns and rows of the terminal, or
+// 80x24 if it can't be determined.
+func terminalSize() (width, height int) {
+	out, err := stty("size")
+	if err == nil {
+		if _, err := fmt.Sscan(out, &height, &width); err == nil && width > 0 && height > 2 {
+			return width, height
+		}
+	}
+	return 80, 24
+}
+
+// readKey reads a key press from a terminal in raw mode. Printable keys are
+// returned as typed; others by name, such as "up", "enter" or "backspace".
+// Unknown escape sequences are returned as an empty string.
+func readKey(r *bufio.Reader) (string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case '\r', '\n':
+		return "enter", nil
+	case 0x7f, 0x08:
+		return "backspace", nil
+	case 0x03:
+		return "ctrl-c", nil
+	case 0x1b:
+		// The bytes of an escape sequence arrive together, so an escape
+		// with nothing after it is the escape key itself.
+		if r.Buffered() == 0 {
+			return "esc", nil
+		}
+		if c, err = r.ReadByte(); err != nil {
+			return "", err
+		}
+		if c != '[' && c != 'O' {
+			return "", nil
+		}
+		var seq []byte
+		for {
+			if c, err = r.ReadByte(); err != nil {
+				return "", err
+			}
+			seq = append(seq, c)
+			if c >= 0x40 && c <= 0x7e {
+				break
+			}
+		}
+		switch string(seq) {
+		case "A":
+			return "up", nil
+		case "B":
+			return "down", nil
+		case "C":
+			return "right", nil
+		case "D":
+			return "left", nil
+		case "H", "1~":
+			return "home", nil
+		case "F", "4~":
+			return "end", nil
+		case "5~":
+			return "pgup", nil
+		case "6~":
+			return "pgdn", nil
+		}
+		return "", nil
+	}
+	if c < 0x80 {
+		return string(rune(c)), nil
+	}
+	if err := r.UnreadByte(); err != nil {
+		return "", err
+	}
+	ch, _, err := r.ReadRune()
+	return string(ch), err
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func runTrace(args []string) error {
+	fs := flag.NewFlagSet("trace", flag.ExitOnError)
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("open file: %v", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	_, err = wasm.ParseWithOptions(f, wasm.ParseOptions{
+		Trace: func(e wasm.TraceEvent) {
+			if e.Kind != wasm.TraceEnd {
+				fmt.Fprintln(w, e)
+			}
+		},
+	})
+	return err
+}
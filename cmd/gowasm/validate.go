@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFileWithOptions(file, wasm.ParseOptions{Strict: true})
+	if err != nil {
+		return fmt.Errorf("%s: malformed: %v", file, err)
+	}
+	if err := mod.Validate(); err != nil {
+		return fmt.Errorf("%s: invalid: %v", file, err)
+	}
+	fmt.Println("ok")
+	return nil
+}
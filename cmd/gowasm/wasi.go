@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/akupila/go-wasm/analysis"
+)
+
+func runWASI(args []string) error {
+	fs := flag.NewFlagSet("wasi", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	r := analysis.WASICapabilities(mod)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(r)
+	}
+
+	if len(r.Imports) == 0 {
+		fmt.Println("no WASI imports")
+		return nil
+	}
+	versions := make([]string, len(r.Versions))
+	for i, v := range r.Versions {
+		versions[i] = string(v)
+	}
+	fmt.Printf("WASI: %s\n\n", strings.Join(versions, ", "))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Capability\tAccess\tFunctions\n")
+	for _, c := range r.Capabilities {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c, c.Description(), strings.Join(r.Functions(c), ", "))
+	}
+	return w.Flush()
+}
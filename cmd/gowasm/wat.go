@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/sourcemap"
+)
+
+func runWat(args []string) error {
+	fs := flag.NewFlagSet("wat", flag.ExitOnError)
+	noCode := fs.Bool("no-code", false, "leave out function bodies and data, printing only a skeleton of the module")
+	function := fs.String("function", "", "print only the function with this index, name or export name")
+	legacy := fs.Bool("legacy", false, "use the mnemonics from before 2018, such as get_local")
+	source := fs.Bool("source", false, "annotate the code with source locations from the source map referenced by the module")
+	sourceMap := fs.String("sourcemap", "", "annotate the code with source locations from this source map")
+	fetch := fs.Bool("fetch", false, "download the source map referenced by the module if it's an http or https URL")
+	file, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mod, err := parseFile(file)
+	if err != nil {
+		return err
+	}
+	opts := wasm.TextOptions{NoCode: *noCode}
+	if *legacy {
+		opts.Version = wasm.TextLegacy
+	}
+	if *source || *sourceMap != "" {
+		comment, err := sourceComments(mod, file, *sourceMap, *fetch)
+		if err != nil {
+			return err
+		}
+		opts.Comment = comment
+	}
+	if *function == "" {
+		return wasm.WriteText(os.Stdout, mod, opts)
+	}
+	fi, err := findFunction(mod, *function)
+	if err != nil {
+		return err
+	}
+	return wasm.WriteFunctionText(os.Stdout, mod, fi, opts)
+}
+
+// sourceComments returns a function that annotates instructions with their
+// source location from the source map at path, or the one referenced by the
+// module if path is empty. A remote source map referenced by the module is
+// only downloaded if fetch is set. A location is only printed when it changes.
+func sourceComments(mod *wasm.Module, file, path string, fetch bool) (func(uint32, wasm.Instruction) string, error) {
+	dir := ""
+	if path == "" {
+		url, err := sourcemap.URL(mod)
+		if err != nil {
+			return nil, err
+		}
+		if url == "" {
+			return nil, fmt.Errorf("%s has no sourceMappingURL section, use -sourcemap", file)
+		}
+		if sourcemap.IsRemote(url) && !fetch {
+			return nil, fmt.Errorf("source map %s is remote, use -fetch to download it or -sourcemap", url)
+		}
+		path, dir = url, filepath.Dir(file)
+	}
+	m, err := sourcemap.Load(path, dir)
+	if err != nil {
+		return nil, err
+	}
+	r, err := sourcemap.NewResolver(m, mod)
+	if err != nil {
+		return nil, err
+	}
+
+	var last string
+	lastFunc := -1
+	return func(fi uint32, in wasm.Instruction) string {
+		var s string
+		if loc, ok := r.Resolve(fi, in.Offset); ok {
+			s = loc.String()
+		}
+		if s == last && int(fi) == lastFunc {
+			return ""
+		}
+		last, lastFunc = s, int(fi)
+		return s
+	}, nil
+}
+
+// findFunction returns the index of the function named s, which is either an
+// index, a name from the name section or the name of an export.
+func findFunction(mod *wasm.Module, s string) (uint32, error) {
+	if i, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(i), nil
+	}
+	if ns := mod.NameSection(); ns != nil && ns.Functions != nil {
+		for _, n := range ns.Functions.Names {
+			if n.Name == s {
+				return n.Index, nil
+			}
+		}
+	}
+	if es := mod.ExportSection(); es != nil {
+		for _, e := range es.Entries {
+			if e.Kind == wasm.ExtKindFunction && e.Field == s {
+				return e.Index, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no function named %q", s)
+}
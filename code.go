@@ -0,0 +1,443 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// An Opcode identifies an instruction. Prefixed op codes are stored as 0xfcNN,
+// where NN is the sub op code following the prefix.
+type Opcode uint16
+
+// A TextVersion selects the mnemonics used in the text format.
+type TextVersion uint8
+
+const (
+	// TextCurrent uses the mnemonics of the current spec, for example
+	// local.get and i32.wrap_i64.
+	TextCurrent TextVersion = iota
+
+	// TextLegacy uses the mnemonics from before they were renamed in 2018,
+	// for example get_local and i32.wrap/i64, as found in older tools and
+	// tutorials. Op codes added after the renaming use their current names.
+	TextLegacy
+)
+
+// String returns the mnemonic of the op code, for example i32.add.
+func (op Opcode) String() string {
+	return op.Name(TextCurrent)
+}
+
+// Name returns the mnemonic of the op code in the given version of the text
+// format.
+func (op Opcode) Name(v TextVersion) string {
+	if name, ok := legacyNames[op]; ok && v == TextLegacy {
+		return name
+	}
+	if info, ok := opcodes[op]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("<0x%02x>", uint16(op))
+}
+
+// LookupOpcode returns the op code with the given mnemonic. Both current and
+// legacy mnemonics are accepted, so get_local and local.get return the same op
+// code. For select, which has two encodings, it returns the one without
+// types.
+func LookupOpcode(name string) (Opcode, bool) {
+	op, ok := opcodesByName[name]
+	return op, ok
+}
+
+// An Instruction is a single decoded instruction of a function body.
+type Instruction struct {
+	// Offset is the position of the instruction in the function body code.
+	Offset int
+
+	// Op is the op code of the instruction.
+	Op Opcode
+
+	// Immediates contains the immediate arguments of the instruction.
+	// Signed integers are sign extended and floats are stored as raw bits.
+	// For br_table, Immediates contains the label indices followed by the
+	// default label. For a memory access, it contains the alignment followed
+	// by the offset.
+	Immediates []uint64
+}
+
+// String returns the instruction in the text format, for example
+// "i32.const 42" or "i32.load offset=8 align=4".
+func (in Instruction) String() string {
+	return in.Format(TextCurrent)
+}
+
+// Format returns the instruction in the given version of the text format.
+func (in Instruction) Format(v TextVersion) string {
+	name := in.Op.Name(v)
+	info, ok := opcodes[in.Op]
+	if !ok || len(in.Immediates) == 0 {
+		return name
+	}
+
+	imm := in.Immediates
+	switch info.imm {
+	case ImmBlockType:
+		switch t := int64(imm[0]); {
+		case t == -0x40:
+			return name
+		case t < 0:
+			return fmt.Sprintf("%s (result %s)", name, ValueType(t&0x7f))
+		default:
+			return fmt.Sprintf("%s (type %d)", name, t)
+		}
+	case ImmIndex, ImmIndex2, ImmBrTable:
+		s := make([]string, len(imm))
+		for i, v := range imm {
+			s[i] = strconv.FormatUint(v, 10)
+		}
+		return name + " " + strings.Join(s, " ")
+	case ImmCallIndirect:
+		if imm[1] != 0 {
+			return fmt.Sprintf("%s %d (type %d)", name, imm[1], imm[0])
+		}
+		return fmt.Sprintf("%s (type %d)", name, imm[0])
+	case ImmMemArg:
+		if imm[1] != 0 {
+			return fmt.Sprintf("%s offset=%d align=%d", name, imm[1], uint64(1)<<(imm[0]&63))
+		}
+		return fmt.Sprintf("%s align=%d", name, uint64(1)<<(imm[0]&63))
+	case ImmI32:
+		return fmt.Sprintf("%s %d", name, int32(imm[0]))
+	case ImmI64:
+		return fmt.Sprintf("%s %d", name, int64(imm[0]))
+	case ImmF32:
+		return name + " " + formatFloat(float64(math.Float32frombits(uint32(imm[0]))), 32)
+	case ImmF64:
+		return name + " " + formatFloat(math.Float64frombits(imm[0]), 64)
+	case ImmSelect:
+		s := make([]string, len(imm))
+		for i, v := range imm {
+			s[i] = ValueType(v & 0x7f).String()
+		}
+		return fmt.Sprintf("%s (result %s)", name, strings.Join(s, " "))
+	case ImmRefType:
+		return name + " " + strings.TrimSuffix(ValueType(imm[0]&0x7f).String(), "ref")
+	}
+
+	return name
+}
+
+// formatFloat formats f like the text format does.
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// Disassemble decodes the bytecode of a function body to a list of
+// instructions.
+func Disassemble(code []byte) ([]Instruction, error) {
+	var ins []Instruction
+	err := decodeCode(code, func(in Instruction) error {
+		ins = append(ins, in)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ins, nil
+}
+
+// A CodeReader decodes the bytecode of a function body one instruction at a
+// time. Unlike Disassemble, it doesn't hold on to the decoded instructions,
+// which keeps analyses that stream through large code sections from
+// allocating memory for every instruction:
+//
+//	r := wasm.NewCodeReader(body.Code)
+//	for {
+//		in, err := r.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		// use in.Op, in.Immediates and in.Offset
+//	}
+type CodeReader struct {
+	code []byte
+	off  int
+	imm  []uint64
+	err  error
+}
+
+// NewCodeReader returns a CodeReader that decodes code.
+func NewCodeReader(code []byte) *CodeReader {
+	return &CodeReader{code: code}
+}
+
+// Next decodes the next instruction. It returns io.EOF at the end of the
+// code. After an error, Next keeps returning the error.
+//
+// The Immediates of the instruction are only valid until the next call of
+// Next, as their storage is reused.
+func (r *CodeReader) Next() (Instruction, error) {
+	if r.err != nil {
+		return Instruction{}, r.err
+	}
+	if r.off == len(r.code) {
+		r.err = io.EOF
+		return Instruction{}, r.err
+	}
+	in, n, err := decodeInstr(r.code[r.off:], r.imm)
+	if err != nil {
+		r.err = fmt.Errorf("0x%x: %v", r.off, err)
+		return Instruction{}, r.err
+	}
+	in.Offset = r.off
+	r.imm = in.Immediates
+	r.off += n
+	return in, nil
+}
+
+// Offset returns the offset of the next instruction in the code.
+func (r *CodeReader) Offset() int {
+	return r.off
+}
+
+// Assemble encodes instructions to bytecode, the reverse of Disassemble. The
+// offsets of the instructions are ignored. Immediates are encoded with the
+// smallest size, so assembling disassembled code may not reproduce the
+// original bytes if it was padded.
+func Assemble(ins []Instruction) ([]byte, error) {
+	var b []byte
+	for _, in := range ins {
+		var err error
+		if b, err = appendInstr(b, in); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendInstr appends the encoding of in to b.
+func appendInstr(b []byte, in Instruction) ([]byte, error) {
+	info, ok := opcodes[in.Op]
+	if !ok {
+		return nil, fmt.Errorf("unknown op code 0x%02x", uint16(in.Op))
+	}
+	if in.Op > 0xff {
+		b = leb128.AppendUint(append(b, byte(in.Op>>8)), uint64(in.Op&0xff))
+	} else {
+		b = append(b, byte(in.Op))
+	}
+
+	imm := in.Immediates
+	want := 0
+	switch info.imm {
+	case ImmBlockType, ImmIndex, ImmMemory, ImmI32, ImmI64, ImmF32, ImmF64, ImmRefType:
+		want = 1
+	case ImmIndex2, ImmCallIndirect, ImmMemArg, ImmMemory2:
+		want = 2
+	case ImmBrTable:
+		if len(imm) == 0 {
+			return nil, fmt.Errorf("%s: missing default label", info.name)
+		}
+		b = leb128.AppendUint(b, uint64(len(imm)-1))
+		want = len(imm)
+	case ImmSelect:
+		b = leb128.AppendUint(b, uint64(len(imm)))
+		want = len(imm)
+	}
+	if len(imm) != want {
+		return nil, fmt.Errorf("%s: expected %d immediates, got %d", info.name, want, len(imm))
+	}
+
+	switch info.imm {
+	case ImmBlockType, ImmI64:
+		b = leb128.AppendInt(b, int64(imm[0]))
+	case ImmSelect, ImmRefType:
+		for _, v := range imm {
+			b = append(b, byte(v))
+		}
+	case ImmI32:
+		b = leb128.AppendInt(b, int64(int32(imm[0])))
+	case ImmF32:
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], uint32(imm[0]))
+		b = append(b, buf[:]...)
+	case ImmF64:
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], imm[0])
+		b = append(b, buf[:]...)
+	default:
+		for _, v := range imm {
+			b = leb128.AppendUint(b, v)
+		}
+	}
+	return b, nil
+}
+
+// decodeInstr decodes the instruction at the start of b and returns it along
+// with the number of bytes it occupies. The immediates are appended to
+// imm[:0], so the caller can reuse the slice.
+func decodeInstr(b []byte, imm []uint64) (Instruction, int, error) {
+	in := Instruction{Immediates: imm[:0]}
+	if len(b) == 0 {
+		return in, 0, io.ErrUnexpectedEOF
+	}
+
+	in.Op = Opcode(b[0])
+	n := 1
+	if in.Op == opPrefixMisc {
+		sub, l, err := leb128.DecodeUint(b[n:], 32)
+		if err != nil {
+			return in, 0, fmt.Errorf("read op code: %v", err)
+		}
+		if sub > 0xff {
+			return in, 0, fmt.Errorf("unknown op code 0xfc %d", sub)
+		}
+		in.Op = opPrefixMisc<<8 | Opcode(sub)
+		n += l
+	}
+
+	info, ok := opcodes[in.Op]
+	if !ok {
+		return in, 0, fmt.Errorf("unknown op code 0x%02x", uint16(in.Op))
+	}
+
+	// readUint reads a varuint32 immediate
+	readUint := func() error {
+		v, l, err := leb128.DecodeUint(b[n:], 32)
+		if err != nil {
+			return err
+		}
+		in.Immediates = append(in.Immediates, v)
+		n += l
+		return nil
+	}
+
+	// readSint reads a signed immediate of the given size in bits
+	readSint := func(bits uint) error {
+		v, l, err := leb128.DecodeInt(b[n:], bits)
+		if err != nil {
+			return err
+		}
+		in.Immediates = append(in.Immediates, uint64(v))
+		n += l
+		return nil
+	}
+
+	// readType reads a value type, which is a single byte
+	readType := func() error {
+		if n >= len(b) {
+			return io.ErrUnexpectedEOF
+		}
+		in.Immediates = append(in.Immediates, uint64(b[n]))
+		n++
+		return nil
+	}
+
+	// readFixed reads a little endian immediate of size bytes
+	readFixed := func(size int) error {
+		if len(b[n:]) < size {
+			return io.ErrUnexpectedEOF
+		}
+		if size == 4 {
+			in.Immediates = append(in.Immediates, uint64(binary.LittleEndian.Uint32(b[n:])))
+		} else {
+			in.Immediates = append(in.Immediates, binary.LittleEndian.Uint64(b[n:]))
+		}
+		n += size
+		return nil
+	}
+
+	var err error
+	switch info.imm {
+	case ImmNone:
+	case ImmBlockType:
+		// A block type is encoded as a signed 33 bit integer.
+		err = readSint(33)
+	case ImmIndex, ImmMemory:
+		err = readUint()
+	case ImmIndex2, ImmCallIndirect, ImmMemArg, ImmMemory2:
+		if err = readUint(); err == nil {
+			err = readUint()
+		}
+	case ImmBrTable:
+		var c uint64
+		var l int
+		c, l, err = leb128.DecodeUint(b[n:], 32)
+		if err != nil {
+			break
+		}
+		if int(c) > len(b) {
+			err = fmt.Errorf("br_table label count %d too large", c)
+			break
+		}
+		n += l
+		for i := uint64(0); i <= c && err == nil; i++ {
+			err = readUint()
+		}
+	case ImmI32:
+		err = readSint(32)
+	case ImmI64:
+		err = readSint(64)
+	case ImmF32:
+		err = readFixed(4)
+	case ImmF64:
+		err = readFixed(8)
+	case ImmSelect:
+		var c uint64
+		var l int
+		c, l, err = leb128.DecodeUint(b[n:], 32)
+		if err != nil {
+			break
+		}
+		n += l
+		if int(c) > len(b) {
+			err = fmt.Errorf("select type count %d too large", c)
+			break
+		}
+		for i := uint64(0); i < c && err == nil; i++ {
+			err = readType()
+		}
+	case ImmRefType:
+		err = readType()
+	}
+	if err != nil {
+		return in, 0, fmt.Errorf("%s: read immediate: %v", info.name, err)
+	}
+
+	return in, n, nil
+}
+
+// decodeCode decodes the instructions in code, calling f for every
+// instruction. Decoding stops at the first error, either returned by f or
+// encountered while decoding.
+func decodeCode(code []byte, f func(in Instruction) error) error {
+	for off := 0; off < len(code); {
+		in, n, err := decodeInstr(code[off:], nil)
+		if err != nil {
+			return fmt.Errorf("0x%x: %v", off, err)
+		}
+		in.Offset = off
+		if err := f(in); err != nil {
+			return err
+		}
+		off += n
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package wasm
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	code := []byte{
+		0x02, 0x7f, // block (result i32)
+		0x41, 0x7f, // i32.const -1
+		0x0c, 0x00, // br 0
+		0x0b,             // end
+		0x28, 0x02, 0x08, // i32.load offset=8 align=4
+		0x0e, 0x02, 0x00, 0x01, 0x02, // br_table 0 1 2
+		0x11, 0x03, 0x00, // call_indirect (type 3)
+		0x43, 0x00, 0x00, 0xc0, 0x7f, // f32.const nan
+		0x44, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f, // f64.const 1.5
+		0xfc, 0x0a, 0x00, 0x00, // memory.copy
+		0x0b, // end
+	}
+	expected := []string{
+		"block (result i32)",
+		"i32.const -1",
+		"br 0",
+		"end",
+		"i32.load offset=8 align=4",
+		"br_table 0 1 2",
+		"call_indirect (type 3)",
+		"f32.const nan",
+		"f64.const 1.5",
+		"memory.copy",
+		"end",
+	}
+
+	ins, err := Disassemble(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ins) != len(expected) {
+		t.Fatalf("Expected %d instructions, got %d", len(expected), len(ins))
+	}
+	for i, in := range ins {
+		if in.String() != expected[i] {
+			t.Errorf("Instruction %d: expected %q, got %q", i, expected[i], in.String())
+		}
+	}
+	if ins[4].Offset != 7 {
+		t.Errorf("Expected offset of instruction 4 to be 7, got %d", ins[4].Offset)
+	}
+
+	if _, err := Disassemble([]byte{0x41, 0x80}); err == nil {
+		t.Errorf("Expected error for truncated immediate")
+	}
+	if _, err := Disassemble([]byte{0xff}); err == nil {
+		t.Errorf("Expected error for unknown op code")
+	}
+}
+
+func TestAssemble(t *testing.T) {
+	code := []byte{
+		0x02, 0x40, // block
+		0x41, 0x7f, // i32.const -1
+		0x42, 0x80, 0x01, // i64.const 128
+		0x0d, 0x00, // br_if 0
+		0x0b,             // end
+		0x28, 0x02, 0x08, // i32.load offset=8 align=4
+		0x0e, 0x02, 0x00, 0x01, 0x02, // br_table 0 1 2
+		0x11, 0x03, 0x00, // call_indirect (type 3)
+		0x1c, 0x01, 0x7c, // select (result f64)
+		0x43, 0x00, 0x00, 0xc0, 0x7f, // f32.const nan
+		0x44, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f, // f64.const 1.5
+		0xfc, 0x0a, 0x00, 0x00, // memory.copy
+		0xd0, 0x70, // ref.null func
+		0x0b, // end
+	}
+	ins, err := Disassemble(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Assemble(ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, code) {
+		t.Errorf("Expected\n%x\ngot\n%x", code, b)
+	}
+
+	// Value types are single bytes, not signed integers.
+	if imm := ins[8].Immediates; len(imm) != 1 || imm[0] != uint64(F64) {
+		t.Errorf("Expected select immediate 0x%02x, got %x", uint8(F64), imm)
+	}
+	b, err = Assemble([]Instruction{
+		{Op: 0x1c, Immediates: []uint64{uint64(I32)}},
+		{Op: 0xd0, Immediates: []uint64{uint64(FuncRef)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []byte{0x1c, 0x01, 0x7f, 0xd0, 0x70}; !bytes.Equal(b, expected) {
+		t.Errorf("Expected % x, got % x", expected, b)
+	}
+
+	if _, err := Assemble([]Instruction{{Op: 0x10}}); err == nil {
+		t.Errorf("Expected error for missing immediate")
+	}
+	if _, err := Assemble([]Instruction{{Op: 0xff}}); err == nil {
+		t.Errorf("Expected error for unknown op code")
+	}
+}
+
+func TestOpcodeNames(t *testing.T) {
+	tests := []struct {
+		op      Opcode
+		current string
+		legacy  string
+	}{
+		{0x20, "local.get", "get_local"},
+		{0x24, "global.set", "set_global"},
+		{0x40, "memory.grow", "grow_memory"},
+		{0xa7, "i32.wrap_i64", "i32.wrap/i64"},
+		{0xbf, "f64.reinterpret_i64", "f64.reinterpret/i64"},
+		{0xfc03, "i32.trunc_sat_f64_u", "i32.trunc_u:sat/f64"},
+		{0x6a, "i32.add", "i32.add"},
+		{0xd2, "ref.func", "ref.func"},
+	}
+	for _, tt := range tests {
+		if name := tt.op.Name(TextCurrent); name != tt.current {
+			t.Errorf("0x%02x: expected current name %s, got %s", uint16(tt.op), tt.current, name)
+		}
+		if name := tt.op.Name(TextLegacy); name != tt.legacy {
+			t.Errorf("0x%02x: expected legacy name %s, got %s", uint16(tt.op), tt.legacy, name)
+		}
+		for _, name := range []string{tt.current, tt.legacy} {
+			if op, ok := LookupOpcode(name); !ok || op != tt.op {
+				t.Errorf("LookupOpcode(%s): expected 0x%02x, got 0x%02x, %v", name, uint16(tt.op), uint16(op), ok)
+			}
+		}
+	}
+
+	if op, ok := LookupOpcode("select"); !ok || op != 0x1b {
+		t.Errorf("LookupOpcode(select): expected 0x1b, got 0x%02x, %v", uint16(op), ok)
+	}
+	if _, ok := LookupOpcode("i32.bogus"); ok {
+		t.Error("LookupOpcode(i32.bogus): expected not found")
+	}
+
+	in := Instruction{Op: 0x23, Immediates: []uint64{3}}
+	if s := in.Format(TextLegacy); s != "get_global 3" {
+		t.Errorf("Expected get_global 3, got %s", s)
+	}
+	if s := in.String(); s != "global.get 3" {
+		t.Errorf("Expected global.get 3, got %s", s)
+	}
+}
+
+func TestCodeReader(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, body := range mod.CodeSection().Bodies {
+		expected, err := Disassemble(body.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewCodeReader(body.Code)
+		for j := 0; ; j++ {
+			in, err := r.Next()
+			if err == io.EOF {
+				if j != len(expected) {
+					t.Fatalf("Body %d: expected %d instructions, got %d", i, len(expected), j)
+				}
+				break
+			}
+			if err != nil {
+				t.Fatalf("Body %d: %v", i, err)
+			}
+			e := expected[j]
+			if in.Op != e.Op || in.Offset != e.Offset || len(in.Immediates) != len(e.Immediates) ||
+				len(e.Immediates) > 0 && !reflect.DeepEqual(in.Immediates, e.Immediates) {
+				t.Fatalf("Body %d, instruction %d: expected %+v, got %+v", i, j, e, in)
+			}
+		}
+	}
+
+	r := NewCodeReader([]byte{0x41, 0x01, 0x41, 0x80})
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Offset() != 2 {
+		t.Errorf("Expected offset 2, got %d", r.Offset())
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("Expected error for truncated immediate, got %v", err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("Expected error to persist, got %v", err)
+	}
+}
+
+func benchmarkCode(b *testing.B) []FunctionBody {
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	mod, err := ParseBytes(buf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return mod.CodeSection().Bodies
+}
+
+func BenchmarkDisassemble(b *testing.B) {
+	bodies := benchmarkCode(b)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, body := range bodies {
+			if _, err := Disassemble(body.Code); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkCodeReader(b *testing.B) {
+	bodies := benchmarkCode(b)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, body := range bodies {
+			r := NewCodeReader(body.Code)
+			for {
+				_, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func TestOpcodeInfo(t *testing.T) {
+	tests := []struct {
+		op          Opcode
+		name        string
+		imm         ImmediateKind
+		pops        []ValueType
+		pushes      []ValueType
+		polymorphic bool
+	}{
+		{0x01, "nop", ImmNone, nil, nil, false},
+		{0x10, "call", ImmIndex, nil, nil, true},
+		{0x36, "i32.store", ImmMemArg, []ValueType{0x7f, 0x7f}, nil, false},
+		{0x42, "i64.const", ImmI64, nil, []ValueType{0x7e}, false},
+		{0x50, "i64.eqz", ImmNone, []ValueType{0x7e}, []ValueType{0x7f}, false},
+		{0xa7, "i32.wrap_i64", ImmNone, []ValueType{0x7e}, []ValueType{0x7f}, false},
+		{0xd2, "ref.func", ImmIndex, nil, []ValueType{0x70}, false},
+		{0xfc0a, "memory.copy", ImmMemory2, []ValueType{0x7f, 0x7f, 0x7f}, nil, false},
+	}
+	for _, tt := range tests {
+		info, ok := tt.op.Info()
+		if !ok {
+			t.Errorf("%s: not found", tt.name)
+			continue
+		}
+		if info.Op != tt.op || info.Name != tt.name || info.Immediates != tt.imm || info.Polymorphic != tt.polymorphic ||
+			!reflect.DeepEqual(info.Pops, tt.pops) || !reflect.DeepEqual(info.Pushes, tt.pushes) {
+			t.Errorf("%s: unexpected info %+v", tt.name, info)
+		}
+	}
+	if _, ok := Opcode(0xff).Info(); ok {
+		t.Error("Expected unknown op code not to be found")
+	}
+
+	all := Opcodes()
+	if len(all) != len(opcodes) {
+		t.Fatalf("Expected %d op codes, got %d", len(opcodes), len(all))
+	}
+	for i, info := range all {
+		if i > 0 && info.Op <= all[i-1].Op {
+			t.Errorf("Op codes not sorted at %s", info.Name)
+		}
+		for _, v := range append(info.Pops, info.Pushes...) {
+			if v == 0 {
+				t.Errorf("%s: unknown type in stack signature %q", info.Name, opcodes[info.Op].sig)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// A CodeMetadata is an item of a code metadata custom section, which attaches
+// data to an instruction of a function, such as a hint for a branch.
+//
+// https://github.com/WebAssembly/tool-conventions/blob/main/CodeMetadata.md
+type CodeMetadata struct {
+	// Function is the index of the function in the function index space.
+	Function uint32
+
+	// Offset is the offset of the instruction in the Code of the function
+	// body, as in Instruction.Offset. In the section, offsets are relative to
+	// the start of the body, including the locals.
+	Offset int
+
+	// Data is the metadata of the instruction. Its format depends on the
+	// kind of metadata.
+	Data []byte
+}
+
+// CodeMetadata returns the items of the custom section named
+// "metadata.code.<kind>", such as kind "branch_hint", in the order they
+// appear in the section. It returns nil if the module has no such section.
+//
+// AddFunctionImport and Instrument remove the code metadata sections, and
+// Merge doesn't copy them.
+func (m *Module) CodeMetadata(kind string) ([]CodeMetadata, error) {
+	secs := m.CustomSections("metadata.code." + kind)
+	if len(secs) == 0 {
+		return nil, nil
+	}
+
+	var bodies []FunctionBody
+	if s := m.CodeSection(); s != nil {
+		bodies = s.Bodies
+	}
+	imported := m.importedFunctions()
+
+	r := bytes.NewReader(secs[0].Payload)
+	var n uint32
+	if err := readVarUint32(r, &n); err != nil {
+		return nil, fmt.Errorf("read function count: %v", err)
+	}
+	var items []CodeMetadata
+	for i := uint32(0); i < n; i++ {
+		var fi, c uint32
+		if err := readVarUint32(r, &fi); err != nil {
+			return nil, fmt.Errorf("function %d: read index: %v", i, err)
+		}
+		if fi < imported || int(fi-imported) >= len(bodies) {
+			return nil, fmt.Errorf("function %d: no function body", fi)
+		}
+		locals := localsSize(bodies[fi-imported])
+		if err := readVarUint32(r, &c); err != nil {
+			return nil, fmt.Errorf("function %d: read item count: %v", fi, err)
+		}
+		for j := uint32(0); j < c; j++ {
+			var off, l uint32
+			if err := readVarUint32(r, &off); err != nil {
+				return nil, fmt.Errorf("function %d: item %d: read offset: %v", fi, j, err)
+			}
+			if int(off) < locals || int(off)-locals >= len(bodies[fi-imported].Code) {
+				return nil, fmt.Errorf("function %d: item %d: offset %d is outside the code", fi, j, off)
+			}
+			if err := readVarUint32(r, &l); err != nil {
+				return nil, fmt.Errorf("function %d: item %d: read size: %v", fi, j, err)
+			}
+			if int(l) > r.Len() {
+				return nil, fmt.Errorf("function %d: item %d: size %d exceeds input", fi, j, l)
+			}
+			data := make([]byte, l)
+			r.Read(data)
+			items = append(items, CodeMetadata{Function: fi, Offset: int(off) - locals, Data: data})
+		}
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d bytes after the last function", r.Len())
+	}
+	return items, nil
+}
+
+// localsSize returns the size of the local declarations at the start of a
+// function body in the input. For bodies that were not parsed, it's the size
+// they are encoded with.
+func localsSize(b FunctionBody) int {
+	if b.code != 0 {
+		return int(b.code - b.offset)
+	}
+	n := leb128.UintSize(uint64(len(b.Locals)))
+	for _, l := range b.Locals {
+		n += leb128.UintSize(uint64(l.Count)) + 1
+	}
+	return n
+}
+
+// removeCodeMetadata removes the code metadata sections, as the function
+// indices and instruction offsets in them no longer match modified code.
+func (m *Module) removeCodeMetadata() {
+	sections := m.Sections[:0]
+	for _, s := range m.Sections {
+		if c, ok := s.(*SectionCustom); ok && strings.HasPrefix(c.SectionName, "metadata.code.") {
+			continue
+		}
+		sections = append(sections, s)
+	}
+	for i := len(sections); i < len(m.Sections); i++ {
+		m.Sections[i] = nil
+	}
+	m.Sections = sections
+}
+
+// A BranchHint tells whether an if or br_if instruction is likely to branch,
+// so an engine can lay out the code for the likely case.
+//
+// https://github.com/WebAssembly/branch-hinting
+type BranchHint struct {
+	// Function is the index of the function in the function index space.
+	Function uint32
+
+	// Offset is the offset of the instruction in the Code of the function
+	// body, as in Instruction.Offset.
+	Offset int
+
+	// Likely is true if the branch is likely to be taken: for if, that the
+	// condition is true.
+	Likely bool
+}
+
+// BranchHints returns the hints of the metadata.code.branch_hint custom
+// section, which toolchains emit for profile guided optimization and for
+// branches annotated as likely or unlikely in the source. It returns nil if
+// the module has no such section.
+func (m *Module) BranchHints() ([]BranchHint, error) {
+	items, err := m.CodeMetadata("branch_hint")
+	if err != nil {
+		return nil, err
+	}
+	var hints []BranchHint
+	for _, it := range items {
+		if len(it.Data) != 1 || it.Data[0] > 1 {
+			return nil, fmt.Errorf("function %d: offset 0x%x: invalid branch hint %x", it.Function, it.Offset, it.Data)
+		}
+		hints = append(hints, BranchHint{Function: it.Function, Offset: it.Offset, Likely: it.Data[0] == 1})
+	}
+	return hints, nil
+}
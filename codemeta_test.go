@@ -0,0 +1,150 @@
+package wasm
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func branchHintModule(payload []byte) *Module {
+	return &Module{Sections: []Section{
+		&SectionType{Entries: []FuncType{{Form: 0x60}}},
+		&SectionImport{Entries: []ImportEntry{
+			{Module: "env", Field: "f", Kind: ExtKindFunction, FunctionType: &FunctionType{Index: 0}},
+		}},
+		&SectionFunction{Types: []uint32{0}},
+		&SectionCode{Bodies: []FunctionBody{
+			{
+				Locals: []LocalEntry{{Count: 1, Type: I32}},
+				Code: []byte{
+					0x20, 0x00, // local.get 0
+					0x04, 0x40, // if
+					0x01, // nop
+					0x0b, // end
+					0x0b, // end
+				},
+			},
+		}},
+		&SectionCustom{SectionName: "metadata.code.branch_hint", Payload: payload},
+	}}
+}
+
+func TestBranchHints(t *testing.T) {
+	// The locals take 3 bytes, so the if at offset 2 of the code is at
+	// offset 5 of the body.
+	mod := branchHintModule([]byte{
+		0x01,       // 1 function
+		0x01, 0x01, // function 1, 1 hint
+		0x05, 0x01, 0x01, // offset 5, likely
+	})
+	expected := []BranchHint{{Function: 1, Offset: 2, Likely: true}}
+
+	hints, err := mod.BranchHints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hints, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, hints)
+	}
+
+	// The offsets of parsed bodies come from the parser.
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hints, err = parsed.BranchHints(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hints, expected) {
+		t.Errorf("Expected %+v after parsing, got %+v", expected, hints)
+	}
+	ins, err := Disassemble(parsed.CodeSection().Bodies[0].Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ins[1].Offset != hints[0].Offset || ins[1].Op != opIf {
+		t.Errorf("Expected hint for if at offset %d, got %s at %d", hints[0].Offset, ins[1].Op, ins[1].Offset)
+	}
+
+	if hints, err := (&Module{}).BranchHints(); err != nil || hints != nil {
+		t.Errorf("Expected no hints without section, got %v, %v", hints, err)
+	}
+}
+
+func TestBranchHintsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		err     string
+	}{
+		{"imported function", []byte{0x01, 0x00, 0x01, 0x05, 0x01, 0x01}, "function 0: no function body"},
+		{"offset in locals", []byte{0x01, 0x01, 0x01, 0x02, 0x01, 0x01}, "offset 2 is outside the code"},
+		{"invalid hint", []byte{0x01, 0x01, 0x01, 0x05, 0x01, 0x02}, "invalid branch hint 02"},
+		{"truncated", []byte{0x01, 0x01, 0x01, 0x05, 0x02, 0x01}, "size 2 exceeds input"},
+		{"trailing bytes", []byte{0x00, 0x00}, "1 bytes after the last function"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := branchHintModule(tt.payload).BranchHints()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestCodeMetadataRemoved(t *testing.T) {
+	payload := []byte{0x01, 0x01, 0x01, 0x05, 0x01, 0x01}
+	tests := []struct {
+		name   string
+		modify func(m *Module) error
+	}{
+		{"AddFunctionImport", func(m *Module) error {
+			_, err := m.AddFunctionImport("env", "g", FuncType{})
+			return err
+		}},
+		{"Instrument", func(m *Module) error {
+			return m.Instrument(Instrumentation{
+				FunctionStart: func(fi uint32) []Instruction {
+					return []Instruction{{Op: 0x01}} // nop
+				},
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mod := branchHintModule(payload)
+			if err := tt.modify(mod); err != nil {
+				t.Fatal(err)
+			}
+			if secs := mod.CustomSections("metadata.code.branch_hint"); len(secs) != 0 {
+				t.Errorf("Expected branch hints to be removed, got %d sections", len(secs))
+			}
+		})
+	}
+
+	// The offsets of a parsed body stay relative to the input when its code
+	// is replaced.
+	var buf bytes.Buffer
+	if err := Encode(&buf, branchHintModule(payload)); err != nil {
+		t.Fatal(err)
+	}
+	mod, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := &mod.CodeSection().Bodies[0]
+	body.Code = append(append([]byte(nil), body.Code...), 0x01, 0x01)
+	hints, err := mod.BranchHints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hints) != 1 || hints[0].Offset != 2 {
+		t.Errorf("Expected hint at offset 2, got %+v", hints)
+	}
+}
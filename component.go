@@ -0,0 +1,136 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// Layers of the binary format. Core modules and components of the component
+// model start with the same magic number, followed by a version and a layer.
+// For core modules the version is 1 and the layer is 0, so the two read
+// together as the 32-bit version 1.
+const (
+	LayerCore      = 0
+	LayerComponent = 1
+)
+
+// A VersionError is returned by the parse functions when the input starts
+// with the magic number but isn't a core module of a supported version, such
+// as a component built by the component model tooling. Use ParseComponent to
+// find the core modules in a component.
+type VersionError struct {
+	// Version and Layer are the fields following the magic number.
+	Version uint16
+	Layer   uint16
+}
+
+func (e *VersionError) Error() string {
+	switch e.Layer {
+	case LayerCore:
+		return fmt.Sprintf("unsupported version %d", e.Version)
+	case LayerComponent:
+		return fmt.Sprintf("component (version 0x%x, layer %d) is not a core module", e.Version, e.Layer)
+	}
+	return fmt.Sprintf("unsupported layer %d, version 0x%x", e.Layer, e.Version)
+}
+
+// IsComponent reports whether the input is a component.
+func (e *VersionError) IsComponent() bool {
+	return e.Layer == LayerComponent
+}
+
+// Sections of a component that contain core modules and nested components.
+const (
+	compSecCoreModule = 0x01
+	compSecComponent  = 0x04
+)
+
+// A Component is a component of the component model, parsed by
+// ParseComponent. Only the core modules in it are parsed.
+type Component struct {
+	// Version is the version of the component binary format.
+	Version uint16
+
+	// Modules contains the core modules in the component and in the
+	// components nested in it, in the order they appear in the input.
+	Modules []ComponentModule
+}
+
+// A ComponentModule is a core module found in a component.
+type ComponentModule struct {
+	// Offset and Length locate the module in the input.
+	Offset int
+	Length int
+
+	// Depth is the number of nested components the module is in, 0 for a
+	// module in the top-level component.
+	Depth int
+
+	// Module is the parsed module, or nil if it could not be parsed.
+	Module *Module
+
+	// Err is the error from parsing the module.
+	Err error
+}
+
+// ParseComponent finds the core modules in a component, including the ones
+// in nested components, and parses them with ParseBytes. A module that fails
+// to parse is returned with the error set. The other sections of the
+// component are skipped.
+func ParseComponent(b []byte) (*Component, error) {
+	var c Component
+	version, err := parseComponent(b, 0, 0, &c.Modules)
+	if err != nil {
+		return nil, err
+	}
+	c.Version = version
+	return &c, nil
+}
+
+// parseComponent adds the core modules in the component b, which starts at
+// offset in the input, to modules.
+func parseComponent(b []byte, offset, depth int, modules *[]ComponentModule) (uint16, error) {
+	if len(b) < 8 || binary.LittleEndian.Uint32(b) != magicnumber {
+		return 0, fmt.Errorf("[0x%06x] not a wasm file", offset)
+	}
+	version := binary.LittleEndian.Uint16(b[4:])
+	if layer := binary.LittleEndian.Uint16(b[6:]); layer != LayerComponent {
+		return 0, fmt.Errorf("[0x%06x] not a component: layer %d", offset, layer)
+	}
+
+	r := bytes.NewReader(b[8:])
+	for r.Len() > 0 {
+		start := len(b) - r.Len()
+		id, _ := r.ReadByte()
+		size, err := leb128.ReadUint(r, 32)
+		if err != nil {
+			return 0, fmt.Errorf("[0x%06x] read section size: %v", offset+start, err)
+		}
+		if size > uint64(r.Len()) {
+			return 0, fmt.Errorf("[0x%06x] section size %d exceeds input", offset+start, size)
+		}
+		payload := len(b) - r.Len()
+		data := b[payload : payload+int(size)]
+		switch id {
+		case compSecCoreModule:
+			m, err := ParseBytes(data)
+			*modules = append(*modules, ComponentModule{
+				Offset: offset + payload,
+				Length: len(data),
+				Depth:  depth,
+				Module: m,
+				Err:    err,
+			})
+		case compSecComponent:
+			if _, err := parseComponent(data, offset+payload, depth+1, modules); err != nil {
+				return 0, err
+			}
+		}
+		r.Seek(int64(size), io.SeekCurrent)
+	}
+	return version, nil
+}
@@ -0,0 +1,85 @@
+package wasm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// componentSection encodes a section of a component. The sizes in the tests
+// fit in a single byte of LEB128.
+func componentSection(id byte, payload []byte) []byte {
+	return append([]byte{id, byte(len(payload))}, payload...)
+}
+
+func TestParseComponent(t *testing.T) {
+	empty, err := ioutil.ReadFile(filepath.Join("testdata", "empty.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	preamble := []byte("\x00asm\x0d\x00\x01\x00")
+
+	var nested []byte
+	nested = append(nested, preamble...)
+	nested = append(nested, componentSection(0x01, empty)...)
+
+	var b []byte
+	b = append(b, preamble...)
+	b = append(b, componentSection(0x00, []byte("\x04name"))...)
+	b = append(b, componentSection(0x01, empty)...)
+	b = append(b, componentSection(0x04, nested)...)
+	b = append(b, componentSection(0x01, []byte("\x00asm\x01\x00\x00\x00\x01"))...)
+
+	_, err = ParseBytes(b)
+	verr, ok := err.(*VersionError)
+	if !ok || !verr.IsComponent() || verr.Version != 0x0d {
+		t.Fatalf("Expected component version error, got %v", err)
+	}
+
+	c, err := ParseComponent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 0x0d {
+		t.Errorf("Expected version 0x0d, got 0x%x", c.Version)
+	}
+	if len(c.Modules) != 3 {
+		t.Fatalf("Expected 3 modules, got %d", len(c.Modules))
+	}
+	for i, want := range []struct{ depth, err bool }{{false, false}, {true, false}, {false, true}} {
+		m := c.Modules[i]
+		if got := m.Depth == 1; got != want.depth {
+			t.Errorf("Module %d: expected nested %t, got depth %d", i, want.depth, m.Depth)
+		}
+		if got := m.Err != nil; got != want.err {
+			t.Errorf("Module %d: expected error %t, got %v", i, want.err, m.Err)
+		}
+		if !want.err && !bytes.Equal(b[m.Offset:m.Offset+m.Length], empty) {
+			t.Errorf("Module %d: expected module at %d to be empty.wasm", i, m.Offset)
+		}
+	}
+}
+
+func TestParseComponentNotComponent(t *testing.T) {
+	empty, err := ioutil.ReadFile(filepath.Join("testdata", "empty.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseComponent(empty); err == nil {
+		t.Error("Expected error for core module")
+	}
+	if _, err := ParseComponent([]byte("\x00asm\x0d\x00\x01\x00\x01\x7f")); err == nil {
+		t.Error("Expected error for truncated section")
+	}
+}
+
+func TestVersionError(t *testing.T) {
+	_, err := ParseBytes([]byte("\x00asm\x02\x00\x00\x00"))
+	if err == nil || err.Error() != "unsupported version 2" {
+		t.Errorf("Expected unsupported version error, got %v", err)
+	}
+	if verr, ok := err.(*VersionError); !ok || verr.IsComponent() {
+		t.Errorf("Expected core version error, got %#v", err)
+	}
+}
@@ -0,0 +1,326 @@
+package wasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpOptions controls the output of WriteDump.
+type DumpOptions struct {
+	// Code includes the disassembly of every function.
+	Code bool
+
+	// Data includes a hex dump of the data segments and custom sections.
+	Data bool
+}
+
+// WriteDump writes a readable description of the whole module to w, similar
+// to wasm-objdump -x: a table of the sections with their offsets, followed by
+// the entries of every section with the names of the functions from the name
+// section.
+func WriteDump(w io.Writer, m *Module, opts DumpOptions) error {
+	d := &dumper{w: bufio.NewWriter(w), m: m, opts: opts, names: make(map[uint32]string)}
+	if s := m.NameSection(); s != nil && s.Functions != nil {
+		for _, n := range s.Functions.Names {
+			d.names[n.Index] = n.Name
+		}
+	}
+	d.headers()
+	d.details()
+	if opts.Code {
+		d.code()
+	}
+	if d.err != nil {
+		return d.err
+	}
+	return d.w.Flush()
+}
+
+type dumper struct {
+	w     *bufio.Writer
+	m     *Module
+	opts  DumpOptions
+	err   error
+	names map[uint32]string
+}
+
+func (d *dumper) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// funcName returns the name of function fi in angle brackets preceded by a
+// space, or an empty string if it has no name.
+func (d *dumper) funcName(fi uint32) string {
+	if n, ok := d.names[fi]; ok {
+		return " <" + n + ">"
+	}
+	return ""
+}
+
+func (d *dumper) headers() {
+	d.printf("Sections:\n\n")
+	for _, s := range d.m.Sections {
+		d.printf("%10s", s.Name())
+		if s.Offset() != 0 {
+			d.printf(" start=0x%08x end=0x%08x", s.PayloadOffset(), s.PayloadOffset()+int64(s.Size()))
+		}
+		size := int(s.Size())
+		if s.Offset() == 0 {
+			if es, err := encodeSection(s); err == nil {
+				size = len(es.content)
+			}
+		}
+		d.printf(" (size=0x%08x)", size)
+		switch s := s.(type) {
+		case *SectionCustom:
+			d.printf(" %q", s.SectionName)
+		case *SectionName:
+			d.printf(" %q", s.SectionName)
+		case *SectionStart:
+			d.printf(" start: %d", s.Index)
+		default:
+			if n, ok := entryCount(s); ok {
+				d.printf(" count: %d", n)
+			}
+		}
+		d.printf("\n")
+	}
+}
+
+// entryCount returns the number of entries of a section that consists of a
+// vector of entries.
+func entryCount(s Section) (int, bool) {
+	switch s := s.(type) {
+	case *SectionType:
+		return len(s.Entries), true
+	case *SectionImport:
+		return len(s.Entries), true
+	case *SectionFunction:
+		return len(s.Types), true
+	case *SectionTable:
+		return len(s.Entries), true
+	case *SectionMemory:
+		return len(s.Entries), true
+	case *SectionGlobal:
+		return len(s.Globals), true
+	case *SectionExport:
+		return len(s.Entries), true
+	case *SectionElement:
+		return len(s.Entries), true
+	case *SectionCode:
+		return len(s.Bodies), true
+	case *SectionData:
+		return len(s.Entries), true
+	}
+	return 0, false
+}
+
+func (d *dumper) details() {
+	d.printf("\nSection Details:\n")
+	var funcs, tables, memories, globals uint32
+	for _, s := range d.m.Sections {
+		if n, ok := entryCount(s); ok {
+			d.printf("\n%s[%d]:\n", s.Name(), n)
+		}
+		switch s := s.(type) {
+		case *SectionType:
+			for i, t := range s.Entries {
+				d.printf(" - type[%d] %s\n", i, t)
+			}
+		case *SectionImport:
+			for _, e := range s.Entries {
+				switch e.Kind {
+				case ExtKindFunction:
+					d.printf(" - func[%d] sig=%d%s", funcs, e.FunctionType.Index, d.funcName(funcs))
+					funcs++
+				case ExtKindTable:
+					d.printf(" - table[%d] type=%s %s", tables, e.TableType.ElemType.String(), limitsDump(e.TableType.Limits))
+					tables++
+				case ExtKindMemory:
+					d.printf(" - memory[%d] pages: %s", memories, limitsDump(e.MemoryType.Limits))
+					memories++
+				case ExtKindGlobal:
+					d.printf(" - global[%d] %s mutable=%v", globals, e.GlobalType.ContentType.String(), e.GlobalType.Mutable)
+					globals++
+				}
+				d.printf(" <- %s.%s\n", e.Module, e.Field)
+			}
+		case *SectionFunction:
+			for _, t := range s.Types {
+				d.printf(" - func[%d] sig=%d%s\n", funcs, t, d.funcName(funcs))
+				funcs++
+			}
+		case *SectionTable:
+			for _, t := range s.Entries {
+				d.printf(" - table[%d] type=%s %s\n", tables, t.ElemType.String(), limitsDump(t.Limits))
+				tables++
+			}
+		case *SectionMemory:
+			for _, mem := range s.Entries {
+				d.printf(" - memory[%d] pages: %s\n", memories, limitsDump(mem.Limits))
+				memories++
+			}
+		case *SectionGlobal:
+			for _, g := range s.Globals {
+				d.printf(" - global[%d] %s mutable=%v - init %s\n", globals, g.Type.ContentType.String(), g.Type.Mutable, initExprDump(g.Init))
+				globals++
+			}
+		case *SectionExport:
+			for _, e := range s.Entries {
+				d.printf(" - %s[%d]", kindText(e.Kind), e.Index)
+				if e.Kind == ExtKindFunction {
+					d.printf("%s", d.funcName(e.Index))
+				}
+				d.printf(" -> %q\n", e.Field)
+			}
+		case *SectionStart:
+			d.printf("\nStart:\n - start function: %d%s\n", s.Index, d.funcName(s.Index))
+		case *SectionElement:
+			for i, e := range s.Entries {
+				d.printf(" - segment[%d] table=%d count=%d - init %s\n", i, e.Index, len(e.Elems), initExprDump(e.Offset))
+				for j, fi := range e.Elems {
+					d.printf("  - elem[%d] = func[%d]%s\n", j, fi, d.funcName(fi))
+				}
+			}
+		case *SectionCode:
+			fi := funcs - uint32(len(s.Bodies))
+			for i, body := range s.Bodies {
+				d.printf(" - func[%d] size=%d%s\n", fi+uint32(i), len(body.Code), d.funcName(fi+uint32(i)))
+			}
+		case *SectionData:
+			for i, seg := range s.Entries {
+				d.printf(" - segment[%d] memory=%d size=%d - init %s\n", i, seg.Index, len(seg.Data), initExprDump(seg.Offset))
+				if d.opts.Data {
+					d.hexDump(seg.Data)
+				}
+			}
+		case *SectionName:
+			d.printf("\nCustom:\n - name: %q\n", s.SectionName)
+			if s.Functions != nil {
+				for _, n := range s.Functions.Names {
+					d.printf("  - func[%d] <%s>\n", n.Index, n.Name)
+				}
+			}
+		case *SectionCustom:
+			d.printf("\nCustom:\n - name: %q\n", s.SectionName)
+			if d.opts.Data {
+				d.hexDump(s.Payload)
+			}
+		}
+	}
+}
+
+// hexDump prints b with 16 bytes per line, each line starting with the offset
+// and ending with the printable characters.
+func (d *dumper) hexDump(b []byte) {
+	for off := 0; off < len(b); off += 16 {
+		line := b[off:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		d.printf("  - %07x:", off)
+		for i := 0; i < 16; i++ {
+			if i%2 == 0 {
+				d.printf(" ")
+			}
+			if i < len(line) {
+				d.printf("%02x", line[i])
+			} else {
+				d.printf("  ")
+			}
+		}
+		d.printf("  ")
+		for _, c := range line {
+			if c < 0x20 || c >= 0x7f {
+				c = '.'
+			}
+			d.printf("%c", c)
+		}
+		d.printf("\n")
+	}
+}
+
+// code prints the disassembly of every function, with the offset of every
+// instruction in the function body.
+func (d *dumper) code() {
+	s := d.m.CodeSection()
+	if s == nil {
+		return
+	}
+	d.printf("\nCode Disassembly:\n")
+	fi := d.m.importedFunctions()
+	for _, body := range s.Bodies {
+		d.printf("\nfunc[%d]%s:\n", fi, d.funcName(fi))
+		for _, l := range body.Locals {
+			d.printf(" %d locals of type %s\n", l.Count, l.Type.String())
+		}
+		depth := 0
+		r := NewCodeReader(body.Code)
+		for {
+			in, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				d.printf(" %06x: error: %v\n", r.Offset(), err)
+				break
+			}
+			if in.Op == opEnd || in.Op == opElse {
+				depth--
+			}
+			raw := body.Code[in.Offset:r.Offset()]
+			hex := fmt.Sprintf("% x", raw)
+			if len(raw) > 8 {
+				hex = fmt.Sprintf("% x ...", raw[:8])
+			}
+			d.printf(" %06x: %-27s | %s%s", in.Offset, hex, strings.Repeat("  ", maxInt(depth, 0)), in.Format(TextCurrent))
+			if in.Op == opCall && d.funcName(uint32(in.Immediates[0])) != "" {
+				d.printf(" %s", d.funcName(uint32(in.Immediates[0]))[1:])
+			}
+			d.printf("\n")
+			switch in.Op {
+			case opBlock, opLoop, opIf, opElse:
+				depth++
+			}
+		}
+		fi++
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func limitsDump(l ResizableLimits) string {
+	s := fmt.Sprintf("initial=%d", l.Initial)
+	if l.HasMaximum {
+		s += fmt.Sprintf(" max=%d", l.Maximum)
+	}
+	if l.Shared {
+		s += " shared"
+	}
+	return s
+}
+
+// initExprDump returns the instructions of an init expression without the
+// terminating end.
+func initExprDump(expr []byte) string {
+	ins, err := Disassemble(expr)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	var s []string
+	for _, in := range ins {
+		if in.Op != opEnd {
+			s = append(s, in.Format(TextCurrent))
+		}
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,48 @@
+package wasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteDump(t *testing.T) {
+	mod := instrumentModule()
+	mod.Sections = append(mod.Sections, &SectionData{Entries: []DataSegment{
+		{Offset: []byte{0x41, 0x80, 0x08, 0x0b}, Data: []byte("hello, world\x00\x01\x02\x03 and more")},
+	}})
+	mod.CodeSection().Bodies[1].Locals = []LocalEntry{{Count: 2, Type: 0x7f}}
+
+	var buf bytes.Buffer
+	if err := WriteDump(&buf, mod, DumpOptions{Code: true, Data: true}); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, buf.Bytes(), "golden/module.dump")
+}
+
+func TestWriteDumpHelloWorld(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDump(&buf, mod, DumpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	for _, want := range []string{
+		"      Code start=0x000014d4 end=0x0012023f (size=0x0011ed6b) count: 1586\n",
+		" - func[5] sig=1 <- go.runtime.scheduleCallback\n",
+		" - func[864] <_rt0_wasm_js> -> \"run\"\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Expected dump to contain %q", want)
+		}
+	}
+	if strings.Contains(s, "Code Disassembly") {
+		t.Error("Expected no disassembly")
+	}
+}
@@ -0,0 +1,125 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// An EmbeddedModule is a module found in text by FindEmbedded.
+type EmbeddedModule struct {
+	// Offset and Length locate the encoded module in the text.
+	Offset int
+	Length int
+
+	// Encoding is the encoding of the module in the text, "base64" or
+	// "hex".
+	Encoding string
+
+	// Data is the decoded module.
+	Data []byte
+
+	// Module is the parsed module, or nil if it could not be parsed.
+	Module *Module
+
+	// Err is the error from parsing the module.
+	Err error
+}
+
+// Prefixes that start an encoded module: the magic number followed by the
+// version.
+var (
+	base64Magic = base64.StdEncoding.EncodeToString([]byte("\x00asm\x01\x00\x00\x00"))[:10]
+	hexMagic    = "0061736d01000000"
+)
+
+// FindEmbedded finds modules that are embedded in text as base64 or as hex,
+// such as in the data URLs and strings that bundlers use to inline modules in
+// JavaScript, HTML or JSON. Every module is decoded and parsed. A module that
+// fails to parse is returned with the error set.
+//
+// A module is recognized by its encoded magic number and version, and extends
+// as far as the encoding allows, for example to the end of the string that
+// contains it.
+func FindEmbedded(text []byte) []EmbeddedModule {
+	var modules []EmbeddedModule
+
+	for _, i := range indexAll(text, []byte(base64Magic), false) {
+		n := 0
+		for i+n < len(text) && isBase64(text[i+n]) {
+			n++
+		}
+		enc := bytes.TrimRight(text[i:i+n], "=")
+		// Drop a trailing partial quantum that can't be decoded.
+		if len(enc)%4 == 1 {
+			enc = enc[:len(enc)-1]
+		}
+		data, err := base64.RawStdEncoding.DecodeString(string(enc))
+		if err != nil {
+			continue
+		}
+		modules = append(modules, embeddedModule(i, n, "base64", data))
+	}
+
+	for _, i := range indexAll(text, []byte(hexMagic), true) {
+		n := 0
+		for i+n < len(text) && isHex(text[i+n]) {
+			n++
+		}
+		data, err := hex.DecodeString(string(text[i : i+n-n%2]))
+		if err != nil {
+			continue
+		}
+		modules = append(modules, embeddedModule(i, n, "hex", data))
+	}
+
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Offset < modules[j].Offset })
+	return modules
+}
+
+func embeddedModule(offset, length int, encoding string, data []byte) EmbeddedModule {
+	e := EmbeddedModule{
+		Offset:   offset,
+		Length:   length,
+		Encoding: encoding,
+		Data:     data,
+	}
+	e.Module, e.Err = ParseBytes(data)
+	if e.Err != nil {
+		e.Module = nil
+		e.Err = fmt.Errorf("%s module at %d: %v", encoding, offset, e.Err)
+	}
+	return e
+}
+
+// indexAll returns the offsets of sep in s that are not preceded by a
+// character of the same encoding, as the encoded module would then start
+// earlier.
+func indexAll(s, sep []byte, fold bool) []int {
+	var idx []int
+	search := s
+	if fold {
+		search = bytes.ToLower(s)
+	}
+	for i := 0; ; {
+		j := bytes.Index(search[i:], sep)
+		if j < 0 {
+			return idx
+		}
+		i += j
+		if i == 0 || fold && !isHex(s[i-1]) || !fold && !isBase64(s[i-1]) {
+			idx = append(idx, i)
+		}
+		i += len(sep)
+	}
+}
+
+func isBase64(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/' || c == '='
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,69 @@
+package wasm
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindEmbedded(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := ioutil.ReadFile(filepath.Join("testdata", "empty.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.StdEncoding.EncodeToString(b)
+	hx := strings.ToUpper(hex.EncodeToString(empty))
+	broken := base64.StdEncoding.EncodeToString(b[:100])
+	text := fmt.Sprintf(`const wasm = "data:application/wasm;base64,%s";
+{"module": "%s"}
+load('%s');
+`, b64, hx, broken)
+
+	modules := FindEmbedded([]byte(text))
+	if len(modules) != 3 {
+		t.Fatalf("Expected 3 modules, got %d", len(modules))
+	}
+
+	m := modules[0]
+	if m.Encoding != "base64" || text[m.Offset:m.Offset+m.Length] != b64 {
+		t.Errorf("Expected base64 module at %d, got %s at %d", strings.Index(text, b64), m.Encoding, m.Offset)
+	}
+	if m.Err != nil || len(m.Module.Sections) != 12 || len(m.Data) != len(b) {
+		t.Errorf("Expected helloworld module, got %v", m.Err)
+	}
+
+	m = modules[1]
+	if m.Encoding != "hex" || text[m.Offset:m.Offset+m.Length] != hx {
+		t.Errorf("Expected hex module at %d, got %s at %d", strings.Index(text, hx), m.Encoding, m.Offset)
+	}
+	if m.Err != nil || m.Module == nil {
+		t.Errorf("Expected empty module, got %v", m.Err)
+	}
+
+	m = modules[2]
+	if m.Err == nil || m.Module != nil {
+		t.Error("Expected error parsing truncated module")
+	}
+}
+
+func TestFindEmbeddedNone(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"no modules here",
+		"xAGFzbQEAAAA", // doesn't start a base64 string
+		"0061736d0100",
+	} {
+		if modules := FindEmbedded([]byte(text)); len(modules) != 0 {
+			t.Errorf("Expected no modules in %q, got %d", text, len(modules))
+		}
+	}
+}
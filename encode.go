@@ -0,0 +1,570 @@
+package wasm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// EncodeOptions controls the behavior of EncodeWithOptions.
+type EncodeOptions struct {
+	// Placement controls the position of custom sections and the alignment
+	// of sections. If nil, the sections are written in the order they appear
+	// in the module, without padding.
+	Placement *Placement
+
+	// LEB selects the encoding of unsigned LEB128 values. Function bodies,
+	// init expressions and custom section payloads are always written as
+	// they are.
+	LEB LEBEncoding
+}
+
+// A Placement describes the layout of an encoded module, much like a linker
+// script. It's meant for tools that memory map sections at runtime and need
+// them at aligned offsets.
+//
+// Sections are referred to by name: known sections by their section name
+// ("Type", "Code", "Data", ...) and custom sections by their custom section
+// name ("name", "producers", ...).
+type Placement struct {
+	// Order positions custom sections relative to the known sections and
+	// each other. Every custom section listed is placed directly after the
+	// section preceding it in Order, or at the start of the module if it's
+	// the first entry. For example, to place the "producers" section after
+	// the data section, followed by the "name" section:
+	//
+	//	Order: []string{"Data", "producers", "name"}
+	//
+	// Known sections must be listed in the order the spec requires; they
+	// are never moved. If a listed known section is not in the module, the
+	// custom sections following it are placed before the next known
+	// section. Custom sections that are not listed keep their
+	// position relative to the sections around them. Names that don't
+	// appear in the module are ignored.
+	Order []string
+
+	// Align maps section names to the alignment of their contents in the
+	// file. The contents of a known section start after its size, the
+	// contents of a custom section after its name.
+	//
+	// Sections are aligned by widening the encoding of the section size
+	// where possible, and otherwise by inserting a custom section named
+	// PadName before the section.
+	Align map[string]int
+
+	// PadName is the name of inserted padding sections. It defaults to
+	// "padding".
+	PadName string
+}
+
+// LEBEncoding selects how the encoder writes unsigned LEB128 values, such as
+// sizes, counts and indices.
+type LEBEncoding int
+
+const (
+	// LEBCanonical encodes every value in as few bytes as possible, which
+	// gives the smallest output.
+	LEBCanonical LEBEncoding = iota
+
+	// LEBPreserve keeps the widths of values that are encoded in more bytes
+	// than needed in the parsed input. Toolchains pad values that the linker
+	// patches later, such as the section sizes and function body sizes of
+	// object files, and re-encoding them minimally would break the
+	// relocations. An unmodified module is encoded to the exact bytes it was
+	// parsed from.
+	//
+	// The values of a section are matched to the parsed ones by their
+	// position in the section, and a padded width is only kept if the value
+	// is unchanged. Sections that weren't parsed are encoded canonically.
+	LEBPreserve
+)
+
+// Encode writes m to w in the binary format.
+func Encode(w io.Writer, m *Module) error {
+	return EncodeWithOptions(w, m, EncodeOptions{})
+}
+
+// EncodeWithOptions writes m to w in the binary format using the given
+// options.
+func EncodeWithOptions(w io.Writer, m *Module, opts EncodeOptions) error {
+	secs := make([]*encodedSection, len(m.Sections))
+	for i, s := range m.Sections {
+		es, err := encodeSectionLEB(s, opts.LEB)
+		if err != nil {
+			return fmt.Errorf("encode section %d: %v", i, err)
+		}
+		secs[i] = es
+	}
+
+	var out []byte
+	out = append(out, 0x00, 0x61, 0x73, 0x6d) // \0asm
+	out = append(out, 0x01, 0x00, 0x00, 0x00) // version
+
+	if pl := opts.Placement; pl != nil {
+		var err error
+		if secs, err = pl.order(secs); err != nil {
+			return fmt.Errorf("placement: %v", err)
+		}
+		if out, err = pl.layout(out, secs); err != nil {
+			return fmt.Errorf("placement: %v", err)
+		}
+	} else {
+		for _, s := range secs {
+			out = s.appendTo(out, 0)
+		}
+	}
+
+	_, err := w.Write(out)
+	return err
+}
+
+// An encodedSection is the encoding of a section, without the section header.
+type encodedSection struct {
+	id      sectionID
+	name    string // section name, or name of a custom section
+	content []byte
+
+	// prefix is the length of the custom section name at the start of
+	// content.
+	prefix int
+
+	// sizeWidth is the minimum number of bytes to encode the size in.
+	sizeWidth int
+}
+
+// appendTo appends the section to b, encoding the size in at least width
+// bytes.
+func (s *encodedSection) appendTo(b []byte, width int) []byte {
+	if width < s.sizeWidth {
+		width = s.sizeWidth
+	}
+	b = append(b, byte(s.id))
+	b = appendPaddedUint(b, uint64(len(s.content)), width)
+	return append(b, s.content...)
+}
+
+func (pl *Placement) order(secs []*encodedSection) ([]*encodedSection, error) {
+	known := make(map[string]sectionID)
+	for id := secType; id <= secData; id++ {
+		known[id.String()] = id
+	}
+
+	listed := make(map[string]bool)
+	var last sectionID
+	var lastName string
+	for _, name := range pl.Order {
+		if id, ok := known[name]; ok {
+			if id <= last {
+				return nil, fmt.Errorf("%s listed after %s", name, lastName)
+			}
+			last, lastName = id, name
+			continue
+		}
+		listed[name] = true
+	}
+
+	var rest []*encodedSection
+	for _, s := range secs {
+		if s.id != secCustom || !listed[s.name] {
+			rest = append(rest, s)
+		}
+	}
+
+	insert := 0
+	for _, name := range pl.Order {
+		if id, ok := known[name]; ok {
+			// Insert after the section, or where it would be.
+			insert = len(rest)
+			for i, s := range rest {
+				if s.id != secCustom && s.id > id {
+					insert = i
+					break
+				}
+				if s.id == id {
+					insert = i + 1
+					break
+				}
+			}
+			continue
+		}
+		for _, s := range secs {
+			if s.id == secCustom && s.name == name {
+				rest = append(rest[:insert], append([]*encodedSection{s}, rest[insert:]...)...)
+				insert++
+			}
+		}
+	}
+	return rest, nil
+}
+
+func (pl *Placement) layout(out []byte, secs []*encodedSection) ([]byte, error) {
+	padName := pl.PadName
+	if padName == "" {
+		padName = "padding"
+	}
+
+	for _, s := range secs {
+		align := pl.Align[s.name]
+		if align < 0 {
+			return nil, fmt.Errorf("invalid alignment %d for %s", align, s.name)
+		}
+		if align <= 1 {
+			out = s.appendTo(out, 0)
+			continue
+		}
+
+		// Find the narrowest encoding of the size that aligns the
+		// contents.
+		min := leb128.UintSize(uint64(len(s.content)))
+		if s.sizeWidth > min {
+			min = s.sizeWidth
+		}
+		aligned := func(pos, width int) bool {
+			return (pos+1+width+s.prefix)%align == 0
+		}
+		width := 0
+		for w := min; w <= 5; w++ {
+			if aligned(len(out), w) {
+				width = w
+				break
+			}
+		}
+
+		if width == 0 {
+			// Insert a padding section that moves the section to an
+			// aligned position.
+			pad := 0
+			for n := 3 + len(padName); n < 3+len(padName)+align+5; n++ {
+				if aligned(len(out)+n, min) && paddingFits(n, padName) {
+					pad = n
+					break
+				}
+			}
+			if pad == 0 {
+				return nil, fmt.Errorf("can't align %s to %d", s.name, align)
+			}
+			out = appendPadding(out, pad, padName)
+			width = min
+		}
+
+		out = s.appendTo(out, width)
+	}
+
+	return out, nil
+}
+
+// paddingFits reports whether a custom section called name can be exactly n
+// bytes long.
+func paddingFits(n int, name string) bool {
+	_, ok := paddingSizeWidth(n, name)
+	return ok
+}
+
+// paddingSizeWidth returns the width of the size of a custom section called
+// name that is n bytes long.
+func paddingSizeWidth(n int, name string) (int, bool) {
+	for w := 1; w <= 5; w++ {
+		c := n - 1 - w
+		if c >= leb128.UintSize(uint64(len(name)))+len(name) && leb128.UintSize(uint64(c)) <= w {
+			return w, true
+		}
+	}
+	return 0, false
+}
+
+// appendPadding appends a custom section called name that is n bytes long.
+func appendPadding(b []byte, n int, name string) []byte {
+	w, _ := paddingSizeWidth(n, name)
+	c := n - 1 - w
+	b = append(b, byte(secCustom))
+	b = appendPaddedUint(b, uint64(c), w)
+	b = appendName(b, name)
+	return append(b, make([]byte, c-leb128.UintSize(uint64(len(name)))-len(name))...)
+}
+
+// appendPaddedUint appends the encoding of v, padded to at least width bytes.
+func appendPaddedUint(b []byte, v uint64, width int) []byte {
+	n := leb128.UintSize(v)
+	if width <= n {
+		return leb128.AppendUint(b, v)
+	}
+	for i := 0; i < width-1; i++ {
+		b = append(b, byte(v&0x7F)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendName(b []byte, s string) []byte {
+	b = leb128.AppendUint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// encodeSection encodes s with canonical LEB128 values.
+func encodeSection(s Section) (*encodedSection, error) {
+	return encodeSectionLEB(s, LEBCanonical)
+}
+
+func encodeSectionLEB(s Section, leb LEBEncoding) (*encodedSection, error) {
+	var b []byte
+	es := &encodedSection{}
+	var h *section
+	if s, ok := s.(interface{ header() *section }); ok {
+		h = s.header()
+	}
+	enc := &uintEncoder{}
+	if leb == LEBPreserve && h != nil {
+		enc.padded = h.padded
+	}
+
+	switch s := s.(type) {
+	case *SectionCustom:
+		es.id, es.name = secCustom, s.SectionName
+		b = enc.name(b, s.SectionName)
+		es.prefix = len(b)
+		b = append(b, s.Payload...)
+	case *SectionName:
+		es.id, es.name = secCustom, s.SectionName
+		b = enc.name(b, s.SectionName)
+		es.prefix = len(b)
+		b = enc.nameSubsections(b, s)
+	case *SectionType:
+		es.id = secType
+		b = enc.u32(b, uint32(len(s.Entries)))
+		for _, t := range s.Entries {
+			b = append(b, byte(t.Form))
+			b = enc.u32(b, uint32(len(t.Params)))
+			for _, p := range t.Params {
+				b = append(b, byte(p))
+			}
+			b = enc.u32(b, uint32(len(t.ReturnTypes)))
+			for _, r := range t.ReturnTypes {
+				b = append(b, byte(r))
+			}
+		}
+	case *SectionImport:
+		es.id = secImport
+		b = enc.u32(b, uint32(len(s.Entries)))
+		for i, e := range s.Entries {
+			b = enc.name(b, e.Module)
+			b = enc.name(b, e.Field)
+			b = append(b, byte(e.Kind))
+			switch {
+			case e.Kind == ExtKindFunction && e.FunctionType != nil:
+				b = enc.u32(b, e.FunctionType.Index)
+			case e.Kind == ExtKindTable && e.TableType != nil:
+				b = append(b, byte(e.TableType.ElemType))
+				b = enc.limits(b, e.TableType.Limits)
+			case e.Kind == ExtKindMemory && e.MemoryType != nil:
+				b = enc.limits(b, e.MemoryType.Limits)
+			case e.Kind == ExtKindGlobal && e.GlobalType != nil:
+				b = append(b, byte(e.GlobalType.ContentType))
+				b = appendBool(b, e.GlobalType.Mutable)
+			default:
+				return nil, fmt.Errorf("import %d: missing type for kind %d", i, e.Kind)
+			}
+		}
+	case *SectionFunction:
+		es.id = secFunction
+		b = enc.u32(b, uint32(len(s.Types)))
+		for _, t := range s.Types {
+			b = enc.u32(b, t)
+		}
+	case *SectionTable:
+		es.id = secTable
+		b = enc.u32(b, uint32(len(s.Entries)))
+		for _, t := range s.Entries {
+			b = append(b, byte(t.ElemType))
+			b = enc.limits(b, t.Limits)
+		}
+	case *SectionMemory:
+		es.id = secMemory
+		b = enc.u32(b, uint32(len(s.Entries)))
+		for _, m := range s.Entries {
+			b = enc.limits(b, m.Limits)
+		}
+	case *SectionGlobal:
+		es.id = secGlobal
+		b = enc.u32(b, uint32(len(s.Globals)))
+		for _, g := range s.Globals {
+			b = append(b, byte(g.Type.ContentType))
+			b = appendBool(b, g.Type.Mutable)
+			b = append(b, g.Init...)
+		}
+	case *SectionExport:
+		es.id = secExport
+		b = enc.u32(b, uint32(len(s.Entries)))
+		for _, e := range s.Entries {
+			b = enc.name(b, e.Field)
+			b = append(b, byte(e.Kind))
+			b = enc.u32(b, e.Index)
+		}
+	case *SectionStart:
+		es.id = secStart
+		b = enc.u32(b, s.Index)
+	case *SectionElement:
+		es.id = secElement
+		b = enc.u32(b, uint32(len(s.Entries)))
+		for _, e := range s.Entries {
+			if e.Index == 0 && !e.ExplicitIndex {
+				b = enc.u32(b, 0)
+				b = append(b, e.Offset...)
+			} else {
+				// Flags 2: explicit table index, element kind funcref.
+				b = enc.u32(b, 2)
+				b = enc.u32(b, e.Index)
+				b = append(b, e.Offset...)
+				b = append(b, 0)
+			}
+			b = enc.u32(b, uint32(len(e.Elems)))
+			for _, fi := range e.Elems {
+				b = enc.u32(b, fi)
+			}
+		}
+	case *SectionCode:
+		es.id = secCode
+		b = enc.u32(b, uint32(len(s.Bodies)))
+		var body []byte
+		for _, f := range s.Bodies {
+			// The size precedes the body, but is known only after
+			// encoding the locals.
+			size := enc.next()
+			body = enc.u32(body[:0], uint32(len(f.Locals)))
+			for _, l := range f.Locals {
+				body = enc.u32(body, l.Count)
+				body = append(body, byte(l.Type))
+			}
+			body = append(body, f.Code...)
+			b = enc.appendAt(b, size, uint32(len(body)))
+			b = append(b, body...)
+		}
+	case *SectionData:
+		es.id = secData
+		b = enc.u32(b, uint32(len(s.Entries)))
+		for _, d := range s.Entries {
+			b = enc.u32(b, d.Index)
+			b = append(b, d.Offset...)
+			b = enc.u32(b, uint32(len(d.Data)))
+			b = append(b, d.Data...)
+		}
+	default:
+		return nil, fmt.Errorf("unknown section type %T", s)
+	}
+
+	if es.id != secCustom {
+		es.name = es.id.String()
+	}
+	es.content = b
+	if leb == LEBPreserve && h != nil && uint32(len(b)) == h.size {
+		es.sizeWidth = h.sizeWidth
+	}
+	return es, nil
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0x01)
+	}
+	return append(b, 0x00)
+}
+
+// A uintEncoder appends the varuint32 values of a section. Values that were
+// padded in the parsed section keep their width if they are unchanged; they are
+// matched by their position among the values, so they must be appended in the
+// order the parser reads them.
+type uintEncoder struct {
+	padded map[int]paddedUint
+	n      int // number of values appended or reserved
+}
+
+// next reserves the position of a value that is appended later with appendAt.
+func (e *uintEncoder) next() int {
+	e.n++
+	return e.n - 1
+}
+
+// appendAt appends v as the value at position i.
+func (e *uintEncoder) appendAt(b []byte, i int, v uint32) []byte {
+	if p, ok := e.padded[i]; ok && p.value == v {
+		return appendPaddedUint(b, uint64(v), p.width)
+	}
+	return leb128.AppendUint(b, uint64(v))
+}
+
+func (e *uintEncoder) u32(b []byte, v uint32) []byte {
+	return e.appendAt(b, e.next(), v)
+}
+
+func (e *uintEncoder) name(b []byte, s string) []byte {
+	b = e.u32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func (e *uintEncoder) limits(b []byte, l ResizableLimits) []byte {
+	var flags byte
+	if l.Shared {
+		flags |= limitsShared
+	}
+	if !l.HasMaximum {
+		return e.u32(append(b, flags), l.Initial)
+	}
+	return e.u32(e.u32(append(b, flags|limitsHasMax), l.Initial), l.Maximum)
+}
+
+// nameSubsections appends the subsections of a name section.
+func (e *uintEncoder) nameSubsections(b []byte, s *SectionName) []byte {
+	sub := func(id uint8, payload func([]byte) []byte) {
+		size := e.next()
+		p := payload(nil)
+		b = append(b, id)
+		b = e.appendAt(b, size, uint32(len(p)))
+		b = append(b, p...)
+	}
+	// The other subsections are written in order of their ids, between the
+	// ones that are parsed.
+	other := s.Other
+	flush := func(id int) {
+		for len(other) > 0 && int(other[0].ID) < id {
+			payload := other[0].Payload
+			sub(other[0].ID, func(p []byte) []byte {
+				return append(p, payload...)
+			})
+			other = other[1:]
+		}
+	}
+	flush(int(nameTypeModule))
+	if s.Module != "" {
+		sub(nameTypeModule, func(p []byte) []byte {
+			return e.name(p, s.Module)
+		})
+	}
+	flush(int(nameTypeFunction))
+	if s.Functions != nil {
+		sub(nameTypeFunction, func(p []byte) []byte {
+			return e.nameMap(p, s.Functions)
+		})
+	}
+	flush(int(nameTypeLocal))
+	if s.Locals != nil {
+		sub(nameTypeLocal, func(p []byte) []byte {
+			p = e.u32(p, uint32(len(s.Locals.Funcs)))
+			for _, l := range s.Locals.Funcs {
+				p = e.u32(p, l.Index)
+				p = e.nameMap(p, &l.LocalMap)
+			}
+			return p
+		})
+	}
+	flush(0x100)
+	return b
+}
+
+func (e *uintEncoder) nameMap(b []byte, m *NameMap) []byte {
+	b = e.u32(b, uint32(len(m.Names)))
+	for _, n := range m.Names {
+		b = e.u32(b, n.Index)
+		b = e.name(b, n.Name)
+	}
+	return b
+}
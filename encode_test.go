@@ -0,0 +1,391 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, file := range []string{"empty.wasm", "helloworld.wasm"} {
+		t.Run(file, func(t *testing.T) {
+			b, err := ioutil.ReadFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			mod, err := ParseBytes(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, mod); err != nil {
+				t.Fatal(err)
+			}
+			actual, err := ParseBytes(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertSameModule(t, mod, actual)
+		})
+	}
+}
+
+func TestEncodeLEB(t *testing.T) {
+	// The section sizes, a function type index and a body size are
+	// padded to 5 and 2 bytes, as in object files.
+	b := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x84, 0x80, 0x80, 0x80, 0x00, 0x01, 0x60, 0x00, 0x00,
+		0x03, 0x03, 0x01, 0x80, 0x00,
+		0x0a, 0x88, 0x80, 0x80, 0x80, 0x00, 0x01, 0x82, 0x80, 0x80, 0x80, 0x00, 0x00, 0x0b,
+	}
+	mod, err := ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeWithOptions(&buf, mod, EncodeOptions{LEB: LEBPreserve}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("Expected padding to be preserved\nexpected % x\ngot      % x", b, buf.Bytes())
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	canonical := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+		0x03, 0x02, 0x01, 0x00,
+		0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b,
+	}
+	if !bytes.Equal(buf.Bytes(), canonical) {
+		t.Errorf("Expected canonical encoding\nexpected % x\ngot      % x", canonical, buf.Bytes())
+	}
+
+	// Changed values are encoded canonically, which also changes the size
+	// of the code section.
+	mod.CodeSection().Bodies[0].Code = []byte{0x01, 0x0b}
+	buf.Reset()
+	if err := EncodeWithOptions(&buf, mod, EncodeOptions{LEB: LEBPreserve}); err != nil {
+		t.Fatal(err)
+	}
+	expected := append(append([]byte(nil), b[:23]...), 0x0a, 0x05, 0x01, 0x03, 0x00, 0x01, 0x0b)
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("Expected changed body to be encoded canonically\nexpected % x\ngot      % x", expected, buf.Bytes())
+	}
+}
+
+func TestEncodeLEBHelloWorld(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, concurrent := range []bool{false, true} {
+		mod, err := ParseBytesWithOptions(b, ParseOptions{Concurrent: concurrent})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := EncodeWithOptions(&buf, mod, EncodeOptions{LEB: LEBPreserve}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), b) {
+			t.Errorf("Concurrent %v: expected the input, got %d bytes instead of %d", concurrent, buf.Len(), len(b))
+		}
+	}
+}
+
+func TestEncodeNameSubsections(t *testing.T) {
+	b := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x00, 0x26, // custom section
+		0x04, 'n', 'a', 'm', 'e',
+		0x00, 0x02, 0x01, 'm', // module
+		0x01, 0x84, 0x00, 0x01, 0x00, 0x01, 'f', // functions, padded size
+		0x02, 0x06, 0x01, 0x00, 0x01, 0x00, 0x01, 'x', // locals
+		0x03, 0x06, 0x01, 0x00, 0x01, 0x00, 0x01, 'l', // labels
+		0x07, 0x04, 0x01, 0x00, 0x01, 'g', // globals
+	}
+	mod, err := ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := mod.Sections[0].(*SectionName)
+	if s.Module != "m" || len(s.Functions.Names) != 1 || len(s.Locals.Funcs) != 1 {
+		t.Errorf("Expected module, function and local names, got %+v", s)
+	}
+	if len(s.Other) != 2 || s.Other[0].ID != 3 || s.Other[1].ID != 7 {
+		t.Errorf("Expected label and global subsections, got %+v", s.Other)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeWithOptions(&buf, mod, EncodeOptions{LEB: LEBPreserve}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("Expected % x, got % x", b, buf.Bytes())
+	}
+}
+
+func TestEncodeLimits(t *testing.T) {
+	mod := &Module{Sections: []Section{
+		&SectionImport{Entries: []ImportEntry{
+			{Module: "env", Field: "memory", Kind: ExtKindMemory, MemoryType: &MemoryType{Limits: ResizableLimits{Initial: 1, Maximum: 16, HasMaximum: true, Shared: true}}},
+		}},
+		&SectionMemory{Entries: []MemoryType{{Limits: ResizableLimits{Initial: 2}}}},
+	}}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte{0x02, 0x03, 0x01, 0x10}) {
+		t.Errorf("Expected shared memory limits with flags 0x03 in % x", buf.Bytes())
+	}
+	actual, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameModule(t, mod, actual)
+
+	// Unknown limits flags are rejected.
+	b := bytes.Replace(buf.Bytes(), []byte{0x02, 0x03, 0x01, 0x10}, []byte{0x02, 0x07, 0x01, 0x10}, 1)
+	if _, err := ParseBytes(b); err == nil {
+		t.Error("Expected error for unknown limits flags")
+	}
+
+	// A maximum of 0 is kept.
+	for _, sec := range [][]byte{
+		{0x05, 0x04, 0x01, 0x01, 0x00, 0x00}, // (memory 0 0)
+		{0x05, 0x03, 0x01, 0x00, 0x00},       // (memory 0)
+		{0x05, 0x04, 0x01, 0x03, 0x00, 0x00}, // (memory 0 0 shared)
+	} {
+		in := append([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, sec...)
+		mod, err := ParseBytes(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Reset()
+		if err := Encode(&buf, mod); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), in) {
+			t.Errorf("Expected % x, got % x", in, buf.Bytes())
+		}
+	}
+}
+
+func TestEncodeTables(t *testing.T) {
+	mod := &Module{Sections: []Section{
+		&SectionType{Entries: []FuncType{{Form: 0x60, ReturnCount: 1, ReturnTypes: []ValueType{0x7f}}}},
+		&SectionFunction{Types: []uint32{0}},
+		&SectionTable{Entries: []TableType{
+			{ElemType: 0x70, Limits: ResizableLimits{Initial: 1}},
+			{ElemType: 0x6f, Limits: ResizableLimits{Initial: 2, Maximum: 4, HasMaximum: true}},
+			{ElemType: 0x70, Limits: ResizableLimits{Initial: 3}},
+		}},
+		&SectionElement{Entries: []ElemSegment{
+			{Offset: []byte{0x41, 0x00, 0x0b}, Elems: []uint32{0}},
+			{Index: 2, Offset: []byte{0x41, 0x01, 0x0b}, Elems: []uint32{0, 0}},
+		}},
+		&SectionCode{Bodies: []FunctionBody{{Code: []byte{0x41, 0x00, 0x0b}}}},
+	}}
+	if err := mod.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	// Segment 1 has flags 2 with the table index and element kind.
+	if !bytes.Contains(buf.Bytes(), []byte{0x02, 0x02, 0x41, 0x01, 0x0b, 0x00, 0x02}) {
+		t.Errorf("Expected element segment with explicit table index in % x", buf.Bytes())
+	}
+	actual, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameModule(t, mod, actual)
+
+	// Passive segments aren't supported.
+	b := bytes.Replace(buf.Bytes(), []byte{0x02, 0x02, 0x41}, []byte{0x01, 0x02, 0x41}, 1)
+	if _, err := ParseBytes(b); err == nil {
+		t.Error("Expected error for passive element segment")
+	}
+
+	// An explicit index of table 0 is kept.
+	b = []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x09, 0x0b, 0x01, // element section, 1 segment
+		0x02, 0x00, // flags 2, table 0
+		0x41, 0x00, 0x0b, // offset
+		0x00,                   // element kind
+		0x02, 0x80, 0x00, 0x00, // 2 elements, the first one padded
+	}
+	mod, err = ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := EncodeWithOptions(&buf, mod, EncodeOptions{LEB: LEBPreserve}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), b) {
+		t.Errorf("Expected % x, got % x", b, buf.Bytes())
+	}
+}
+
+func assertSameModule(t *testing.T, expected, actual *Module) {
+	t.Helper()
+	ej, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aj, err := json.Marshal(actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ej, aj) {
+		t.Fatal("Module does not match")
+	}
+}
+
+// sectionLayout returns the name and the offset of the contents of every
+// section in an encoded module.
+func sectionLayout(t *testing.T, b []byte) (names []string, offsets []int) {
+	t.Helper()
+	i := 8
+	for i < len(b) {
+		id := sectionID(b[i])
+		size, n, err := leb128.DecodeUint(b[i+1:], 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := i + 1 + n
+		name, offset := id.String(), start
+		if id == secCustom {
+			l, n, err := leb128.DecodeUint(b[start:], 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name = string(b[start+n : start+n+int(l)])
+			offset = start + n + int(l)
+		}
+		names = append(names, name)
+		offsets = append(offsets, offset)
+		i = start + int(size)
+	}
+	return names, offsets
+}
+
+func TestEncodePlacement(t *testing.T) {
+	mod := &Module{Sections: []Section{
+		&SectionCustom{SectionName: "a", Payload: []byte("a")},
+		&SectionType{Entries: []FuncType{{Form: 0x60}}},
+		&SectionFunction{Types: []uint32{0}},
+		&SectionMemory{Entries: []MemoryType{{Limits: ResizableLimits{Initial: 1}}}},
+		&SectionCustom{SectionName: "b", Payload: []byte("b")},
+		&SectionCode{Bodies: []FunctionBody{{Code: []byte{0x0b}}}},
+		&SectionData{Entries: []DataSegment{{Offset: []byte{0x41, 0x00, 0x0b}, Data: []byte("data")}}},
+		&SectionCustom{SectionName: "c", Payload: []byte("c")},
+	}}
+
+	tt := []struct {
+		name      string
+		placement Placement
+		sections  []string
+		err       bool
+	}{
+		{
+			name:     "unchanged",
+			sections: []string{"a", "Type", "Function", "Memory", "b", "Code", "Data", "c"},
+		},
+		{
+			name:      "order",
+			placement: Placement{Order: []string{"c", "Function", "a", "Data", "b"}},
+			sections:  []string{"c", "Type", "Function", "a", "Memory", "Code", "Data", "b"},
+		},
+		{
+			name:      "missing anchor",
+			placement: Placement{Order: []string{"Global", "a", "x"}},
+			sections:  []string{"Type", "Function", "Memory", "b", "a", "Code", "Data", "c"},
+		},
+		{
+			name:      "align",
+			placement: Placement{Order: []string{"Data", "a"}, Align: map[string]int{"Data": 16, "a": 4096, "c": 3}},
+			sections:  []string{"Type", "Function", "Memory", "b", "Code", "padding", "Data", "padding", "a", "c"},
+		},
+		{
+			name:      "known out of order",
+			placement: Placement{Order: []string{"Data", "Code"}},
+			err:       true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := EncodeWithOptions(&buf, mod, EncodeOptions{Placement: &tc.placement})
+			if tc.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names, offsets := sectionLayout(t, buf.Bytes())
+			if !reflect.DeepEqual(names, tc.sections) {
+				t.Errorf("Sections do not match; expected %v, actual %v", tc.sections, names)
+			}
+			for i, name := range names {
+				if a := tc.placement.Align[name]; a > 0 && offsets[i]%a != 0 {
+					t.Errorf("Contents of %s at offset %d, expected alignment %d", name, offsets[i], a)
+				}
+			}
+
+			actual, err := ParseBytes(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(actual.Sections) != len(tc.sections) {
+				t.Errorf("Expected %d sections after parsing, got %d", len(tc.sections), len(actual.Sections))
+			}
+		})
+	}
+}
+
+func TestEncodeAlignAll(t *testing.T) {
+	// Every alignment that needs padding of any length must be possible.
+	for size := 0; size < 300; size += 7 {
+		for _, align := range []int{2, 3, 4, 8, 64, 100} {
+			mod := &Module{Sections: []Section{
+				&SectionCustom{SectionName: "x", Payload: make([]byte, size)},
+				&SectionCustom{SectionName: "y", Payload: []byte("y")},
+			}}
+			var buf bytes.Buffer
+			err := EncodeWithOptions(&buf, mod, EncodeOptions{Placement: &Placement{Align: map[string]int{"y": align}}})
+			if err != nil {
+				t.Fatalf("size %d, align %d: %v", size, align, err)
+			}
+			names, offsets := sectionLayout(t, buf.Bytes())
+			if offsets[len(offsets)-1]%align != 0 || names[len(names)-1] != "y" {
+				t.Fatalf("size %d, align %d: y at %d", size, align, offsets[len(offsets)-1])
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package interp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// Op codes with special handling in the compiler or interpreter. Numeric
+// instructions are referred to by value.
+const (
+	opUnreachable  = 0x00
+	opNop          = 0x01
+	opBlock        = 0x02
+	opLoop         = 0x03
+	opIf           = 0x04
+	opElse         = 0x05
+	opEnd          = 0x0b
+	opBr           = 0x0c
+	opBrIf         = 0x0d
+	opBrTable      = 0x0e
+	opReturn       = 0x0f
+	opCall         = 0x10
+	opCallIndirect = 0x11
+	opDrop         = 0x1a
+	opSelect       = 0x1b
+	opSelectT      = 0x1c
+	opLocalGet     = 0x20
+	opLocalSet     = 0x21
+	opLocalTee     = 0x22
+	opGlobalGet    = 0x23
+	opGlobalSet    = 0x24
+	opMemorySize   = 0x3f
+	opMemoryGrow   = 0x40
+	opI32Const     = 0x41
+	opI64Const     = 0x42
+	opF32Const     = 0x43
+	opF64Const     = 0x44
+	opPrefixMisc   = 0xfc
+)
+
+// Prefixed op codes are stored as 0xfcNN, where NN is the sub op code.
+const (
+	opMemoryCopy = 0xfc0a
+	opMemoryFill = 0xfc0b
+)
+
+// instr is a decoded instruction.
+type instr struct {
+	op uint16
+
+	// imm is the immediate of the instruction: an index, a branch depth,
+	// the raw value of a constant or a memory offset.
+	imm uint64
+
+	// imm2 is the table index of call_indirect.
+	imm2 uint32
+
+	// end is the index of the matching end for block, loop, if and else.
+	end int
+
+	// els is the index of the else for if, or -1 if there is no else.
+	els int
+
+	// params and results are the block type arity of block, loop and if.
+	params  int
+	results int
+
+	// table contains the branch depths of br_table. The default is in imm.
+	table []uint32
+}
+
+// code is a compiled function body.
+type code struct {
+	locals int
+	instrs []instr
+}
+
+// compile decodes a function body to a list of instructions, resolving the
+// targets of all structured control instructions.
+func compile(inst *Instance, body *wasm.FunctionBody) (*code, error) {
+	c := &code{}
+	for _, l := range body.Locals {
+		c.locals += int(l.Count)
+	}
+
+	d := &decoder{b: body.Code}
+	var blocks []int
+	for d.i < len(d.b) {
+		offset := d.i
+		in := instr{op: uint16(d.byte()), els: -1}
+
+		switch in.op {
+		case opBlock, opLoop, opIf:
+			p, r, err := inst.blockType(d)
+			if err != nil {
+				return nil, fmt.Errorf("0x%x: %v", offset, err)
+			}
+			in.params, in.results = p, r
+			blocks = append(blocks, len(c.instrs))
+		case opElse:
+			if len(blocks) == 0 || c.instrs[blocks[len(blocks)-1]].op != opIf {
+				return nil, fmt.Errorf("0x%x: else without if", offset)
+			}
+			c.instrs[blocks[len(blocks)-1]].els = len(c.instrs)
+			blocks = append(blocks, len(c.instrs))
+		case opEnd:
+			for len(blocks) > 0 {
+				b := blocks[len(blocks)-1]
+				blocks = blocks[:len(blocks)-1]
+				c.instrs[b].end = len(c.instrs)
+				if c.instrs[b].op != opElse {
+					break
+				}
+			}
+		case opBr, opBrIf, opCall, opLocalGet, opLocalSet, opLocalTee, opGlobalGet, opGlobalSet:
+			in.imm = uint64(d.u32())
+		case opBrTable:
+			n := d.u32()
+			if int(n) > len(d.b) {
+				return nil, fmt.Errorf("0x%x: br_table too large", offset)
+			}
+			in.table = make([]uint32, n)
+			for i := range in.table {
+				in.table[i] = d.u32()
+			}
+			in.imm = uint64(d.u32())
+		case opCallIndirect:
+			in.imm = uint64(d.u32())
+			in.imm2 = d.u32()
+		case opSelectT:
+			n := d.u32()
+			for i := uint32(0); i < n && d.err == nil; i++ {
+				d.byte()
+			}
+			in.op = opSelect
+		case opMemorySize, opMemoryGrow:
+			d.byte()
+		case opI32Const:
+			in.imm = I32(d.s32())
+		case opI64Const:
+			in.imm = I64(d.s64())
+		case opF32Const:
+			in.imm = uint64(binary.LittleEndian.Uint32(d.bytes(4)))
+		case opF64Const:
+			in.imm = binary.LittleEndian.Uint64(d.bytes(8))
+		case opPrefixMisc:
+			in.op = 0xfc00 | uint16(d.u32())
+			switch in.op {
+			case opMemoryCopy:
+				d.byte()
+				d.byte()
+			case opMemoryFill:
+				d.byte()
+			default:
+				if in.op > 0xfc07 {
+					return nil, fmt.Errorf("0x%x: unsupported op code 0x%04x", offset, in.op)
+				}
+			}
+		default:
+			switch {
+			case in.op >= 0x28 && in.op <= 0x3e:
+				// memory load/store: alignment, offset
+				d.u32()
+				in.imm = uint64(d.u32())
+			case in.op <= opNop, in.op == opReturn, in.op == opDrop, in.op == opSelect:
+			case in.op >= 0x45 && in.op <= 0xc4:
+				// numeric, no immediates
+			default:
+				return nil, fmt.Errorf("0x%x: unsupported op code 0x%02x", offset, in.op)
+			}
+		}
+		if d.err != nil {
+			return nil, fmt.Errorf("0x%x: %v", offset, d.err)
+		}
+
+		c.instrs = append(c.instrs, in)
+	}
+
+	if len(blocks) > 0 {
+		return nil, fmt.Errorf("unterminated block")
+	}
+	if len(c.instrs) == 0 || c.instrs[len(c.instrs)-1].op != opEnd {
+		return nil, fmt.Errorf("function body not terminated by end")
+	}
+
+	return c, nil
+}
+
+// blockType reads a block type and returns the number of parameters and
+// results of the block.
+func (inst *Instance) blockType(d *decoder) (int, int, error) {
+	t := d.int(33)
+	switch {
+	case d.err != nil:
+		return 0, 0, d.err
+	case t == -0x40:
+		// empty
+		return 0, 0, nil
+	case t < 0:
+		// single value type
+		return 0, 1, nil
+	}
+	typ, err := inst.funcType(uint32(t))
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(typ.Params), len(typ.ReturnTypes), nil
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of function body")
+
+// decoder reads immediates from a function body. The first error is recorded
+// in err, after which all reads return zero values.
+type decoder struct {
+	b   []byte
+	i   int
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.i >= len(d.b) {
+		d.err = errUnexpectedEnd
+		return 0
+	}
+	b := d.b[d.i]
+	d.i++
+	return b
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err == nil && d.i+n > len(d.b) {
+		d.err = errUnexpectedEnd
+	}
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	b := d.b[d.i : d.i+n]
+	d.i += n
+	return b
+}
+
+func (d *decoder) u32() uint32 {
+	return uint32(d.uint(32))
+}
+
+func (d *decoder) s32() int32 {
+	return int32(d.int(32))
+}
+
+func (d *decoder) s64() int64 {
+	return d.int(64)
+}
+
+func (d *decoder) uint(bits uint) uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n, err := leb128.DecodeUint(d.b[d.i:], bits)
+	if err != nil {
+		d.err = err
+		return 0
+	}
+	d.i += n
+	return v
+}
+
+func (d *decoder) int(bits uint) int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n, err := leb128.DecodeInt(d.b[d.i:], bits)
+	if err != nil {
+		d.err = err
+		return 0
+	}
+	d.i += n
+	return v
+}
@@ -0,0 +1,808 @@
+package interp
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// maxCallDepth limits the recursion depth of calls.
+const maxCallDepth = 10000
+
+// hostError is used to propagate an error returned by a host function up to
+// the caller of the exported function.
+type hostError struct {
+	err error
+}
+
+// machine holds the execution state of a call.
+type machine struct {
+	stack []uint64
+	depth int
+}
+
+// label is an entry in the control stack of a function.
+type label struct {
+	// cont is the index of the instruction to continue from when branching to
+	// the label.
+	cont int
+
+	// height is the height of the value stack when the block was entered.
+	height int
+
+	// arity is the number of values a branch to the label carries.
+	arity int
+
+	loop bool
+}
+
+func (m *machine) push(v uint64) {
+	m.stack = append(m.stack, v)
+}
+
+func (m *machine) pop() uint64 {
+	v := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return v
+}
+
+func (m *machine) pushI32(v int32)   { m.push(I32(v)) }
+func (m *machine) pushU32(v uint32)  { m.push(uint64(v)) }
+func (m *machine) pushI64(v int64)   { m.push(uint64(v)) }
+func (m *machine) pushF32(v float32) { m.push(F32(v)) }
+func (m *machine) pushF64(v float64) { m.push(F64(v)) }
+func (m *machine) pushBool(v bool) {
+	if v {
+		m.push(1)
+	} else {
+		m.push(0)
+	}
+}
+
+func (m *machine) popI32() int32   { return AsI32(m.pop()) }
+func (m *machine) popU32() uint32  { return uint32(m.pop()) }
+func (m *machine) popI64() int64   { return AsI64(m.pop()) }
+func (m *machine) popF32() float32 { return AsF32(m.pop()) }
+func (m *machine) popF64() float64 { return AsF64(m.pop()) }
+
+// unwind moves the top n values on the stack to height and discards
+// everything above them.
+func (m *machine) unwind(height, n int) {
+	copy(m.stack[height:], m.stack[len(m.stack)-n:])
+	m.stack = m.stack[:height+n]
+}
+
+// call calls f. The arguments are popped from the stack and the results are
+// pushed to it.
+func (m *machine) call(f *Function) {
+	n := len(f.typ.Params)
+	if len(m.stack) < n {
+		trap("stack underflow")
+	}
+
+	if f.host != nil {
+		args := make([]uint64, n)
+		copy(args, m.stack[len(m.stack)-n:])
+		m.stack = m.stack[:len(m.stack)-n]
+		res, err := f.host(f.inst, args)
+		if err != nil {
+			panic(hostError{err})
+		}
+		if len(res) != len(f.typ.ReturnTypes) {
+			trap("host function returned %d values, expected %d", len(res), len(f.typ.ReturnTypes))
+		}
+		m.stack = append(m.stack, res...)
+		return
+	}
+
+	if f.compiled == nil {
+		c, err := compile(f.inst, f.body)
+		if err != nil {
+			trap("invalid function body: %v", err)
+		}
+		f.compiled = c
+	}
+
+	m.depth++
+	if m.depth > maxCallDepth {
+		trap("call stack exhausted")
+	}
+
+	locals := make([]uint64, n+f.compiled.locals)
+	copy(locals, m.stack[len(m.stack)-n:])
+	m.stack = m.stack[:len(m.stack)-n]
+
+	m.exec(f, locals)
+	m.depth--
+}
+
+// exec executes the body of f.
+func (m *machine) exec(f *Function, locals []uint64) {
+	inst := f.inst
+	code := f.compiled.instrs
+	base := len(m.stack)
+	results := len(f.typ.ReturnTypes)
+
+	var labels []label
+
+	// branch branches to the label at depth and returns the index of the
+	// next instruction. A branch past the outermost label returns from the
+	// function.
+	branch := func(depth int) int {
+		if depth >= len(labels) {
+			return len(code)
+		}
+		l := labels[len(labels)-1-depth]
+		m.unwind(l.height, l.arity)
+		if l.loop {
+			labels = labels[:len(labels)-depth]
+		} else {
+			labels = labels[:len(labels)-1-depth]
+		}
+		return l.cont
+	}
+
+	pc := 0
+	for pc < len(code) {
+		in := &code[pc]
+		pc++
+
+		switch in.op {
+		case opUnreachable:
+			trap("unreachable")
+		case opNop:
+		case opBlock:
+			labels = append(labels, label{cont: in.end + 1, height: len(m.stack) - in.params, arity: in.results})
+		case opLoop:
+			labels = append(labels, label{cont: pc, height: len(m.stack) - in.params, arity: in.params, loop: true})
+		case opIf:
+			cond := m.popI32()
+			if cond != 0 {
+				labels = append(labels, label{cont: in.end + 1, height: len(m.stack) - in.params, arity: in.results})
+			} else if in.els >= 0 {
+				labels = append(labels, label{cont: in.end + 1, height: len(m.stack) - in.params, arity: in.results})
+				pc = in.els + 1
+			} else {
+				pc = in.end + 1
+			}
+		case opElse:
+			// end of the then branch
+			labels = labels[:len(labels)-1]
+			pc = in.end + 1
+		case opEnd:
+			if len(labels) > 0 {
+				labels = labels[:len(labels)-1]
+			}
+		case opBr:
+			pc = branch(int(in.imm))
+		case opBrIf:
+			if m.popI32() != 0 {
+				pc = branch(int(in.imm))
+			}
+		case opBrTable:
+			i := m.popU32()
+			if int(i) < len(in.table) {
+				pc = branch(int(in.table[i]))
+			} else {
+				pc = branch(int(in.imm))
+			}
+		case opReturn:
+			pc = len(code)
+		case opCall:
+			if int(in.imm) >= len(inst.funcs) {
+				trap("function %d not defined", in.imm)
+			}
+			m.call(inst.funcs[in.imm])
+		case opCallIndirect:
+			m.callIndirect(inst, in)
+		case opDrop:
+			m.pop()
+		case opSelect:
+			c := m.popI32()
+			b := m.pop()
+			a := m.pop()
+			if c != 0 {
+				m.push(a)
+			} else {
+				m.push(b)
+			}
+		case opLocalGet:
+			m.push(locals[in.imm])
+		case opLocalSet:
+			locals[in.imm] = m.pop()
+		case opLocalTee:
+			locals[in.imm] = m.stack[len(m.stack)-1]
+		case opGlobalGet:
+			m.push(inst.global(in.imm).Value)
+		case opGlobalSet:
+			inst.global(in.imm).Value = m.pop()
+		case opMemorySize:
+			m.pushU32(inst.memory().Size())
+		case opMemoryGrow:
+			m.pushI32(inst.memory().Grow(m.popU32()))
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			m.push(in.imm)
+		case opMemoryCopy:
+			mem := inst.memory()
+			n := uint64(m.popU32())
+			src := uint64(m.popU32())
+			dst := uint64(m.popU32())
+			if src+n > uint64(len(mem.data)) || dst+n > uint64(len(mem.data)) {
+				trap("out of bounds memory access")
+			}
+			copy(mem.data[dst:dst+n], mem.data[src:src+n])
+		case opMemoryFill:
+			mem := inst.memory()
+			n := uint64(m.popU32())
+			v := byte(m.popU32())
+			dst := uint64(m.popU32())
+			if dst+n > uint64(len(mem.data)) {
+				trap("out of bounds memory access")
+			}
+			for i := dst; i < dst+n; i++ {
+				mem.data[i] = v
+			}
+		default:
+			switch {
+			case in.op >= 0x28 && in.op <= 0x3e:
+				m.memoryOp(inst.memory(), in)
+			default:
+				m.numeric(in.op)
+			}
+		}
+	}
+
+	m.unwind(base, results)
+}
+
+func (m *machine) callIndirect(inst *Instance, in *instr) {
+	if int(in.imm2) >= len(inst.tables) {
+		trap("table %d not defined", in.imm2)
+	}
+	t := inst.tables[in.imm2]
+	i := m.popU32()
+	if int(i) >= len(t.elems) {
+		trap("undefined element %d", i)
+	}
+	f := t.elems[i]
+	if f == nil {
+		trap("uninitialized element %d", i)
+	}
+	typ, err := inst.funcType(uint32(in.imm))
+	if err != nil {
+		trap("%v", err)
+	}
+	if !sameType(f.typ, typ) {
+		trap("indirect call type mismatch")
+	}
+	m.call(f)
+}
+
+func (inst *Instance) global(i uint64) *Global {
+	if int(i) >= len(inst.globals) {
+		trap("global %d not defined", i)
+	}
+	return inst.globals[i]
+}
+
+func (inst *Instance) memory() *Memory {
+	if len(inst.memories) == 0 {
+		trap("memory not defined")
+	}
+	return inst.memories[0]
+}
+
+// memoryOp executes a load or store instruction.
+func (m *machine) memoryOp(mem *Memory, in *instr) {
+	le := binary.LittleEndian
+
+	// addr pops the base address and returns the effective address of an
+	// access of n bytes.
+	addr := func(n uint64) uint64 {
+		ea := uint64(m.popU32()) + in.imm
+		if ea+n > uint64(len(mem.data)) {
+			trap("out of bounds memory access")
+		}
+		return ea
+	}
+
+	switch in.op {
+	case 0x28: // i32.load
+		m.pushU32(le.Uint32(mem.data[addr(4):]))
+	case 0x29: // i64.load
+		m.push(le.Uint64(mem.data[addr(8):]))
+	case 0x2a: // f32.load
+		m.pushU32(le.Uint32(mem.data[addr(4):]))
+	case 0x2b: // f64.load
+		m.push(le.Uint64(mem.data[addr(8):]))
+	case 0x2c: // i32.load8_s
+		m.pushI32(int32(int8(mem.data[addr(1)])))
+	case 0x2d: // i32.load8_u
+		m.pushU32(uint32(mem.data[addr(1)]))
+	case 0x2e: // i32.load16_s
+		m.pushI32(int32(int16(le.Uint16(mem.data[addr(2):]))))
+	case 0x2f: // i32.load16_u
+		m.pushU32(uint32(le.Uint16(mem.data[addr(2):])))
+	case 0x30: // i64.load8_s
+		m.pushI64(int64(int8(mem.data[addr(1)])))
+	case 0x31: // i64.load8_u
+		m.push(uint64(mem.data[addr(1)]))
+	case 0x32: // i64.load16_s
+		m.pushI64(int64(int16(le.Uint16(mem.data[addr(2):]))))
+	case 0x33: // i64.load16_u
+		m.push(uint64(le.Uint16(mem.data[addr(2):])))
+	case 0x34: // i64.load32_s
+		m.pushI64(int64(int32(le.Uint32(mem.data[addr(4):]))))
+	case 0x35: // i64.load32_u
+		m.push(uint64(le.Uint32(mem.data[addr(4):])))
+	default:
+		v := m.pop()
+		switch in.op {
+		case 0x36, 0x38, 0x3e: // i32.store, f32.store, i64.store32
+			le.PutUint32(mem.data[addr(4):], uint32(v))
+		case 0x37, 0x39: // i64.store, f64.store
+			le.PutUint64(mem.data[addr(8):], v)
+		case 0x3a, 0x3c: // i32.store8, i64.store8
+			mem.data[addr(1)] = byte(v)
+		case 0x3b, 0x3d: // i32.store16, i64.store16
+			le.PutUint16(mem.data[addr(2):], uint16(v))
+		}
+	}
+}
+
+// numeric executes a numeric instruction.
+func (m *machine) numeric(op uint16) {
+	switch op {
+	// i32 comparison
+	case 0x45: // i32.eqz
+		m.pushBool(m.popU32() == 0)
+	case 0x46, 0x47, 0x48, 0x49, 0x4a, 0x4b, 0x4c, 0x4d, 0x4e, 0x4f:
+		b, a := m.popU32(), m.popU32()
+		m.pushBool(cmpI32(op, a, b))
+
+	// i64 comparison
+	case 0x50: // i64.eqz
+		m.pushBool(m.pop() == 0)
+	case 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5a:
+		b, a := m.pop(), m.pop()
+		m.pushBool(cmpI64(op, a, b))
+
+	// f32 comparison
+	case 0x5b, 0x5c, 0x5d, 0x5e, 0x5f, 0x60:
+		b, a := m.popF32(), m.popF32()
+		m.pushBool(cmpF(op-0x5b, float64(a), float64(b)))
+
+	// f64 comparison
+	case 0x61, 0x62, 0x63, 0x64, 0x65, 0x66:
+		b, a := m.popF64(), m.popF64()
+		m.pushBool(cmpF(op-0x61, a, b))
+
+	// i32 arithmetic
+	case 0x67: // i32.clz
+		m.pushU32(uint32(bits.LeadingZeros32(m.popU32())))
+	case 0x68: // i32.ctz
+		m.pushU32(uint32(bits.TrailingZeros32(m.popU32())))
+	case 0x69: // i32.popcnt
+		m.pushU32(uint32(bits.OnesCount32(m.popU32())))
+	case 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f, 0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78:
+		b, a := m.popU32(), m.popU32()
+		m.pushU32(binI32(op, a, b))
+
+	// i64 arithmetic
+	case 0x79: // i64.clz
+		m.push(uint64(bits.LeadingZeros64(m.pop())))
+	case 0x7a: // i64.ctz
+		m.push(uint64(bits.TrailingZeros64(m.pop())))
+	case 0x7b: // i64.popcnt
+		m.push(uint64(bits.OnesCount64(m.pop())))
+	case 0x7c, 0x7d, 0x7e, 0x7f, 0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89, 0x8a:
+		b, a := m.pop(), m.pop()
+		m.push(binI64(op, a, b))
+
+	// f32 arithmetic
+	case 0x8b: // f32.abs
+		m.pushU32(m.popU32() &^ (1 << 31))
+	case 0x8c: // f32.neg
+		m.pushU32(m.popU32() ^ (1 << 31))
+	case 0x8d, 0x8e, 0x8f, 0x90, 0x91: // f32.ceil, floor, trunc, nearest, sqrt
+		m.pushF32(float32(unF(op-0x8d, float64(m.popF32()))))
+	case 0x92, 0x93, 0x94, 0x95, 0x96, 0x97: // f32.add, sub, mul, div, min, max
+		b, a := m.popF32(), m.popF32()
+		m.pushF32(binF32(op-0x92, a, b))
+	case 0x98: // f32.copysign
+		b, a := m.popU32(), m.popU32()
+		m.pushU32(a&^(1<<31) | b&(1<<31))
+
+	// f64 arithmetic
+	case 0x99: // f64.abs
+		m.push(m.pop() &^ (1 << 63))
+	case 0x9a: // f64.neg
+		m.push(m.pop() ^ (1 << 63))
+	case 0x9b, 0x9c, 0x9d, 0x9e, 0x9f: // f64.ceil, floor, trunc, nearest, sqrt
+		m.pushF64(unF(op-0x9b, m.popF64()))
+	case 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5: // f64.add, sub, mul, div, min, max
+		b, a := m.popF64(), m.popF64()
+		m.pushF64(binF64(op-0xa0, a, b))
+	case 0xa6: // f64.copysign
+		b, a := m.pop(), m.pop()
+		m.push(a&^(1<<63) | b&(1<<63))
+
+	// conversions
+	case 0xa7: // i32.wrap_i64
+		m.pushU32(uint32(m.pop()))
+	case 0xa8: // i32.trunc_f32_s
+		m.pushI32(int32(truncS(float64(m.popF32()), 32)))
+	case 0xa9: // i32.trunc_f32_u
+		m.pushU32(uint32(truncU(float64(m.popF32()), 32)))
+	case 0xaa: // i32.trunc_f64_s
+		m.pushI32(int32(truncS(m.popF64(), 32)))
+	case 0xab: // i32.trunc_f64_u
+		m.pushU32(uint32(truncU(m.popF64(), 32)))
+	case 0xac: // i64.extend_i32_s
+		m.pushI64(int64(m.popI32()))
+	case 0xad: // i64.extend_i32_u
+		m.push(uint64(m.popU32()))
+	case 0xae: // i64.trunc_f32_s
+		m.pushI64(truncS(float64(m.popF32()), 64))
+	case 0xaf: // i64.trunc_f32_u
+		m.push(truncU(float64(m.popF32()), 64))
+	case 0xb0: // i64.trunc_f64_s
+		m.pushI64(truncS(m.popF64(), 64))
+	case 0xb1: // i64.trunc_f64_u
+		m.push(truncU(m.popF64(), 64))
+	case 0xb2: // f32.convert_i32_s
+		m.pushF32(float32(m.popI32()))
+	case 0xb3: // f32.convert_i32_u
+		m.pushF32(float32(m.popU32()))
+	case 0xb4: // f32.convert_i64_s
+		m.pushF32(float32(m.popI64()))
+	case 0xb5: // f32.convert_i64_u
+		m.pushF32(float32(m.pop()))
+	case 0xb6: // f32.demote_f64
+		m.pushF32(float32(m.popF64()))
+	case 0xb7: // f64.convert_i32_s
+		m.pushF64(float64(m.popI32()))
+	case 0xb8: // f64.convert_i32_u
+		m.pushF64(float64(m.popU32()))
+	case 0xb9: // f64.convert_i64_s
+		m.pushF64(float64(m.popI64()))
+	case 0xba: // f64.convert_i64_u
+		m.pushF64(float64(m.pop()))
+	case 0xbb: // f64.promote_f32
+		m.pushF64(float64(m.popF32()))
+	case 0xbc, 0xbd, 0xbe, 0xbf:
+		// reinterpretations do not change the raw value
+
+	// sign extension
+	case 0xc0: // i32.extend8_s
+		m.pushI32(int32(int8(m.pop())))
+	case 0xc1: // i32.extend16_s
+		m.pushI32(int32(int16(m.pop())))
+	case 0xc2: // i64.extend8_s
+		m.pushI64(int64(int8(m.pop())))
+	case 0xc3: // i64.extend16_s
+		m.pushI64(int64(int16(m.pop())))
+	case 0xc4: // i64.extend32_s
+		m.pushI64(int64(int32(m.pop())))
+
+	// saturating truncation
+	case 0xfc00: // i32.trunc_sat_f32_s
+		m.pushI32(int32(truncSatS(float64(m.popF32()), 32)))
+	case 0xfc01: // i32.trunc_sat_f32_u
+		m.pushU32(uint32(truncSatU(float64(m.popF32()), 32)))
+	case 0xfc02: // i32.trunc_sat_f64_s
+		m.pushI32(int32(truncSatS(m.popF64(), 32)))
+	case 0xfc03: // i32.trunc_sat_f64_u
+		m.pushU32(uint32(truncSatU(m.popF64(), 32)))
+	case 0xfc04: // i64.trunc_sat_f32_s
+		m.pushI64(truncSatS(float64(m.popF32()), 64))
+	case 0xfc05: // i64.trunc_sat_f32_u
+		m.push(truncSatU(float64(m.popF32()), 64))
+	case 0xfc06: // i64.trunc_sat_f64_s
+		m.pushI64(truncSatS(m.popF64(), 64))
+	case 0xfc07: // i64.trunc_sat_f64_u
+		m.push(truncSatU(m.popF64(), 64))
+
+	default:
+		trap("unsupported op code 0x%02x", op)
+	}
+}
+
+func cmpI32(op uint16, a, b uint32) bool {
+	switch op {
+	case 0x46: // eq
+		return a == b
+	case 0x47: // ne
+		return a != b
+	case 0x48: // lt_s
+		return int32(a) < int32(b)
+	case 0x49: // lt_u
+		return a < b
+	case 0x4a: // gt_s
+		return int32(a) > int32(b)
+	case 0x4b: // gt_u
+		return a > b
+	case 0x4c: // le_s
+		return int32(a) <= int32(b)
+	case 0x4d: // le_u
+		return a <= b
+	case 0x4e: // ge_s
+		return int32(a) >= int32(b)
+	default: // ge_u
+		return a >= b
+	}
+}
+
+func cmpI64(op uint16, a, b uint64) bool {
+	switch op {
+	case 0x51: // eq
+		return a == b
+	case 0x52: // ne
+		return a != b
+	case 0x53: // lt_s
+		return int64(a) < int64(b)
+	case 0x54: // lt_u
+		return a < b
+	case 0x55: // gt_s
+		return int64(a) > int64(b)
+	case 0x56: // gt_u
+		return a > b
+	case 0x57: // le_s
+		return int64(a) <= int64(b)
+	case 0x58: // le_u
+		return a <= b
+	case 0x59: // ge_s
+		return int64(a) >= int64(b)
+	default: // ge_u
+		return a >= b
+	}
+}
+
+// cmpF compares floats. i is the offset of the op code from eq.
+func cmpF(i uint16, a, b float64) bool {
+	switch i {
+	case 0: // eq
+		return a == b
+	case 1: // ne
+		return a != b
+	case 2: // lt
+		return a < b
+	case 3: // gt
+		return a > b
+	case 4: // le
+		return a <= b
+	default: // ge
+		return a >= b
+	}
+}
+
+func binI32(op uint16, a, b uint32) uint32 {
+	switch op {
+	case 0x6a: // add
+		return a + b
+	case 0x6b: // sub
+		return a - b
+	case 0x6c: // mul
+		return a * b
+	case 0x6d: // div_s
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(a) == math.MinInt32 && int32(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint32(int32(a) / int32(b))
+	case 0x6e: // div_u
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a / b
+	case 0x6f: // rem_s
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(b) == -1 {
+			return 0
+		}
+		return uint32(int32(a) % int32(b))
+	case 0x70: // rem_u
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a % b
+	case 0x71: // and
+		return a & b
+	case 0x72: // or
+		return a | b
+	case 0x73: // xor
+		return a ^ b
+	case 0x74: // shl
+		return a << (b & 31)
+	case 0x75: // shr_s
+		return uint32(int32(a) >> (b & 31))
+	case 0x76: // shr_u
+		return a >> (b & 31)
+	case 0x77: // rotl
+		return bits.RotateLeft32(a, int(b&31))
+	default: // rotr
+		return bits.RotateLeft32(a, -int(b&31))
+	}
+}
+
+func binI64(op uint16, a, b uint64) uint64 {
+	switch op {
+	case 0x7c: // add
+		return a + b
+	case 0x7d: // sub
+		return a - b
+	case 0x7e: // mul
+		return a * b
+	case 0x7f: // div_s
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(int64(a) / int64(b))
+	case 0x80: // div_u
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a / b
+	case 0x81: // rem_s
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(b) == -1 {
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	case 0x82: // rem_u
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a % b
+	case 0x83: // and
+		return a & b
+	case 0x84: // or
+		return a | b
+	case 0x85: // xor
+		return a ^ b
+	case 0x86: // shl
+		return a << (b & 63)
+	case 0x87: // shr_s
+		return uint64(int64(a) >> (b & 63))
+	case 0x88: // shr_u
+		return a >> (b & 63)
+	case 0x89: // rotl
+		return bits.RotateLeft64(a, int(b&63))
+	default: // rotr
+		return bits.RotateLeft64(a, -int(b&63))
+	}
+}
+
+// unF executes a unary float operation. i is the offset of the op code from
+// ceil.
+func unF(i uint16, v float64) float64 {
+	switch i {
+	case 0:
+		return math.Ceil(v)
+	case 1:
+		return math.Floor(v)
+	case 2:
+		return math.Trunc(v)
+	case 3:
+		return math.RoundToEven(v)
+	default:
+		return math.Sqrt(v)
+	}
+}
+
+// binF32 executes a binary f32 operation. i is the offset of the op code from
+// add.
+func binF32(i uint16, a, b float32) float32 {
+	switch i {
+	case 0:
+		return a + b
+	case 1:
+		return a - b
+	case 2:
+		return a * b
+	case 3:
+		return a / b
+	case 4:
+		return float32(fmin(float64(a), float64(b)))
+	default:
+		return float32(fmax(float64(a), float64(b)))
+	}
+}
+
+// binF64 executes a binary f64 operation. i is the offset of the op code from
+// add.
+func binF64(i uint16, a, b float64) float64 {
+	switch i {
+	case 0:
+		return a + b
+	case 1:
+		return a - b
+	case 2:
+		return a * b
+	case 3:
+		return a / b
+	case 4:
+		return fmin(a, b)
+	default:
+		return fmax(a, b)
+	}
+}
+
+func fmin(a, b float64) float64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.NaN()
+	}
+	return math.Min(a, b)
+}
+
+func fmax(a, b float64) float64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.NaN()
+	}
+	return math.Max(a, b)
+}
+
+// truncS truncates v to a signed integer of size bits, trapping if the value
+// does not fit.
+func truncS(v float64, size uint) int64 {
+	if math.IsNaN(v) {
+		trap("invalid conversion to integer")
+	}
+	t := math.Trunc(v)
+	limit := math.Ldexp(1, int(size-1))
+	if t < -limit || t >= limit {
+		trap("integer overflow")
+	}
+	return int64(t)
+}
+
+// truncU truncates v to an unsigned integer of size bits, trapping if the
+// value does not fit.
+func truncU(v float64, size uint) uint64 {
+	if math.IsNaN(v) {
+		trap("invalid conversion to integer")
+	}
+	t := math.Trunc(v)
+	if t <= -1 || t >= math.Ldexp(1, int(size)) {
+		trap("integer overflow")
+	}
+	return uint64(t)
+}
+
+// truncSatS truncates v to a signed integer of size bits, saturating values
+// that do not fit.
+func truncSatS(v float64, size uint) int64 {
+	limit := math.Ldexp(1, int(size-1))
+	switch t := math.Trunc(v); {
+	case math.IsNaN(v):
+		return 0
+	case t < -limit:
+		return -1 << (size - 1)
+	case t >= limit:
+		return 1<<(size-1) - 1
+	default:
+		return int64(t)
+	}
+}
+
+// truncSatU truncates v to an unsigned integer of size bits, saturating values
+// that do not fit.
+func truncSatU(v float64, size uint) uint64 {
+	switch t := math.Trunc(v); {
+	case math.IsNaN(v), t <= -1:
+		return 0
+	case t >= math.Ldexp(1, int(size)):
+		return 1<<size - 1
+	default:
+		return uint64(t)
+	}
+}
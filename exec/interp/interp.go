@@ -0,0 +1,481 @@
+// Package interp implements a simple interpreter for WebAssembly modules
+// parsed by the wasm package.
+//
+// The interpreter is not optimized for speed. It is meant for testing and
+// lightweight embedding, where the ability to call into a module is more
+// important than raw performance.
+//
+// All values are passed in and out of the interpreter as raw 64 bit patterns.
+// The I32, I64, F32 and F64 functions convert Go values to that
+// representation, AsI32, AsI64, AsF32 and AsF64 convert back.
+package interp
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// HostFunc is a function implemented in Go that can be imported by a module.
+//
+// The function receives the instance that called it, which gives access to
+// the instance memory. It must return as many results as declared by the
+// function type of the import.
+type HostFunc func(inst *Instance, args []uint64) ([]uint64, error)
+
+// Imports provides the values imported by a module. The outer map is keyed
+// by module name and the inner map by field name.
+//
+// The values may be a HostFunc, a *Function exported by another instance, a
+// *Memory, a *Table or a *Global.
+type Imports map[string]map[string]interface{}
+
+// A Trap is returned when the execution of a function is aborted, for example
+// by an out of bounds memory access or an unreachable instruction.
+type Trap struct {
+	msg string
+}
+
+func (t *Trap) Error() string {
+	return "trap: " + t.msg
+}
+
+func trap(format string, args ...interface{}) {
+	panic(&Trap{msg: fmt.Sprintf(format, args...)})
+}
+
+// A Function is a function in the function index space of an instance. It's
+// either defined by the module or imported.
+type Function struct {
+	inst *Instance
+	typ  wasm.FuncType
+
+	// set for host functions
+	host HostFunc
+
+	// set for module defined functions
+	body     *wasm.FunctionBody
+	compiled *code
+}
+
+// Call calls the function with the given arguments. If a host function
+// returns an error, execution is aborted and the error is returned as is.
+func (f *Function) Call(args ...uint64) (res []uint64, err error) {
+	if len(args) != len(f.typ.Params) {
+		return nil, fmt.Errorf("function expects %d arguments, got %d", len(f.typ.Params), len(args))
+	}
+
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *Trap:
+			res, err = nil, r
+		case hostError:
+			res, err = nil, r.err
+		case runtime.Error:
+			// The module is not validated before execution, invalid code
+			// may for example underflow the stack.
+			res, err = nil, &Trap{msg: r.Error()}
+		default:
+			panic(r)
+		}
+	}()
+
+	m := &machine{}
+	m.stack = append(m.stack, args...)
+	m.call(f)
+	return m.stack, nil
+}
+
+// An Instance is an instantiated module.
+type Instance struct {
+	types    []wasm.FuncType
+	funcs    []*Function
+	tables   []*Table
+	memories []*Memory
+	globals  []*Global
+	exports  map[string]interface{}
+}
+
+// checkLimits checks the limits of a table or memory before it's allocated,
+// as the sizes come from the module and may be anything.
+func checkLimits(l wasm.ResizableLimits, max uint32) error {
+	if l.Initial > max {
+		return fmt.Errorf("initial size %d exceeds %d", l.Initial, max)
+	}
+	if l.HasMaximum && l.Initial > l.Maximum {
+		return fmt.Errorf("initial size %d exceeds maximum size %d", l.Initial, l.Maximum)
+	}
+	return nil
+}
+
+// Instantiate instantiates the module m. Imports required by the module are
+// resolved from imports.
+//
+// The element and data segments are copied to the tables and memories, after
+// which the start function is called, if the module has one.
+func Instantiate(m *wasm.Module, imports Imports) (*Instance, error) {
+	inst := &Instance{
+		exports: make(map[string]interface{}),
+	}
+
+	var (
+		funcs    *wasm.SectionFunction
+		code     *wasm.SectionCode
+		imps     *wasm.SectionImport
+		tables   *wasm.SectionTable
+		memories *wasm.SectionMemory
+		globals  *wasm.SectionGlobal
+		exports  *wasm.SectionExport
+		start    *wasm.SectionStart
+		elements *wasm.SectionElement
+		data     *wasm.SectionData
+	)
+	for _, s := range m.Sections {
+		switch s := s.(type) {
+		case *wasm.SectionType:
+			inst.types = s.Entries
+		case *wasm.SectionImport:
+			imps = s
+		case *wasm.SectionFunction:
+			funcs = s
+		case *wasm.SectionTable:
+			tables = s
+		case *wasm.SectionMemory:
+			memories = s
+		case *wasm.SectionGlobal:
+			globals = s
+		case *wasm.SectionExport:
+			exports = s
+		case *wasm.SectionStart:
+			start = s
+		case *wasm.SectionElement:
+			elements = s
+		case *wasm.SectionCode:
+			code = s
+		case *wasm.SectionData:
+			data = s
+		}
+	}
+
+	if imps != nil {
+		for i, e := range imps.Entries {
+			if err := inst.resolveImport(e, imports); err != nil {
+				return nil, fmt.Errorf("import %d (%s.%s): %v", i, e.Module, e.Field, err)
+			}
+		}
+	}
+
+	if funcs != nil {
+		if code == nil || len(code.Bodies) != len(funcs.Types) {
+			return nil, fmt.Errorf("number of function bodies does not match number of functions")
+		}
+		for i, t := range funcs.Types {
+			typ, err := inst.funcType(t)
+			if err != nil {
+				return nil, fmt.Errorf("function %d: %v", i, err)
+			}
+			inst.funcs = append(inst.funcs, &Function{
+				inst: inst,
+				typ:  typ,
+				body: &code.Bodies[i],
+			})
+		}
+	}
+
+	if tables != nil {
+		for i, t := range tables.Entries {
+			if err := checkLimits(t.Limits, maxTableSize); err != nil {
+				return nil, fmt.Errorf("table %d: %v", i, err)
+			}
+			max := uint32(math.MaxUint32)
+			if t.Limits.HasMaximum {
+				max = t.Limits.Maximum
+			}
+			inst.tables = append(inst.tables, NewTable(t.Limits.Initial, max))
+		}
+	}
+
+	if memories != nil {
+		for i, mem := range memories.Entries {
+			if err := checkLimits(mem.Limits, maxPages); err != nil {
+				return nil, fmt.Errorf("memory %d: %v", i, err)
+			}
+			max := uint32(maxPages)
+			if mem.Limits.HasMaximum {
+				max = mem.Limits.Maximum
+			}
+			inst.memories = append(inst.memories, NewMemory(mem.Limits.Initial, max))
+		}
+	}
+
+	if globals != nil {
+		for i, g := range globals.Globals {
+			v, err := inst.eval(g.Init)
+			if err != nil {
+				return nil, fmt.Errorf("global %d: %v", i, err)
+			}
+			inst.globals = append(inst.globals, &Global{
+				typ:     g.Type.ContentType,
+				Value:   v,
+				Mutable: g.Type.Mutable,
+			})
+		}
+	}
+
+	if exports != nil {
+		for _, e := range exports.Entries {
+			v, err := inst.lookup(e.Kind, e.Index)
+			if err != nil {
+				return nil, fmt.Errorf("export %q: %v", e.Field, err)
+			}
+			inst.exports[e.Field] = v
+		}
+	}
+
+	if elements != nil {
+		for i, e := range elements.Entries {
+			if int(e.Index) >= len(inst.tables) {
+				return nil, fmt.Errorf("element segment %d: table %d not defined", i, e.Index)
+			}
+			t := inst.tables[e.Index]
+			off, err := inst.eval(e.Offset)
+			if err != nil {
+				return nil, fmt.Errorf("element segment %d: %v", i, err)
+			}
+			if uint64(uint32(off))+uint64(len(e.Elems)) > uint64(len(t.elems)) {
+				return nil, fmt.Errorf("element segment %d does not fit in table", i)
+			}
+			for j, fi := range e.Elems {
+				if int(fi) >= len(inst.funcs) {
+					return nil, fmt.Errorf("element segment %d: function %d not defined", i, fi)
+				}
+				t.elems[uint32(off)+uint32(j)] = inst.funcs[fi]
+			}
+		}
+	}
+
+	if data != nil {
+		for i, d := range data.Entries {
+			if int(d.Index) >= len(inst.memories) {
+				return nil, fmt.Errorf("data segment %d: memory %d not defined", i, d.Index)
+			}
+			mem := inst.memories[d.Index]
+			off, err := inst.eval(d.Offset)
+			if err != nil {
+				return nil, fmt.Errorf("data segment %d: %v", i, err)
+			}
+			if uint64(uint32(off))+uint64(len(d.Data)) > uint64(len(mem.data)) {
+				return nil, fmt.Errorf("data segment %d does not fit in memory", i)
+			}
+			copy(mem.data[uint32(off):], d.Data)
+		}
+	}
+
+	if start != nil {
+		if int(start.Index) >= len(inst.funcs) {
+			return nil, fmt.Errorf("start function %d not defined", start.Index)
+		}
+		if _, err := inst.funcs[start.Index].Call(); err != nil {
+			return nil, fmt.Errorf("start function: %v", err)
+		}
+	}
+
+	return inst, nil
+}
+
+func (inst *Instance) resolveImport(e wasm.ImportEntry, imports Imports) error {
+	v, ok := imports[e.Module][e.Field]
+	if !ok {
+		return fmt.Errorf("not provided")
+	}
+
+	switch e.Kind {
+	case wasm.ExtKindFunction:
+		typ, err := inst.funcType(e.FunctionType.Index)
+		if err != nil {
+			return err
+		}
+		switch v := v.(type) {
+		case HostFunc:
+			inst.funcs = append(inst.funcs, &Function{inst: inst, typ: typ, host: v})
+		case func(*Instance, []uint64) ([]uint64, error):
+			inst.funcs = append(inst.funcs, &Function{inst: inst, typ: typ, host: v})
+		case *Function:
+			if !sameType(v.typ, typ) {
+				return fmt.Errorf("function signature does not match")
+			}
+			inst.funcs = append(inst.funcs, v)
+		default:
+			return fmt.Errorf("expected function, got %T", v)
+		}
+	case wasm.ExtKindTable:
+		t, ok := v.(*Table)
+		if !ok {
+			return fmt.Errorf("expected *Table, got %T", v)
+		}
+		if uint32(len(t.elems)) < e.TableType.Limits.Initial {
+			return fmt.Errorf("table too small")
+		}
+		inst.tables = append(inst.tables, t)
+	case wasm.ExtKindMemory:
+		mem, ok := v.(*Memory)
+		if !ok {
+			return fmt.Errorf("expected *Memory, got %T", v)
+		}
+		if mem.Size() < e.MemoryType.Limits.Initial {
+			return fmt.Errorf("memory too small")
+		}
+		inst.memories = append(inst.memories, mem)
+	case wasm.ExtKindGlobal:
+		g, ok := v.(*Global)
+		if !ok {
+			return fmt.Errorf("expected *Global, got %T", v)
+		}
+		g.typ = e.GlobalType.ContentType
+		inst.globals = append(inst.globals, g)
+	default:
+		return fmt.Errorf("unknown import kind %d", e.Kind)
+	}
+
+	return nil
+}
+
+func (inst *Instance) funcType(i uint32) (wasm.FuncType, error) {
+	if int(i) >= len(inst.types) {
+		return wasm.FuncType{}, fmt.Errorf("type %d not defined", i)
+	}
+	return inst.types[i], nil
+}
+
+func (inst *Instance) lookup(kind wasm.ExternalKind, i uint32) (interface{}, error) {
+	var v interface{}
+	var n int
+	switch kind {
+	case wasm.ExtKindFunction:
+		if n = len(inst.funcs); int(i) < n {
+			v = inst.funcs[i]
+		}
+	case wasm.ExtKindTable:
+		if n = len(inst.tables); int(i) < n {
+			v = inst.tables[i]
+		}
+	case wasm.ExtKindMemory:
+		if n = len(inst.memories); int(i) < n {
+			v = inst.memories[i]
+		}
+	case wasm.ExtKindGlobal:
+		if n = len(inst.globals); int(i) < n {
+			v = inst.globals[i]
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %d", kind)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("index %d out of range, have %d", i, n)
+	}
+	return v, nil
+}
+
+// eval evaluates an init expression and returns the result as a raw value.
+func (inst *Instance) eval(expr []byte) (uint64, error) {
+	globals := make([]interface{}, len(inst.globals))
+	for i, g := range inst.globals {
+		switch g.typ {
+		case wasm.I32:
+			globals[i] = AsI32(g.Value)
+		case wasm.I64:
+			globals[i] = AsI64(g.Value)
+		case wasm.F32:
+			globals[i] = AsF32(g.Value)
+		case wasm.F64:
+			globals[i] = AsF64(g.Value)
+		}
+	}
+
+	v, err := wasm.Eval(expr, globals)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := v.(type) {
+	case int32:
+		return I32(v), nil
+	case int64:
+		return I64(v), nil
+	case float32:
+		return F32(v), nil
+	case float64:
+		return F64(v), nil
+	}
+	return 0, fmt.Errorf("unexpected value %T", v)
+}
+
+// Export returns the exported value with the given name. The value is a
+// *Function, *Table, *Memory or *Global. Nil is returned if the module does
+// not have an export with the name.
+func (inst *Instance) Export(name string) interface{} {
+	return inst.exports[name]
+}
+
+// Call calls the exported function name with the given arguments and returns
+// the results.
+func (inst *Instance) Call(name string, args ...uint64) ([]uint64, error) {
+	f, ok := inst.exports[name].(*Function)
+	if !ok {
+		return nil, fmt.Errorf("function %q not exported", name)
+	}
+	return f.Call(args...)
+}
+
+// Memory returns the default memory (index 0) of the instance, or nil if the
+// instance does not have memory.
+func (inst *Instance) Memory() *Memory {
+	if len(inst.memories) == 0 {
+		return nil
+	}
+	return inst.memories[0]
+}
+
+func sameType(a, b wasm.FuncType) bool {
+	if len(a.Params) != len(b.Params) || len(a.ReturnTypes) != len(b.ReturnTypes) {
+		return false
+	}
+	for i := range a.Params {
+		if a.Params[i] != b.Params[i] {
+			return false
+		}
+	}
+	for i := range a.ReturnTypes {
+		if a.ReturnTypes[i] != b.ReturnTypes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// I32 converts v to a raw value.
+func I32(v int32) uint64 { return uint64(uint32(v)) }
+
+// I64 converts v to a raw value.
+func I64(v int64) uint64 { return uint64(v) }
+
+// F32 converts v to a raw value.
+func F32(v float32) uint64 { return uint64(math.Float32bits(v)) }
+
+// F64 converts v to a raw value.
+func F64(v float64) uint64 { return math.Float64bits(v) }
+
+// AsI32 converts the raw value v to an int32.
+func AsI32(v uint64) int32 { return int32(uint32(v)) }
+
+// AsI64 converts the raw value v to an int64.
+func AsI64(v uint64) int64 { return int64(v) }
+
+// AsF32 converts the raw value v to a float32.
+func AsF32(v uint64) float32 { return math.Float32frombits(uint32(v)) }
+
+// AsF64 converts the raw value v to a float64.
+func AsF64(v uint64) float64 { return math.Float64frombits(v) }
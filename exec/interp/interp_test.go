@@ -0,0 +1,389 @@
+package interp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+const (
+	i32 = wasm.I32
+	i64 = wasm.I64
+	f64 = wasm.F64
+)
+
+// fn is a function defined in a test module.
+type fn struct {
+	name    string
+	params  []wasm.ValueType
+	results []wasm.ValueType
+	locals  []wasm.LocalEntry
+	code    []byte
+}
+
+// module builds a module exporting the given functions. Additional sections
+// are appended to the module as is.
+func module(fns []fn, sections ...wasm.Section) *wasm.Module {
+	var (
+		types   wasm.SectionType
+		funcs   wasm.SectionFunction
+		exports wasm.SectionExport
+		code    wasm.SectionCode
+	)
+	for i, f := range fns {
+		types.Entries = append(types.Entries, wasm.FuncType{Form: 0x60, Params: f.params, ReturnTypes: f.results})
+		funcs.Types = append(funcs.Types, uint32(i))
+		if f.name != "" {
+			exports.Entries = append(exports.Entries, wasm.ExportEntry{Field: f.name, Kind: wasm.ExtKindFunction, Index: uint32(i)})
+		}
+		code.Bodies = append(code.Bodies, wasm.FunctionBody{Locals: f.locals, Code: f.code})
+	}
+	m := &wasm.Module{Sections: []wasm.Section{&types, &funcs, &exports, &code}}
+	m.Sections = append(m.Sections, sections...)
+	return m
+}
+
+func instantiate(t *testing.T, m *wasm.Module, imports Imports) *Instance {
+	t.Helper()
+	inst, err := Instantiate(m, imports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inst
+}
+
+func call(t *testing.T, inst *Instance, name string, args ...uint64) []uint64 {
+	t.Helper()
+	res, err := inst.Call(name, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestArithmetic(t *testing.T) {
+	m := module([]fn{
+		{name: "add", params: []wasm.ValueType{i32, i32}, results: []wasm.ValueType{i32}, code: []byte{
+			0x20, 0x00, // local.get 0
+			0x20, 0x01, // local.get 1
+			0x6a, // i32.add
+			0x0b, // end
+		}},
+		{name: "sub64", params: []wasm.ValueType{i64, i64}, results: []wasm.ValueType{i64}, code: []byte{
+			0x20, 0x00, 0x20, 0x01, 0x7d, 0x0b,
+		}},
+		{name: "sqrt", params: []wasm.ValueType{f64}, results: []wasm.ValueType{f64}, code: []byte{
+			0x20, 0x00, 0x9f, 0x0b,
+		}},
+		{name: "div", params: []wasm.ValueType{i32, i32}, results: []wasm.ValueType{i32}, code: []byte{
+			0x20, 0x00, 0x20, 0x01, 0x6d, 0x0b,
+		}},
+		{name: "neg", results: []wasm.ValueType{i32}, code: []byte{
+			0x41, 0x7f, // i32.const -1
+			0x0b,
+		}},
+	})
+	inst := instantiate(t, m, nil)
+
+	if got := AsI32(call(t, inst, "add", I32(40), I32(2))[0]); got != 42 {
+		t.Errorf("add: expected 42, got %d", got)
+	}
+	if got := AsI64(call(t, inst, "sub64", I64(1), I64(3))[0]); got != -2 {
+		t.Errorf("sub64: expected -2, got %d", got)
+	}
+	if got := AsF64(call(t, inst, "sqrt", F64(2))[0]); got != math.Sqrt2 {
+		t.Errorf("sqrt: expected %v, got %v", math.Sqrt2, got)
+	}
+	if got := AsI32(call(t, inst, "neg")[0]); got != -1 {
+		t.Errorf("neg: expected -1, got %d", got)
+	}
+
+	_, err := inst.Call("div", I32(1), I32(0))
+	if _, ok := err.(*Trap); !ok {
+		t.Errorf("div: expected trap, got %v", err)
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	m := module([]fn{
+		// recursive factorial
+		{name: "fac", params: []wasm.ValueType{i64}, results: []wasm.ValueType{i64}, code: []byte{
+			0x20, 0x00, // local.get 0
+			0x50,       // i64.eqz
+			0x04, 0x7e, // if (result i64)
+			0x42, 0x01, // i64.const 1
+			0x05,       // else
+			0x20, 0x00, // local.get 0
+			0x20, 0x00, // local.get 0
+			0x42, 0x01, // i64.const 1
+			0x7d,       // i64.sub
+			0x10, 0x00, // call 0
+			0x7e, // i64.mul
+			0x0b, // end
+			0x0b, // end
+		}},
+		// sum of 1..n using a loop
+		{name: "sum", params: []wasm.ValueType{i32}, results: []wasm.ValueType{i32}, locals: []wasm.LocalEntry{{Count: 1, Type: i32}}, code: []byte{
+			0x02, 0x40, // block
+			0x03, 0x40, // loop
+			0x20, 0x00, // local.get 0
+			0x45,       // i32.eqz
+			0x0d, 0x01, // br_if 1
+			0x20, 0x01, // local.get 1
+			0x20, 0x00, // local.get 0
+			0x6a,       // i32.add
+			0x21, 0x01, // local.set 1
+			0x20, 0x00, // local.get 0
+			0x41, 0x01, // i32.const 1
+			0x6b,       // i32.sub
+			0x21, 0x00, // local.set 0
+			0x0c, 0x00, // br 0
+			0x0b,       // end
+			0x0b,       // end
+			0x20, 0x01, // local.get 1
+			0x0b, // end
+		}},
+		// br_table returning 10, 20 or 30
+		{name: "switch", params: []wasm.ValueType{i32}, results: []wasm.ValueType{i32}, code: []byte{
+			0x02, 0x40, // block
+			0x02, 0x40, // block
+			0x02, 0x40, // block
+			0x20, 0x00, // local.get 0
+			0x0e, 0x02, 0x00, 0x01, 0x02, // br_table 0 1 2
+			0x0b,       // end
+			0x41, 0x0a, // i32.const 10
+			0x0f,       // return
+			0x0b,       // end
+			0x41, 0x14, // i32.const 20
+			0x0f,       // return
+			0x0b,       // end
+			0x41, 0x1e, // i32.const 30
+			0x0b, // end
+		}},
+	})
+	inst := instantiate(t, m, nil)
+
+	if got := AsI64(call(t, inst, "fac", I64(10))[0]); got != 3628800 {
+		t.Errorf("fac: expected 3628800, got %d", got)
+	}
+	if got := AsI32(call(t, inst, "sum", I32(100))[0]); got != 5050 {
+		t.Errorf("sum: expected 5050, got %d", got)
+	}
+	for i, want := range []int32{10, 20, 30, 30} {
+		if got := AsI32(call(t, inst, "switch", I32(int32(i)))[0]); got != want {
+			t.Errorf("switch(%d): expected %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestMemoryAndImports(t *testing.T) {
+	m := module([]fn{
+		// calls env.print with the address and length of the data segment
+		{name: "hello", code: []byte{
+			0x41, 0x08, // i32.const 8
+			0x41, 0x05, // i32.const 5
+			0x10, 0x00, // call 0 (imported)
+			0x0b,
+		}},
+		// increments the i32 at address 0
+		{name: "inc", results: []wasm.ValueType{i32}, code: []byte{
+			0x41, 0x00, // i32.const 0
+			0x41, 0x00, // i32.const 0
+			0x28, 0x02, 0x00, // i32.load
+			0x41, 0x01, // i32.const 1
+			0x6a,             // i32.add
+			0x36, 0x02, 0x00, // i32.store
+			0x41, 0x00, // i32.const 0
+			0x28, 0x02, 0x00, // i32.load
+			0x0b,
+		}},
+	},
+		&wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 1}}}},
+		&wasm.SectionData{Entries: []wasm.DataSegment{{Offset: []byte{0x41, 0x08, 0x0b}, Data: []byte("hello")}}},
+	)
+
+	// The imported function shifts the function index space by one.
+	imp := &wasm.SectionImport{Entries: []wasm.ImportEntry{{
+		Module:       "env",
+		Field:        "print",
+		Kind:         wasm.ExtKindFunction,
+		FunctionType: &wasm.FunctionType{Index: 2},
+	}}}
+	types := m.Sections[0].(*wasm.SectionType)
+	types.Entries = append(types.Entries, wasm.FuncType{Form: 0x60, Params: []wasm.ValueType{i32, i32}})
+	exports := m.Sections[2].(*wasm.SectionExport)
+	for i := range exports.Entries {
+		exports.Entries[i].Index++
+	}
+	m.Sections = append([]wasm.Section{types, imp}, m.Sections[1:]...)
+
+	var printed string
+	inst := instantiate(t, m, Imports{
+		"env": {
+			"print": HostFunc(func(inst *Instance, args []uint64) ([]uint64, error) {
+				p, n := AsI32(args[0]), AsI32(args[1])
+				printed = string(inst.Memory().Bytes()[p : p+n])
+				return nil, nil
+			}),
+		},
+	})
+
+	call(t, inst, "hello")
+	if printed != "hello" {
+		t.Errorf("Expected host function to print %q, got %q", "hello", printed)
+	}
+
+	for i := int32(1); i <= 3; i++ {
+		if got := AsI32(call(t, inst, "inc")[0]); got != i {
+			t.Errorf("inc: expected %d, got %d", i, got)
+		}
+	}
+	if got := binary.LittleEndian.Uint32(inst.Memory().Bytes()); got != 3 {
+		t.Errorf("Expected memory to contain 3, got %d", got)
+	}
+}
+
+func TestMemoryGrow(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   wasm.ResizableLimits
+		expected int32
+	}{
+		{"no maximum", wasm.ResizableLimits{Initial: 0}, 0},
+		{"below maximum", wasm.ResizableLimits{Initial: 0, Maximum: 2, HasMaximum: true}, 0},
+		{"maximum 0", wasm.ResizableLimits{Initial: 0, Maximum: 0, HasMaximum: true}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := module([]fn{
+				{name: "grow", results: []wasm.ValueType{i32}, code: []byte{
+					0x41, 0x01, // i32.const 1
+					0x40, 0x00, // memory.grow
+					0x0b,
+				}},
+			}, &wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: tt.limits}}})
+			inst := instantiate(t, m, nil)
+			if got := AsI32(call(t, inst, "grow")[0]); got != tt.expected {
+				t.Errorf("Expected memory.grow to return %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestHostError(t *testing.T) {
+	m := module([]fn{
+		{name: "run", code: []byte{0x10, 0x00, 0x0b}},
+	})
+	imp := &wasm.SectionImport{Entries: []wasm.ImportEntry{{
+		Module:       "env",
+		Field:        "exit",
+		Kind:         wasm.ExtKindFunction,
+		FunctionType: &wasm.FunctionType{Index: 0},
+	}}}
+	m.Sections[2].(*wasm.SectionExport).Entries[0].Index = 1
+	m.Sections = append([]wasm.Section{m.Sections[0], imp}, m.Sections[1:]...)
+
+	errExit := fmt.Errorf("exit")
+	inst := instantiate(t, m, Imports{
+		"env": {
+			"exit": func(*Instance, []uint64) ([]uint64, error) {
+				return nil, errExit
+			},
+		},
+	})
+
+	if _, err := inst.Call("run"); err != errExit {
+		t.Errorf("Expected host error to be returned, got %v", err)
+	}
+}
+
+func TestCallIndirect(t *testing.T) {
+	m := module([]fn{
+		{name: "dispatch", params: []wasm.ValueType{i32}, results: []wasm.ValueType{i32}, code: []byte{
+			0x20, 0x00, // local.get 0
+			0x11, 0x01, 0x00, // call_indirect type 1
+			0x0b,
+		}},
+		{results: []wasm.ValueType{i32}, code: []byte{0x41, 0x01, 0x0b}},
+		{results: []wasm.ValueType{i32}, code: []byte{0x41, 0x02, 0x0b}},
+	},
+		&wasm.SectionTable{Entries: []wasm.TableType{{ElemType: 0x70, Limits: wasm.ResizableLimits{Initial: 3}}}},
+		&wasm.SectionElement{Entries: []wasm.ElemSegment{{Offset: []byte{0x41, 0x00, 0x0b}, Elems: []uint32{1, 2}}}},
+	)
+	inst := instantiate(t, m, nil)
+
+	for i, want := range []int32{1, 2} {
+		if got := AsI32(call(t, inst, "dispatch", I32(int32(i)))[0]); got != want {
+			t.Errorf("dispatch(%d): expected %d, got %d", i, want, got)
+		}
+	}
+
+	for _, i := range []int32{2, 3} {
+		if _, err := inst.Call("dispatch", I32(i)); err == nil {
+			t.Errorf("dispatch(%d): expected trap", i)
+		}
+	}
+}
+
+func ExampleInstance_Call() {
+	m := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{{Form: 0x60, Params: []wasm.ValueType{0x7f, 0x7f}, ReturnTypes: []wasm.ValueType{0x7f}}}},
+		&wasm.SectionFunction{Types: []uint32{0}},
+		&wasm.SectionExport{Entries: []wasm.ExportEntry{{Field: "mul", Kind: wasm.ExtKindFunction}}},
+		&wasm.SectionCode{Bodies: []wasm.FunctionBody{{Code: []byte{0x20, 0x00, 0x20, 0x01, 0x6c, 0x0b}}}},
+	}}
+
+	inst, err := Instantiate(m, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	res, err := inst.Call("mul", I32(6), I32(7))
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(AsI32(res[0]))
+	// Output:
+	// 42
+}
+
+func TestInstantiateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		section wasm.Section
+		err     string
+	}{
+		{
+			name:    "memory too large",
+			section: &wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 0xffffffff}}}},
+			err:     "memory 0: initial size 4294967295 exceeds 65536",
+		},
+		{
+			name:    "memory above maximum",
+			section: &wasm.SectionMemory{Entries: []wasm.MemoryType{{Limits: wasm.ResizableLimits{Initial: 2, Maximum: 1, HasMaximum: true}}}},
+			err:     "memory 0: initial size 2 exceeds maximum size 1",
+		},
+		{
+			name:    "table too large",
+			section: &wasm.SectionTable{Entries: []wasm.TableType{{ElemType: wasm.FuncRef, Limits: wasm.ResizableLimits{Initial: 0xffffffff}}}},
+			err:     "table 0: initial size 4294967295 exceeds 10000000",
+		},
+		{
+			name:    "table above maximum",
+			section: &wasm.SectionTable{Entries: []wasm.TableType{{ElemType: wasm.FuncRef, Limits: wasm.ResizableLimits{Initial: 4, Maximum: 2, HasMaximum: true}}}},
+			err:     "table 0: initial size 4 exceeds maximum size 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Instantiate(module(nil, tt.section), nil)
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package interp
+
+import (
+	wasm "github.com/akupila/go-wasm"
+)
+
+// pageSize is the size of a WebAssembly memory page.
+const pageSize = 65536
+
+// maxPages is the maximum number of pages a 32 bit memory can hold.
+const maxPages = 65536
+
+// maxTableSize is the maximum number of elements of a table created by
+// Instantiate, the same as the limit of web browsers.
+const maxTableSize = 10000000
+
+// Memory is a linear memory.
+type Memory struct {
+	data []byte
+	max  uint32
+}
+
+// NewMemory creates a new memory with an initial size of min pages, which must
+// not exceed 65536 (4GiB). The memory may grow up to max pages, but not beyond
+// 4GiB.
+func NewMemory(min, max uint32) *Memory {
+	if max > maxPages {
+		max = maxPages
+	}
+	return &Memory{
+		data: make([]byte, int(min)*pageSize),
+		max:  max,
+	}
+}
+
+// Size returns the current size of the memory in pages.
+func (m *Memory) Size() uint32 {
+	return uint32(len(m.data) / pageSize)
+}
+
+// Bytes returns the contents of the memory. The slice is only valid until the
+// memory is grown.
+func (m *Memory) Bytes() []byte {
+	return m.data
+}
+
+// Grow grows the memory by delta pages. It returns the previous size in pages,
+// or -1 if the memory cannot be grown.
+func (m *Memory) Grow(delta uint32) int32 {
+	prev := m.Size()
+	if uint64(prev)+uint64(delta) > uint64(m.max) {
+		return -1
+	}
+	if delta > 0 {
+		m.data = append(m.data, make([]byte, int(delta)*pageSize)...)
+	}
+	return int32(prev)
+}
+
+// A Table is a table of function references.
+type Table struct {
+	elems []*Function
+	max   uint32
+}
+
+// NewTable creates a new table with an initial size of min elements. The table
+// may grow up to max elements.
+func NewTable(min, max uint32) *Table {
+	return &Table{
+		elems: make([]*Function, min),
+		max:   max,
+	}
+}
+
+// Len returns the number of elements in the table.
+func (t *Table) Len() int {
+	return len(t.elems)
+}
+
+// Get returns the element at index i. Nil is returned if the element is not
+// set or i is out of range.
+func (t *Table) Get(i int) *Function {
+	if i < 0 || i >= len(t.elems) {
+		return nil
+	}
+	return t.elems[i]
+}
+
+// Set sets the element at index i to f.
+func (t *Table) Set(i int, f *Function) {
+	t.elems[i] = f
+}
+
+// A Global is a global variable.
+type Global struct {
+	// Value is the raw value of the global.
+	Value uint64
+
+	// Mutable is true if the module can modify the value.
+	Mutable bool
+
+	typ wasm.ValueType
+}
@@ -0,0 +1,57 @@
+//go:build go1.18
+// +build go1.18
+
+package wasm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzParse checks that parsing arbitrary input never panics or runs out of
+// memory, whichever way the input is read, and that parsed modules can be
+// encoded.
+func FuzzParse(f *testing.F) {
+	for _, name := range []string{"empty.wasm", "helloworld.wasm"} {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			f.Fatal(err)
+		}
+		if len(b) > 4096 {
+			// Keep the seed small, the fuzzer mutates the start of it.
+			b = b[:4096]
+		}
+		f.Add(b)
+	}
+	f.Add([]byte("\x00asm\x01\x00\x00\x00\x00\x05\x04name"))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m, err := ParseBytes(b)
+		if _, serr := Parse(bytes.NewBufferString(string(b))); (err == nil) != (serr == nil) {
+			t.Fatalf("ParseBytes and Parse disagree: %v, %v", err, serr)
+		}
+		if _, cerr := ParseBytesWithOptions(b, ParseOptions{Concurrent: true}); (err == nil) != (cerr == nil) {
+			t.Fatalf("sequential and concurrent parsing disagree: %v, %v", err, cerr)
+		}
+		if err != nil {
+			return
+		}
+		Encode(ioutil.Discard, m)
+	})
+}
+
+// FuzzEval checks that evaluating arbitrary init expressions never panics.
+func FuzzEval(f *testing.F) {
+	f.Add([]byte{0x41, 0x2a, 0x0b})
+	f.Add([]byte{0x42, 0x80, 0x7f, 0x0b})
+	f.Add([]byte{0x43, 0x00, 0x00, 0x80, 0x3f, 0x0b})
+	f.Add([]byte{0x44, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0x0b})
+	f.Add([]byte{0x23, 0x01, 0x0b})
+
+	globals := []interface{}{int32(1), int64(2)}
+	f.Fuzz(func(t *testing.T, expr []byte) {
+		Eval(expr, globals)
+	})
+}
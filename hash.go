@@ -0,0 +1,48 @@
+package wasm
+
+import (
+	"crypto/sha256"
+)
+
+// HashOptions controls which sections Module.Hash includes.
+type HashOptions struct {
+	// IgnoreCustom leaves out all custom sections, including the name
+	// section, so that builds that differ only in debug information and
+	// metadata hash the same.
+	IgnoreCustom bool
+
+	// Ignore lists the names of custom sections to leave out, such as
+	// "name" or "producers".
+	Ignore []string
+}
+
+// Hash returns the SHA-256 hash of the module. It's the hash of the module
+// encoded by Encode without the ignored sections, which makes it independent
+// of how the input encoded sizes and indices: modules that differ only in the
+// padding of LEB128 values hash the same.
+func (m *Module) Hash(opts HashOptions) ([]byte, error) {
+	ignore := make(map[string]bool)
+	for _, name := range opts.Ignore {
+		ignore[name] = true
+	}
+	var sections []Section
+	for _, s := range m.Sections {
+		switch s := s.(type) {
+		case *SectionCustom:
+			if opts.IgnoreCustom || ignore[s.SectionName] {
+				continue
+			}
+		case *SectionName:
+			if opts.IgnoreCustom || ignore[s.SectionName] {
+				continue
+			}
+		}
+		sections = append(sections, s)
+	}
+
+	h := sha256.New()
+	if err := Encode(h, &Module{Sections: sections}); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,70 @@
+package wasm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	hash := func(mod *Module, opts HashOptions) []byte {
+		h, err := mod.Hash(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	mod := instrumentModule()
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	if sum := sha256.Sum256(buf.Bytes()); !bytes.Equal(hash(mod, HashOptions{}), sum[:]) {
+		t.Error("Expected hash of the encoded module")
+	}
+
+	other := instrumentModule()
+	other.NameSection().Functions.Names[0].Name = "g"
+	if err := other.AddCustomSection("producers", []byte{0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(hash(mod, HashOptions{}), hash(other, HashOptions{})) {
+		t.Error("Expected different hashes with custom sections")
+	}
+	if !bytes.Equal(hash(mod, HashOptions{IgnoreCustom: true}), hash(other, HashOptions{IgnoreCustom: true})) {
+		t.Error("Expected same hashes without custom sections")
+	}
+	ignore := HashOptions{Ignore: []string{"name", "producers"}}
+	if !bytes.Equal(hash(mod, ignore), hash(other, ignore)) {
+		t.Error("Expected same hashes without name and producers sections")
+	}
+
+	other.CodeSection().Bodies[0].Code = []byte{0x01, 0x0b}
+	if bytes.Equal(hash(mod, HashOptions{IgnoreCustom: true}), hash(other, HashOptions{IgnoreCustom: true})) {
+		t.Error("Expected different hashes with different code")
+	}
+}
+
+func TestHashPadding(t *testing.T) {
+	// The same module with a padded function type index.
+	a, err := ParseBytes([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x03, 0x02, 0x01, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseBytes([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x03, 0x03, 0x01, 0x80, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ha, err := a.Hash(HashOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := b.Hash(HashOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ha, hb) {
+		t.Errorf("Expected same hashes, got %x and %x", ha, hb)
+	}
+}
@@ -0,0 +1,208 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// Op codes that may appear in an init expression.
+const (
+	opGetGlobal = 0x23
+	opI32Const  = 0x41
+	opI64Const  = 0x42
+	opF32Const  = 0x43
+	opF64Const  = 0x44
+)
+
+// Eval evaluates an init expression, such as the Offset of a DataSegment or
+// the Init of a GlobalVariable.
+//
+// An init expression may read the value of an imported global variable with
+// get_global. The values of the globals are provided in globals, indexed by
+// the global index. It is an error to reference a global that is not in
+// globals.
+//
+// The returned value is an int32, int64, float32 or float64, depending on the
+// type of the expression.
+//
+// https://github.com/WebAssembly/design/blob/master/Modules.md#initializer-expression
+func Eval(expr []byte, globals []interface{}) (interface{}, error) {
+	if len(expr) == 0 {
+		return nil, fmt.Errorf("empty init expression")
+	}
+
+	var v interface{}
+	var n int
+	var err error
+
+	op, b := expr[0], expr[1:]
+	switch op {
+	case opI32Const:
+		var i int64
+		i, n, err = leb128.DecodeInt(b, 32)
+		v = int32(i)
+	case opI64Const:
+		v, n, err = leb128.DecodeInt(b, 64)
+	case opF32Const:
+		if len(b) < 4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v, n = math.Float32frombits(binary.LittleEndian.Uint32(b)), 4
+	case opF64Const:
+		if len(b) < 8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		v, n = math.Float64frombits(binary.LittleEndian.Uint64(b)), 8
+	case opGetGlobal:
+		var i uint64
+		i, n, err = leb128.DecodeUint(b, 32)
+		if err == nil {
+			if int(i) >= len(globals) {
+				return nil, fmt.Errorf("global index %d out of range", i)
+			}
+			v = globals[i]
+		}
+	default:
+		return nil, fmt.Errorf("op code 0x%02x not allowed in init expression", op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read immediate: %v", err)
+	}
+
+	if rest := b[n:]; len(rest) != 1 || rest[0] != opEnd {
+		return nil, fmt.Errorf("init expression not terminated by end")
+	}
+
+	return v, nil
+}
+
+// readInitExpr reads an init expression, including the terminating end op
+// code, into v.
+//
+// The expression cannot simply be read until the first end byte as the
+// immediate may contain the same value, so it's read instruction by
+// instruction. Any instruction is accepted; whether the expression is
+// constant is checked by Validate.
+func readInitExpr(r io.Reader, v *[]byte) error {
+	depth := 0
+	for {
+		start := len(*v)
+		if err := readInstrBytes(r, v); err != nil {
+			return err
+		}
+		in, _, err := decodeInstr((*v)[start:], nil)
+		if err != nil {
+			return err
+		}
+		switch in.Op {
+		case opBlock, opLoop, opIf:
+			depth++
+		case opEnd:
+			if depth == 0 {
+				if r := traced(r); r != nil {
+					ins, _ := Disassemble(*v)
+					r.emit(TraceRead, "init expr", ins)
+				}
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+// readInstrBytes appends the bytes of the next instruction in r to v.
+func readInstrBytes(r io.Reader, v *[]byte) error {
+	op, err := readByte(r)
+	if err != nil {
+		return err
+	}
+	*v = append(*v, op)
+
+	// The immediates are decoded to find their end, and copied as read.
+	tr := io.TeeReader(r, appendWriter{v})
+	code := Opcode(op)
+	if op == opPrefixMisc {
+		sub, err := leb128.ReadUint(tr, 32)
+		if err != nil {
+			return err
+		}
+		if sub > 0xff {
+			return fmt.Errorf("unknown op code 0xfc %d", sub)
+		}
+		code = opPrefixMisc<<8 | Opcode(sub)
+	}
+	info, ok := opcodes[code]
+	if !ok {
+		return fmt.Errorf("unknown op code 0x%02x", uint16(code))
+	}
+
+	uints := func(n uint64) error {
+		for i := uint64(0); i < n; i++ {
+			if _, err := leb128.ReadUint(tr, 32); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	sint := func(bits uint) error {
+		_, err := leb128.ReadInt(tr, bits)
+		return err
+	}
+	types := func(n uint64) error {
+		for i := uint64(0); i < n; i++ {
+			if _, err := readByte(tr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch info.imm {
+	case ImmBlockType:
+		return sint(33)
+	case ImmI32:
+		return sint(32)
+	case ImmI64:
+		return sint(64)
+	case ImmRefType:
+		return types(1)
+	case ImmIndex, ImmMemory:
+		return uints(1)
+	case ImmIndex2, ImmCallIndirect, ImmMemArg, ImmMemory2:
+		return uints(2)
+	case ImmF32, ImmF64:
+		n := 4
+		if info.imm == ImmF64 {
+			n = 8
+		}
+		_, err := io.ReadFull(tr, make([]byte, n))
+		return err
+	case ImmBrTable:
+		c, err := leb128.ReadUint(tr, 32)
+		if err != nil {
+			return err
+		}
+		return uints(c + 1) // the labels and the default label
+	case ImmSelect:
+		c, err := leb128.ReadUint(tr, 32)
+		if err != nil {
+			return err
+		}
+		return types(c)
+	}
+	return nil
+}
+
+// appendWriter appends the bytes written to it to a slice.
+type appendWriter struct {
+	b *[]byte
+}
+
+func (w appendWriter) Write(p []byte) (int, error) {
+	*w.b = append(*w.b, p...)
+	return len(p), nil
+}
@@ -0,0 +1,304 @@
+package wasm
+
+import (
+	"fmt"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+const opRefFunc = 0xd2
+
+// AddFunctionImport adds an import of the function module.field with the
+// signature t and returns its index in the function index space. The type is
+// added to the type section unless the module already has an identical one.
+//
+// Imported functions come first in the function index space, so the import
+// takes the index after the last imported function, and the indices of the
+// functions defined in the module increase by one. All references to them are
+// updated: calls and ref.func instructions in the function bodies and global
+// init expressions, element segments, exports, the start function and the
+// name section. Relocations in the custom sections of object files are not
+// updated, and code metadata sections such as branch hints are removed.
+func (m *Module) AddFunctionImport(module, field string, t FuncType) (uint32, error) {
+	s := m.ImportSection()
+	if s != nil {
+		for _, e := range s.Entries {
+			if e.Module == module && e.Field == field {
+				return 0, fmt.Errorf("import %s.%s already exists", module, field)
+			}
+		}
+	}
+	fi := m.importedFunctions()
+
+	if err := m.remapFunctions(func(i uint32) uint32 {
+		if i >= fi {
+			return i + 1
+		}
+		return i
+	}); err != nil {
+		return 0, err
+	}
+
+	if s == nil {
+		s = &SectionImport{}
+		m.insertSection(s)
+	}
+	s.Entries = append(s.Entries, ImportEntry{
+		Module:       module,
+		Field:        field,
+		Kind:         ExtKindFunction,
+		FunctionType: &FunctionType{Index: m.addType(t)},
+	})
+	return fi, nil
+}
+
+// addType returns the index of the function type t in the type section, adding
+// it if there is no identical type.
+func (m *Module) addType(t FuncType) uint32 {
+	if t.Form == 0 {
+		t.Form = 0x60 // func
+	}
+	t.ReturnCount = uint8(len(t.ReturnTypes))
+
+	s := m.TypeSection()
+	if s == nil {
+		s = &SectionType{}
+		m.insertSection(s)
+	}
+	for i, e := range s.Entries {
+		if sameValueTypes(e.Params, t.Params) && sameValueTypes(e.ReturnTypes, t.ReturnTypes) {
+			return uint32(i)
+		}
+	}
+	s.Entries = append(s.Entries, t)
+	return uint32(len(s.Entries) - 1)
+}
+
+func sameValueTypes(a, b []ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// remapFunctions replaces every reference to a function index i with f(i).
+func (m *Module) remapFunctions(f func(uint32) uint32) error {
+	// Rewrite the code first, so the module is unchanged if it fails to
+	// decode.
+	code := m.CodeSection()
+	var bodies [][]byte
+	if code != nil {
+		bodies = make([][]byte, len(code.Bodies))
+		for i, body := range code.Bodies {
+			b, err := remapFunctionsInCode(body.Code, f)
+			if err != nil {
+				return fmt.Errorf("function body %d: %v", i, err)
+			}
+			bodies[i] = b
+		}
+	}
+	globals := m.GlobalSection()
+	var inits [][]byte
+	if globals != nil {
+		inits = make([][]byte, len(globals.Globals))
+		for i, g := range globals.Globals {
+			b, err := remapFunctionsInCode(g.Init, f)
+			if err != nil {
+				return fmt.Errorf("global %d: %v", i, err)
+			}
+			inits[i] = b
+		}
+	}
+
+	for i, b := range bodies {
+		code.Bodies[i].Code = b
+	}
+	for i, b := range inits {
+		globals.Globals[i].Init = b
+	}
+	if s := m.ElementSection(); s != nil {
+		for i := range s.Entries {
+			elems := make([]uint32, len(s.Entries[i].Elems))
+			for j, fi := range s.Entries[i].Elems {
+				elems[j] = f(fi)
+			}
+			s.Entries[i].Elems = elems
+		}
+	}
+	if s := m.ExportSection(); s != nil {
+		for i, e := range s.Entries {
+			if e.Kind == ExtKindFunction {
+				s.Entries[i].Index = f(e.Index)
+			}
+		}
+	}
+	if s := m.StartSection(); s != nil {
+		s.Index = f(s.Index)
+	}
+	if s := m.NameSection(); s != nil {
+		if s.Functions != nil {
+			for i, n := range s.Functions.Names {
+				s.Functions.Names[i].Index = f(n.Index)
+			}
+		}
+		if s.Locals != nil {
+			for i, l := range s.Locals.Funcs {
+				s.Locals.Funcs[i].Index = f(l.Index)
+			}
+		}
+	}
+	m.removeCodeMetadata()
+	return nil
+}
+
+// remapFunctionsInCode returns a copy of code with the function index of
+// every call and ref.func instruction i replaced with f(i).
+func remapFunctionsInCode(code []byte, f func(uint32) uint32) ([]byte, error) {
+	out := make([]byte, 0, len(code))
+	last := 0
+	for off := 0; off < len(code); {
+		in, n, err := decodeInstr(code[off:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("0x%x: %v", off, err)
+		}
+		if in.Op == opCall || in.Op == opRefFunc {
+			out = append(out, code[last:off]...)
+			out = leb128.AppendUint(append(out, byte(in.Op)), uint64(f(uint32(in.Immediates[0]))))
+			last = off + n
+		}
+		off += n
+	}
+	return append(out, code[last:]...), nil
+}
+
+// An Instrumentation describes the instructions that Instrument inserts into
+// the function bodies. Each function returns the instructions to insert, or
+// nil to insert nothing. The offsets of the returned instructions are
+// ignored.
+//
+// The inserted instructions must leave the operand stack as they find it:
+// before a call, the arguments of the call are on the stack, and after a call
+// its results are.
+type Instrumentation struct {
+	// FunctionStart returns the instructions to insert at the start of the
+	// body of the function with index fi in the function index space.
+	FunctionStart func(fi uint32) []Instruction
+
+	// BeforeCall and AfterCall return the instructions to insert before and
+	// after a call or call_indirect instruction.
+	BeforeCall func(c CallSite) []Instruction
+	AfterCall  func(c CallSite) []Instruction
+}
+
+// A CallSite is a call or call_indirect instruction found by Instrument.
+type CallSite struct {
+	// Caller is the index of the function that contains the call in the
+	// function index space.
+	Caller uint32
+
+	// Call is the call instruction. Its offset is the offset in the body
+	// before any instructions were inserted.
+	Call Instruction
+}
+
+// Instrument inserts instructions into the bodies of the functions defined in
+// the module, which is the basis for coverage and profiling tools. To call a
+// function of the host, such as a counter, import it with AddFunctionImport
+// first, so the function indices passed to ins are the final ones:
+//
+//	enter, err := m.AddFunctionImport("env", "enter", wasm.FuncType{Params: []wasm.ValueType{wasm.I32}})
+//	...
+//	err = m.Instrument(wasm.Instrumentation{
+//		FunctionStart: func(fi uint32) []wasm.Instruction {
+//			return []wasm.Instruction{
+//				{Op: 0x41, Immediates: []uint64{uint64(fi)}},    // i32.const fi
+//				{Op: 0x10, Immediates: []uint64{uint64(enter)}}, // call enter
+//			}
+//		},
+//	})
+//
+// The offsets of the instructions change, so code metadata sections such as
+// branch hints are removed. The module is unchanged if Instrument returns an
+// error.
+func (m *Module) Instrument(ins Instrumentation) error {
+	code := m.CodeSection()
+	if code == nil {
+		return nil
+	}
+	imported := m.importedFunctions()
+
+	bodies := make([][]byte, len(code.Bodies))
+	for i, body := range code.Bodies {
+		fi := imported + uint32(i)
+		b, err := instrumentCode(body.Code, fi, ins)
+		if err != nil {
+			return fmt.Errorf("function %d: %v", fi, err)
+		}
+		bodies[i] = b
+	}
+	for i, b := range bodies {
+		code.Bodies[i].Code = b
+	}
+	m.removeCodeMetadata()
+	return nil
+}
+
+// importedFunctions returns the number of imported functions.
+func (m *Module) importedFunctions() uint32 {
+	var n uint32
+	if s := m.ImportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind == ExtKindFunction {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// instrumentCode returns a copy of the code of function fi with the
+// instructions of ins inserted.
+func instrumentCode(code []byte, fi uint32, ins Instrumentation) ([]byte, error) {
+	out := make([]byte, 0, len(code))
+	insert := func(ins []Instruction) error {
+		var err error
+		for _, in := range ins {
+			if out, err = appendInstr(out, in); err != nil {
+				return fmt.Errorf("insert %s: %v", in.Op, err)
+			}
+		}
+		return nil
+	}
+
+	if ins.FunctionStart != nil {
+		if err := insert(ins.FunctionStart(fi)); err != nil {
+			return nil, err
+		}
+	}
+	for off := 0; off < len(code); {
+		in, n, err := decodeInstr(code[off:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("0x%x: %v", off, err)
+		}
+		in.Offset = off
+		call := in.Op == opCall || in.Op == opCallIndirect
+		if call && ins.BeforeCall != nil {
+			if err := insert(ins.BeforeCall(CallSite{Caller: fi, Call: in})); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, code[off:off+n]...)
+		if call && ins.AfterCall != nil {
+			if err := insert(ins.AfterCall(CallSite{Caller: fi, Call: in})); err != nil {
+				return nil, err
+			}
+		}
+		off += n
+	}
+	return out, nil
+}
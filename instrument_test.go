@@ -0,0 +1,212 @@
+package wasm
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func instrumentModule() *Module {
+	return &Module{Sections: []Section{
+		&SectionType{Entries: []FuncType{
+			{Form: 0x60},
+			{Form: 0x60, Params: []ValueType{0x7f}},
+		}},
+		&SectionImport{Entries: []ImportEntry{
+			{Module: "env", Field: "f", Kind: ExtKindFunction, FunctionType: &FunctionType{Index: 0}},
+		}},
+		&SectionFunction{Types: []uint32{0, 0}},
+		&SectionTable{Entries: []TableType{{ElemType: 0x70, Limits: ResizableLimits{Initial: 2}}}},
+		&SectionGlobal{Globals: []GlobalVariable{
+			{Type: GlobalType{ContentType: 0x7f}, Init: []byte{0x41, 0x02, 0x0b}},
+		}},
+		&SectionExport{Entries: []ExportEntry{{Field: "b", Kind: ExtKindFunction, Index: 2}}},
+		&SectionStart{Index: 1},
+		&SectionElement{Entries: []ElemSegment{
+			{Offset: []byte{0x41, 0x00, 0x0b}, Elems: []uint32{1, 2}},
+		}},
+		&SectionCode{Bodies: []FunctionBody{
+			{Code: []byte{
+				0x10, 0x02, // call 2
+				0x0b, // end
+			}},
+			{Code: []byte{
+				0x10, 0x00, // call 0
+				0xd2, 0x01, // ref.func 1
+				0x1a, // drop
+				0x0b, // end
+			}},
+		}},
+		&SectionName{
+			SectionName: "name",
+			Functions: &NameMap{Names: []Naming{
+				{Index: 0, Name: "f"}, {Index: 1, Name: "a"}, {Index: 2, Name: "b"},
+			}},
+		},
+	}}
+}
+
+func TestAddFunctionImport(t *testing.T) {
+	mod := instrumentModule()
+	mod.GlobalSection().Globals[0] = GlobalVariable{
+		Type: GlobalType{ContentType: 0x70},
+		Init: []byte{0xd2, 0x02, 0x0b}, // ref.func 2
+	}
+	fi, err := mod.AddFunctionImport("env", "hook", FuncType{Params: []ValueType{0x7f}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi != 1 {
+		t.Errorf("Expected import at index 1, got %d", fi)
+	}
+	if n := len(mod.TypeSection().Entries); n != 2 {
+		t.Errorf("Expected the existing type to be used, got %d types", n)
+	}
+	if e := mod.ImportSection().Entries[1]; e.Field != "hook" || e.FunctionType.Index != 1 {
+		t.Errorf("Expected hook import with type 1, got %+v", e)
+	}
+
+	code := mod.CodeSection().Bodies
+	if expected := []byte{0x10, 0x03, 0x0b}; !bytes.Equal(code[0].Code, expected) {
+		t.Errorf("Expected code %x, got %x", expected, code[0].Code)
+	}
+	if expected := []byte{0x10, 0x00, 0xd2, 0x02, 0x1a, 0x0b}; !bytes.Equal(code[1].Code, expected) {
+		t.Errorf("Expected code %x, got %x", expected, code[1].Code)
+	}
+	if init := mod.GlobalSection().Globals[0].Init; !bytes.Equal(init, []byte{0xd2, 0x03, 0x0b}) {
+		t.Errorf("Expected global init ref.func 3, got %x", init)
+	}
+	if elems := mod.ElementSection().Entries[0].Elems; !reflect.DeepEqual(elems, []uint32{2, 3}) {
+		t.Errorf("Expected elements [2 3], got %v", elems)
+	}
+	if i := mod.ExportSection().Entries[0].Index; i != 3 {
+		t.Errorf("Expected export of function 3, got %d", i)
+	}
+	if i := mod.StartSection().Index; i != 2 {
+		t.Errorf("Expected start function 2, got %d", i)
+	}
+	var names []uint32
+	for _, n := range mod.NameSection().Functions.Names {
+		names = append(names, n.Index)
+	}
+	if !reflect.DeepEqual(names, []uint32{0, 2, 3}) {
+		t.Errorf("Expected names for functions [0 2 3], got %v", names)
+	}
+
+	if _, err := mod.AddFunctionImport("env", "hook", FuncType{}); err == nil {
+		t.Error("Expected error adding existing import")
+	}
+	if _, err := mod.AddFunctionImport("env", "other", FuncType{ReturnTypes: []ValueType{0x7e}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(mod.TypeSection().Entries); n != 3 {
+		t.Errorf("Expected a new type, got %d types", n)
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	mod := instrumentModule()
+	hook, err := mod.AddFunctionImport("env", "hook", FuncType{Params: []ValueType{0x7f}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(v uint64) []Instruction {
+		return []Instruction{
+			{Op: 0x41, Immediates: []uint64{v}},
+			{Op: opCall, Immediates: []uint64{uint64(hook)}},
+		}
+	}
+	var sites []CallSite
+	err = mod.Instrument(Instrumentation{
+		FunctionStart: func(fi uint32) []Instruction {
+			return call(uint64(fi))
+		},
+		BeforeCall: func(c CallSite) []Instruction {
+			sites = append(sites, c)
+			return call(100 + c.Call.Immediates[0])
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := mod.CodeSection().Bodies
+	expected := []byte{
+		0x41, 0x02, 0x10, 0x01, // i32.const 2, call hook
+		0x41, 0xe7, 0x00, 0x10, 0x01, // i32.const 103, call hook
+		0x10, 0x03, // call 3
+		0x0b, // end
+	}
+	if !bytes.Equal(code[0].Code, expected) {
+		t.Errorf("Expected code\n%x\ngot\n%x", expected, code[0].Code)
+	}
+	expected = []byte{
+		0x41, 0x03, 0x10, 0x01, // i32.const 3, call hook
+		0x41, 0xe4, 0x00, 0x10, 0x01, // i32.const 100, call hook
+		0x10, 0x00, // call 0
+		0xd2, 0x02, // ref.func 2
+		0x1a, // drop
+		0x0b, // end
+	}
+	if !bytes.Equal(code[1].Code, expected) {
+		t.Errorf("Expected code\n%x\ngot\n%x", expected, code[1].Code)
+	}
+	if len(sites) != 2 || sites[0].Caller != 2 || sites[1].Caller != 3 || sites[1].Call.Offset != 0 {
+		t.Errorf("Unexpected call sites %+v", sites)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual.CodeSection().Bodies[1].Code, expected) {
+		t.Errorf("Expected instrumented code after encoding")
+	}
+
+	err = mod.Instrument(Instrumentation{
+		FunctionStart: func(fi uint32) []Instruction {
+			return []Instruction{{Op: opCall}}
+		},
+	})
+	if err == nil {
+		t.Error("Expected error for invalid instruction")
+	}
+	if !bytes.Equal(mod.CodeSection().Bodies[1].Code, expected) {
+		t.Errorf("Expected module unchanged after error")
+	}
+}
+
+func TestAddFunctionImportHelloWorld(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := mod.AddFunctionImport("env", "hook", FuncType{Params: []ValueType{0x7f}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi != 14 {
+		t.Errorf("Expected import at index 14, got %d", fi)
+	}
+	if e := mod.ExportSection().Entries[0]; e.Field != "run" || e.Index != 865 {
+		t.Errorf("Expected run to be function 865, got %+v", e)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameModule(t, mod, actual)
+}
@@ -0,0 +1,219 @@
+// Package leb128 encodes and decodes integers in the LEB128 variable length
+// format used by WebAssembly.
+//
+// https://webassembly.github.io/spec/core/binary/values.html#integers
+//
+// An N bit integer is encoded in at most ceil(N/7) bytes. The encoding may be
+// padded with redundant bytes up to that length, but the bits of the last byte
+// that don't fit in the integer must be zero for unsigned integers, or a sign
+// extension of the value for signed integers. Encodings that break these rules
+// are rejected by the decoders.
+package leb128
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	// ErrOverflow is returned if an encoding is longer than ceil(N/7)
+	// bytes.
+	ErrOverflow = errors.New("integer representation too long")
+
+	// ErrTooLarge is returned if the value doesn't fit in N bits.
+	ErrTooLarge = errors.New("integer too large")
+)
+
+// DecodeUint decodes an unsigned integer of at most bits bits from the start of
+// b. It returns the value and the number of bytes consumed.
+//
+// If b ends before the end of the integer, io.ErrUnexpectedEOF is returned.
+func DecodeUint(b []byte, bits uint) (uint64, int, error) {
+	var v uint64
+	var shift uint
+	max := maxLen(bits)
+	for i, c := range b {
+		if i == max-1 {
+			if err := checkLast(c, bits, shift, false); err != nil {
+				return 0, 0, err
+			}
+		}
+		v |= uint64(c&0x7F) << shift
+		if c&0x80 == 0 {
+			return v, i + 1, nil
+		}
+		shift += 7
+	}
+	return 0, 0, io.ErrUnexpectedEOF
+}
+
+// DecodeInt decodes a signed integer of at most bits bits from the start of b.
+// It returns the value and the number of bytes consumed.
+//
+// If b ends before the end of the integer, io.ErrUnexpectedEOF is returned.
+func DecodeInt(b []byte, bits uint) (int64, int, error) {
+	var v int64
+	var shift uint
+	max := maxLen(bits)
+	for i, c := range b {
+		if i == max-1 {
+			if err := checkLast(c, bits, shift, true); err != nil {
+				return 0, 0, err
+			}
+		}
+		v |= int64(c&0x7F) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			return signExtend(v, c, shift), i + 1, nil
+		}
+	}
+	return 0, 0, io.ErrUnexpectedEOF
+}
+
+// ReadUint reads an unsigned integer of at most bits bits from r.
+//
+// If r is at the end of the input, io.EOF is returned. If the input ends
+// before the end of the integer, io.ErrUnexpectedEOF is returned.
+func ReadUint(r io.Reader, bits uint) (uint64, error) {
+	var v uint64
+	var shift uint
+	max := maxLen(bits)
+	for i := 0; ; i++ {
+		c, err := readByte(r, i)
+		if err != nil {
+			return 0, err
+		}
+		if i == max-1 {
+			if err := checkLast(c, bits, shift, false); err != nil {
+				return 0, err
+			}
+		}
+		v |= uint64(c&0x7F) << shift
+		if c&0x80 == 0 {
+			return v, nil
+		}
+		shift += 7
+	}
+}
+
+// ReadInt reads a signed integer of at most bits bits from r.
+//
+// If r is at the end of the input, io.EOF is returned. If the input ends
+// before the end of the integer, io.ErrUnexpectedEOF is returned.
+func ReadInt(r io.Reader, bits uint) (int64, error) {
+	var v int64
+	var shift uint
+	max := maxLen(bits)
+	for i := 0; ; i++ {
+		c, err := readByte(r, i)
+		if err != nil {
+			return 0, err
+		}
+		if i == max-1 {
+			if err := checkLast(c, bits, shift, true); err != nil {
+				return 0, err
+			}
+		}
+		v |= int64(c&0x7F) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			return signExtend(v, c, shift), nil
+		}
+	}
+}
+
+// AppendUint appends the shortest encoding of v to b.
+func AppendUint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// AppendInt appends the shortest encoding of v to b.
+func AppendInt(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// UintSize returns the length of the shortest encoding of v.
+func UintSize(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		n++
+		v >>= 7
+	}
+	return n
+}
+
+// IntSize returns the length of the shortest encoding of v.
+func IntSize(v int64) int {
+	n := 1
+	for v < -0x40 || v >= 0x40 {
+		n++
+		v >>= 7
+	}
+	return n
+}
+
+// maxLen returns the maximum length of the encoding of an integer with the
+// given number of bits.
+func maxLen(bits uint) int {
+	return int(bits+6) / 7
+}
+
+// checkLast checks the last byte c that may be part of an integer of the given
+// number of bits. shift is the number of bits decoded before c.
+func checkLast(c byte, bits, shift uint, signed bool) error {
+	if c&0x80 != 0 {
+		return ErrOverflow
+	}
+	rem := bits - shift
+	if rem >= 7 {
+		return nil
+	}
+	if !signed {
+		if c>>rem != 0 {
+			return ErrTooLarge
+		}
+		return nil
+	}
+	// The sign bit and all unused bits must be equal.
+	mask := byte(0x7F) &^ (1<<(rem-1) - 1)
+	if s := c & mask; s != 0 && s != mask {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// signExtend extends the sign of v if the sign bit of the last byte c is set.
+func signExtend(v int64, c byte, shift uint) int64 {
+	if shift < 64 && c&0x40 != 0 {
+		v |= -1 << shift
+	}
+	return v
+}
+
+// readByte reads the byte at index i of an integer from r.
+func readByte(r io.Reader, i int) (byte, error) {
+	var c byte
+	var err error
+	if br, ok := r.(io.ByteReader); ok {
+		c, err = br.ReadByte()
+	} else {
+		var b [1]byte
+		_, err = io.ReadFull(r, b[:])
+		c = b[0]
+	}
+	if err == io.EOF && i > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return c, err
+}
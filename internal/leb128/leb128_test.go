@@ -0,0 +1,269 @@
+package leb128
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+)
+
+var uintTests = []struct {
+	name  string
+	in    []byte
+	bits  uint
+	value uint64
+	err   error
+}{
+	{"zero", []byte{0x00}, 32, 0, nil},
+	{"one byte", []byte{0x7f}, 32, 127, nil},
+	{"two bytes", []byte{0x80, 0x01}, 32, 128, nil},
+	{"padded", []byte{0x83, 0x80, 0x80, 0x80, 0x00}, 32, 3, nil},
+	{"max uint32", []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, 32, math.MaxUint32, nil},
+	{"too long uint32", []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, 32, 0, ErrOverflow},
+	{"unused bits uint32", []byte{0xff, 0xff, 0xff, 0xff, 0x1f}, 32, 0, ErrTooLarge},
+	{"max uint64", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, 64, math.MaxUint64, nil},
+	{"too long uint64", []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, 64, 0, ErrOverflow},
+	{"unused bits uint64", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x03}, 64, 0, ErrTooLarge},
+	{"uint1", []byte{0x01}, 1, 1, nil},
+	{"uint1 too large", []byte{0x02}, 1, 0, ErrTooLarge},
+	{"uint7 too long", []byte{0x80, 0x00}, 7, 0, ErrOverflow},
+	{"truncated", []byte{0x80, 0x80}, 32, 0, io.ErrUnexpectedEOF},
+}
+
+var intTests = []struct {
+	name  string
+	in    []byte
+	bits  uint
+	value int64
+	err   error
+}{
+	{"zero", []byte{0x00}, 32, 0, nil},
+	{"minus one", []byte{0x7f}, 32, -1, nil},
+	{"positive with sign bit", []byte{0xc0, 0x00}, 32, 64, nil},
+	{"negative", []byte{0x80, 0x7f}, 32, -128, nil},
+	{"padded negative", []byte{0xff, 0xff, 0xff, 0xff, 0x7f}, 32, -1, nil},
+	{"max int32", []byte{0xff, 0xff, 0xff, 0xff, 0x07}, 32, math.MaxInt32, nil},
+	{"min int32", []byte{0x80, 0x80, 0x80, 0x80, 0x78}, 32, math.MinInt32, nil},
+	{"too long int32", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, 32, 0, ErrOverflow},
+	{"unused bits int32", []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, 32, 0, ErrTooLarge},
+	{"unused bits negative int32", []byte{0x80, 0x80, 0x80, 0x80, 0x70}, 32, 0, ErrTooLarge},
+	{"max int33", []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, 33, 1<<32 - 1, nil},
+	{"unused bits int33", []byte{0xff, 0xff, 0xff, 0xff, 0x1f}, 33, 0, ErrTooLarge},
+	{"max int64", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}, 64, math.MaxInt64, nil},
+	{"min int64", []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7f}, 64, math.MinInt64, nil},
+	{"unused bits int64", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, 64, 0, ErrTooLarge},
+	{"int7", []byte{0x40}, 7, -64, nil},
+	{"int7 too long", []byte{0xff, 0x7f}, 7, 0, ErrOverflow},
+	{"truncated", []byte{0xff}, 64, 0, io.ErrUnexpectedEOF},
+}
+
+func TestDecodeUint(t *testing.T) {
+	for _, tc := range uintTests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Trailing bytes must not be consumed.
+			in := append(append([]byte(nil), tc.in...), 0xff)
+			if tc.err == io.ErrUnexpectedEOF {
+				in = tc.in
+			}
+			v, n, err := DecodeUint(in, tc.bits)
+			if err != tc.err {
+				t.Fatalf("Error does not match; expected %v, actual %v", tc.err, err)
+			}
+			if v != tc.value {
+				t.Errorf("Value does not match; expected %d, actual %d", tc.value, v)
+			}
+			if err == nil && n != len(tc.in) {
+				t.Errorf("Length does not match; expected %d, actual %d", len(tc.in), n)
+			}
+		})
+	}
+}
+
+func TestDecodeInt(t *testing.T) {
+	for _, tc := range intTests {
+		t.Run(tc.name, func(t *testing.T) {
+			in := append(append([]byte(nil), tc.in...), 0xff)
+			if tc.err == io.ErrUnexpectedEOF {
+				in = tc.in
+			}
+			v, n, err := DecodeInt(in, tc.bits)
+			if err != tc.err {
+				t.Fatalf("Error does not match; expected %v, actual %v", tc.err, err)
+			}
+			if v != tc.value {
+				t.Errorf("Value does not match; expected %d, actual %d", tc.value, v)
+			}
+			if err == nil && n != len(tc.in) {
+				t.Errorf("Length does not match; expected %d, actual %d", len(tc.in), n)
+			}
+		})
+	}
+}
+
+func TestReadUint(t *testing.T) {
+	for _, tc := range uintTests {
+		t.Run(tc.name, func(t *testing.T) {
+			// OneByteReader hides the io.ByteReader implementation.
+			for _, r := range []io.Reader{bytes.NewReader(tc.in), iotest.OneByteReader(bytes.NewReader(tc.in))} {
+				v, err := ReadUint(r, tc.bits)
+				if err != tc.err {
+					t.Fatalf("Error does not match; expected %v, actual %v", tc.err, err)
+				}
+				if v != tc.value {
+					t.Errorf("Value does not match; expected %d, actual %d", tc.value, v)
+				}
+			}
+		})
+	}
+
+	if _, err := ReadUint(bytes.NewReader(nil), 32); err != io.EOF {
+		t.Errorf("Expected io.EOF for empty input, got %v", err)
+	}
+}
+
+func TestReadInt(t *testing.T) {
+	for _, tc := range intTests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, r := range []io.Reader{bytes.NewReader(tc.in), iotest.OneByteReader(bytes.NewReader(tc.in))} {
+				v, err := ReadInt(r, tc.bits)
+				if err != tc.err {
+					t.Fatalf("Error does not match; expected %v, actual %v", tc.err, err)
+				}
+				if v != tc.value {
+					t.Errorf("Value does not match; expected %d, actual %d", tc.value, v)
+				}
+			}
+		})
+	}
+
+	if _, err := ReadInt(bytes.NewReader(nil), 32); err != io.EOF {
+		t.Errorf("Expected io.EOF for empty input, got %v", err)
+	}
+}
+
+// TestShortEncodings checks all one and two byte inputs: the decoders must
+// agree with each other and every value must encode back to the same bytes,
+// unless the input is padded.
+func TestShortEncodings(t *testing.T) {
+	for i := 0; i < 1<<16; i++ {
+		in := []byte{byte(i), byte(i >> 8)}
+		if in[0]&0x80 == 0 {
+			if i > 0xff {
+				continue
+			}
+			in = in[:1]
+		}
+		padded := len(in) == 2 && (in[1] == 0 || in[1] == 0x7f)
+
+		u, n, err := DecodeUint(in, 32)
+		if err != nil {
+			if in[1]&0x80 == 0 {
+				t.Fatalf("DecodeUint(% x): %v", in, err)
+			}
+			continue
+		}
+		if r, _ := ReadUint(bytes.NewReader(in), 32); r != u {
+			t.Fatalf("ReadUint(% x) = %d, DecodeUint = %d", in, r, u)
+		}
+		if n != len(in) {
+			t.Fatalf("DecodeUint(% x) consumed %d bytes", in, n)
+		}
+		if out := AppendUint(nil, u); !bytes.Equal(out, in) && !(padded && in[1] == 0) {
+			t.Fatalf("AppendUint(%d) = % x, expected % x", u, out, in)
+		}
+
+		s, _, err := DecodeInt(in, 32)
+		if err != nil {
+			t.Fatalf("DecodeInt(% x): %v", in, err)
+		}
+		if r, _ := ReadInt(bytes.NewReader(in), 32); r != s {
+			t.Fatalf("ReadInt(% x) = %d, DecodeInt = %d", in, r, s)
+		}
+		if out := AppendInt(nil, s); !bytes.Equal(out, in) && !(padded && in[0]&0x40 == in[1]&0x40) {
+			t.Fatalf("AppendInt(%d) = % x, expected % x", s, out, in)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var values []uint64
+	for shift := uint(0); shift < 64; shift++ {
+		v := uint64(1) << shift
+		values = append(values, v-1, v, v+1)
+	}
+	values = append(values, math.MaxUint64)
+
+	for _, v := range values {
+		b := AppendUint(nil, v)
+		if len(b) != UintSize(v) {
+			t.Errorf("UintSize(%d) = %d, encoded %d bytes", v, UintSize(v), len(b))
+		}
+		d, n, err := DecodeUint(b, 64)
+		if err != nil || d != v || n != len(b) {
+			t.Errorf("DecodeUint(AppendUint(%d)) = %d, %d, %v", v, d, n, err)
+		}
+		if v <= math.MaxUint32 {
+			if _, _, err := DecodeUint(b, 32); err != nil {
+				t.Errorf("DecodeUint(AppendUint(%d), 32): %v", v, err)
+			}
+		} else if _, _, err := DecodeUint(b, 32); err == nil {
+			t.Errorf("Expected error decoding %d as uint32", v)
+		}
+
+		for _, s := range []int64{int64(v), -int64(v)} {
+			b := AppendInt(nil, s)
+			if len(b) != IntSize(s) {
+				t.Errorf("IntSize(%d) = %d, encoded %d bytes", s, IntSize(s), len(b))
+			}
+			d, n, err := DecodeInt(b, 64)
+			if err != nil || d != s || n != len(b) {
+				t.Errorf("DecodeInt(AppendInt(%d)) = %d, %d, %v", s, d, n, err)
+			}
+			_, _, err = DecodeInt(b, 32)
+			if fits := s >= math.MinInt32 && s <= math.MaxInt32; fits != (err == nil) {
+				t.Errorf("DecodeInt(AppendInt(%d), 32): %v", s, err)
+			}
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	tt := []struct {
+		v    uint64
+		size int
+	}{
+		{0, 1},
+		{127, 1},
+		{128, 2},
+		{255, 2},
+		{1<<14 - 1, 2},
+		{1 << 14, 3},
+		{math.MaxUint32, 5},
+		{math.MaxUint64, 10},
+	}
+
+	for _, tc := range tt {
+		if s := UintSize(tc.v); s != tc.size {
+			t.Errorf("Size of %d does not match; expected %d, actual %d", tc.v, tc.size, s)
+		}
+	}
+
+	for _, v := range []int64{-64, 63} {
+		if s := IntSize(v); s != 1 {
+			t.Errorf("Size of %d does not match; expected 1, actual %d", v, s)
+		}
+	}
+	for _, v := range []int64{-65, 64} {
+		if s := IntSize(v); s != 2 {
+			t.Errorf("Size of %d does not match; expected 2, actual %d", v, s)
+		}
+	}
+}
+
+func BenchmarkDecodeUint(b *testing.B) {
+	in := AppendUint(nil, math.MaxUint32)
+	for i := 0; i < b.N; i++ {
+		DecodeUint(in, 32)
+	}
+}
@@ -0,0 +1,279 @@
+// Command specgen downloads the WebAssembly spec test suite and converts the
+// modules in its scripts to the binary files used by the spec tests of the
+// parser:
+//
+//	go generate github.com/akupila/go-wasm
+//
+// The files are written to testdata/spec, one directory per script. Every
+// module is named after the line of its command and what the parser is
+// expected to do with it:
+//
+//	binary-leb128/12.valid.wasm      must parse
+//	binary-leb128/220.malformed.wasm must fail to parse
+//
+// Modules given in the binary format are extracted from the scripts. Modules in
+// the text format are converted with wast2json from WABT if it's installed,
+// and skipped otherwise.
+//
+// The test suite is pinned to the commit in testdata/spec/COMMIT, which is
+// written when the suite is downloaded and is checked in. Run with -ref to
+// download another commit, branch or tag, which is required if there is no
+// COMMIT file yet.
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/akupila/go-wasm/wast"
+)
+
+const repo = "WebAssembly/testsuite"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("specgen: ")
+
+	dir := flag.String("dir", filepath.Join("testdata", "spec"), "directory to write the modules to")
+	ref := flag.String("ref", "", "commit, branch or tag of the test suite (default: the commit in <dir>/COMMIT)")
+	skip := flag.String("skip", `^simd_`, "regular expression matching the scripts to skip, which use features the parser doesn't support")
+	flag.Parse()
+
+	skipRe, err := regexp.Compile(*skip)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ref == "" {
+		b, err := ioutil.ReadFile(filepath.Join(*dir, "COMMIT"))
+		if err != nil {
+			log.Fatalf("no pinned commit: %v; run with -ref to choose one", err)
+		}
+		*ref = strings.TrimSpace(string(b))
+	}
+
+	commit, err := resolve(*ref)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("downloading %s@%s", repo, commit)
+	scripts, err := download(commit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.RemoveAll(*dir); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(*dir, "COMMIT"), []byte(commit+"\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = exec.LookPath("wast2json")
+	haveWast2json := err == nil
+	if !haveWast2json {
+		log.Print("wast2json not found, skipping modules in the text format")
+	}
+
+	var total, skipped int
+	for name, src := range scripts {
+		if skipRe.MatchString(name) {
+			continue
+		}
+		var modules []module
+		if haveWast2json {
+			modules, err = convertText(name, src)
+		} else {
+			modules, skipped, err = convertBinary(src, skipped)
+		}
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		if len(modules) == 0 {
+			continue
+		}
+
+		out := filepath.Join(*dir, strings.TrimSuffix(name, ".wast"))
+		if err := os.MkdirAll(out, 0755); err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range modules {
+			file := filepath.Join(out, fmt.Sprintf("%d.%s.wasm", m.line, m.expect))
+			if err := ioutil.WriteFile(file, m.data, 0644); err != nil {
+				log.Fatal(err)
+			}
+		}
+		total += len(modules)
+	}
+	log.Printf("wrote %d modules, skipped %d modules in the text format", total, skipped)
+}
+
+// A module is a module from a script with the expected result of parsing it.
+type module struct {
+	line   int
+	expect string // "valid" or "malformed"
+	data   []byte
+}
+
+// resolve returns the commit that ref refers to.
+func resolve(ref string) (string, error) {
+	req, err := http.NewRequest("GET", "https://api.github.com/repos/"+repo+"/commits/"+ref, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+	b, err := get(req)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %v", ref, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// download returns the scripts in the top level directory of the test suite,
+// keyed by file name. The scripts of proposals live in subdirectories.
+func download(commit string) (map[string][]byte, error) {
+	req, err := http.NewRequest("GET", "https://codeload.github.com/"+repo+"/tar.gz/"+commit, nil)
+	if err != nil {
+		return nil, err
+	}
+	b, err := get(req)
+	if err != nil {
+		return nil, fmt.Errorf("download: %v", err)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	scripts := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return scripts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Names are prefixed with a directory named after the repository
+		// and commit.
+		parts := strings.Split(h.Name, "/")
+		if len(parts) != 2 || path.Ext(parts[1]) != ".wast" {
+			continue
+		}
+		src, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		scripts[parts[1]] = src
+	}
+}
+
+func get(req *http.Request) ([]byte, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// convertBinary returns the modules of a script that are given in the binary
+// format, and adds the number of modules in the text format to skipped.
+func convertBinary(src []byte, skipped int) ([]module, int, error) {
+	s, err := wast.ParseBytes(src)
+	if err != nil {
+		return nil, skipped, err
+	}
+	var modules []module
+	for _, c := range s.Commands {
+		var expect string
+		switch c.Type {
+		case "module":
+			expect = "valid"
+		case "assert_malformed":
+			expect = "malformed"
+		default:
+			continue
+		}
+		if !c.Module.IsBinary() {
+			skipped++
+			continue
+		}
+		modules = append(modules, module{line: c.Line, expect: expect, data: c.Module.Binary})
+	}
+	return modules, skipped, nil
+}
+
+// convertText converts all modules of a script with wast2json.
+func convertText(name string, src []byte) ([]module, error) {
+	tmp, err := ioutil.TempDir("", "specgen")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	script := filepath.Join(tmp, name)
+	if err := ioutil.WriteFile(script, src, 0644); err != nil {
+		return nil, err
+	}
+	manifest := filepath.Join(tmp, "script.json")
+	cmd := exec.Command("wast2json", "--enable-all", script, "-o", manifest)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("wast2json: %v\n%s", err, out)
+	}
+
+	b, err := ioutil.ReadFile(manifest)
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		Commands []struct {
+			Type       string `json:"type"`
+			Line       int    `json:"line"`
+			Filename   string `json:"filename"`
+			ModuleType string `json:"module_type"`
+		} `json:"commands"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("read %s: %v", manifest, err)
+	}
+
+	var modules []module
+	for _, c := range v.Commands {
+		var expect string
+		switch {
+		case c.Type == "module":
+			expect = "valid"
+		case c.Type == "assert_malformed" && c.ModuleType == "binary":
+			expect = "malformed"
+		default:
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(tmp, c.Filename))
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, module{line: c.Line, expect: expect, data: data})
+	}
+	return modules, nil
+}
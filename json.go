@@ -0,0 +1,134 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// sectionKinds maps the names used to tell sections apart in the JSON encoding
+// of a module to a function that returns a new section of that kind.
+var sectionKinds = map[string]func() Section{
+	"custom":   func() Section { return &SectionCustom{} },
+	"type":     func() Section { return &SectionType{} },
+	"import":   func() Section { return &SectionImport{} },
+	"function": func() Section { return &SectionFunction{} },
+	"table":    func() Section { return &SectionTable{} },
+	"memory":   func() Section { return &SectionMemory{} },
+	"global":   func() Section { return &SectionGlobal{} },
+	"export":   func() Section { return &SectionExport{} },
+	"start":    func() Section { return &SectionStart{} },
+	"element":  func() Section { return &SectionElement{} },
+	"code":     func() Section { return &SectionCode{} },
+	"data":     func() Section { return &SectionData{} },
+	"name":     func() Section { return &SectionName{} },
+}
+
+// sectionKind returns the name of the kind of s in the JSON encoding.
+func sectionKind(s Section) (string, error) {
+	switch s.(type) {
+	case *SectionCustom:
+		return "custom", nil
+	case *SectionType:
+		return "type", nil
+	case *SectionImport:
+		return "import", nil
+	case *SectionFunction:
+		return "function", nil
+	case *SectionTable:
+		return "table", nil
+	case *SectionMemory:
+		return "memory", nil
+	case *SectionGlobal:
+		return "global", nil
+	case *SectionExport:
+		return "export", nil
+	case *SectionStart:
+		return "start", nil
+	case *SectionElement:
+		return "element", nil
+	case *SectionCode:
+		return "code", nil
+	case *SectionData:
+		return "data", nil
+	case *SectionName:
+		return "name", nil
+	}
+	return "", fmt.Errorf("unknown section type %T", s)
+}
+
+// MarshalJSON encodes the module as JSON. Every section is encoded as an
+// object with the fields of the section and a "Section" field that holds the
+// kind of the section in lower case, such as "import", "code" or "name", so
+// the module can be decoded with UnmarshalJSON:
+//
+//	{
+//		"Sections": [
+//			{"Section": "start", "Index": 1}
+//		]
+//	}
+func (m Module) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"Sections":`)
+	if m.Sections == nil {
+		buf.WriteString("null")
+	} else {
+		buf.WriteByte('[')
+		for i, s := range m.Sections {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			kind, err := sectionKind(s)
+			if err != nil {
+				return nil, err
+			}
+			b, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&buf, `{"Section":%q`, kind)
+			if len(b) > 2 {
+				// Add the fields of the section after the kind.
+				buf.WriteByte(',')
+			}
+			buf.Write(b[1:])
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a module encoded with MarshalJSON.
+//
+// The offsets and sizes of the decoded sections are unknown, so Offset,
+// PayloadOffset and Size return 0. Single sections, such as the ones in the
+// golden files of the tests, can be decoded directly to the section type.
+func (m *Module) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Sections []json.RawMessage
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	m.Sections = nil
+	for i, raw := range v.Sections {
+		var kind struct {
+			Section string
+		}
+		if err := json.Unmarshal(raw, &kind); err != nil {
+			return fmt.Errorf("section %d: %v", i, err)
+		}
+		newSection, ok := sectionKinds[kind.Section]
+		if !ok {
+			return fmt.Errorf("section %d: unknown section kind %q", i, kind.Section)
+		}
+		s := newSection()
+		if err := json.Unmarshal(raw, s); err != nil {
+			return fmt.Errorf("section %d (%s): %v", i, kind.Section, err)
+		}
+		m.Sections = append(m.Sections, s)
+	}
+	return nil
+}
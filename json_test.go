@@ -0,0 +1,144 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestModuleJSON(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod, err := ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := json.Marshal(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Module
+	if err := json.Unmarshal(j, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded.Sections) != len(mod.Sections) {
+		t.Fatalf("Expected %d sections, got %d", len(mod.Sections), len(decoded.Sections))
+	}
+	for i, s := range decoded.Sections {
+		if reflect.TypeOf(s) != reflect.TypeOf(mod.Sections[i]) {
+			t.Errorf("Section %d: expected %T, got %T", i, mod.Sections[i], s)
+		}
+		if s.ID() != mod.Sections[i].ID() || s.Name() != mod.Sections[i].Name() {
+			t.Errorf("Section %d: expected %s (0x%02x), got %s (0x%02x)", i, mod.Sections[i].Name(), mod.Sections[i].ID(), s.Name(), s.ID())
+		}
+	}
+
+	j2, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(j, j2) {
+		t.Error("JSON of decoded module does not match")
+	}
+
+	var expected, actual bytes.Buffer
+	if err := Encode(&expected, mod); err != nil {
+		t.Fatal(err)
+	}
+	if err := Encode(&actual, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+		t.Error("Decoded module does not encode to the same bytes")
+	}
+}
+
+func TestModuleJSONFormat(t *testing.T) {
+	mod := &Module{Sections: []Section{
+		&SectionStart{Index: 1},
+		&SectionCustom{SectionName: "a", Payload: []byte{1}},
+	}}
+	j, err := json.Marshal(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"Sections":[{"Section":"start","Index":1},{"Section":"custom","SectionName":"a","Payload":"AQ=="}]}`
+	if string(j) != expected {
+		t.Errorf("Expected %s, got %s", expected, j)
+	}
+
+	for _, in := range []string{
+		`{"Sections":[{"Section":"unknown"}]}`,
+		`{"Sections":[{"Index":1}]}`,
+		`{"Sections":[{"Section":"start","Index":"x"}]}`,
+	} {
+		var m Module
+		if err := json.Unmarshal([]byte(in), &m); err == nil {
+			t.Errorf("Expected error decoding %s", in)
+		}
+	}
+}
+
+func TestValueTypeJSON(t *testing.T) {
+	types := []ValueType{I32, I64, F32, F64, V128, FuncRef, ExternRef, 0x40}
+	j, err := json.Marshal(types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `["i32","i64","f32","f64","v128","funcref","externref",64]`
+	if string(j) != expected {
+		t.Errorf("Expected %s, got %s", expected, j)
+	}
+	var actual []ValueType
+	if err := json.Unmarshal(j, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, types) {
+		t.Errorf("Expected %v, got %v", types, actual)
+	}
+
+	// Types used to be encoded as numbers.
+	var lt LocalEntry
+	if err := json.Unmarshal([]byte(`{"Count":1,"Type":127}`), &lt); err != nil || lt.Type != I32 {
+		t.Errorf("Expected i32 from number, got %v, %v", lt.Type, err)
+	}
+	for _, in := range []string{`"i31"`, `256`, `-1`, `true`} {
+		var vt ValueType
+		if err := json.Unmarshal([]byte(in), &vt); err == nil {
+			t.Errorf("Expected error decoding %s", in)
+		}
+	}
+}
+
+func TestSectionJSONGolden(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The golden files contain a single section without the kind, which can
+	// be decoded to the section type.
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "golden", "helloworld-02.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s SectionImport
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name() != "Import" {
+		t.Errorf("Expected name Import, got %s", s.Name())
+	}
+	if !reflect.DeepEqual(s.Entries, mod.Sections[2].(*SectionImport).Entries) {
+		t.Error("Decoded import section does not match")
+	}
+}
@@ -0,0 +1,265 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// A SymbolKind is the kind of a symbol in the symbol table of a relocatable
+// object file.
+type SymbolKind uint8
+
+// Symbol kinds.
+const (
+	SymbolFunction SymbolKind = iota // 0x00
+	SymbolData                       // 0x01
+	SymbolGlobal                     // 0x02
+	SymbolSection                    // 0x03
+	SymbolTag                        // 0x04
+	SymbolTable                      // 0x05
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolFunction:
+		return "function"
+	case SymbolData:
+		return "data"
+	case SymbolGlobal:
+		return "global"
+	case SymbolSection:
+		return "section"
+	case SymbolTag:
+		return "tag"
+	case SymbolTable:
+		return "table"
+	}
+	return fmt.Sprintf("kind %d", uint8(k))
+}
+
+// Symbol flags.
+const (
+	SymbolBindingWeak      = 0x01
+	SymbolBindingLocal     = 0x02
+	SymbolVisibilityHidden = 0x04
+	SymbolUndefined        = 0x10
+	SymbolExported         = 0x20
+	SymbolExplicitName     = 0x40
+	SymbolNoStrip          = 0x80
+	SymbolTLS              = 0x100
+	SymbolAbsolute         = 0x200
+)
+
+// A Symbol is an entry in the symbol table of a relocatable object file, as
+// produced by compilers for the linker.
+//
+// https://github.com/WebAssembly/tool-conventions/blob/main/Linking.md#symbol-table-subsection
+type Symbol struct {
+	// Kind is the kind of the symbol.
+	Kind SymbolKind
+
+	// Flags is a combination of the Symbol flag constants.
+	Flags uint32
+
+	// Name is the name of the symbol. The name of an undefined symbol
+	// without an explicit name is the field name of its import, and the name
+	// of a section symbol is the name of the custom section.
+	Name string
+
+	// Module is the module name of the import of an undefined function,
+	// global, tag or table.
+	Module string
+
+	// Index is the index of a function, global, tag or table in its index
+	// space, or the index of the section of a section symbol.
+	Index uint32
+
+	// Segment, Offset and Size locate a defined data symbol in the data
+	// segments.
+	Segment uint32
+	Offset  uint32
+	Size    uint32
+}
+
+// Undefined reports whether the symbol is defined in another object file.
+func (s Symbol) Undefined() bool {
+	return s.Flags&SymbolUndefined != 0
+}
+
+// Binding returns the binding of the symbol: "weak", "local" or "global".
+func (s Symbol) Binding() string {
+	switch {
+	case s.Flags&SymbolBindingWeak != 0:
+		return "weak"
+	case s.Flags&SymbolBindingLocal != 0:
+		return "local"
+	}
+	return "global"
+}
+
+// Visibility returns the visibility of the symbol: "hidden" or "default".
+func (s Symbol) Visibility() string {
+	if s.Flags&SymbolVisibilityHidden != 0 {
+		return "hidden"
+	}
+	return "default"
+}
+
+// Linking subsection types.
+const (
+	linkingSymbolTable = 0x08
+)
+
+// Symbols returns the symbol table in the linking section of a relocatable
+// object file. It returns nil if the module has no linking section, which is
+// the case for linked modules.
+func (m *Module) Symbols() ([]Symbol, error) {
+	linking := m.CustomSections("linking")
+	if len(linking) == 0 {
+		return nil, nil
+	}
+	var imports []ImportEntry
+	if s := m.ImportSection(); s != nil {
+		imports = s.Entries
+	}
+
+	r := bytes.NewReader(linking[0].Payload)
+	var version uint32
+	if err := readVarUint32(r, &version); err != nil {
+		return nil, fmt.Errorf("read linking version: %v", err)
+	}
+	if version != 2 {
+		return nil, fmt.Errorf("unsupported linking version %d", version)
+	}
+
+	var syms []Symbol
+	for {
+		var t uint8
+		if err := read(r, &t); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("read subsection type: %v", err)
+		}
+		var n uint32
+		if err := readVarUint32(r, &n); err != nil {
+			return nil, fmt.Errorf("read subsection length: %v", err)
+		}
+		if int(n) > r.Len() {
+			return nil, fmt.Errorf("subsection 0x%02x: length %d exceeds section", t, n)
+		}
+		sub := make([]byte, n)
+		r.Read(sub)
+		if t != linkingSymbolTable {
+			continue
+		}
+		var err error
+		syms, err = readSymbolTable(bytes.NewReader(sub))
+		if err != nil {
+			return nil, fmt.Errorf("symbol table: %v", err)
+		}
+	}
+
+	// Undefined symbols are named after their imports unless they have an
+	// explicit name.
+	importNames := make(map[ExternalKind][]ImportEntry)
+	for _, e := range imports {
+		importNames[e.Kind] = append(importNames[e.Kind], e)
+	}
+	for i, s := range syms {
+		switch {
+		case s.Kind == SymbolSection:
+			if int(s.Index) < len(m.Sections) {
+				if c, ok := m.Sections[s.Index].(*SectionCustom); ok {
+					syms[i].Name = c.SectionName
+				}
+			}
+		case s.Undefined() && s.Kind != SymbolData:
+			kind := ExtKindFunction
+			switch s.Kind {
+			case SymbolGlobal:
+				kind = ExtKindGlobal
+			case SymbolTable:
+				kind = ExtKindTable
+			case SymbolTag:
+				// Tags are not supported by the parser.
+				continue
+			}
+			entries := importNames[kind]
+			if int(s.Index) >= len(entries) {
+				return nil, fmt.Errorf("symbol %d: import %d out of range", i, s.Index)
+			}
+			syms[i].Module = entries[s.Index].Module
+			if s.Flags&SymbolExplicitName == 0 {
+				syms[i].Name = entries[s.Index].Field
+			}
+		}
+	}
+
+	return syms, nil
+}
+
+func readSymbolTable(r *bytes.Reader) ([]Symbol, error) {
+	var count uint32
+	if err := readVarUint32(r, &count); err != nil {
+		return nil, fmt.Errorf("read count: %v", err)
+	}
+	var syms []Symbol
+	for i := uint32(0); i < count; i++ {
+		var s Symbol
+		var kind uint8
+		if err := read(r, &kind); err != nil {
+			return nil, fmt.Errorf("symbol %d: read kind: %v", i, err)
+		}
+		s.Kind = SymbolKind(kind)
+		if err := readVarUint32(r, &s.Flags); err != nil {
+			return nil, fmt.Errorf("symbol %d: read flags: %v", i, err)
+		}
+
+		var err error
+		switch s.Kind {
+		case SymbolFunction, SymbolGlobal, SymbolTag, SymbolTable:
+			if err = readVarUint32(r, &s.Index); err != nil {
+				break
+			}
+			if !s.Undefined() || s.Flags&SymbolExplicitName != 0 {
+				s.Name, err = readName(r)
+			}
+		case SymbolData:
+			if s.Name, err = readName(r); err != nil || s.Undefined() {
+				break
+			}
+			if err = readVarUint32(r, &s.Segment); err != nil {
+				break
+			}
+			if err = readVarUint32(r, &s.Offset); err != nil {
+				break
+			}
+			err = readVarUint32(r, &s.Size)
+		case SymbolSection:
+			err = readVarUint32(r, &s.Index)
+		default:
+			return nil, fmt.Errorf("symbol %d: unknown kind 0x%02x", i, kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("symbol %d: %v", i, err)
+		}
+		syms = append(syms, s)
+	}
+	return syms, nil
+}
+
+// readName reads a length prefixed UTF-8 string.
+func readName(r *bytes.Reader) (string, error) {
+	var l uint32
+	if err := readVarUint32(r, &l); err != nil {
+		return "", fmt.Errorf("read name length: %v", err)
+	}
+	if int(l) > r.Len() {
+		return "", fmt.Errorf("name length %d exceeds input", l)
+	}
+	b := make([]byte, l)
+	r.Read(b)
+	return string(b), nil
+}
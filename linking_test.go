@@ -0,0 +1,113 @@
+package wasm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+func TestSymbols(t *testing.T) {
+	u := func(v ...uint64) []byte {
+		var b []byte
+		for _, x := range v {
+			b = leb128.AppendUint(b, x)
+		}
+		return b
+	}
+	name := func(s string) []byte {
+		return append(u(uint64(len(s))), s...)
+	}
+	cat := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, p := range parts {
+			b = append(b, p...)
+		}
+		return b
+	}
+
+	table := cat(
+		u(7),
+		u(0, 0, 1), name("main"),
+		u(0, 0x10, 0),
+		u(0, 0x10|0x01|0x40, 1), name("maybe"),
+		u(1, 0x02|0x04), name("buf"), u(0, 16, 8),
+		u(1, 0x10), name("errno"),
+		u(3, 0x02, 3),
+		u(2, 0x10, 0),
+	)
+	payload := cat(
+		u(2),
+		u(5), u(2), []byte{0, 0}, // segment info, skipped
+		u(8, uint64(len(table))), table,
+	)
+
+	mod := &Module{Sections: []Section{
+		&SectionImport{Entries: []ImportEntry{
+			{Module: "env", Field: "puts", Kind: ExtKindFunction, FunctionType: &FunctionType{}},
+			{Module: "env", Field: "__stack_pointer", Kind: ExtKindGlobal, GlobalType: &GlobalType{ContentType: 0x7f, Mutable: true}},
+			{Module: "env", Field: "opt", Kind: ExtKindFunction, FunctionType: &FunctionType{}},
+		}},
+		&SectionFunction{Types: []uint32{0}},
+		&SectionCustom{SectionName: "linking", Payload: payload},
+		&SectionCustom{SectionName: ".debug_info"},
+	}}
+
+	syms, err := mod.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Symbol{
+		{Kind: SymbolFunction, Index: 1, Name: "main"},
+		{Kind: SymbolFunction, Flags: 0x10, Index: 0, Name: "puts", Module: "env"},
+		{Kind: SymbolFunction, Flags: 0x51, Index: 1, Name: "maybe", Module: "env"},
+		{Kind: SymbolData, Flags: 0x06, Name: "buf", Offset: 16, Size: 8},
+		{Kind: SymbolData, Flags: 0x10, Name: "errno"},
+		{Kind: SymbolSection, Flags: 0x02, Index: 3, Name: ".debug_info"},
+		{Kind: SymbolGlobal, Flags: 0x10, Index: 0, Name: "__stack_pointer", Module: "env"},
+	}
+	if !reflect.DeepEqual(syms, expected) {
+		t.Fatalf("Expected\n%+v\ngot\n%+v", expected, syms)
+	}
+
+	flags := []struct {
+		binding, visibility string
+		undefined           bool
+	}{
+		{"global", "default", false},
+		{"global", "default", true},
+		{"weak", "default", true},
+		{"local", "hidden", false},
+		{"global", "default", true},
+		{"local", "default", false},
+		{"global", "default", true},
+	}
+	for i, f := range flags {
+		s := syms[i]
+		if s.Binding() != f.binding || s.Visibility() != f.visibility || s.Undefined() != f.undefined {
+			t.Errorf("Symbol %s: expected %s %s undefined=%v, got %s %s undefined=%v", s.Name, f.binding, f.visibility, f.undefined, s.Binding(), s.Visibility(), s.Undefined())
+		}
+	}
+
+	// Truncated symbol table.
+	mod.Sections[2].(*SectionCustom).Payload = payload[:len(payload)-1]
+	if _, err := mod.Symbols(); err == nil {
+		t.Error("Expected error for truncated linking section")
+	}
+}
+
+func TestSymbolsLinked(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syms, err := mod.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if syms != nil {
+		t.Errorf("Expected no symbols in linked module, got %d", len(syms))
+	}
+}
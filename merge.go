@@ -0,0 +1,624 @@
+package wasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Merge links modules into a single module, the way a static linker bundles
+// the modules of a multi-module build.
+//
+// The index spaces of the modules are concatenated in the order the modules
+// are given, and every reference to a function, table, memory, global, type,
+// element segment or data segment is rebased: in the function bodies, init
+// expressions, element segments, exports, the start function and the name
+// section. Identical function types are merged into one.
+//
+// An import of one module is resolved by an export of another module with
+// the same name and kind if the import module name is the name of the other
+// module, as given by the module name in its name section. Imports from
+// "env", which is what C and Rust toolchains import undefined symbols from,
+// are resolved by an export of any other module. The types of resolved
+// functions and globals must match. Imports that aren't resolved are kept,
+// and identical imports of several modules are merged into one.
+//
+// The exports of all modules are kept and must have different names. If more
+// than one module has a start function, the merged module gets a new start
+// function that calls them in order. Since memory instructions can only
+// access the first memory, the modules can have at most one memory in total,
+// counting identical imports once: a module that needs the memory of another
+// must import it. Custom sections other than the name section are not copied.
+func Merge(modules ...*Module) (*Module, error) {
+	ins := make([]*mergeInput, len(modules))
+	for i, m := range modules {
+		in, err := newMergeInput(m)
+		if err != nil {
+			return nil, fmt.Errorf("module %d: %v", i, err)
+		}
+		ins[i] = in
+	}
+
+	out := &Module{}
+	for _, in := range ins {
+		for ti, t := range in.types {
+			in.typeMap[ti] = out.addType(t)
+		}
+	}
+
+	if err := resolveImports(ins); err != nil {
+		return nil, err
+	}
+
+	// Assign indices to the imports that are kept, then to the definitions of
+	// every module in order.
+	var next [4]uint32
+	importSec := &SectionImport{}
+	kept := make(map[string]int)
+	for i, in := range ins {
+		var n [4]uint32
+		for _, e := range in.imports {
+			k := e.Kind
+			pos := n[k]
+			n[k]++
+			if in.resolved[k][pos] != nil {
+				continue
+			}
+			if e.Kind == ExtKindFunction {
+				e.FunctionType = &FunctionType{Index: in.typeMap[e.FunctionType.Index]}
+			}
+			key := fmt.Sprintf("%d %s.%s", k, e.Module, e.Field)
+			if j, ok := kept[key]; ok {
+				if !sameImport(importSec.Entries[j], e) {
+					return nil, fmt.Errorf("module %d: import %s.%s has a different type than in an earlier module", i, e.Module, e.Field)
+				}
+				in.indices[k][pos] = importIndex(importSec.Entries, j)
+				continue
+			}
+			kept[key] = len(importSec.Entries)
+			importSec.Entries = append(importSec.Entries, e)
+			in.indices[k][pos] = next[k]
+			next[k]++
+		}
+	}
+	for _, in := range ins {
+		for k := range next {
+			for i := in.imported[k]; i < len(in.indices[k]); i++ {
+				in.indices[k][i] = next[k]
+				next[k]++
+			}
+		}
+	}
+	if next[ExtKindMemory] > 1 {
+		return nil, fmt.Errorf("the merged module would have %d memories, but only one is supported", next[ExtKindMemory])
+	}
+
+	limit := 0
+	for _, in := range ins {
+		limit += len(in.imports)
+	}
+	for i, in := range ins {
+		for k := range in.resolved {
+			for pos, ref := range in.resolved[k] {
+				if ref == nil {
+					continue
+				}
+				idx, err := resolvedIndex(ins, ref, limit)
+				if err != nil {
+					return nil, fmt.Errorf("module %d: import %s.%s: %v", i, ref.imp.Module, ref.imp.Field, err)
+				}
+				in.indices[k][pos] = idx
+			}
+		}
+	}
+
+	var elems, data uint32
+	for _, in := range ins {
+		for i := range in.elemMap {
+			in.elemMap[i] = elems
+			elems++
+		}
+		for i := range in.dataMap {
+			in.dataMap[i] = data
+			data++
+		}
+	}
+
+	var (
+		funcSec   = &SectionFunction{}
+		tableSec  = &SectionTable{}
+		memSec    = &SectionMemory{}
+		globalSec = &SectionGlobal{}
+		exportSec = &SectionExport{}
+		elemSec   = &SectionElement{}
+		codeSec   = &SectionCode{}
+		dataSec   = &SectionData{}
+		nameSec   = &SectionName{SectionName: "name", Functions: &NameMap{}, Locals: &Locals{}}
+		exported  = make(map[string]int)
+		starts    []uint32
+	)
+	for i, in := range ins {
+		if err := in.merge(funcSec, codeSec, globalSec, elemSec, dataSec); err != nil {
+			return nil, fmt.Errorf("module %d: %v", i, err)
+		}
+		for _, s := range in.m.Sections {
+			switch s := s.(type) {
+			case *SectionTable:
+				tableSec.Entries = append(tableSec.Entries, s.Entries...)
+			case *SectionMemory:
+				memSec.Entries = append(memSec.Entries, s.Entries...)
+			case *SectionExport:
+				for _, e := range s.Entries {
+					if j, ok := exported[e.Field]; ok {
+						return nil, fmt.Errorf("export %q is defined by modules %d and %d", e.Field, j, i)
+					}
+					exported[e.Field] = i
+					idx, err := in.index(e.Kind, e.Index)
+					if err != nil {
+						return nil, fmt.Errorf("module %d: export %q: %v", i, e.Field, err)
+					}
+					exportSec.Entries = append(exportSec.Entries, ExportEntry{Field: e.Field, Kind: e.Kind, Index: idx})
+				}
+			case *SectionStart:
+				idx, err := in.index(ExtKindFunction, s.Index)
+				if err != nil {
+					return nil, fmt.Errorf("module %d: start function: %v", i, err)
+				}
+				starts = append(starts, idx)
+			case *SectionName:
+				in.mergeNames(s, nameSec)
+			}
+		}
+	}
+
+	var startSec *SectionStart
+	switch {
+	case len(starts) == 1:
+		startSec = &SectionStart{Index: starts[0]}
+	case len(starts) > 1:
+		// Call the start functions from a new function.
+		var code []byte
+		for _, fi := range starts {
+			code, _ = appendInstr(code, Instruction{Op: opCall, Immediates: []uint64{uint64(fi)}})
+		}
+		funcSec.Types = append(funcSec.Types, out.addType(FuncType{}))
+		codeSec.Bodies = append(codeSec.Bodies, FunctionBody{Code: append(code, opEnd)})
+		startSec = &SectionStart{Index: next[ExtKindFunction]}
+	}
+
+	if len(importSec.Entries) > 0 {
+		out.insertSection(importSec)
+	}
+	if len(funcSec.Types) > 0 {
+		out.insertSection(funcSec)
+		out.insertSection(codeSec)
+	}
+	if len(tableSec.Entries) > 0 {
+		out.insertSection(tableSec)
+	}
+	if len(memSec.Entries) > 0 {
+		out.insertSection(memSec)
+	}
+	if len(globalSec.Globals) > 0 {
+		out.insertSection(globalSec)
+	}
+	if len(exportSec.Entries) > 0 {
+		out.insertSection(exportSec)
+	}
+	if startSec != nil {
+		out.insertSection(startSec)
+	}
+	if len(elemSec.Entries) > 0 {
+		out.insertSection(elemSec)
+	}
+	if len(dataSec.Entries) > 0 {
+		out.insertSection(dataSec)
+	}
+	if len(nameSec.Functions.Names) > 0 || len(nameSec.Locals.Funcs) > 0 {
+		sort.Slice(nameSec.Functions.Names, func(i, j int) bool {
+			return nameSec.Functions.Names[i].Index < nameSec.Functions.Names[j].Index
+		})
+		sort.Slice(nameSec.Locals.Funcs, func(i, j int) bool {
+			return nameSec.Locals.Funcs[i].Index < nameSec.Locals.Funcs[j].Index
+		})
+		if len(nameSec.Functions.Names) == 0 {
+			nameSec.Functions = nil
+		}
+		if len(nameSec.Locals.Funcs) == 0 {
+			nameSec.Locals = nil
+		}
+		out.Sections = append(out.Sections, nameSec)
+	}
+	return out, nil
+}
+
+// mergeInput is a module passed to Merge, with its index spaces and their
+// mapping to the merged module.
+type mergeInput struct {
+	m       *Module
+	name    string // module name from the name section
+	types   []FuncType
+	imports []ImportEntry
+	exports map[string]ExportEntry
+
+	// funcTypes and globalTypes contain the type of every function and
+	// global, imports first.
+	funcTypes   []uint32
+	globalTypes []GlobalType
+
+	// imported contains the number of imports of each kind, and resolved the
+	// export that satisfies every import, indexed by kind and the index of the
+	// import in the index space, or nil if the import is kept.
+	imported [4]int
+	resolved [4][]*mergeRef
+
+	// typeMap, indices, elemMap and dataMap map the indices of the module to
+	// the ones in the merged module. indices is indexed by kind.
+	typeMap []uint32
+	indices [4][]uint32
+	elemMap []uint32
+	dataMap []uint32
+}
+
+// A mergeRef is an import resolved by the export of another module.
+type mergeRef struct {
+	imp    ImportEntry
+	module int
+	index  uint32 // index of the export in the other module
+}
+
+func newMergeInput(m *Module) (*mergeInput, error) {
+	in := &mergeInput{m: m, exports: make(map[string]ExportEntry)}
+	if s := m.TypeSection(); s != nil {
+		in.types = s.Entries
+	}
+	if s := m.ImportSection(); s != nil {
+		in.imports = s.Entries
+	}
+	for _, e := range in.imports {
+		if e.Kind > ExtKindGlobal {
+			return nil, fmt.Errorf("import %s.%s: unknown external kind %d", e.Module, e.Field, e.Kind)
+		}
+		in.imported[e.Kind]++
+		switch e.Kind {
+		case ExtKindFunction:
+			if int(e.FunctionType.Index) >= len(in.types) {
+				return nil, fmt.Errorf("import %s.%s: type index %d out of range", e.Module, e.Field, e.FunctionType.Index)
+			}
+			in.funcTypes = append(in.funcTypes, e.FunctionType.Index)
+		case ExtKindGlobal:
+			in.globalTypes = append(in.globalTypes, *e.GlobalType)
+		}
+	}
+	var bodies int
+	for _, s := range m.Sections {
+		switch s := s.(type) {
+		case *SectionFunction:
+			for _, ti := range s.Types {
+				if int(ti) >= len(in.types) {
+					return nil, fmt.Errorf("type index %d out of range", ti)
+				}
+			}
+			in.funcTypes = append(in.funcTypes, s.Types...)
+		case *SectionGlobal:
+			for _, g := range s.Globals {
+				in.globalTypes = append(in.globalTypes, g.Type)
+			}
+		case *SectionExport:
+			for _, e := range s.Entries {
+				in.exports[e.Field] = e
+			}
+		case *SectionElement:
+			in.elemMap = make([]uint32, len(s.Entries))
+		case *SectionCode:
+			bodies = len(s.Bodies)
+		case *SectionData:
+			in.dataMap = make([]uint32, len(s.Entries))
+		case *SectionName:
+			in.name = s.Module
+		}
+	}
+	if defined := len(in.funcTypes) - in.imported[ExtKindFunction]; bodies != defined {
+		return nil, fmt.Errorf("%d functions but %d function bodies", defined, bodies)
+	}
+
+	in.typeMap = make([]uint32, len(in.types))
+	for k := range in.indices {
+		n, _ := m.indexSpaceSize(ExternalKind(k))
+		in.indices[k] = make([]uint32, n)
+		in.resolved[k] = make([]*mergeRef, in.imported[k])
+	}
+	return in, nil
+}
+
+// resolveImports finds the exports of other modules that satisfy the imports
+// of each module.
+func resolveImports(ins []*mergeInput) error {
+	for i, in := range ins {
+		var n [4]uint32
+		for _, e := range in.imports {
+			pos := n[e.Kind]
+			n[e.Kind]++
+			j, err := exporter(ins, i, e)
+			if err != nil {
+				return fmt.Errorf("module %d: import %s.%s: %v", i, e.Module, e.Field, err)
+			}
+			if j < 0 {
+				continue
+			}
+			exp := ins[j].exports[e.Field]
+			if exp.Kind != e.Kind {
+				return fmt.Errorf("module %d: import %s.%s is a %s, but module %d exports a %s", i, e.Module, e.Field, kindNames[e.Kind], j, kindNames[exp.Kind])
+			}
+			if int(exp.Index) >= len(ins[j].indices[e.Kind]) {
+				return fmt.Errorf("module %d: export %q: %s index %d out of range", j, e.Field, kindNames[e.Kind], exp.Index)
+			}
+			switch e.Kind {
+			case ExtKindFunction:
+				want := in.types[e.FunctionType.Index]
+				got := ins[j].types[ins[j].funcTypes[exp.Index]]
+				if !sameValueTypes(want.Params, got.Params) || !sameValueTypes(want.ReturnTypes, got.ReturnTypes) {
+					return fmt.Errorf("module %d: import %s.%s has type %s, but module %d exports it with type %s", i, e.Module, e.Field, want, j, got)
+				}
+			case ExtKindGlobal:
+				if want, got := *e.GlobalType, ins[j].globalTypes[exp.Index]; want != got {
+					return fmt.Errorf("module %d: import %s.%s has type %s, but module %d exports it with type %s", i, e.Module, e.Field, globalTypeText(want), j, globalTypeText(got))
+				}
+			}
+			in.resolved[e.Kind][pos] = &mergeRef{imp: e, module: j, index: exp.Index}
+		}
+	}
+	return nil
+}
+
+// exporter returns the index of the module other than i whose export resolves
+// the import e, or -1 if there is none.
+func exporter(ins []*mergeInput, i int, e ImportEntry) (int, error) {
+	for j, in := range ins {
+		if j != i && in.name != "" && in.name == e.Module {
+			if _, ok := in.exports[e.Field]; !ok {
+				return 0, fmt.Errorf("module %d doesn't export %q", j, e.Field)
+			}
+			return j, nil
+		}
+	}
+	if e.Module != "env" {
+		return -1, nil
+	}
+	found := -1
+	for j, in := range ins {
+		if _, ok := in.exports[e.Field]; ok && j != i {
+			if found >= 0 {
+				return 0, fmt.Errorf("exported by modules %d and %d", found, j)
+			}
+			found = j
+		}
+	}
+	return found, nil
+}
+
+// resolvedIndex returns the index in the merged module of the export that
+// resolves an import. The export may itself be a resolved import, so the
+// chain is followed for at most limit steps.
+func resolvedIndex(ins []*mergeInput, ref *mergeRef, limit int) (uint32, error) {
+	for ; limit >= 0; limit-- {
+		in, k := ins[ref.module], ref.imp.Kind
+		if int(ref.index) >= len(in.resolved[k]) || in.resolved[k][ref.index] == nil {
+			return in.indices[k][ref.index], nil
+		}
+		ref = in.resolved[k][ref.index]
+	}
+	return 0, fmt.Errorf("import cycle")
+}
+
+// sameImport reports whether two imports of the same name import the same
+// type. Function types are compared by their index in the merged module.
+func sameImport(a, b ImportEntry) bool {
+	switch a.Kind {
+	case ExtKindFunction:
+		return a.FunctionType.Index == b.FunctionType.Index
+	case ExtKindGlobal:
+		return *a.GlobalType == *b.GlobalType
+	}
+	return true
+}
+
+// importIndex returns the index of the j-th import in the index space of its
+// kind.
+func importIndex(imports []ImportEntry, j int) uint32 {
+	var n uint32
+	for _, e := range imports[:j] {
+		if e.Kind == imports[j].Kind {
+			n++
+		}
+	}
+	return n
+}
+
+// index returns the index in the merged module of the function, table,
+// memory or global with index i in the module.
+func (in *mergeInput) index(kind ExternalKind, i uint32) (uint32, error) {
+	if kind > ExtKindGlobal {
+		return 0, fmt.Errorf("unknown external kind %d", kind)
+	}
+	if int(i) >= len(in.indices[kind]) {
+		return 0, fmt.Errorf("%s index %d out of range", kindNames[kind], i)
+	}
+	return in.indices[kind][i], nil
+}
+
+// merge appends the functions, globals, element segments and data segments
+// of the module to the sections of the merged module.
+func (in *mergeInput) merge(funcSec *SectionFunction, codeSec *SectionCode, globalSec *SectionGlobal, elemSec *SectionElement, dataSec *SectionData) error {
+	for _, ti := range in.funcTypes[in.imported[ExtKindFunction]:] {
+		funcSec.Types = append(funcSec.Types, in.typeMap[ti])
+	}
+	for _, s := range in.m.Sections {
+		switch s := s.(type) {
+		case *SectionGlobal:
+			for i, g := range s.Globals {
+				init, err := in.remap(g.Init)
+				if err != nil {
+					return fmt.Errorf("global %d: %v", in.imported[ExtKindGlobal]+i, err)
+				}
+				globalSec.Globals = append(globalSec.Globals, GlobalVariable{Type: g.Type, Init: init})
+			}
+		case *SectionElement:
+			for i, e := range s.Entries {
+				seg := ElemSegment{Elems: make([]uint32, len(e.Elems))}
+				var err error
+				if seg.Index, err = in.index(ExtKindTable, e.Index); err != nil {
+					return fmt.Errorf("element segment %d: %v", i, err)
+				}
+				if seg.Offset, err = in.remap(e.Offset); err != nil {
+					return fmt.Errorf("element segment %d: %v", i, err)
+				}
+				for j, fi := range e.Elems {
+					if seg.Elems[j], err = in.index(ExtKindFunction, fi); err != nil {
+						return fmt.Errorf("element segment %d: %v", i, err)
+					}
+				}
+				elemSec.Entries = append(elemSec.Entries, seg)
+			}
+		case *SectionCode:
+			for i, body := range s.Bodies {
+				code, err := in.remap(body.Code)
+				if err != nil {
+					return fmt.Errorf("function %d: %v", in.imported[ExtKindFunction]+i, err)
+				}
+				codeSec.Bodies = append(codeSec.Bodies, FunctionBody{Locals: body.Locals, Code: code})
+			}
+		case *SectionData:
+			for i, d := range s.Entries {
+				seg := DataSegment{Data: d.Data}
+				var err error
+				if seg.Index, err = in.index(ExtKindMemory, d.Index); err != nil {
+					return fmt.Errorf("data segment %d: %v", i, err)
+				}
+				if seg.Offset, err = in.remap(d.Offset); err != nil {
+					return fmt.Errorf("data segment %d: %v", i, err)
+				}
+				dataSec.Entries = append(dataSec.Entries, seg)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeNames adds the function and local names of the module to the name
+// section of the merged module. Names of imports that were resolved or merged
+// with an earlier import are dropped.
+func (in *mergeInput) mergeNames(s *SectionName, out *SectionName) {
+	named := make(map[uint32]bool, len(out.Functions.Names))
+	for _, n := range out.Functions.Names {
+		named[n.Index] = true
+	}
+	if s.Functions != nil {
+		for _, n := range s.Functions.Names {
+			if int(n.Index) < in.imported[ExtKindFunction] && in.resolved[ExtKindFunction][n.Index] != nil {
+				continue
+			}
+			fi, err := in.index(ExtKindFunction, n.Index)
+			if err != nil || named[fi] {
+				continue
+			}
+			named[fi] = true
+			out.Functions.Names = append(out.Functions.Names, Naming{Index: fi, Name: n.Name})
+		}
+	}
+	if s.Locals != nil {
+		for _, l := range s.Locals.Funcs {
+			if int(l.Index) < in.imported[ExtKindFunction] {
+				continue
+			}
+			if fi, err := in.index(ExtKindFunction, l.Index); err == nil {
+				out.Locals.Funcs = append(out.Locals.Funcs, LocalName{Index: fi, LocalMap: l.LocalMap})
+			}
+		}
+	}
+}
+
+// remap returns a copy of code, which is a function body or an init
+// expression of the module, with every index replaced with the one in the
+// merged module.
+func (in *mergeInput) remap(code []byte) ([]byte, error) {
+	out := make([]byte, 0, len(code))
+	var imm []uint64
+	for off := 0; off < len(code); {
+		ins, n, err := decodeInstr(code[off:], imm)
+		if err != nil {
+			return nil, fmt.Errorf("0x%x: %v", off, err)
+		}
+		imm = ins.Immediates
+		changed, err := in.remapInstr(ins)
+		if err != nil {
+			return nil, fmt.Errorf("0x%x: %s: %v", off, ins.Op, err)
+		}
+		if changed {
+			if out, err = appendInstr(out, ins); err != nil {
+				return nil, fmt.Errorf("0x%x: %v", off, err)
+			}
+		} else {
+			out = append(out, code[off:off+n]...)
+		}
+		off += n
+	}
+	return out, nil
+}
+
+// remapInstr replaces the indices in the immediates of ins and reports
+// whether it has any.
+func (in *mergeInput) remapInstr(ins Instruction) (bool, error) {
+	imm := ins.Immediates
+	var err error
+	remap := func(i int, kind ExternalKind) {
+		if err == nil {
+			var v uint32
+			v, err = in.index(kind, uint32(imm[i]))
+			imm[i] = uint64(v)
+		}
+	}
+	switch ins.Op {
+	case opBlock, opLoop, opIf:
+		if int64(imm[0]) < 0 {
+			return false, nil
+		}
+		if imm[0] >= uint64(len(in.typeMap)) {
+			return false, fmt.Errorf("type index %d out of range", imm[0])
+		}
+		imm[0] = uint64(in.typeMap[imm[0]])
+	case opCall, opRefFunc:
+		remap(0, ExtKindFunction)
+	case opCallIndirect:
+		if imm[0] >= uint64(len(in.typeMap)) {
+			return false, fmt.Errorf("type index %d out of range", imm[0])
+		}
+		imm[0] = uint64(in.typeMap[imm[0]])
+		remap(1, ExtKindTable)
+	case opGetGlobal, opGlobalSet:
+		remap(0, ExtKindGlobal)
+	case opTableGet, opTableSet, opTableGrow, opTableSize, opTableFill:
+		remap(0, ExtKindTable)
+	case opTableCopy:
+		remap(0, ExtKindTable)
+		remap(1, ExtKindTable)
+	case opTableInit:
+		if imm[0] >= uint64(len(in.elemMap)) {
+			return false, fmt.Errorf("element segment index %d out of range", imm[0])
+		}
+		imm[0] = uint64(in.elemMap[imm[0]])
+		remap(1, ExtKindTable)
+	case opElemDrop:
+		if imm[0] >= uint64(len(in.elemMap)) {
+			return false, fmt.Errorf("element segment index %d out of range", imm[0])
+		}
+		imm[0] = uint64(in.elemMap[imm[0]])
+	case opMemoryInit, opDataDrop:
+		if imm[0] >= uint64(len(in.dataMap)) {
+			return false, fmt.Errorf("data segment index %d out of range", imm[0])
+		}
+		imm[0] = uint64(in.dataMap[imm[0]])
+	default:
+		return false, nil
+	}
+	return true, err
+}
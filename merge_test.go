@@ -0,0 +1,252 @@
+package wasm
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mergeModules returns three modules to merge: math defines add and a memory
+// and is imported from by main, and both main and the third module import
+// env.log from the host.
+func mergeModules() []*Module {
+	math := &Module{Sections: []Section{
+		&SectionType{Entries: []FuncType{
+			{Form: 0x60, Params: []ValueType{I32, I32}, ReturnTypes: []ValueType{I32}},
+			{Form: 0x60},
+		}},
+		&SectionFunction{Types: []uint32{0, 1}},
+		&SectionMemory{Entries: []MemoryType{{Limits: ResizableLimits{Initial: 1}}}},
+		&SectionExport{Entries: []ExportEntry{
+			{Field: "add", Kind: ExtKindFunction, Index: 0},
+			{Field: "memory", Kind: ExtKindMemory, Index: 0},
+		}},
+		&SectionStart{Index: 1},
+		&SectionCode{Bodies: []FunctionBody{
+			{Code: []byte{
+				0x20, 0x00, // local.get 0
+				0x20, 0x01, // local.get 1
+				0x6a, // i32.add
+				0x0b, // end
+			}},
+			{Code: []byte{0x0b}},
+		}},
+		&SectionData{Entries: []DataSegment{
+			{Offset: []byte{0x41, 0x00, 0x0b}, Data: []byte("hi")},
+		}},
+		&SectionName{
+			SectionName: "name",
+			Module:      "math",
+			Functions:   &NameMap{Names: []Naming{{Index: 0, Name: "add"}, {Index: 1, Name: "init"}}},
+		},
+	}}
+
+	main := &Module{Sections: []Section{
+		&SectionType{Entries: []FuncType{
+			{Form: 0x60, Params: []ValueType{I32}},
+			{Form: 0x60, Params: []ValueType{I32, I32}, ReturnTypes: []ValueType{I32}},
+			{Form: 0x60},
+		}},
+		&SectionImport{Entries: []ImportEntry{
+			{Module: "env", Field: "log", Kind: ExtKindFunction, FunctionType: &FunctionType{Index: 0}},
+			{Module: "math", Field: "add", Kind: ExtKindFunction, FunctionType: &FunctionType{Index: 1}},
+			{Module: "env", Field: "memory", Kind: ExtKindMemory, MemoryType: &MemoryType{Limits: ResizableLimits{Initial: 1}}},
+		}},
+		&SectionFunction{Types: []uint32{2}},
+		&SectionTable{Entries: []TableType{{ElemType: FuncRef, Limits: ResizableLimits{Initial: 1}}}},
+		&SectionExport{Entries: []ExportEntry{{Field: "run", Kind: ExtKindFunction, Index: 2}}},
+		&SectionStart{Index: 2},
+		&SectionElement{Entries: []ElemSegment{
+			{Offset: []byte{0x41, 0x00, 0x0b}, Elems: []uint32{2}},
+		}},
+		&SectionCode{Bodies: []FunctionBody{
+			{Code: []byte{
+				0x41, 0x01, // i32.const 1
+				0x41, 0x02, // i32.const 2
+				0x10, 0x01, // call 1
+				0x10, 0x00, // call 0
+				0x0b, // end
+			}},
+		}},
+		&SectionName{
+			SectionName: "name",
+			Functions: &NameMap{Names: []Naming{
+				{Index: 0, Name: "log"}, {Index: 1, Name: "add"}, {Index: 2, Name: "run"},
+			}},
+		},
+	}}
+
+	other := &Module{Sections: []Section{
+		&SectionType{Entries: []FuncType{
+			{Form: 0x60, Params: []ValueType{I32}},
+			{Form: 0x60},
+		}},
+		&SectionImport{Entries: []ImportEntry{
+			{Module: "env", Field: "log", Kind: ExtKindFunction, FunctionType: &FunctionType{Index: 0}},
+		}},
+		&SectionFunction{Types: []uint32{1}},
+		&SectionStart{Index: 1},
+		&SectionCode{Bodies: []FunctionBody{
+			{Code: []byte{
+				0x41, 0x03, // i32.const 3
+				0x10, 0x00, // call 0
+				0x0b, // end
+			}},
+		}},
+	}}
+
+	return []*Module{math, main, other}
+}
+
+func TestMerge(t *testing.T) {
+	mod, err := Merge(mergeModules()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mod.Validate(); err != nil {
+		t.Fatalf("Merged module is invalid: %v", err)
+	}
+
+	if n := len(mod.TypeSection().Entries); n != 3 {
+		t.Errorf("Expected identical types to be merged into 3, got %d", n)
+	}
+	imports := mod.ImportSection().Entries
+	if len(imports) != 1 || imports[0].Field != "log" {
+		t.Errorf("Expected only env.log to be imported, got %+v", imports)
+	}
+	if types := mod.FunctionSection().Types; !reflect.DeepEqual(types, []uint32{0, 1, 1, 1, 1}) {
+		t.Errorf("Expected function types [0 1 1 1 1], got %v", types)
+	}
+
+	code := mod.CodeSection().Bodies
+	if len(code) != 5 {
+		t.Fatalf("Expected 5 function bodies, got %d", len(code))
+	}
+	if expected := []byte{0x41, 0x01, 0x41, 0x02, 0x10, 0x01, 0x10, 0x00, 0x0b}; !bytes.Equal(code[2].Code, expected) {
+		t.Errorf("Expected run to call add and log as %x, got %x", expected, code[2].Code)
+	}
+	if expected := []byte{0x41, 0x03, 0x10, 0x00, 0x0b}; !bytes.Equal(code[3].Code, expected) {
+		t.Errorf("Expected the third module to call log as %x, got %x", expected, code[3].Code)
+	}
+	if expected := []byte{0x10, 0x02, 0x10, 0x03, 0x10, 0x04, 0x0b}; !bytes.Equal(code[4].Code, expected) {
+		t.Errorf("Expected a start function that calls %x, got %x", expected, code[4].Code)
+	}
+	if s := mod.StartSection(); s == nil || s.Index != 5 {
+		t.Errorf("Expected start function 5, got %+v", s)
+	}
+
+	expected := []ExportEntry{
+		{Field: "add", Kind: ExtKindFunction, Index: 1},
+		{Field: "memory", Kind: ExtKindMemory, Index: 0},
+		{Field: "run", Kind: ExtKindFunction, Index: 3},
+	}
+	if exports := mod.ExportSection().Entries; !reflect.DeepEqual(exports, expected) {
+		t.Errorf("Expected exports %+v, got %+v", expected, exports)
+	}
+	if elems := mod.ElementSection().Entries[0].Elems; !reflect.DeepEqual(elems, []uint32{3}) {
+		t.Errorf("Expected elements [3], got %v", elems)
+	}
+	if n := len(mod.MemorySection().Entries); n != 1 {
+		t.Errorf("Expected 1 memory, got %d", n)
+	}
+
+	names := []Naming{{0, "log"}, {1, "add"}, {2, "init"}, {3, "run"}}
+	if s := mod.NameSection(); s == nil || !reflect.DeepEqual(s.Functions.Names, names) {
+		t.Errorf("Expected function names %v, got %+v", names, s)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseBytes(buf.Bytes()); err != nil {
+		t.Errorf("Merged module doesn't parse: %v", err)
+	}
+}
+
+func TestMergeRemapsIndices(t *testing.T) {
+	mods := mergeModules()
+	// Give the third module a global, a table and an element segment, which
+	// come after the ones of the other modules.
+	other := mods[2]
+	other.insertSection(&SectionTable{Entries: []TableType{{ElemType: FuncRef, Limits: ResizableLimits{Initial: 1}}}})
+	other.insertSection(&SectionGlobal{Globals: []GlobalVariable{
+		{Type: GlobalType{ContentType: I32, Mutable: true}, Init: []byte{0x41, 0x00, 0x0b}},
+	}})
+	other.insertSection(&SectionElement{Entries: []ElemSegment{
+		{Offset: []byte{0x41, 0x00, 0x0b}, Elems: []uint32{1}},
+	}})
+	other.CodeSection().Bodies[0].Code = []byte{
+		0x23, 0x00, // global.get 0
+		0x11, 0x01, 0x00, // call_indirect (type 1) (table 0)
+		0xfc, 0x0d, 0x00, // elem.drop 0
+		0x0b, // end
+	}
+
+	mod, err := Merge(mods...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0x23, 0x00, 0x11, 0x01, 0x01, 0xfc, 0x0d, 0x01, 0x0b}
+	if code := mod.CodeSection().Bodies[3].Code; !bytes.Equal(code, expected) {
+		t.Errorf("Expected code %x, got %x", expected, code)
+	}
+	if seg := mod.ElementSection().Entries[1]; seg.Index != 1 || !reflect.DeepEqual(seg.Elems, []uint32{4}) {
+		t.Errorf("Expected element segment for table 1 with [4], got %+v", seg)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(mods []*Module)
+		err    string
+	}{
+		{
+			name: "duplicate export",
+			modify: func(mods []*Module) {
+				mods[1].ExportSection().Entries[0].Field = "add"
+			},
+			err: `export "add" is defined by modules 0 and 1`,
+		},
+		{
+			name: "type mismatch",
+			modify: func(mods []*Module) {
+				mods[1].ImportSection().Entries[1].FunctionType.Index = 0
+			},
+			err: "module 1: import math.add has type (i32) -> (), but module 0 exports it with type (i32, i32) -> (i32)",
+		},
+		{
+			name: "missing export",
+			modify: func(mods []*Module) {
+				mods[1].ImportSection().Entries[1].Field = "sub"
+			},
+			err: `module 1: import math.sub: module 0 doesn't export "sub"`,
+		},
+		{
+			name: "two memories",
+			modify: func(mods []*Module) {
+				mods[2].insertSection(&SectionMemory{Entries: []MemoryType{{Limits: ResizableLimits{Initial: 1}}}})
+			},
+			err: "the merged module would have 2 memories",
+		},
+		{
+			name: "different import types",
+			modify: func(mods []*Module) {
+				mods[2].TypeSection().Entries[0].Params = []ValueType{I64}
+			},
+			err: "module 2: import env.log has a different type than in an earlier module",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mods := mergeModules()
+			test.modify(mods)
+			_, err := Merge(mods...)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected error containing %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package wasm
 
+import "fmt"
+
 // A Module represents a parsed WASM module.
 type Module struct {
 	// Sections contains the sections in the parsed file, in the order they
@@ -8,3 +10,188 @@ type Module struct {
 	// The items in the slice will be a mix of the SectionXXX types.
 	Sections []Section
 }
+
+// CustomSections returns the custom sections with the given name, in the order
+// they appear in the module. The name section is not included, use NameSection
+// for it.
+func (m *Module) CustomSections(name string) []*SectionCustom {
+	var ss []*SectionCustom
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionCustom); ok && s.SectionName == name {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// AddCustomSection adds a custom section with the given name and payload to
+// the module. Sections named "dylink" or "dylink.0" describe dynamic libraries
+// and are added at the start of the module, where loaders require them. Other
+// sections are added at the end, after the known sections and existing custom
+// sections such as the name section, which is where toolchains put metadata
+// like "producers".
+//
+// The name section can't be added as a custom section; modify the section
+// returned by NameSection instead.
+func (m *Module) AddCustomSection(name string, payload []byte) error {
+	if name == "name" {
+		return fmt.Errorf("can't add name section as custom section")
+	}
+	s := &SectionCustom{SectionName: name, Payload: payload}
+	if name == "dylink" || name == "dylink.0" {
+		m.Sections = append([]Section{s}, m.Sections...)
+		return nil
+	}
+	m.Sections = append(m.Sections, s)
+	return nil
+}
+
+// ReplaceCustomSection sets the payload of the custom section with the given
+// name. The first section with the name keeps its position and any further
+// ones are removed. If the module has no such section, it's added as by
+// AddCustomSection.
+func (m *Module) ReplaceCustomSection(name string, payload []byte) error {
+	if name == "name" {
+		return fmt.Errorf("can't replace name section as custom section")
+	}
+	replaced := false
+	sections := m.Sections[:0]
+	for _, s := range m.Sections {
+		if c, ok := s.(*SectionCustom); ok && c.SectionName == name {
+			if replaced {
+				continue
+			}
+			s = &SectionCustom{SectionName: name, Payload: payload}
+			replaced = true
+		}
+		sections = append(sections, s)
+	}
+	for i := len(sections); i < len(m.Sections); i++ {
+		m.Sections[i] = nil
+	}
+	m.Sections = sections
+	if !replaced {
+		return m.AddCustomSection(name, payload)
+	}
+	return nil
+}
+
+// TypeSection returns the type section of the module, or nil if it has none.
+func (m *Module) TypeSection() *SectionType {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionType); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// ImportSection returns the import section of the module, or nil if it has none.
+func (m *Module) ImportSection() *SectionImport {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionImport); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// FunctionSection returns the function section of the module, or nil if it has none.
+func (m *Module) FunctionSection() *SectionFunction {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionFunction); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// TableSection returns the table section of the module, or nil if it has none.
+func (m *Module) TableSection() *SectionTable {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionTable); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// MemorySection returns the memory section of the module, or nil if it has none.
+func (m *Module) MemorySection() *SectionMemory {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionMemory); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// GlobalSection returns the global section of the module, or nil if it has none.
+func (m *Module) GlobalSection() *SectionGlobal {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionGlobal); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// ExportSection returns the export section of the module, or nil if it has none.
+func (m *Module) ExportSection() *SectionExport {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionExport); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// StartSection returns the start section of the module, or nil if it has none.
+func (m *Module) StartSection() *SectionStart {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionStart); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// ElementSection returns the element section of the module, or nil if it has none.
+func (m *Module) ElementSection() *SectionElement {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionElement); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// CodeSection returns the code section of the module, or nil if it has none.
+func (m *Module) CodeSection() *SectionCode {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionCode); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// DataSection returns the data section of the module, or nil if it has none.
+func (m *Module) DataSection() *SectionData {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionData); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// NameSection returns the name section of the module, or nil if it has none.
+func (m *Module) NameSection() *SectionName {
+	for _, s := range m.Sections {
+		if s, ok := s.(*SectionName); ok {
+			return s
+		}
+	}
+	return nil
+}
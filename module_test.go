@@ -0,0 +1,116 @@
+package wasm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestModuleSections(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := mod.CodeSection(); s == nil || len(s.Bodies) != 1586 {
+		t.Errorf("Expected code section with 1586 bodies, got %v", s)
+	}
+	if s := mod.ImportSection(); s == nil || len(s.Entries) != 14 {
+		t.Errorf("Expected import section with 14 entries")
+	}
+	if s := mod.StartSection(); s != nil {
+		t.Errorf("Expected no start section, got %v", s)
+	}
+	if s := mod.NameSection(); s == nil || s.SectionName != "name" {
+		t.Errorf("Expected name section")
+	}
+	if ss := mod.CustomSections("go.buildid"); len(ss) != 1 {
+		t.Errorf("Expected 1 go.buildid section, got %d", len(ss))
+	}
+	if ss := mod.CustomSections("name"); len(ss) != 0 {
+		t.Errorf("Expected name section not to be returned as custom section")
+	}
+}
+
+func TestSectionCreated(t *testing.T) {
+	// Sections that were not parsed have an id and name, but no offset or
+	// size.
+	for _, tc := range []struct {
+		s    Section
+		id   uint8
+		name string
+	}{
+		{&SectionCustom{}, 0x00, "Custom"},
+		{&SectionCode{}, 0x0a, "Code"},
+		{&SectionName{}, 0x00, "Custom"},
+	} {
+		if tc.s.ID() != tc.id || tc.s.Name() != tc.name {
+			t.Errorf("%T: expected %s (0x%02x), got %s (0x%02x)", tc.s, tc.name, tc.id, tc.s.Name(), tc.s.ID())
+		}
+		if tc.s.Offset() != 0 || tc.s.PayloadOffset() != 0 || tc.s.Size() != 0 {
+			t.Errorf("%T: expected zero offsets and size", tc.s)
+		}
+	}
+}
+
+func TestAddCustomSection(t *testing.T) {
+	mod := instrumentModule()
+	n := len(mod.Sections)
+	if err := mod.AddCustomSection("version", []byte("1.0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := mod.AddCustomSection("dylink.0", []byte{0x01}); err != nil {
+		t.Fatal(err)
+	}
+	if len(mod.Sections) != n+2 {
+		t.Fatalf("Expected %d sections, got %d", n+2, len(mod.Sections))
+	}
+	if s, ok := mod.Sections[0].(*SectionCustom); !ok || s.SectionName != "dylink.0" {
+		t.Errorf("Expected dylink.0 section first, got %T", mod.Sections[0])
+	}
+	if s, ok := mod.Sections[n+1].(*SectionCustom); !ok || s.SectionName != "version" || string(s.Payload) != "1.0" {
+		t.Errorf("Expected version section last, got %T", mod.Sections[n+1])
+	}
+	if err := mod.AddCustomSection("name", nil); err == nil {
+		t.Error("Expected error adding name section")
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss := actual.CustomSections("version"); len(ss) != 1 || string(ss[0].Payload) != "1.0" {
+		t.Errorf("Expected version section after encoding, got %v", ss)
+	}
+}
+
+func TestReplaceCustomSection(t *testing.T) {
+	mod := instrumentModule()
+	mod.Sections = append(mod.Sections[:1], append([]Section{
+		&SectionCustom{SectionName: "license", Payload: []byte("MIT")},
+	}, mod.Sections[1:]...)...)
+	mod.Sections = append(mod.Sections, &SectionCustom{SectionName: "license", Payload: []byte("BSD")})
+	n := len(mod.Sections)
+
+	if err := mod.ReplaceCustomSection("license", []byte("Apache-2.0")); err != nil {
+		t.Fatal(err)
+	}
+	if len(mod.Sections) != n-1 {
+		t.Fatalf("Expected duplicate to be removed, got %d sections", len(mod.Sections))
+	}
+	if ss := mod.CustomSections("license"); len(ss) != 1 || string(ss[0].Payload) != "Apache-2.0" || mod.Sections[1] != ss[0] {
+		t.Errorf("Expected replaced section at its position, got %v", ss)
+	}
+
+	if err := mod.ReplaceCustomSection("signature", []byte{0xff}); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := mod.Sections[len(mod.Sections)-1].(*SectionCustom); !ok || s.SectionName != "signature" {
+		t.Errorf("Expected missing section to be added last")
+	}
+}
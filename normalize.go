@@ -0,0 +1,70 @@
+package wasm
+
+// Op codes rewritten by Normalize.
+const (
+	opNop         Opcode = 0x01
+	opBlock       Opcode = 0x02
+	opLoop        Opcode = 0x03
+	opIf          Opcode = 0x04
+	opBr          Opcode = 0x0c
+	opBrIf        Opcode = 0x0d
+	opBrTable     Opcode = 0x0e
+	opSelect      Opcode = 0x1b
+	opSelectTyped Opcode = 0x1c
+)
+
+// Normalize returns a normalized copy of the instructions of a function body,
+// as returned by Disassemble, for comparing the code of two builds without
+// the noise of trivial differences in the encoding:
+//
+//   - Offsets are replaced by the position of the instruction in the result,
+//     as they differ when immediates are encoded with different sizes, such as
+//     integers with and without redundant LEB128 padding, which otherwise
+//     decode to the same instruction.
+//   - nop instructions are removed.
+//   - select with a single numeric result type is replaced by the untyped
+//     select, which is equivalent.
+//   - The relative label depths of br, br_if and br_table are replaced by the
+//     number of the target block. Blocks are numbered from 1 in the order they
+//     start, and 0 is the function body itself. This way adding or removing a
+//     block only changes the branches to blocks that come after it.
+//
+// The result is meant for diffing and can't be encoded, as the branch
+// immediates no longer hold label depths.
+func Normalize(ins []Instruction) []Instruction {
+	out := make([]Instruction, 0, len(ins))
+	labels := []uint64{0}
+	next := uint64(1)
+	for _, in := range ins {
+		imm := append([]uint64(nil), in.Immediates...)
+		switch in.Op {
+		case opNop:
+			continue
+		case opBlock, opLoop, opIf:
+			labels = append(labels, next)
+			next++
+		case opEnd:
+			if len(labels) > 0 {
+				labels = labels[:len(labels)-1]
+			}
+		case opBr, opBrIf, opBrTable:
+			for i, depth := range imm {
+				if depth < uint64(len(labels)) {
+					imm[i] = labels[uint64(len(labels))-1-depth]
+				}
+			}
+		case opSelectTyped:
+			if len(imm) == 1 {
+				switch ValueType(imm[0] & 0x7f) {
+				case I32, I64, F32, F64:
+					in.Op, imm = opSelect, nil
+				}
+			}
+		}
+		if len(imm) == 0 {
+			imm = nil
+		}
+		out = append(out, Instruction{Offset: len(out), Op: in.Op, Immediates: imm})
+	}
+	return out
+}
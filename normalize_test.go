@@ -0,0 +1,84 @@
+package wasm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	a := []byte{
+		0x02, 0x40, // block
+		0x03, 0x40, // loop
+		0x41, 0x81, 0x80, 0x80, 0x80, 0x00, // i32.const 1 (padded)
+		0x0d, 0x00, // br_if 0
+		0x01,       // nop
+		0x0c, 0x01, // br 1
+		0x0b,       // end
+		0x0b,       // end
+		0x41, 0x00, // i32.const 0
+		0x41, 0x01, // i32.const 1
+		0x41, 0x01, // i32.const 1
+		0x1c, 0x01, 0x7f, // select (result i32)
+		0x0e, 0x01, 0x00, 0x00, // br_table 0 0
+		0x0b, // end
+	}
+	b := []byte{
+		0x02, 0x40, // block
+		0x03, 0x40, // loop
+		0x41, 0x01, // i32.const 1
+		0x0d, 0x00, // br_if 0
+		0x0c, 0x01, // br 1
+		0x0b,       // end
+		0x0b,       // end
+		0x41, 0x00, // i32.const 0
+		0x41, 0x01, // i32.const 1
+		0x41, 0x01, // i32.const 1
+		0x1b,                   // select
+		0x0e, 0x01, 0x00, 0x00, // br_table 0 0
+		0x0b, // end
+	}
+
+	ia, err := Disassemble(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ib, err := Disassemble(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	na, nb := Normalize(ia), Normalize(ib)
+	if !reflect.DeepEqual(na, nb) {
+		t.Errorf("Expected normalized code to match:\n%v\n%v", na, nb)
+	}
+
+	var lines []string
+	for _, in := range na {
+		lines = append(lines, in.String())
+	}
+	expected := []string{
+		"block",
+		"loop",
+		"i32.const 1",
+		"br_if 2",
+		"br 1",
+		"end",
+		"end",
+		"i32.const 0",
+		"i32.const 1",
+		"i32.const 1",
+		"select",
+		"br_table 0 0",
+		"end",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected\n%v\ngot\n%v", expected, lines)
+	}
+	if na[3].Offset != 3 {
+		t.Errorf("Expected offset 3, got %d", na[3].Offset)
+	}
+
+	// The input is not modified.
+	if ia[3].Immediates[0] != 0 {
+		t.Errorf("Normalize modified its input")
+	}
+}
@@ -3,9 +3,11 @@
 package wasm
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"runtime"
+	"sync"
 )
 
 // magicnumber is a magic number which must appear as the very first bytes of a
@@ -34,35 +36,297 @@ const (
 
 type parser struct {
 	r *reader
+
+	// copy makes readBytes copy from in-memory input.
+	copy bool
+
+	// concurrent makes parseModule parse the sections in parallel.
+	concurrent bool
+
+	// strict makes parseModule check the order of the sections and that
+	// there is a function body for every function.
+	strict bool
+	order  sectionOrder
 }
 
 var errDone = fmt.Errorf("done")
 
+// ParseOptions controls the behavior of ParseWithOptions.
+type ParseOptions struct {
+	// Trace is called for every value read from the input and the start and
+	// end of every structure, such as sections and section entries. This
+	// shows exactly how the parser interprets each byte, which helps to
+	// track down why a file is rejected or parsed differently than expected.
+	Trace func(TraceEvent)
+
+	// Copy makes ParseBytesWithOptions copy the function bodies, data
+	// segments and custom section payloads out of the input, so the module
+	// doesn't refer to it. It has no effect when parsing from an io.Reader.
+	Copy bool
+
+	// Concurrent parses the sections in parallel, which reduces the time it
+	// takes to parse large modules. The section headers are read first to
+	// find the section boundaries, and then every section is parsed from its
+	// own reader.
+	//
+	// This requires random access to the input: it's used by ParseBytes and
+	// when the io.Reader is also an io.ReaderAt and an io.Seeker, such as an
+	// *os.File. Otherwise, and when Trace is set, the sections are parsed
+	// one at a time.
+	//
+	// A section whose contents don't match its size is an error whether or
+	// not it's parsed concurrently.
+	Concurrent bool
+
+	// Strict rejects modules that the spec considers malformed but that can
+	// otherwise be parsed: modules in which a section other than a custom
+	// section appears more than once or out of order, and modules in which
+	// the number of functions in the function section differs from the
+	// number of bodies in the code section.
+	Strict bool
+}
+
 // Parse parses the input to a WASM module.
 func Parse(r io.Reader) (*Module, error) {
-	p := &parser{
-		r: newReader(r),
+	return ParseWithOptions(r, ParseOptions{})
+}
+
+// ParseWithOptions parses the input to a WASM module using the given options.
+func ParseWithOptions(r io.Reader, opts ParseOptions) (*Module, error) {
+	return parse(newReader(r), opts)
+}
+
+// ParseBytes parses a WASM module from b.
+//
+// The function bodies, data segments and custom section payloads in the
+// returned module are slices of b instead of copies, which makes parsing
+// large modules considerably faster. b must not be modified while the module
+// is in use. Use ParseBytesWithOptions with Copy set to get a module that
+// doesn't refer to b.
+func ParseBytes(b []byte) (*Module, error) {
+	return ParseBytesWithOptions(b, ParseOptions{})
+}
+
+// ParseBytesWithOptions parses a WASM module from b using the given options.
+func ParseBytesWithOptions(b []byte, opts ParseOptions) (*Module, error) {
+	if b == nil {
+		b = []byte{}
+	}
+	return parse(newBytesReader(b), opts)
+}
+
+func parse(r *reader, opts ParseOptions) (*Module, error) {
+	p := newParser(r, opts)
+	m, err := p.parseModule()
+	if err != nil {
+		return nil, err
+	}
+	if p.r.atMagic() {
+		err := fmt.Errorf("[0x%06x] another module follows, use ParseAllModules to parse all modules", p.r.Index())
+		p.r.fail(err)
+		return nil, err
+	}
+	return m, nil
+}
+
+// A ModuleAt is a module parsed by ParseAllModules.
+type ModuleAt struct {
+	// Offset is the offset of the module in the input.
+	Offset int64
+
+	// Module is the parsed module.
+	Module *Module
+}
+
+// ParseAllModules parses the modules in input that consists of one or more
+// modules concatenated together. Every module ends at the end of the input or
+// where the next module starts, which is detected by its magic number
+// ("\0asm"). The magic number can't be mistaken for a section, so the modules
+// can be told apart without any other information.
+func ParseAllModules(r io.Reader) ([]ModuleAt, error) {
+	p := newParser(newReader(r), ParseOptions{})
+	var modules []ModuleAt
+	for {
+		offset := int64(p.r.Index())
+		m, err := p.parseModule()
+		if err != nil {
+			return nil, fmt.Errorf("module %d at 0x%06x: %v", len(modules), offset, err)
+		}
+		modules = append(modules, ModuleAt{Offset: offset, Module: m})
+		if !p.r.atMagic() {
+			return modules, nil
+		}
 	}
+}
+
+func newParser(r *reader, opts ParseOptions) *parser {
+	r.trace = opts.Trace
+	return &parser{
+		r:          r,
+		copy:       opts.Copy,
+		concurrent: opts.Concurrent && opts.Trace == nil && r.randomAccess(),
+		strict:     opts.Strict,
+	}
+}
 
+// parseModule parses a module up to the end of the input or the start of the
+// next module.
+func (p *parser) parseModule() (*Module, error) {
 	if err := p.parsePreamble(); err != nil {
+		p.r.fail(err)
 		return nil, err
 	}
 
+	p.order = sectionOrder{}
+	if p.concurrent {
+		return p.parseConcurrent()
+	}
+
 	// Parse file sections
 	var m Module
 	for {
 		err := p.parseSection(&m.Sections)
+		if err != nil {
+			if err == errDone {
+				break
+			}
+			p.r.fail(err)
+			return nil, fmt.Errorf("[0x%06x] parse section: %v", p.r.Index(), err)
+		}
+	}
+	if p.strict {
+		if err := checkFunctionBodies(&m); err != nil {
+			p.r.fail(err)
+			return nil, err
+		}
+	}
+	return &m, nil
+}
+
+// sectionOrder tracks the sections of a module to check that every known
+// section appears at most once and in the order of their ids. Custom
+// sections may appear anywhere.
+type sectionOrder struct {
+	last sectionID
+	seen [secData + 1]bool
+}
+
+// check reports an error if a section with the given id may not follow the
+// sections seen so far.
+func (o *sectionOrder) check(id sectionID) error {
+	switch {
+	case id == secCustom || id > secData:
+		return nil
+	case o.seen[id]:
+		return fmt.Errorf("duplicate %s section", id)
+	case id < o.last:
+		return fmt.Errorf("%s section must come before %s section", id, o.last)
+	}
+	o.seen[id] = true
+	o.last = id
+	return nil
+}
+
+// checkFunctionBodies reports an error if the number of functions declared
+// in the function section differs from the number of function bodies.
+func checkFunctionBodies(m *Module) error {
+	var funcs, bodies int
+	var offset int64
+	if s := m.FunctionSection(); s != nil {
+		funcs = len(s.Types)
+		offset = s.Offset()
+	}
+	if s := m.CodeSection(); s != nil {
+		bodies = len(s.Bodies)
+		offset = s.Offset()
+	}
+	if funcs != bodies {
+		return fmt.Errorf("[0x%06x] function section declares %d functions, but code section has %d function bodies", offset, funcs, bodies)
+	}
+	return nil
+}
+
+// parseConcurrent parses the sections following the preamble in parallel. The
+// input must support random access.
+func (p *parser) parseConcurrent() (*Module, error) {
+	var headers []*section
+	var starts []int
+	for !p.r.atMagic() {
+		base, err := p.parseSectionHeader()
 		if err != nil {
 			if err == errDone {
 				break
 			}
 			return nil, fmt.Errorf("[0x%06x] parse section: %v", p.r.Index(), err)
 		}
+		if base.id > secData {
+			return nil, fmt.Errorf("[0x%06x] parse section: section id 0x%02x not valid", p.r.Index(), base.id)
+		}
+		if p.strict {
+			if err := p.order.check(base.id); err != nil {
+				return nil, fmt.Errorf("[0x%06x] parse section: %v", p.r.Index(), err)
+			}
+		}
+		headers = append(headers, base)
+		starts = append(starts, p.r.Index())
+		if err := p.r.Skip(int(base.size)); err != nil {
+			return nil, fmt.Errorf("[0x%06x] parse section: discard section payload, %d bytes: %v", p.r.Index(), base.size, err)
+		}
+	}
+
+	sections := make([]Section, len(headers))
+	errs := make([]error, len(headers))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i := range headers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			sp := &parser{
+				r:    p.r.section(starts[i], int(headers[i].size)),
+				copy: p.copy,
+			}
+			sp.r.sec = headers[i]
+			s, err := sp.parseSectionPayload(headers[i])
+			if err == nil {
+				if n := starts[i] + int(headers[i].size) - sp.r.Index(); n > 0 {
+					err = fmt.Errorf("section size mismatch: %d bytes not consumed", n)
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				errs[i] = fmt.Errorf("[0x%06x] parse section: %v", sp.r.Index(), err)
+				return
+			}
+			sections[i] = s
+		}(i)
+	}
+	wg.Wait()
+
+	var m Module
+	for i, s := range sections {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if s != nil {
+			m.Sections = append(m.Sections, s)
+		}
+	}
+	if p.strict {
+		if err := checkFunctionBodies(&m); err != nil {
+			return nil, err
+		}
 	}
 	return &m, nil
 }
 
 func (p *parser) parsePreamble() error {
+	p.r.begin("preamble")
 	var h, v uint32
 	if err := read(p.r, &h); err != nil {
 		return fmt.Errorf("could not read file header")
@@ -74,34 +338,91 @@ func (p *parser) parsePreamble() error {
 		return fmt.Errorf("could not version")
 	}
 	if v != 1 {
-		return fmt.Errorf("unsupported version %d", v)
+		return &VersionError{Version: uint16(v), Layer: uint16(v >> 16)}
 	}
+	p.r.end()
 	return nil
 }
 
 func (p *parser) parseSection(ss *[]Section) error {
+	if p.r.atMagic() {
+		// The next module starts.
+		return errDone
+	}
+	p.r.begin("section")
+	base, err := p.parseSectionHeader()
+	if err != nil {
+		if err == errDone {
+			p.r.end()
+		}
+		return err
+	}
+	if p.strict {
+		if err := p.order.check(base.id); err != nil {
+			return err
+		}
+	}
+
+	p.r.begin(base.id.String())
+	p.r.sec = base
+	s, err := p.parseSectionPayload(base)
+	p.r.sec = nil
+	if err != nil {
+		return err
+	}
+	// The section parser must end at the end of the section. The
+	// concurrent parser reads every section from its own reader and makes
+	// the same check, so the two agree on malformed input.
+	switch n := int(base.payload) + int(base.size) - p.r.Index(); {
+	case n < 0:
+		return fmt.Errorf("section payload exceeds section size %d", base.size)
+	case n > 0:
+		return fmt.Errorf("section size mismatch: %d bytes not consumed", n)
+	}
+	p.r.end()
+	p.r.end()
+
+	if s != nil {
+		*ss = append(*ss, s)
+	}
+
+	return nil
+}
+
+// parseSectionHeader reads the id and size of a section. errDone is returned
+// if the input ends before the section.
+func (p *parser) parseSectionHeader() (*section, error) {
+	offset := p.r.Index()
 	var i uint8
 	if err := readVarUint7(p.r, &i); err != nil {
 		if err == io.EOF {
-			return errDone
+			return nil, errDone
 		}
-		return fmt.Errorf("read section id: %v", err)
+		return nil, fmt.Errorf("read section id: %v", err)
 	}
 	sid := sectionID(i)
 
-	var s Section
-	var err error
-
 	base := &section{
-		id:   sid,
-		name: sid.String(),
+		id:     sid,
+		offset: int64(offset),
 	}
 
+	start := p.r.Index()
 	if err := readVarUint32(p.r, &base.size); err != nil {
-		return fmt.Errorf("read type section payload length: %v", err)
+		return nil, fmt.Errorf("read type section payload length: %v", err)
 	}
+	base.payload = int64(p.r.Index())
+	base.sizeWidth = p.r.Index() - start
+	return base, nil
+}
+
+// parseSectionPayload parses the payload of the section with the header base.
+// Unknown sections are skipped and nil is returned.
+func (p *parser) parseSectionPayload(base *section) (Section, error) {
+	var s Section
+	var err error
 
-	switch sid {
+	switch base.id {
 	case secCustom:
 		s, err = p.parseCustomSection(base)
 	case secType:
@@ -127,26 +448,20 @@ func (p *parser) parseSection(ss *[]Section) error {
 	case secData:
 		s, err = p.parseDataSection(base)
 	default:
-		if _, err := io.CopyN(ioutil.Discard, p.r, int64(base.size)); err != nil {
-			return fmt.Errorf("discard section payload, %d bytes: %v", base.size, err)
+		if err := p.r.Skip(int(base.size)); err != nil {
+			return nil, fmt.Errorf("discard section payload, %d bytes: %v", base.size, err)
 		}
-		if sid > secData {
-			// This happens if the previous section was not read to the end,
-			// indicating a bug in that section parser.
-			return fmt.Errorf("data corrupted; section id 0x%02x not valid", sid)
+		if base.id > secData {
+			return nil, fmt.Errorf("section id 0x%02x not valid", base.id)
 		}
 		// Skip unknown section
-		return nil
+		traceValue(p.r, "skipped", nil)
+		return nil, nil
 	}
 	if err != nil {
-		return err
-	}
-
-	if s != nil {
-		*ss = append(*ss, s)
+		return nil, err
 	}
-
-	return nil
+	return s, nil
 }
 
 func (p *parser) parseCustomSection(base *section) (Section, error) {
@@ -154,20 +469,27 @@ func (p *parser) parseCustomSection(base *section) (Section, error) {
 	if err := readVarUint32(p.r, &nl); err != nil {
 		return nil, fmt.Errorf("read section name length: %v", err)
 	}
+	if nl > base.size {
+		return nil, fmt.Errorf("section name length %d exceeds section size %d", nl, base.size)
+	}
 
-	b := make([]byte, nl)
-	if err := read(p.r, &b); err != nil {
+	b, err := p.readBytes(int(nl))
+	if err != nil {
 		return nil, fmt.Errorf("read section name: %v", err)
 	}
 	name := string(b)
 
-	base.size -= uint32(nl)                // sizeof name
-	base.size -= uint32(varUint32Size(nl)) // sizeof name_len
+	// The size of the payload excludes the name and its length.
+	n := int64(p.r.Index()) - base.payload
+	if n > int64(base.size) {
+		return nil, fmt.Errorf("section name length %d exceeds section size %d", nl, base.size)
+	}
+	size := base.size - uint32(n)
 
 	if name == "name" {
 		// A name section is a special custom section meant for debugging
 		// purposes. It's defined in the spec so we'll parse it.
-		return p.parseNameSection(base, name, base.size)
+		return p.parseNameSection(base, name, size)
 	}
 
 	s := SectionCustom{
@@ -176,8 +498,8 @@ func (p *parser) parseCustomSection(base *section) (Section, error) {
 	}
 
 	// set raw bytes
-	s.Payload = make([]byte, base.size)
-	if err := read(p.r, s.Payload); err != nil {
+	s.Payload, err = p.readBytes(int(size))
+	if err != nil {
 		return nil, fmt.Errorf("read custom section payload: %v", err)
 	}
 
@@ -190,29 +512,37 @@ func (p *parser) parseTypeSection(base *section) (*SectionType, error) {
 	err := p.loopCount(func() error {
 		var e FuncType
 
-		if err := readVarInt7(p.r, &e.Form); err != nil {
+		var form ValueType
+		if err := readValueType(p.r, &form); err != nil {
 			return fmt.Errorf("read form: %v", err)
 		}
+		e.Form = int8(form)
 
-		p.loopCount(func() error {
-			var param int8
-			if err := readVarInt7(p.r, &param); err != nil {
+		err := p.loopCount(func() error {
+			var param ValueType
+			if err := readValueType(p.r, &param); err != nil {
 				return fmt.Errorf("read function param type: %v", err)
 			}
 			e.Params = append(e.Params, param)
 			return nil
 		})
+		if err != nil {
+			return fmt.Errorf("read function params: %v", err)
+		}
 
-		var rc uint8
-		if err := readVarUint1(p.r, &rc); err != nil {
+		var rc uint32
+		if err := readVarUint32(p.r, &rc); err != nil {
 			return fmt.Errorf("read number of returns from function: %v", err)
 		}
-		e.ReturnTypes = make([]int8, rc)
-		for i := range e.ReturnTypes {
-			if err := readVarInt7(p.r, &e.ReturnTypes[i]); err != nil {
+		e.ReturnTypes = make([]ValueType, 0, prealloc(rc))
+		for i := uint32(0); i < rc; i++ {
+			var t ValueType
+			if err := readValueType(p.r, &t); err != nil {
 				return fmt.Errorf("read function return type: %v", err)
 			}
+			e.ReturnTypes = append(e.ReturnTypes, t)
 		}
+		e.ReturnCount = uint8(rc)
 
 		s.Entries = append(s.Entries, e)
 		return nil
@@ -235,8 +565,8 @@ func (p *parser) parseImportSection(base *section) (*SectionImport, error) {
 			return fmt.Errorf("read module length: %v", err)
 		}
 
-		mn := make([]byte, ml)
-		if err := read(p.r, mn); err != nil {
+		mn, err := p.readBytes(int(ml))
+		if err != nil {
 			return fmt.Errorf("read module name: %v", err)
 		}
 		e.Module = string(mn)
@@ -246,8 +576,8 @@ func (p *parser) parseImportSection(base *section) (*SectionImport, error) {
 			return fmt.Errorf("read field length: %v", err)
 		}
 
-		fn := make([]byte, fl)
-		if err := read(p.r, fn); err != nil {
+		fn, err := p.readBytes(int(fl))
+		if err != nil {
 			return fmt.Errorf("read field name")
 		}
 		e.Field = string(fn)
@@ -266,7 +596,7 @@ func (p *parser) parseImportSection(base *section) (*SectionImport, error) {
 			}
 		case ExtKindTable:
 			e.TableType = &TableType{}
-			if err := readVarInt7(p.r, &e.TableType.ElemType); err != nil {
+			if err := readValueType(p.r, &e.TableType.ElemType); err != nil {
 				return fmt.Errorf("read table element type: %v", err)
 			}
 
@@ -280,7 +610,7 @@ func (p *parser) parseImportSection(base *section) (*SectionImport, error) {
 			}
 		case ExtKindGlobal:
 			e.GlobalType = &GlobalType{}
-			if err := readVarInt7(p.r, &e.GlobalType.ContentType); err != nil {
+			if err := readValueType(p.r, &e.GlobalType.ContentType); err != nil {
 				return fmt.Errorf("read global content type: %v", err)
 			}
 
@@ -324,10 +654,14 @@ func (p *parser) parseTableSection(base *section) (*SectionTable, error) {
 	s := SectionTable{section: base}
 
 	err := p.loopCount(func() error {
-		var e MemoryType
+		var e TableType
+
+		if err := readValueType(p.r, &e.ElemType); err != nil {
+			return fmt.Errorf("read table element type: %v", err)
+		}
 
 		if err := p.parseResizableLimits(&e.Limits); err != nil {
-			return fmt.Errorf("read memory resizable limits: %v", err)
+			return fmt.Errorf("read table resizable limits: %v", err)
 		}
 
 		s.Entries = append(s.Entries, e)
@@ -366,7 +700,7 @@ func (p *parser) parseGlobalSection(base *section) (*SectionGlobal, error) {
 	err := p.loopCount(func() error {
 		var e GlobalVariable
 
-		if err := readVarInt7(p.r, &e.Type.ContentType); err != nil {
+		if err := readValueType(p.r, &e.Type.ContentType); err != nil {
 			return fmt.Errorf("read global content type: %v", err)
 		}
 
@@ -374,7 +708,7 @@ func (p *parser) parseGlobalSection(base *section) (*SectionGlobal, error) {
 			return fmt.Errorf("read global mutability: %v", err)
 		}
 
-		if err := readUntil(p.r, opEnd, &e.Init); err != nil {
+		if err := readInitExpr(p.r, &e.Init); err != nil {
 			return fmt.Errorf("read global init expression: %v", err)
 		}
 
@@ -399,8 +733,8 @@ func (p *parser) parseExportSection(base *section) (*SectionExport, error) {
 			return fmt.Errorf("read field length: %v", err)
 		}
 
-		f := make([]byte, fl)
-		if err := read(p.r, f); err != nil {
+		f, err := p.readBytes(int(fl))
+		if err != nil {
 			return fmt.Errorf("read field")
 		}
 		e.Field = string(f)
@@ -441,23 +775,49 @@ func (p *parser) parseElementSection(base *section) (*SectionElement, error) {
 	err := p.loopCount(func() error {
 		var e ElemSegment
 
-		if err := readVarUint32(p.r, &e.Index); err != nil {
-			return fmt.Errorf("read element index: %v", err)
+		// The table index of the MVP became flags with reference types.
+		// Only active segments of function indices are supported: 0 for
+		// table 0, and 2 for a table index that follows the flags.
+		var flags uint32
+		if err := readVarUint32(p.r, &flags); err != nil {
+			return fmt.Errorf("read element flags: %v", err)
+		}
+		switch flags {
+		case 0:
+		case 2:
+			if err := readVarUint32(p.r, &e.Index); err != nil {
+				return fmt.Errorf("read element table index: %v", err)
+			}
+			e.ExplicitIndex = e.Index == 0
+		default:
+			return fmt.Errorf("unsupported element segment flags %d", flags)
 		}
 
-		if err := readUntil(p.r, opEnd, &e.Offset); err != nil {
+		if err := readInitExpr(p.r, &e.Offset); err != nil {
 			return fmt.Errorf("read offset expression: %v", err)
 		}
 
+		if flags == 2 {
+			var kind uint8
+			if err := read(p.r, &kind); err != nil {
+				return fmt.Errorf("read element kind: %v", err)
+			}
+			if kind != 0 {
+				return fmt.Errorf("unsupported element kind 0x%02x", kind)
+			}
+		}
+
 		var numElem uint32
 		if err := readVarUint32(p.r, &numElem); err != nil {
 			return fmt.Errorf("read number of elements: %v", err)
 		}
-		e.Elems = make([]uint32, int(numElem))
-		for i := range e.Elems {
-			if err := readVarUint32(p.r, &e.Elems[i]); err != nil {
+		e.Elems = make([]uint32, 0, prealloc(numElem))
+		for i := uint32(0); i < numElem; i++ {
+			var fi uint32
+			if err := readVarUint32(p.r, &fi); err != nil {
 				return fmt.Errorf("read element function index %d: %v", i, err)
 			}
+			e.Elems = append(e.Elems, fi)
 		}
 
 		s.Entries = append(s.Entries, e)
@@ -481,15 +841,16 @@ func (p *parser) parseCodeSection(base *section) (*SectionCode, error) {
 			return fmt.Errorf("read body size: %v", err)
 		}
 
+		e.offset, e.size = int64(p.r.Index()), bs
 		end := p.r.Index() + int(bs)
 
-		p.loopCount(func() error {
+		err := p.loopCount(func() error {
 			var l LocalEntry
 
 			if err := readVarUint32(p.r, &l.Count); err != nil {
 				return fmt.Errorf("read local entry count: %v", err)
 			}
-			if err := read(p.r, &l.Type); err != nil {
+			if err := readValueType(p.r, &l.Type); err != nil {
 				return fmt.Errorf("read local entry value type: %v", err)
 			}
 
@@ -497,10 +858,17 @@ func (p *parser) parseCodeSection(base *section) (*SectionCode, error) {
 
 			return nil
 		})
+		if err != nil {
+			return fmt.Errorf("read locals: %v", err)
+		}
 
 		numBytes := end - p.r.Index()
-		e.Code = make([]byte, numBytes)
-		if err := read(p.r, e.Code); err != nil {
+		if numBytes < 0 {
+			return fmt.Errorf("body size %d too small for locals", bs)
+		}
+		e.code = int64(p.r.Index())
+		e.Code, err = p.readBytes(numBytes)
+		if err != nil {
 			return fmt.Errorf("read function bytecode: %v", err)
 		}
 
@@ -524,7 +892,7 @@ func (p *parser) parseDataSection(base *section) (*SectionData, error) {
 			return fmt.Errorf("read data segment index: %v", err)
 		}
 
-		if err := readUntil(p.r, opEnd, &e.Offset); err != nil {
+		if err := readInitExpr(p.r, &e.Offset); err != nil {
 			return fmt.Errorf("read data section offset initializer: %v", err)
 		}
 
@@ -533,8 +901,9 @@ func (p *parser) parseDataSection(base *section) (*SectionData, error) {
 			return fmt.Errorf("read data section size: %v", err)
 		}
 
-		e.Data = make([]byte, size)
-		if err := read(p.r, e.Data); err != nil {
+		var err error
+		e.Data, err = p.readBytes(int(size))
+		if err != nil {
 			return fmt.Errorf("read data section data: %v", err)
 		}
 
@@ -561,71 +930,154 @@ func (p *parser) parseNameSection(base *section, name string, n uint32) (*Sectio
 		SectionName: name,
 	}
 
-	var t uint8
-	if err := read(p.r, &t); err != nil {
-		return nil, fmt.Errorf("read name type: %v", err)
-	}
-
-	var pl uint32
-	if err := readVarUint32(p.r, &pl); err != nil {
-		return nil, fmt.Errorf("read payload length: %v", err)
-	}
-
-	switch t {
-	case nameTypeModule:
-		var l uint32
-		if err := readVarUint32(p.r, &l); err != nil {
-			return nil, fmt.Errorf("read module name length: %v", err)
+	end := int(base.payload) + int(base.size)
+	for p.r.Index() < end {
+		var t uint8
+		if err := read(p.r, &t); err != nil {
+			return nil, fmt.Errorf("read name type: %v", err)
 		}
 
-		name := make([]byte, l)
-		if err := read(p.r, name); err != nil {
-			return nil, fmt.Errorf("read module name: %v", err)
+		var pl uint32
+		if err := readVarUint32(p.r, &pl); err != nil {
+			return nil, fmt.Errorf("read payload length: %v", err)
 		}
-
-		s.Module = string(name)
-	case nameTypeFunction:
-		s.Functions = &NameMap{}
-		if err := p.parseNameMap(s.Functions); err != nil {
-			return nil, fmt.Errorf("read function name map: %v", err)
+		if int(pl) > end-p.r.Index() {
+			return nil, fmt.Errorf("name subsection 0x%02x: payload length %d exceeds section", t, pl)
 		}
-	case nameTypeLocal:
-		s.Locals = &Locals{}
-		p.loopCount(func() error {
-			var l LocalName
-			if err := readVarUint32(p.r, &l.Index); err != nil {
-				return fmt.Errorf("read local func index: %v", err)
+		subEnd := p.r.Index() + int(pl)
+
+		switch t {
+		case nameTypeModule:
+			var l uint32
+			if err := readVarUint32(p.r, &l); err != nil {
+				return nil, fmt.Errorf("read module name length: %v", err)
 			}
-			if err := p.parseNameMap(&l.LocalMap); err != nil {
-				return fmt.Errorf("read local name map: %v", err)
+
+			name, err := p.readBytes(int(l))
+			if err != nil {
+				return nil, fmt.Errorf("read module name: %v", err)
 			}
-			s.Locals.Funcs = append(s.Locals.Funcs, l)
-			return nil
-		})
-	default:
-		return nil, fmt.Errorf("unknown name type 0x%02x", t)
+
+			s.Module = string(name)
+		case nameTypeFunction:
+			s.Functions = &NameMap{}
+			if err := p.parseNameMap(s.Functions); err != nil {
+				return nil, fmt.Errorf("read function name map: %v", err)
+			}
+		case nameTypeLocal:
+			s.Locals = &Locals{}
+			err := p.loopCount(func() error {
+				var l LocalName
+				if err := readVarUint32(p.r, &l.Index); err != nil {
+					return fmt.Errorf("read local func index: %v", err)
+				}
+				if err := p.parseNameMap(&l.LocalMap); err != nil {
+					return fmt.Errorf("read local name map: %v", err)
+				}
+				s.Locals.Funcs = append(s.Locals.Funcs, l)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("read local names: %v", err)
+			}
+		default:
+			b, err := p.readBytes(int(pl))
+			if err != nil {
+				return nil, fmt.Errorf("read name subsection 0x%02x: %v", t, err)
+			}
+			s.Other = append(s.Other, NameSubsection{ID: t, Payload: b})
+		}
+
+		if p.r.Index() != subEnd {
+			return nil, fmt.Errorf("name subsection 0x%02x: payload length %d does not match its content", t, pl)
+		}
 	}
 
 	return &s, nil
 }
 
+// Flags of resizable limits.
+const (
+	limitsHasMax = 0x01
+	limitsShared = 0x02
+)
+
 func (p *parser) parseResizableLimits(l *ResizableLimits) error {
-	var hasMax uint8
-	if err := readVarUint1(p.r, &hasMax); err != nil {
+	p.r.begin("limits")
+	var flags uint8
+	if err := readVarUint7(p.r, &flags); err != nil {
 		return fmt.Errorf("flags: %v", err)
 	}
+	if flags&^(limitsHasMax|limitsShared) != 0 {
+		return fmt.Errorf("flags: unknown flags 0x%02x", flags)
+	}
+	l.Shared = flags&limitsShared != 0
 	if err := readVarUint32(p.r, &l.Initial); err != nil {
 		return fmt.Errorf("initial: %v", err)
 	}
-	if hasMax == 0 {
-		return nil
-	}
-	if err := readVarUint32(p.r, &l.Maximum); err != nil {
-		return fmt.Errorf("maximum: %v", err)
+	l.HasMaximum = flags&limitsHasMax != 0
+	if l.HasMaximum {
+		if err := readVarUint32(p.r, &l.Maximum); err != nil {
+			return fmt.Errorf("maximum: %v", err)
+		}
 	}
+	p.r.end()
 	return nil
 }
 
+// maxPrealloc limits the memory allocated up front for a count or length read
+// from the input, which may be corrupt. Larger slices grow as the input is
+// read, so a corrupt length fails with an unexpected EOF instead of running out
+// of memory.
+const maxPrealloc = 1 << 16
+
+// prealloc returns the capacity to allocate for n elements read from the
+// input.
+func prealloc(n uint32) int {
+	if n > maxPrealloc {
+		return maxPrealloc
+	}
+	return int(n)
+}
+
+// readBytes reads n bytes. If the input is in memory, the returned slice refers
+// to the input unless copying was requested.
+func (p *parser) readBytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+	if p.r.buf != nil {
+		if !p.copy {
+			b, err := p.r.slice(n)
+			if err != nil {
+				return nil, err
+			}
+			traceValue(p.r, "bytes", b)
+			return b, nil
+		}
+		if n > len(p.r.buf)-p.r.i {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	if n <= maxPrealloc || p.r.buf != nil {
+		b := make([]byte, n)
+		if err := read(p.r, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, p.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	traceValue(p.r, "bytes", buf.Bytes())
+	return buf.Bytes(), nil
+}
+
 // loopCount reads a varuint32 count and and calls the f n times. All sections
 // except custom start with this pattern.
 //
@@ -638,16 +1090,18 @@ func (p *parser) loopCount(f func() error) error {
 	}
 
 	for i := uint32(0); i < n; i++ {
+		p.r.begin(fmt.Sprintf("entry %d", i))
 		if err := f(); err != nil {
 			return fmt.Errorf("entry %d: %v", i, err)
 		}
+		p.r.end()
 	}
 
 	return nil
 }
 
 func (p *parser) parseNameMap(v *NameMap) error {
-	p.loopCount(func() error {
+	return p.loopCount(func() error {
 		var n Naming
 
 		if err := readVarUint32(p.r, &n.Index); err != nil {
@@ -659,8 +1113,8 @@ func (p *parser) parseNameMap(v *NameMap) error {
 			return fmt.Errorf("read naming length: %v", err)
 		}
 
-		name := make([]byte, l)
-		if err := read(p.r, name); err != nil {
+		name, err := p.readBytes(int(l))
+		if err != nil {
 			return fmt.Errorf("read name: %v", err)
 		}
 
@@ -669,6 +1123,4 @@ func (p *parser) parseNameMap(v *NameMap) error {
 
 		return nil
 	})
-
-	return nil
 }
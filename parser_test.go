@@ -1,6 +1,7 @@
 package wasm
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"flag"
@@ -12,6 +13,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/akupila/go-wasm/internal/leb128"
 )
 
 var update = flag.Bool("update", false, "Update golden files")
@@ -58,6 +61,412 @@ func TestParser(t *testing.T) {
 	}
 }
 
+func TestParseBytes(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, copy := range []bool{false, true} {
+		t.Run(fmt.Sprintf("copy=%v", copy), func(t *testing.T) {
+			in := append([]byte(nil), b...)
+			actual, err := ParseBytesWithOptions(in, ParseOptions{Copy: copy})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ej, err := json.Marshal(expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			aj, err := json.Marshal(actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ej, aj) {
+				t.Fatal("Module does not match module parsed from io.Reader")
+			}
+
+			var code []byte
+			for _, s := range actual.Sections {
+				if s, ok := s.(*SectionCode); ok {
+					code = s.Bodies[0].Code
+				}
+			}
+			for i := range in {
+				in[i] = 0
+			}
+			shared := bytes.Count(code, []byte{0}) == len(code)
+			if shared == copy {
+				t.Errorf("Expected function body to be shared with the input: %v", !copy)
+			}
+			if cap(code) != len(code) {
+				t.Errorf("Expected capacity of function body to be limited to its length")
+			}
+		})
+	}
+}
+
+func TestParseBytesTruncated(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{0, 4, 9, len(b) / 2} {
+		if _, err := ParseBytes(b[:n]); err == nil {
+			t.Errorf("Expected error parsing the first %d bytes", n)
+		}
+	}
+}
+
+func TestParseTypeSection(t *testing.T) {
+	preamble := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	b := append(preamble, 0x01, 0x07, 0x01, 0x60, 0x01, 0x7f, 0x02, 0x7e, 0x7d)
+	mod, err := ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := mod.TypeSection().Entries[0]
+	if ft.ReturnCount != 2 || len(ft.ReturnTypes) != 2 {
+		t.Errorf("Expected 2 results, got ReturnCount %d and %v", ft.ReturnCount, ft.ReturnTypes)
+	}
+
+	// Params that run past the end of the input are reported.
+	b[12] = 0x05
+	if _, err := ParseBytes(b); err == nil || !strings.Contains(err.Error(), "read function params") {
+		t.Errorf("Expected error reading params, got %v", err)
+	}
+}
+
+func TestParseGlobalInitExpr(t *testing.T) {
+	preamble := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	tests := []struct {
+		name    string
+		section []byte
+		init    []byte
+	}{
+		{
+			name:    "ref.null",
+			section: []byte{0x06, 0x06, 0x01, 0x70, 0x00, 0xd0, 0x70, 0x0b},
+			init:    []byte{0xd0, 0x70, 0x0b},
+		},
+		{
+			name:    "ref.func",
+			section: []byte{0x06, 0x06, 0x01, 0x70, 0x00, 0xd2, 0x00, 0x0b},
+			init:    []byte{0xd2, 0x00, 0x0b},
+		},
+		{
+			name:    "end in immediate",
+			section: []byte{0x06, 0x06, 0x01, 0x7f, 0x00, 0x41, 0x0b, 0x0b},
+			init:    []byte{0x41, 0x0b, 0x0b},
+		},
+		{
+			name: "extended const",
+			section: []byte{
+				0x06, 0x09, 0x01, 0x7f, 0x00,
+				0x23, 0x00, // global.get 0
+				0x41, 0x10, // i32.const 16
+				0x6a, // i32.add
+				0x0b, // end
+			},
+			init: []byte{0x23, 0x00, 0x41, 0x10, 0x6a, 0x0b},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := append(append([]byte{}, preamble...), tt.section...)
+			for _, parse := range []func([]byte) (*Module, error){
+				ParseBytes,
+				func(b []byte) (*Module, error) { return Parse(bytes.NewReader(b)) },
+			} {
+				mod, err := parse(b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				init := mod.GlobalSection().Globals[0].Init
+				if !bytes.Equal(init, tt.init) {
+					t.Errorf("Expected init % x, got % x", tt.init, init)
+				}
+			}
+		})
+	}
+}
+
+func TestSectionOffsets(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, concurrent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent=%v", concurrent), func(t *testing.T) {
+			mod, err := ParseBytesWithOptions(b, ParseOptions{Concurrent: concurrent})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The sections follow the preamble without gaps.
+			offset := int64(8)
+			for i, s := range mod.Sections {
+				if s.Offset() != offset {
+					t.Errorf("Section %d: expected offset 0x%x, got 0x%x", i, offset, s.Offset())
+				}
+				if b[s.Offset()] != s.ID() {
+					t.Errorf("Section %d: expected id 0x%02x at offset, got 0x%02x", i, s.ID(), b[s.Offset()])
+				}
+				size, n, err := leb128.DecodeUint(b[s.Offset()+1:], 32)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if uint32(size) != s.Size() {
+					t.Errorf("Section %d: expected size %d, got %d", i, size, s.Size())
+				}
+				if p := s.Offset() + 1 + int64(n); s.PayloadOffset() != p {
+					t.Errorf("Section %d: expected payload offset 0x%x, got 0x%x", i, p, s.PayloadOffset())
+				}
+				offset = s.PayloadOffset() + int64(s.Size())
+			}
+			if offset != int64(len(b)) {
+				t.Errorf("Expected sections to end at 0x%x, got 0x%x", len(b), offset)
+			}
+
+			custom := mod.Sections[0].(*SectionCustom)
+			end := custom.PayloadOffset() + int64(custom.Size())
+			if !bytes.Equal(b[end-int64(len(custom.Payload)):end], custom.Payload) {
+				t.Error("Custom section payload does not end at the end of the section")
+			}
+		})
+	}
+}
+
+func TestFunctionBodyOffsets(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, concurrent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("concurrent=%v", concurrent), func(t *testing.T) {
+			mod, err := ParseWithOptions(bytes.NewReader(b), ParseOptions{Concurrent: concurrent})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The bodies fill the code section, each preceded by its
+			// size.
+			code := mod.CodeSection()
+			_, n, _ := leb128.DecodeUint(b[code.PayloadOffset():], 32)
+			offset := code.PayloadOffset() + int64(n)
+			for i, body := range code.Bodies {
+				size, n, err := leb128.DecodeUint(b[offset:], 32)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body.Offset() != offset+int64(n) || body.Size() != uint32(size) {
+					t.Fatalf("Body %d: expected offset 0x%x and size %d, got 0x%x and %d", i, offset+int64(n), size, body.Offset(), body.Size())
+				}
+				if !bytes.Equal(b[body.CodeOffset():body.Offset()+int64(body.Size())], body.Code) {
+					t.Fatalf("Body %d: code not found at 0x%x", i, body.CodeOffset())
+				}
+				offset = body.Offset() + int64(body.Size())
+			}
+			if end := code.PayloadOffset() + int64(code.Size()); offset != end {
+				t.Errorf("Expected bodies to end at 0x%x, got 0x%x", end, offset)
+			}
+		})
+	}
+
+	// The offset of the code stays in the input when the code is modified.
+	mod, err := ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := mod.CodeSection().Bodies[0]
+	offset := body.CodeOffset()
+	body.Code = append([]byte{0x01}, body.Code...)
+	if body.CodeOffset() != offset {
+		t.Errorf("Expected code offset 0x%x after modifying the code, got 0x%x", offset, body.CodeOffset())
+	}
+
+	body = FunctionBody{Code: []byte{0x0b}}
+	if body.Offset() != 0 || body.Size() != 0 || body.CodeOffset() != 0 {
+		t.Error("Expected zero offsets and size for body that was not parsed")
+	}
+}
+
+func TestParseConcurrent(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ej, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ParseOptions{Concurrent: true}
+	tests := []struct {
+		name  string
+		parse func() (*Module, error)
+	}{
+		{"bytes", func() (*Module, error) {
+			return ParseBytesWithOptions(b, opts)
+		}},
+		{"file", func() (*Module, error) {
+			f, done := open(t, "helloworld.wasm")
+			defer done()
+			return ParseWithOptions(f, opts)
+		}},
+		{"offset", func() (*Module, error) {
+			// The module doesn't start at the beginning of the ReaderAt.
+			r := bytes.NewReader(append([]byte("prefix"), b...))
+			if _, err := r.Seek(6, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			return ParseWithOptions(r, opts)
+		}},
+		{"reader", func() (*Module, error) {
+			// Not random access, parsed sequentially.
+			return ParseWithOptions(bufio.NewReader(bytes.NewReader(b)), opts)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := tt.parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			aj, err := json.Marshal(actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ej, aj) {
+				t.Fatal("Module does not match module parsed sequentially")
+			}
+		})
+	}
+}
+
+func TestParseConcurrentTruncated(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ParseOptions{Concurrent: true}
+	for _, n := range []int{0, 4, 9, len(b) / 2, len(b) - 1} {
+		if _, err := ParseBytesWithOptions(b[:n], opts); err == nil {
+			t.Errorf("Expected error parsing the first %d bytes", n)
+		}
+		if _, err := ParseWithOptions(bytes.NewReader(b[:n]), opts); err == nil {
+			t.Errorf("Expected error parsing the first %d bytes from io.Reader", n)
+		}
+	}
+
+	// The type section claims to be a single byte, but the entry continues
+	// past it.
+	short := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x01, 0x01, 0x60, 0x00, 0x00,
+	}
+	if _, err := ParseBytesWithOptions(short, opts); err == nil {
+		t.Error("Expected error parsing section that reads past its end")
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseBytesWithOptions(b, ParseOptions{Strict: true}); err != nil {
+		t.Fatalf("Expected helloworld.wasm to parse in strict mode: %v", err)
+	}
+
+	preamble := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	tests := []struct {
+		name     string
+		sections []byte
+		err      string
+	}{
+		{
+			name: "ordered",
+			sections: []byte{
+				0x01, 0x01, 0x00, // type section
+				0x00, 0x02, 0x01, 'a', // custom section
+				0x02, 0x01, 0x00, // import section
+			},
+		},
+		{
+			name: "duplicate",
+			sections: []byte{
+				0x01, 0x01, 0x00, // type section
+				0x01, 0x01, 0x00, // type section
+			},
+			err: "duplicate Type section",
+		},
+		{
+			name: "out of order",
+			sections: []byte{
+				0x03, 0x01, 0x00, // function section
+				0x01, 0x01, 0x00, // type section
+			},
+			err: "Type section must come before Function section",
+		},
+		{
+			name: "missing bodies",
+			sections: []byte{
+				0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type section
+				0x03, 0x02, 0x01, 0x00, // function section
+			},
+			err: "function section declares 1 functions, but code section has 0 function bodies",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := append(append([]byte{}, preamble...), tt.sections...)
+			if _, err := ParseBytes(b); err != nil {
+				t.Fatalf("Expected module to parse without Strict: %v", err)
+			}
+			for _, concurrent := range []bool{false, true} {
+				_, err := ParseBytesWithOptions(b, ParseOptions{Strict: true, Concurrent: concurrent})
+				switch {
+				case tt.err == "" && err != nil:
+					t.Errorf("Concurrent %v: unexpected error: %v", concurrent, err)
+				case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+					t.Errorf("Concurrent %v: expected error containing %q, got %v", concurrent, tt.err, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSectionSizeMismatch(t *testing.T) {
+	b := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x06, 0x01, 0x60, 0x00, 0x00, // type section
+		0xff, 0xff, // junk
+	}
+	for _, opts := range []ParseOptions{{}, {Concurrent: true}, {Strict: true}, {Strict: true, Concurrent: true}} {
+		_, err := ParseBytesWithOptions(b, opts)
+		if err == nil || !strings.Contains(err.Error(), "section size mismatch: 2 bytes not consumed") {
+			t.Errorf("%+v: expected size mismatch, got %v", opts, err)
+		}
+	}
+}
+
 var filename = "testdata/helloworld.wasm"
 
 func Example_parseFile() {
@@ -92,6 +501,36 @@ func BenchmarkParser(b *testing.B) {
 	}
 }
 
+func BenchmarkParseBytes(b *testing.B) {
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseBytes(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseConcurrent(b *testing.B) {
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseBytesWithOptions(buf, ParseOptions{Concurrent: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func open(t testing.TB, name string) (io.Reader, func()) {
 	f, err := os.Open(filepath.Join("testdata", name))
 	if err != nil {
@@ -132,3 +571,63 @@ func assertGolden(t *testing.T, b []byte, name string) {
 		t.Errorf("Golden file %s does not match; difference at address 0x%06x", tf, addr)
 	}
 }
+
+func TestParseAllModules(t *testing.T) {
+	hello, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := ioutil.ReadFile(filepath.Join("testdata", "empty.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b []byte
+	b = append(b, hello...)
+	b = append(b, empty...)
+	b = append(b, hello...)
+
+	for _, r := range []io.Reader{bytes.NewReader(b), bufio.NewReader(bytes.NewReader(b))} {
+		modules, err := ParseAllModules(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(modules) != 3 {
+			t.Fatalf("Expected 3 modules, got %d", len(modules))
+		}
+		offsets := []int64{0, int64(len(hello)), int64(len(hello) + len(empty))}
+		sections := []int{12, 0, 12}
+		for i, m := range modules {
+			if m.Offset != offsets[i] {
+				t.Errorf("Module %d: expected offset %d, got %d", i, offsets[i], m.Offset)
+			}
+			if len(m.Module.Sections) != sections[i] {
+				t.Errorf("Module %d: expected %d sections, got %d", i, sections[i], len(m.Module.Sections))
+			}
+		}
+		if s := modules[2].Module.Sections[0]; s.Offset() != offsets[2]+8 {
+			t.Errorf("Expected section offset in the input, got 0x%x", s.Offset())
+		}
+	}
+
+	// A single module is parsed as well.
+	modules, err := ParseAllModules(bytes.NewReader(hello))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 1 {
+		t.Errorf("Expected 1 module, got %d", len(modules))
+	}
+
+	// Parse doesn't accept multiple modules.
+	if _, err := Parse(bytes.NewReader(b)); err == nil || !strings.Contains(err.Error(), "ParseAllModules") {
+		t.Errorf("Expected error parsing multiple modules, got %v", err)
+	}
+	if _, err := ParseBytesWithOptions(b, ParseOptions{Concurrent: true}); err == nil {
+		t.Error("Expected error parsing multiple modules concurrently")
+	}
+
+	// The following module is truncated.
+	if _, err := ParseAllModules(bytes.NewReader(b[:len(hello)+len(empty)+100])); err == nil {
+		t.Error("Expected error parsing truncated module")
+	}
+}
@@ -0,0 +1,66 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A ProducerField is a field of the producers section, which records the
+// tools that produced a module.
+//
+// https://github.com/WebAssembly/tool-conventions/blob/main/ProducersSection.md
+type ProducerField struct {
+	// Name is the name of the field: "language", "processed-by" or "sdk".
+	Name string
+
+	// Values contains the languages or tools, in the order they are listed.
+	Values []ProducerValue
+}
+
+// A ProducerValue is a language or tool with its version.
+type ProducerValue struct {
+	Name    string
+	Version string
+}
+
+// Producers returns the fields of the producers custom section. It returns
+// nil if the module has no producers section.
+func (m *Module) Producers() ([]ProducerField, error) {
+	secs := m.CustomSections("producers")
+	if len(secs) == 0 {
+		return nil, nil
+	}
+
+	r := bytes.NewReader(secs[0].Payload)
+	var n uint32
+	if err := readVarUint32(r, &n); err != nil {
+		return nil, fmt.Errorf("read field count: %v", err)
+	}
+	fields := make([]ProducerField, 0, prealloc(n))
+	for i := uint32(0); i < n; i++ {
+		var f ProducerField
+		var err error
+		if f.Name, err = readName(r); err != nil {
+			return nil, fmt.Errorf("field %d: %v", i, err)
+		}
+		var c uint32
+		if err := readVarUint32(r, &c); err != nil {
+			return nil, fmt.Errorf("field %s: read value count: %v", f.Name, err)
+		}
+		for j := uint32(0); j < c; j++ {
+			var v ProducerValue
+			if v.Name, err = readName(r); err != nil {
+				return nil, fmt.Errorf("field %s: value %d: %v", f.Name, j, err)
+			}
+			if v.Version, err = readName(r); err != nil {
+				return nil, fmt.Errorf("field %s: value %d: %v", f.Name, j, err)
+			}
+			f.Values = append(f.Values, v)
+		}
+		fields = append(fields, f)
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d bytes after the last field", r.Len())
+	}
+	return fields, nil
+}
@@ -0,0 +1,52 @@
+package wasm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProducers(t *testing.T) {
+	name := func(s string) []byte {
+		return append([]byte{byte(len(s))}, s...)
+	}
+	var payload []byte
+	payload = append(payload, 2)
+	payload = append(payload, name("language")...)
+	payload = append(payload, 1)
+	payload = append(payload, name("Go")...)
+	payload = append(payload, name("1.21")...)
+	payload = append(payload, name("processed-by")...)
+	payload = append(payload, 2)
+	payload = append(payload, name("TinyGo")...)
+	payload = append(payload, name("0.30.0")...)
+	payload = append(payload, name("wasm-opt")...)
+	payload = append(payload, name("")...)
+
+	mod := &Module{Sections: []Section{
+		&SectionCustom{SectionName: "producers", Payload: payload},
+	}}
+	fields, err := mod.Producers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ProducerField{
+		{Name: "language", Values: []ProducerValue{{"Go", "1.21"}}},
+		{Name: "processed-by", Values: []ProducerValue{{"TinyGo", "0.30.0"}, {"wasm-opt", ""}}},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected\n%+v\ngot\n%+v", expected, fields)
+	}
+
+	mod.Sections[0].(*SectionCustom).Payload = payload[:len(payload)-2]
+	if _, err := mod.Producers(); err == nil {
+		t.Error("Expected error for truncated producers section")
+	}
+	mod.Sections[0].(*SectionCustom).Payload = append(payload, 0)
+	if _, err := mod.Producers(); err == nil {
+		t.Error("Expected error for trailing bytes")
+	}
+
+	if fields, err := (&Module{}).Producers(); fields != nil || err != nil {
+		t.Errorf("Expected no fields without producers section, got %v, %v", fields, err)
+	}
+}
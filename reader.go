@@ -1,17 +1,103 @@
 package wasm
 
 import (
+	"bufio"
 	"io"
+	"io/ioutil"
 )
 
 // reader wraps io.Reader and keeps track of the current position in the input.
+//
+// Input from an io.Reader is buffered, so reading single bytes is cheap.
 type reader struct {
-	rd io.Reader // reader provided by the client
-	i  int       // current index
+	rd     *bufio.Reader // buffered reader provided by the client
+	seeker io.Seeker     // reader provided by the client, if it can seek
+	src    io.Reader     // reader provided by the client
+	buf    []byte        // input provided by the client, instead of rd
+	i      int           // current index
+
+	// If the input supports random access, at is the reader provided by
+	// the client and origin is the offset in it where the input starts.
+	at     io.ReaderAt
+	origin int64
+
+	// If trace is set, the bytes read are collected in pending until they
+	// are reported as a TraceEvent.
+	trace   func(TraceEvent)
+	path    []string // names of the open structures
+	start   int      // index of the first pending byte
+	pending []byte
+
+	// sec is the header of the section being parsed, which records the
+	// varuint32 values read.
+	sec *section
 }
 
 func newReader(r io.Reader) *reader {
-	return &reader{r, 0}
+	rd := &reader{
+		rd:  bufio.NewReaderSize(r, 64*1024),
+		src: r,
+	}
+	rd.seeker, _ = r.(io.Seeker)
+	return rd
+}
+
+// newBytesReader returns a reader that reads from b.
+func newBytesReader(b []byte) *reader {
+	return &reader{buf: b}
+}
+
+// randomAccess reports whether the input supports random access, which is
+// required by section. It must be called before anything is read.
+func (r *reader) randomAccess() bool {
+	if r.buf != nil {
+		return true
+	}
+	at, ok := r.src.(io.ReaderAt)
+	if !ok || r.seeker == nil {
+		return false
+	}
+	origin, err := r.seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false
+	}
+	r.at = at
+	r.origin = origin
+	return true
+}
+
+// section returns a reader for the n bytes of input starting at index start.
+// The returned reader can be used concurrently with r and other sections, and
+// it reports the same indices as r. randomAccess must have returned true.
+func (r *reader) section(start, n int) *reader {
+	if r.buf != nil {
+		end := start + n
+		if end > len(r.buf) {
+			end = len(r.buf)
+		}
+		return &reader{buf: r.buf[:end], i: start}
+	}
+	sr := newReader(io.NewSectionReader(r.at, r.origin+int64(start), int64(n)))
+	sr.i = start
+	return sr
+}
+
+// atMagic reports whether the next bytes of the input are the magic number
+// that starts a module.
+func (r *reader) atMagic() bool {
+	var b []byte
+	if r.buf != nil {
+		if len(r.buf)-r.i < 4 {
+			return false
+		}
+		b = r.buf[r.i : r.i+4]
+	} else {
+		var err error
+		if b, err = r.rd.Peek(4); err != nil {
+			return false
+		}
+	}
+	return b[0] == 0x00 && b[1] == 'a' && b[2] == 's' && b[3] == 'm'
 }
 
 // Index returns the current position in the file.
@@ -22,10 +108,113 @@ func (r *reader) Index() int {
 // Read reads bytes into p and returns the number of bytes read.
 // The number of bytes read are recorded in the reader.
 func (r *reader) Read(p []byte) (int, error) {
-	n, err := r.rd.Read(p)
-	if err != nil {
-		return 0, err
+	var n int
+	if r.buf != nil {
+		if r.i >= len(r.buf) && len(p) > 0 {
+			return 0, io.EOF
+		}
+		n = copy(p, r.buf[r.i:])
+	} else {
+		var err error
+		n, err = r.rd.Read(p)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if r.trace != nil {
+		r.pending = append(r.pending, p[:n]...)
 	}
 	r.i += n
 	return n, nil
 }
+
+// ReadByte reads a single byte.
+func (r *reader) ReadByte() (byte, error) {
+	var b byte
+	if r.buf != nil {
+		if r.i >= len(r.buf) {
+			return 0, io.EOF
+		}
+		b = r.buf[r.i]
+	} else {
+		var err error
+		b, err = r.rd.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if r.trace != nil {
+		r.pending = append(r.pending, b)
+	}
+	r.i++
+	return b, nil
+}
+
+// Skip discards the next n bytes. If the input is an io.Seeker, the bytes that
+// are not yet buffered are skipped by seeking instead of reading them.
+//
+// If the input ends before n bytes have been skipped, io.ErrUnexpectedEOF is
+// returned.
+func (r *reader) Skip(n int) error {
+	switch {
+	case r.trace != nil:
+		// The skipped bytes are reported to the trace.
+		m, err := io.CopyN(ioutil.Discard, r, int64(n))
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if m < int64(n) && err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	case r.buf != nil:
+		if n > len(r.buf)-r.i {
+			r.i = len(r.buf)
+			return io.ErrUnexpectedEOF
+		}
+		r.i += n
+		return nil
+	case r.seeker != nil && n > r.rd.Buffered():
+		// Seeking past the end is allowed, so check the size first.
+		b := r.rd.Buffered()
+		cur, err := r.seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		end, err := r.seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if int64(n-b) > end-cur {
+			n = b + int(end-cur)
+			err = io.ErrUnexpectedEOF
+		}
+		if _, serr := r.seeker.Seek(cur+int64(n-b), io.SeekStart); serr != nil {
+			return serr
+		}
+		r.rd.Reset(r.src)
+		r.i += n
+		return err
+	}
+	m, err := r.rd.Discard(n)
+	r.i += m
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// slice returns the next n bytes of the input without copying them. It may only
+// be called if the reader was created with newBytesReader. The capacity of the
+// returned slice is limited to n, so appending to it doesn't modify the input.
+func (r *reader) slice(n int) ([]byte, error) {
+	if n > len(r.buf)-r.i {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.buf[r.i : r.i+n : r.i+n]
+	if r.trace != nil {
+		r.pending = append(r.pending, b...)
+	}
+	r.i += n
+	return b, nil
+}
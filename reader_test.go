@@ -0,0 +1,93 @@
+package wasm
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	io.ReadSeeker
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestReaderSkip(t *testing.T) {
+	input := make([]byte, 1<<20)
+	for i := range input {
+		input[i] = byte(i)
+	}
+
+	tt := []struct {
+		name   string
+		reader func() *reader
+	}{
+		{"seeker", func() *reader { return newReader(bytes.NewReader(input)) }},
+		{"reader", func() *reader { return newReader(iotest.HalfReader(bytes.NewReader(input))) }},
+		{"bytes", func() *reader { return newBytesReader(input) }},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r := tc.reader()
+			for _, n := range []int{1, 10, 100000, 500000} {
+				if err := r.Skip(n); err != nil {
+					t.Fatal(err)
+				}
+				b, err := r.ReadByte()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if b != input[r.Index()-1] {
+					t.Fatalf("Byte at %d does not match; expected %d, actual %d", r.Index()-1, input[r.Index()-1], b)
+				}
+			}
+
+			if err := r.Skip(len(input)); err != io.ErrUnexpectedEOF {
+				t.Errorf("Expected io.ErrUnexpectedEOF when skipping past the end, got %v", err)
+			}
+			if r.Index() != len(input) {
+				t.Errorf("Expected index to be at the end of the input, got %d", r.Index())
+			}
+			if _, err := r.ReadByte(); err != io.EOF {
+				t.Errorf("Expected io.EOF at the end of the input, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReaderSkipSeeks(t *testing.T) {
+	cr := &countingReader{ReadSeeker: bytes.NewReader(make([]byte, 1<<20))}
+	r := newReader(cr)
+	if _, err := r.ReadByte(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Skip(1<<20 - 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadByte(); err != nil {
+		t.Fatal(err)
+	}
+	if cr.n > 1<<17 {
+		t.Errorf("Expected skipped bytes to be seeked past, read %d bytes", cr.n)
+	}
+}
+
+func TestReaderReadByteAllocs(t *testing.T) {
+	r := newReader(bytes.NewReader(make([]byte, 1<<20)))
+	allocs := testing.AllocsPerRun(1000, func() {
+		if _, err := readByte(r); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
@@ -1,17 +1,111 @@
 package wasm
 
-type section struct {
-	id   sectionID
-	name string
-	size uint32
-}
+import (
+	"fmt"
+	"strconv"
+	"strings"
 
-func (s *section) ID() uint8    { return uint8(s.id) }
-func (s *section) Name() string { return s.name }
-func (s *section) Size() uint32 { return s.size }
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// section is the header of a section read by the parser. It's nil in sections
+// that were created by other means, in which case the offsets and size are 0.
+type section struct {
+	id      sectionID
+	size    uint32
+	offset  int64
+	payload int64
+
+	// sizeWidth is the number of bytes the size is encoded in.
+	sizeWidth int
+
+	// uints is the number of varuint32 values read from the payload, and
+	// padded contains the ones that are encoded in more bytes than needed,
+	// by their position among them. They are used by the encoder to keep
+	// the padding.
+	uints  int
+	padded map[int]paddedUint
+}
+
+// A paddedUint is a varuint32 value encoded in more bytes than needed.
+type paddedUint struct {
+	value uint32
+	width int
+}
+
+// recordUint records a varuint32 value read from the payload, and the number
+// of bytes it was encoded in.
+func (s *section) recordUint(v uint32, width int) {
+	if width > leb128.UintSize(uint64(v)) {
+		if s.padded == nil {
+			s.padded = make(map[int]paddedUint)
+		}
+		s.padded[s.uints] = paddedUint{value: v, width: width}
+	}
+	s.uints++
+}
+
+// header returns the section header, which is nil if the section wasn't
+// parsed.
+func (s *section) header() *section {
+	return s
+}
+
+func (s *section) Size() uint32 {
+	if s == nil {
+		return 0
+	}
+	return s.size
+}
+
+func (s *section) Offset() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.offset
+}
+
+func (s *section) PayloadOffset() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.payload
+}
+
+func (*SectionCustom) ID() uint8   { return uint8(secCustom) }
+func (*SectionType) ID() uint8     { return uint8(secType) }
+func (*SectionImport) ID() uint8   { return uint8(secImport) }
+func (*SectionFunction) ID() uint8 { return uint8(secFunction) }
+func (*SectionTable) ID() uint8    { return uint8(secTable) }
+func (*SectionMemory) ID() uint8   { return uint8(secMemory) }
+func (*SectionGlobal) ID() uint8   { return uint8(secGlobal) }
+func (*SectionExport) ID() uint8   { return uint8(secExport) }
+func (*SectionStart) ID() uint8    { return uint8(secStart) }
+func (*SectionElement) ID() uint8  { return uint8(secElement) }
+func (*SectionCode) ID() uint8     { return uint8(secCode) }
+func (*SectionData) ID() uint8     { return uint8(secData) }
+func (*SectionName) ID() uint8     { return uint8(secCustom) }
+
+func (*SectionCustom) Name() string   { return secCustom.String() }
+func (*SectionType) Name() string     { return secType.String() }
+func (*SectionImport) Name() string   { return secImport.String() }
+func (*SectionFunction) Name() string { return secFunction.String() }
+func (*SectionTable) Name() string    { return secTable.String() }
+func (*SectionMemory) Name() string   { return secMemory.String() }
+func (*SectionGlobal) Name() string   { return secGlobal.String() }
+func (*SectionExport) Name() string   { return secExport.String() }
+func (*SectionStart) Name() string    { return secStart.String() }
+func (*SectionElement) Name() string  { return secElement.String() }
+func (*SectionCode) Name() string     { return secCode.String() }
+func (*SectionData) Name() string     { return secData.String() }
+func (*SectionName) Name() string     { return secCustom.String() }
 
 // A Section contains all the information for a single section in the WASM
 // file. A file is built up of zero or more sections.
+//
+// Every section type in this package implements Section. Use a type switch or
+// the accessors of Module, such as Module.CodeSection, to get to the contents
+// of a section.
 type Section interface {
 	// ID returns the WASM identifier of the section, for example 0x0A for the
 	// code section.
@@ -20,8 +114,19 @@ type Section interface {
 	// Name returns the name of the section.
 	Name() string
 
-	// Size returns the size of the section in bytes.
+	// Size returns the size of the section payload in bytes, as declared in
+	// the section header. For custom sections this includes the section name.
+	// It's 0 for sections that were not parsed.
 	Size() uint32
+
+	// Offset returns the offset of the section in the file, which is the
+	// offset of the section id.
+	Offset() int64
+
+	// PayloadOffset returns the offset of the section payload in the file.
+	// The payload follows the section id and size, and ends at
+	// PayloadOffset() + Size().
+	PayloadOffset() int64
 }
 
 // SectionCustom is a custom or name section added by the compiler that
@@ -51,16 +156,23 @@ type FuncType struct {
 	Form int8
 
 	// Params contains the parameter types of the function.
-	Params []int8
+	Params []ValueType
 
-	// ReturnCount returns the number of results from the function.
-	// The value will be 0 or 1.
+	// ReturnCount is the number of results from the function, truncated to
+	// 8 bits. The encoder writes len(ReturnTypes) instead.
 	//
-	// Future version may allow more: https://github.com/WebAssembly/design/issues/1146
+	// Deprecated: With multi-value, functions may return more than 255
+	// values. Use len(ReturnTypes).
 	ReturnCount uint8
 
-	// ReturnType is the result type if ReturnCount > 0.
-	ReturnTypes []int8
+	// ReturnTypes contains the result types of the function.
+	ReturnTypes []ValueType
+}
+
+// String returns the signature of the function type, for example
+// "(i32, i32) -> (i64)".
+func (t FuncType) String() string {
+	return fmt.Sprintf("(%s) -> (%s)", valueTypeNames(t.Params), valueTypeNames(t.ReturnTypes))
 }
 
 // SectionImport declares all imports defined by the module.
@@ -108,10 +220,12 @@ type MemoryType struct {
 	Limits ResizableLimits
 }
 
-// TableType is the type for a table import.
+// TableType is the type of a table, either imported or defined in the table
+// section.
 type TableType struct {
-	// ElemType specifies the type of the elements.
-	ElemType int8
+	// ElemType specifies the type of the elements, FuncRef or, with
+	// reference types, ExternRef.
+	ElemType ValueType
 
 	// Limits specifies the resizable limits of the table.
 	Limits ResizableLimits
@@ -120,7 +234,7 @@ type TableType struct {
 // GlobalType is the type for a global import.
 type GlobalType struct {
 	// ContentType is the type of the value.
-	ContentType int8
+	ContentType ValueType
 
 	// Mutable is true if the global value can be modified.
 	Mutable bool
@@ -131,8 +245,16 @@ type ResizableLimits struct {
 	// Initial is the initial length of the memory.
 	Initial uint32
 
-	// Maximum is the maximum length of the memory. May not be set.
+	// Maximum is the maximum length of the memory, if HasMaximum is set.
 	Maximum uint32
+
+	// HasMaximum is true if the limits have a maximum. A maximum of 0 is
+	// valid, and different from no maximum.
+	HasMaximum bool
+
+	// Shared is true if the memory is shared between threads. Shared
+	// memories must have a maximum.
+	Shared bool
 }
 
 // SectionFunction declares the signatures of all functions in the modules.
@@ -150,9 +272,11 @@ type SectionFunction struct {
 // references, raw OS handles, or native pointers -- that are accessed by
 // WebAssembly code indirectly through an integer index.
 //
+// Modules may define more than one table with reference types.
+//
 // https://github.com/WebAssembly/design/blob/master/Semantics.md#table
 type SectionTable struct {
-	Entries []MemoryType
+	Entries []TableType
 
 	*section
 }
@@ -234,10 +358,20 @@ type SectionElement struct {
 
 // An ElemSegment is an element segment. It initializes a table with initial
 // values.
+//
+// Only active segments of function indices are supported. Passive and
+// declarative segments, and segments of element expressions, which are
+// encoded with flags 1 and 3 to 7 since reference types, are rejected by the
+// parser.
 type ElemSegment struct {
-	// Index is the table index.
+	// Index is the table index. Segments for tables other than 0 are encoded
+	// with an explicit table index, as introduced with reference types.
 	Index uint32
 
+	// ExplicitIndex is true if the segment is encoded with an explicit table
+	// index even though it's for table 0.
+	ExplicitIndex bool
+
 	// Offset is an init expression (wasm bytecode) to compute the offset at
 	// which to place the elements.
 	Offset []byte
@@ -261,6 +395,32 @@ type FunctionBody struct {
 
 	// Code is the wasm bytecode of the function.
 	Code []byte
+
+	// offset and size are the position of the body in the input and its
+	// size, and code the position of the code after the locals, as read by
+	// the parser.
+	offset int64
+	size   uint32
+	code   int64
+}
+
+// Offset returns the offset of the body in the input, following its size. It's
+// 0 for bodies that were not parsed. With the input at hand, for example as an
+// io.ReaderAt, the body can be read without parsing the module again.
+func (b FunctionBody) Offset() int64 {
+	return b.offset
+}
+
+// Size returns the size of the body in the input, as declared before it,
+// including the locals. It's 0 for bodies that were not parsed.
+func (b FunctionBody) Size() uint32 {
+	return b.size
+}
+
+// CodeOffset returns the offset of Code in the input, which instruction
+// offsets are relative to. It's 0 for bodies that were not parsed.
+func (b FunctionBody) CodeOffset() int64 {
+	return b.code
 }
 
 // LocalEntry is a local variable in a function.
@@ -269,7 +429,13 @@ type LocalEntry struct {
 	Count uint32
 
 	// Type is the type of the variable.
-	Type int8
+	Type ValueType
+}
+
+// String returns the number and type of the local variables, for example
+// "2 x i32".
+func (l LocalEntry) String() string {
+	return fmt.Sprintf("%d x %s", l.Count, l.Type)
 }
 
 // SectionData declares the initialized data that is loaded into the linear
@@ -312,9 +478,23 @@ type SectionName struct {
 	// Locals contains local function name mappings.
 	Locals *Locals
 
+	// Other contains the subsections of other kinds, such as the names of
+	// labels, types or globals, in the order they appear. They are kept as
+	// is, so indices in them are not updated when the module is modified.
+	Other []NameSubsection
+
 	*section
 }
 
+// A NameSubsection is a subsection of the name section that isn't parsed.
+type NameSubsection struct {
+	// ID identifies the kind of names in the subsection.
+	ID uint8
+
+	// Payload is the content of the subsection.
+	Payload []byte
+}
+
 // A NameMap is a map that maps an index to a name.
 type NameMap struct {
 	// Names contains a list of mappings in the NameMap.
@@ -364,3 +544,92 @@ const (
 	// ExtKindGlobal is an imported global.
 	ExtKindGlobal
 )
+
+// A ValueType is the type of a value: a number, a vector or a reference. It's
+// encoded as a single byte.
+type ValueType uint8
+
+// Value types.
+const (
+	I32       ValueType = 0x7f
+	I64       ValueType = 0x7e
+	F32       ValueType = 0x7d
+	F64       ValueType = 0x7c
+	V128      ValueType = 0x7b
+	FuncRef   ValueType = 0x70
+	ExternRef ValueType = 0x6f
+)
+
+// String returns the name of the type as used in the text format, such as
+// "i32" or "funcref". Unknown types are printed as their encoding.
+func (t ValueType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	case V128:
+		return "v128"
+	case FuncRef:
+		return "funcref"
+	case ExternRef:
+		return "externref"
+	case 0x60:
+		// The type constructor of function types, which is read like a
+		// value type.
+		return "func"
+	}
+	return fmt.Sprintf("<0x%02x>", uint8(t))
+}
+
+// MarshalJSON encodes the type as its name, or as a number if it's unknown.
+func (t ValueType) MarshalJSON() ([]byte, error) {
+	if _, ok := valueTypesByName[t.String()]; !ok {
+		return []byte(strconv.Itoa(int(t))), nil
+	}
+	return []byte(strconv.Quote(t.String())), nil
+}
+
+// UnmarshalJSON decodes a type from its name or its encoding as a number.
+func (t *ValueType) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		name, err := strconv.Unquote(string(b))
+		if err != nil {
+			return err
+		}
+		v, ok := valueTypesByName[name]
+		if !ok {
+			return fmt.Errorf("unknown value type %q", name)
+		}
+		*t = v
+		return nil
+	}
+	n, err := strconv.ParseUint(string(b), 10, 8)
+	if err != nil {
+		return fmt.Errorf("invalid value type %s", b)
+	}
+	*t = ValueType(n)
+	return nil
+}
+
+var valueTypesByName = map[string]ValueType{
+	"i32":       I32,
+	"i64":       I64,
+	"f32":       F32,
+	"f64":       F64,
+	"v128":      V128,
+	"funcref":   FuncRef,
+	"externref": ExternRef,
+}
+
+func valueTypeNames(types []ValueType) string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = t.String()
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,309 @@
+// Package sourcemap maps the code of a module back to the source it was
+// compiled from with a source map, as referenced by the sourceMappingURL
+// section that Emscripten and other toolchains write.
+//
+// Source maps for WebAssembly use a single line, with the byte offset of an
+// instruction in the module file as the column.
+//
+// https://sourcemaps.info/spec.html
+package sourcemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	wasm "github.com/akupila/go-wasm"
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// A Location is a position in a source file.
+type Location struct {
+	// Source is the path or URL of the file, including the source root of
+	// the map.
+	Source string
+
+	// Line and Column are 1-based.
+	Line   int
+	Column int
+
+	// Name is the original name of the symbol at the position, if the map
+	// has one.
+	Name string
+}
+
+func (l Location) String() string {
+	return fmt.Sprintf("%s:%d:%d", l.Source, l.Line, l.Column)
+}
+
+// A segment maps a generated offset to a location, or to no location if
+// source is -1.
+type segment struct {
+	offset int64
+	source int
+	line   int
+	column int
+	name   int
+}
+
+// Map is a parsed source map.
+type Map struct {
+	// Sources contains the paths of the source files, including the source
+	// root.
+	Sources []string
+
+	// Names contains the symbol names referenced by the mappings.
+	Names []string
+
+	// segments contains the mappings of the first line, sorted by offset.
+	segments []segment
+}
+
+// Parse parses a version 3 source map.
+func Parse(b []byte) (*Map, error) {
+	var raw struct {
+		Version    int      `json:"version"`
+		SourceRoot string   `json:"sourceRoot"`
+		Sources    []string `json:"sources"`
+		Names      []string `json:"names"`
+		Mappings   string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("decode source map: %v", err)
+	}
+	if raw.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version %d", raw.Version)
+	}
+
+	m := &Map{Names: raw.Names}
+	for _, s := range raw.Sources {
+		if raw.SourceRoot != "" && !strings.Contains(s, "://") && !strings.HasPrefix(s, "/") {
+			s = strings.TrimSuffix(raw.SourceRoot, "/") + "/" + s
+		}
+		m.Sources = append(m.Sources, s)
+	}
+
+	// Only the first line is used for WebAssembly; the fields other than
+	// the column are relative to the previous segment across lines, so the
+	// remaining lines needn't be decoded.
+	line := raw.Mappings
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = line[:i]
+	}
+	var prev segment
+	for i, field := range strings.Split(line, ",") {
+		if field == "" {
+			continue
+		}
+		v, err := decodeVLQ(field)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %d: %v", i, err)
+		}
+		s := segment{offset: prev.offset + int64(v[0]), source: -1}
+		prev.offset = s.offset
+		switch len(v) {
+		case 1:
+		case 4, 5:
+			prev.source += v[1]
+			prev.line += v[2]
+			prev.column += v[3]
+			if prev.source < 0 || prev.source >= len(m.Sources) {
+				return nil, fmt.Errorf("mapping %d: source %d out of range", i, prev.source)
+			}
+			s.source, s.line, s.column, s.name = prev.source, prev.line, prev.column, -1
+			if len(v) == 5 {
+				prev.name += v[4]
+				if prev.name < 0 || prev.name >= len(m.Names) {
+					return nil, fmt.Errorf("mapping %d: name %d out of range", i, prev.name)
+				}
+				s.name = prev.name
+			}
+		default:
+			return nil, fmt.Errorf("mapping %d: %d fields", i, len(v))
+		}
+		m.segments = append(m.segments, s)
+	}
+	sort.SliceStable(m.segments, func(i, j int) bool {
+		return m.segments[i].offset < m.segments[j].offset
+	})
+	return m, nil
+}
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeVLQ decodes the base64 VLQ encoded values of a segment.
+func decodeVLQ(s string) ([]int, error) {
+	var values []int
+	var v, shift uint
+	for i := 0; i < len(s); i++ {
+		d := strings.IndexByte(base64Chars, s[i])
+		if d < 0 {
+			return nil, fmt.Errorf("invalid character %q", s[i])
+		}
+		if shift > 28 {
+			return nil, fmt.Errorf("value too large")
+		}
+		v |= uint(d&0x1f) << shift
+		if d&0x20 != 0 {
+			shift += 5
+			continue
+		}
+		n := int(v >> 1)
+		if v&1 != 0 {
+			n = -n
+		}
+		values = append(values, n)
+		v, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, fmt.Errorf("truncated value")
+	}
+	return values, nil
+}
+
+// Lookup returns the source location of the code at offset in the module
+// file, which is the location of the closest mapping at or before offset.
+// It returns false if there is no such mapping or if it maps to no source.
+func (m *Map) Lookup(offset int64) (Location, bool) {
+	i := sort.Search(len(m.segments), func(i int) bool {
+		return m.segments[i].offset > offset
+	}) - 1
+	if i < 0 || m.segments[i].source < 0 {
+		return Location{}, false
+	}
+	s := m.segments[i]
+	l := Location{Source: m.Sources[s.source], Line: s.line + 1, Column: s.column + 1}
+	if s.name >= 0 {
+		l.Name = m.Names[s.name]
+	}
+	return l, true
+}
+
+// URL returns the URL of the source map of mod from its sourceMappingURL
+// section, or an empty string if it has none.
+func URL(mod *wasm.Module) (string, error) {
+	secs := mod.CustomSections("sourceMappingURL")
+	if len(secs) == 0 {
+		return "", nil
+	}
+	b := secs[0].Payload
+	n, l, err := leb128.DecodeUint(b, 32)
+	if err != nil {
+		return "", fmt.Errorf("sourceMappingURL: read length: %v", err)
+	}
+	if uint64(len(b)-l) != n {
+		return "", fmt.Errorf("sourceMappingURL: length %d doesn't match section size", n)
+	}
+	return string(b[l:]), nil
+}
+
+// IsRemote reports whether url is an http or https URL, which Load downloads.
+// Callers that load the URL a module refers to should only do so when the
+// user has asked for it.
+func IsRemote(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// Load loads the source map at url, which is either an http or https URL or
+// a path. Relative paths are relative to dir, normally the directory of the
+// module.
+func Load(url, dir string) (*Map, error) {
+	var b []byte
+	var err error
+	switch {
+	case IsRemote(url):
+		b, err = fetch(url)
+	default:
+		path := strings.TrimPrefix(url, "file://")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load source map: %v", err)
+	}
+	return Parse(b)
+}
+
+// maxSize is the largest source map that is downloaded.
+const maxSize = 64 << 20
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+func fetch(url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", url, resp.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxSize {
+		return nil, fmt.Errorf("get %s: source map larger than %d bytes", url, maxSize)
+	}
+	return b, nil
+}
+
+// A Resolver resolves instructions of a module to source locations.
+type Resolver struct {
+	m        *Map
+	code     []int64
+	imported int
+}
+
+// NewResolver returns a Resolver for the functions of mod, which must have
+// been parsed from a file so that the offsets of the function bodies are known.
+func NewResolver(m *Map, mod *wasm.Module) (*Resolver, error) {
+	r := &Resolver{m: m}
+	if s := mod.ImportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind == wasm.ExtKindFunction {
+				r.imported++
+			}
+		}
+	}
+	code := mod.CodeSection()
+	if code == nil {
+		return r, nil
+	}
+	for i, body := range code.Bodies {
+		if body.Offset() == 0 {
+			return nil, fmt.Errorf("offset of function %d unknown", r.imported+i)
+		}
+		r.code = append(r.code, body.CodeOffset())
+	}
+	return r, nil
+}
+
+// CodeOffset returns the offset in the module file of the code of function
+// fi, the offset Instruction.Offset is relative to. It returns false if the
+// function is imported or doesn't exist.
+func (r *Resolver) CodeOffset(fi uint32) (int64, bool) {
+	i := int(fi) - r.imported
+	if i < 0 || i >= len(r.code) {
+		return 0, false
+	}
+	return r.code[i], true
+}
+
+// Resolve returns the source location of the instruction at offset in the
+// code of function fi.
+func (r *Resolver) Resolve(fi uint32, offset int) (Location, bool) {
+	base, ok := r.CodeOffset(fi)
+	if !ok {
+		return Location{}, false
+	}
+	return r.m.Lookup(base + int64(offset))
+}
@@ -0,0 +1,223 @@
+package sourcemap
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func TestDecodeVLQ(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []int
+	}{
+		{"A", []int{0}},
+		{"C", []int{1}},
+		{"D", []int{-1}},
+		{"gB", []int{16}},
+		{"AAgBC", []int{0, 0, 16, 1}},
+		{"2H", []int{123}},
+	}
+	for _, tt := range tests {
+		v, err := decodeVLQ(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.out) {
+			t.Errorf("%s: expected %v, got %v", tt.in, tt.out, v)
+		}
+	}
+	for _, in := range []string{"g", "A*", "ggggggggB"} {
+		if _, err := decodeVLQ(in); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	// Offsets 10, 14, 20 and 30: a.c:1:1, a.c:2:5 (name f), unmapped,
+	// b.c:1:3. The second line is ignored.
+	m, err := Parse([]byte(`{
+		"version": 3,
+		"sourceRoot": "src/",
+		"sources": ["a.c", "/abs/b.c"],
+		"names": ["f"],
+		"mappings": "UAAA,IACIA,M,UCDFA;AAAA"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		offset int64
+		loc    string
+		name   string
+		ok     bool
+	}{
+		{9, "", "", false},
+		{10, "src/a.c:1:1", "", true},
+		{13, "src/a.c:1:1", "", true},
+		{14, "src/a.c:2:5", "f", true},
+		{20, "", "", false},
+		{30, "/abs/b.c:1:3", "f", true},
+		{1000, "/abs/b.c:1:3", "f", true},
+	}
+	for _, tt := range tests {
+		loc, ok := m.Lookup(tt.offset)
+		if ok != tt.ok || ok && (loc.String() != tt.loc || loc.Name != tt.name) {
+			t.Errorf("%d: expected %s %q (%v), got %s %q (%v)", tt.offset, tt.loc, tt.name, tt.ok, loc, loc.Name, ok)
+		}
+	}
+
+	for _, s := range []string{
+		`{"version": 2, "mappings": ""}`,
+		`{"version": 3, "sources": ["a.c"], "mappings": "AAAA,ACAA"}`,
+		`{"version": 3, "sources": ["a.c"], "mappings": "AA"}`,
+	} {
+		if _, err := Parse([]byte(s)); err == nil {
+			t.Errorf("Expected error for %s", s)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{{Form: 0x60}}},
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "f", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{}},
+		}},
+		&wasm.SectionFunction{Types: []uint32{0, 0}},
+		&wasm.SectionCode{Bodies: []wasm.FunctionBody{
+			{Code: []byte{0x01, 0x0b}},
+			{Locals: []wasm.LocalEntry{{Count: 200, Type: 0x7f}}, Code: []byte{0x10, 0x00, 0x0b}},
+		}},
+		&wasm.SectionCustom{SectionName: "sourceMappingURL", Payload: []byte("\x08main.map")},
+	}}
+	var buf bytes.Buffer
+	if err := wasm.Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	mod, err := wasm.ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := URL(mod)
+	if err != nil || url != "main.map" {
+		t.Fatalf("Expected URL main.map, got %q, %v", url, err)
+	}
+
+	// Map the call in function 2.
+	call := int64(bytes.Index(b, []byte{0x10, 0x00, 0x0b}))
+	dir, err := ioutil.TempDir("", "sourcemap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mappings := encodeVLQ(int(call), 0, 41, 2)
+	err = ioutil.WriteFile(filepath.Join(dir, "main.map"), []byte(`{"version":3,"sources":["main.c"],"names":[],"mappings":"`+mappings+`"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(url, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewResolver(m, mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off, ok := r.CodeOffset(1); !ok || !bytes.Equal(b[off:off+2], []byte{0x01, 0x0b}) {
+		t.Errorf("Wrong offset %d for function 1", off)
+	}
+	if off, ok := r.CodeOffset(2); !ok || off != call {
+		t.Errorf("Expected offset %d for function 2, got %d", call, off)
+	}
+	if _, ok := r.CodeOffset(0); ok {
+		t.Error("Expected no offset for imported function")
+	}
+	if loc, ok := r.Resolve(2, 1); !ok || loc.String() != "main.c:42:3" {
+		t.Errorf("Expected main.c:42:3, got %s (%v)", loc, ok)
+	}
+	if _, ok := r.Resolve(1, 0); ok {
+		t.Error("Expected no location before the first mapping")
+	}
+}
+
+func TestLoadRemote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/main.map" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"version":3,"sources":["main.c"],"names":[],"mappings":""}`))
+	}))
+	defer srv.Close()
+
+	if !IsRemote(srv.URL) || IsRemote("main.map") || IsRemote("file:///main.map") {
+		t.Error("Expected only http URLs to be remote")
+	}
+	m, err := Load(srv.URL+"/main.map", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Sources, []string{"main.c"}) {
+		t.Errorf("Expected sources [main.c], got %v", m.Sources)
+	}
+	if _, err := Load(srv.URL+"/other.map", ""); err == nil {
+		t.Error("Expected error for missing source map")
+	}
+}
+
+func TestResolverHelloWorld(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("..", "testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mod, err := wasm.ParseBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewResolver(&Map{}, mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, body := range mod.CodeSection().Bodies {
+		fi := uint32(14 + i)
+		off, ok := r.CodeOffset(fi)
+		if !ok || !bytes.Equal(b[off:off+int64(len(body.Code))], body.Code) {
+			t.Fatalf("Wrong code offset %d for function %d", off, fi)
+		}
+	}
+}
+
+// encodeVLQ encodes the values of a segment.
+func encodeVLQ(values ...int) string {
+	var s []byte
+	for _, n := range values {
+		v := uint(n) << 1
+		if n < 0 {
+			v = uint(-n)<<1 | 1
+		}
+		for {
+			d := v & 0x1f
+			v >>= 5
+			if v != 0 {
+				d |= 0x20
+			}
+			s = append(s, base64Chars[d])
+			if v == 0 {
+				break
+			}
+		}
+	}
+	return string(s)
+}
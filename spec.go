@@ -0,0 +1,433 @@
+package wasm
+
+import (
+	"sort"
+	"strings"
+)
+
+// An ImmediateKind describes the immediates that follow the op code of an
+// instruction. Instruction.Immediates holds them as described on
+// Instruction.
+type ImmediateKind uint8
+
+// Immediate kinds.
+const (
+	ImmNone         ImmediateKind = iota // no immediates
+	ImmBlockType                         // block type: empty, value type or type index
+	ImmIndex                             // varuint32 index: function, local, global, label, ...
+	ImmBrTable                           // vector of label indices followed by default label
+	ImmCallIndirect                      // type index, table index
+	ImmMemArg                            // alignment, offset
+	ImmMemory                            // reserved memory index (0x00)
+	ImmI32                               // varint32
+	ImmI64                               // varint64
+	ImmF32                               // 4 byte IEEE 754
+	ImmF64                               // 8 byte IEEE 754
+	ImmSelect                            // vector of value types, one byte each
+	ImmRefType                           // reference type, one byte
+	ImmIndex2                            // two varuint32 indices
+	ImmMemory2                           // two reserved memory indices
+)
+
+var immediateKindNames = [...]string{
+	ImmNone:         "none",
+	ImmBlockType:    "blocktype",
+	ImmIndex:        "index",
+	ImmBrTable:      "br_table",
+	ImmCallIndirect: "call_indirect",
+	ImmMemArg:       "memarg",
+	ImmMemory:       "memory",
+	ImmI32:          "i32",
+	ImmI64:          "i64",
+	ImmF32:          "f32",
+	ImmF64:          "f64",
+	ImmSelect:       "select",
+	ImmRefType:      "reftype",
+	ImmIndex2:       "index2",
+	ImmMemory2:      "memory2",
+}
+
+func (k ImmediateKind) String() string {
+	if int(k) < len(immediateKindNames) {
+		return immediateKindNames[k]
+	}
+	return "<unknown>"
+}
+
+// OpcodeInfo describes an op code.
+type OpcodeInfo struct {
+	Op Opcode
+
+	// Name is the mnemonic of the op code in the current text format.
+	Name string
+
+	// Immediates is the kind of the immediates that follow the op code.
+	Immediates ImmediateKind
+
+	// Pops contains the types of the operands that the instruction pops
+	// from the operand stack and Pushes the types of the results it pushes,
+	// both with the top of the stack last. For example, i32.store pops
+	// [i32 i32] and pushes nothing.
+	Pops   []ValueType
+	Pushes []ValueType
+
+	// Polymorphic is true if the operands and results depend on the
+	// immediates or the context of the instruction, as for call, local.get,
+	// drop, select and the control instructions. Pops and Pushes are nil.
+	Polymorphic bool
+}
+
+// Info returns the description of the op code. It returns false if the op
+// code is not known.
+func (op Opcode) Info() (OpcodeInfo, bool) {
+	info, ok := opcodes[op]
+	if !ok {
+		return OpcodeInfo{}, false
+	}
+	oi := OpcodeInfo{Op: op, Name: info.name, Immediates: info.imm}
+	if info.sig == "" {
+		oi.Polymorphic = true
+		return oi, true
+	}
+	i := strings.Index(info.sig, "->")
+	oi.Pops = sigTypes(info.sig[:i])
+	oi.Pushes = sigTypes(info.sig[i+2:])
+	return oi, true
+}
+
+// Opcodes returns the description of every known op code, ordered by op
+// code. Prefixed op codes come last.
+func Opcodes() []OpcodeInfo {
+	ops := make([]Opcode, 0, len(opcodes))
+	for op := range opcodes {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	infos := make([]OpcodeInfo, len(ops))
+	for i, op := range ops {
+		infos[i], _ = op.Info()
+	}
+	return infos
+}
+
+// sigTypes returns the value types in one side of a stack signature.
+func sigTypes(s string) []ValueType {
+	var types []ValueType
+	for _, name := range strings.Fields(s) {
+		types = append(types, sigValueTypes[name])
+	}
+	return types
+}
+
+var sigValueTypes = map[string]ValueType{
+	"i32":     I32,
+	"i64":     I64,
+	"f32":     F32,
+	"f64":     F64,
+	"funcref": FuncRef,
+}
+
+// opPrefixMisc is the prefix for multi-byte op codes: saturating truncation,
+// bulk memory and table instructions.
+const opPrefixMisc = 0xfc
+
+// opInfo describes an op code.
+type opInfo struct {
+	name string
+	imm  ImmediateKind
+
+	// sig is the stack signature, the operands and the results separated by
+	// an arrow, such as "i32 i32 -> i32". It's empty if the instruction is
+	// polymorphic.
+	sig string
+}
+
+// opcodes describes all known op codes.
+var opcodes = map[Opcode]opInfo{
+	0x00: {"unreachable", ImmNone, ""},
+	0x01: {"nop", ImmNone, "->"},
+	0x02: {"block", ImmBlockType, ""},
+	0x03: {"loop", ImmBlockType, ""},
+	0x04: {"if", ImmBlockType, ""},
+	0x05: {"else", ImmNone, ""},
+	0x0b: {"end", ImmNone, ""},
+	0x0c: {"br", ImmIndex, ""},
+	0x0d: {"br_if", ImmIndex, ""},
+	0x0e: {"br_table", ImmBrTable, ""},
+	0x0f: {"return", ImmNone, ""},
+	0x10: {"call", ImmIndex, ""},
+	0x11: {"call_indirect", ImmCallIndirect, ""},
+
+	0x1a: {"drop", ImmNone, ""},
+	0x1b: {"select", ImmNone, ""},
+	0x1c: {"select", ImmSelect, ""},
+
+	0x20: {"local.get", ImmIndex, ""},
+	0x21: {"local.set", ImmIndex, ""},
+	0x22: {"local.tee", ImmIndex, ""},
+	0x23: {"global.get", ImmIndex, ""},
+	0x24: {"global.set", ImmIndex, ""},
+	0x25: {"table.get", ImmIndex, ""},
+	0x26: {"table.set", ImmIndex, ""},
+
+	0x28: {"i32.load", ImmMemArg, "i32 -> i32"},
+	0x29: {"i64.load", ImmMemArg, "i32 -> i64"},
+	0x2a: {"f32.load", ImmMemArg, "i32 -> f32"},
+	0x2b: {"f64.load", ImmMemArg, "i32 -> f64"},
+	0x2c: {"i32.load8_s", ImmMemArg, "i32 -> i32"},
+	0x2d: {"i32.load8_u", ImmMemArg, "i32 -> i32"},
+	0x2e: {"i32.load16_s", ImmMemArg, "i32 -> i32"},
+	0x2f: {"i32.load16_u", ImmMemArg, "i32 -> i32"},
+	0x30: {"i64.load8_s", ImmMemArg, "i32 -> i64"},
+	0x31: {"i64.load8_u", ImmMemArg, "i32 -> i64"},
+	0x32: {"i64.load16_s", ImmMemArg, "i32 -> i64"},
+	0x33: {"i64.load16_u", ImmMemArg, "i32 -> i64"},
+	0x34: {"i64.load32_s", ImmMemArg, "i32 -> i64"},
+	0x35: {"i64.load32_u", ImmMemArg, "i32 -> i64"},
+	0x36: {"i32.store", ImmMemArg, "i32 i32 ->"},
+	0x37: {"i64.store", ImmMemArg, "i32 i64 ->"},
+	0x38: {"f32.store", ImmMemArg, "i32 f32 ->"},
+	0x39: {"f64.store", ImmMemArg, "i32 f64 ->"},
+	0x3a: {"i32.store8", ImmMemArg, "i32 i32 ->"},
+	0x3b: {"i32.store16", ImmMemArg, "i32 i32 ->"},
+	0x3c: {"i64.store8", ImmMemArg, "i32 i64 ->"},
+	0x3d: {"i64.store16", ImmMemArg, "i32 i64 ->"},
+	0x3e: {"i64.store32", ImmMemArg, "i32 i64 ->"},
+	0x3f: {"memory.size", ImmMemory, "-> i32"},
+	0x40: {"memory.grow", ImmMemory, "i32 -> i32"},
+
+	0x41: {"i32.const", ImmI32, "-> i32"},
+	0x42: {"i64.const", ImmI64, "-> i64"},
+	0x43: {"f32.const", ImmF32, "-> f32"},
+	0x44: {"f64.const", ImmF64, "-> f64"},
+
+	0x45: {"i32.eqz", ImmNone, "i32 -> i32"},
+	0x46: {"i32.eq", ImmNone, "i32 i32 -> i32"},
+	0x47: {"i32.ne", ImmNone, "i32 i32 -> i32"},
+	0x48: {"i32.lt_s", ImmNone, "i32 i32 -> i32"},
+	0x49: {"i32.lt_u", ImmNone, "i32 i32 -> i32"},
+	0x4a: {"i32.gt_s", ImmNone, "i32 i32 -> i32"},
+	0x4b: {"i32.gt_u", ImmNone, "i32 i32 -> i32"},
+	0x4c: {"i32.le_s", ImmNone, "i32 i32 -> i32"},
+	0x4d: {"i32.le_u", ImmNone, "i32 i32 -> i32"},
+	0x4e: {"i32.ge_s", ImmNone, "i32 i32 -> i32"},
+	0x4f: {"i32.ge_u", ImmNone, "i32 i32 -> i32"},
+
+	0x50: {"i64.eqz", ImmNone, "i64 -> i32"},
+	0x51: {"i64.eq", ImmNone, "i64 i64 -> i32"},
+	0x52: {"i64.ne", ImmNone, "i64 i64 -> i32"},
+	0x53: {"i64.lt_s", ImmNone, "i64 i64 -> i32"},
+	0x54: {"i64.lt_u", ImmNone, "i64 i64 -> i32"},
+	0x55: {"i64.gt_s", ImmNone, "i64 i64 -> i32"},
+	0x56: {"i64.gt_u", ImmNone, "i64 i64 -> i32"},
+	0x57: {"i64.le_s", ImmNone, "i64 i64 -> i32"},
+	0x58: {"i64.le_u", ImmNone, "i64 i64 -> i32"},
+	0x59: {"i64.ge_s", ImmNone, "i64 i64 -> i32"},
+	0x5a: {"i64.ge_u", ImmNone, "i64 i64 -> i32"},
+
+	0x5b: {"f32.eq", ImmNone, "f32 f32 -> i32"},
+	0x5c: {"f32.ne", ImmNone, "f32 f32 -> i32"},
+	0x5d: {"f32.lt", ImmNone, "f32 f32 -> i32"},
+	0x5e: {"f32.gt", ImmNone, "f32 f32 -> i32"},
+	0x5f: {"f32.le", ImmNone, "f32 f32 -> i32"},
+	0x60: {"f32.ge", ImmNone, "f32 f32 -> i32"},
+
+	0x61: {"f64.eq", ImmNone, "f64 f64 -> i32"},
+	0x62: {"f64.ne", ImmNone, "f64 f64 -> i32"},
+	0x63: {"f64.lt", ImmNone, "f64 f64 -> i32"},
+	0x64: {"f64.gt", ImmNone, "f64 f64 -> i32"},
+	0x65: {"f64.le", ImmNone, "f64 f64 -> i32"},
+	0x66: {"f64.ge", ImmNone, "f64 f64 -> i32"},
+
+	0x67: {"i32.clz", ImmNone, "i32 -> i32"},
+	0x68: {"i32.ctz", ImmNone, "i32 -> i32"},
+	0x69: {"i32.popcnt", ImmNone, "i32 -> i32"},
+	0x6a: {"i32.add", ImmNone, "i32 i32 -> i32"},
+	0x6b: {"i32.sub", ImmNone, "i32 i32 -> i32"},
+	0x6c: {"i32.mul", ImmNone, "i32 i32 -> i32"},
+	0x6d: {"i32.div_s", ImmNone, "i32 i32 -> i32"},
+	0x6e: {"i32.div_u", ImmNone, "i32 i32 -> i32"},
+	0x6f: {"i32.rem_s", ImmNone, "i32 i32 -> i32"},
+	0x70: {"i32.rem_u", ImmNone, "i32 i32 -> i32"},
+	0x71: {"i32.and", ImmNone, "i32 i32 -> i32"},
+	0x72: {"i32.or", ImmNone, "i32 i32 -> i32"},
+	0x73: {"i32.xor", ImmNone, "i32 i32 -> i32"},
+	0x74: {"i32.shl", ImmNone, "i32 i32 -> i32"},
+	0x75: {"i32.shr_s", ImmNone, "i32 i32 -> i32"},
+	0x76: {"i32.shr_u", ImmNone, "i32 i32 -> i32"},
+	0x77: {"i32.rotl", ImmNone, "i32 i32 -> i32"},
+	0x78: {"i32.rotr", ImmNone, "i32 i32 -> i32"},
+
+	0x79: {"i64.clz", ImmNone, "i64 -> i64"},
+	0x7a: {"i64.ctz", ImmNone, "i64 -> i64"},
+	0x7b: {"i64.popcnt", ImmNone, "i64 -> i64"},
+	0x7c: {"i64.add", ImmNone, "i64 i64 -> i64"},
+	0x7d: {"i64.sub", ImmNone, "i64 i64 -> i64"},
+	0x7e: {"i64.mul", ImmNone, "i64 i64 -> i64"},
+	0x7f: {"i64.div_s", ImmNone, "i64 i64 -> i64"},
+	0x80: {"i64.div_u", ImmNone, "i64 i64 -> i64"},
+	0x81: {"i64.rem_s", ImmNone, "i64 i64 -> i64"},
+	0x82: {"i64.rem_u", ImmNone, "i64 i64 -> i64"},
+	0x83: {"i64.and", ImmNone, "i64 i64 -> i64"},
+	0x84: {"i64.or", ImmNone, "i64 i64 -> i64"},
+	0x85: {"i64.xor", ImmNone, "i64 i64 -> i64"},
+	0x86: {"i64.shl", ImmNone, "i64 i64 -> i64"},
+	0x87: {"i64.shr_s", ImmNone, "i64 i64 -> i64"},
+	0x88: {"i64.shr_u", ImmNone, "i64 i64 -> i64"},
+	0x89: {"i64.rotl", ImmNone, "i64 i64 -> i64"},
+	0x8a: {"i64.rotr", ImmNone, "i64 i64 -> i64"},
+
+	0x8b: {"f32.abs", ImmNone, "f32 -> f32"},
+	0x8c: {"f32.neg", ImmNone, "f32 -> f32"},
+	0x8d: {"f32.ceil", ImmNone, "f32 -> f32"},
+	0x8e: {"f32.floor", ImmNone, "f32 -> f32"},
+	0x8f: {"f32.trunc", ImmNone, "f32 -> f32"},
+	0x90: {"f32.nearest", ImmNone, "f32 -> f32"},
+	0x91: {"f32.sqrt", ImmNone, "f32 -> f32"},
+	0x92: {"f32.add", ImmNone, "f32 f32 -> f32"},
+	0x93: {"f32.sub", ImmNone, "f32 f32 -> f32"},
+	0x94: {"f32.mul", ImmNone, "f32 f32 -> f32"},
+	0x95: {"f32.div", ImmNone, "f32 f32 -> f32"},
+	0x96: {"f32.min", ImmNone, "f32 f32 -> f32"},
+	0x97: {"f32.max", ImmNone, "f32 f32 -> f32"},
+	0x98: {"f32.copysign", ImmNone, "f32 f32 -> f32"},
+
+	0x99: {"f64.abs", ImmNone, "f64 -> f64"},
+	0x9a: {"f64.neg", ImmNone, "f64 -> f64"},
+	0x9b: {"f64.ceil", ImmNone, "f64 -> f64"},
+	0x9c: {"f64.floor", ImmNone, "f64 -> f64"},
+	0x9d: {"f64.trunc", ImmNone, "f64 -> f64"},
+	0x9e: {"f64.nearest", ImmNone, "f64 -> f64"},
+	0x9f: {"f64.sqrt", ImmNone, "f64 -> f64"},
+	0xa0: {"f64.add", ImmNone, "f64 f64 -> f64"},
+	0xa1: {"f64.sub", ImmNone, "f64 f64 -> f64"},
+	0xa2: {"f64.mul", ImmNone, "f64 f64 -> f64"},
+	0xa3: {"f64.div", ImmNone, "f64 f64 -> f64"},
+	0xa4: {"f64.min", ImmNone, "f64 f64 -> f64"},
+	0xa5: {"f64.max", ImmNone, "f64 f64 -> f64"},
+	0xa6: {"f64.copysign", ImmNone, "f64 f64 -> f64"},
+
+	0xa7: {"i32.wrap_i64", ImmNone, "i64 -> i32"},
+	0xa8: {"i32.trunc_f32_s", ImmNone, "f32 -> i32"},
+	0xa9: {"i32.trunc_f32_u", ImmNone, "f32 -> i32"},
+	0xaa: {"i32.trunc_f64_s", ImmNone, "f64 -> i32"},
+	0xab: {"i32.trunc_f64_u", ImmNone, "f64 -> i32"},
+	0xac: {"i64.extend_i32_s", ImmNone, "i32 -> i64"},
+	0xad: {"i64.extend_i32_u", ImmNone, "i32 -> i64"},
+	0xae: {"i64.trunc_f32_s", ImmNone, "f32 -> i64"},
+	0xaf: {"i64.trunc_f32_u", ImmNone, "f32 -> i64"},
+	0xb0: {"i64.trunc_f64_s", ImmNone, "f64 -> i64"},
+	0xb1: {"i64.trunc_f64_u", ImmNone, "f64 -> i64"},
+	0xb2: {"f32.convert_i32_s", ImmNone, "i32 -> f32"},
+	0xb3: {"f32.convert_i32_u", ImmNone, "i32 -> f32"},
+	0xb4: {"f32.convert_i64_s", ImmNone, "i64 -> f32"},
+	0xb5: {"f32.convert_i64_u", ImmNone, "i64 -> f32"},
+	0xb6: {"f32.demote_f64", ImmNone, "f64 -> f32"},
+	0xb7: {"f64.convert_i32_s", ImmNone, "i32 -> f64"},
+	0xb8: {"f64.convert_i32_u", ImmNone, "i32 -> f64"},
+	0xb9: {"f64.convert_i64_s", ImmNone, "i64 -> f64"},
+	0xba: {"f64.convert_i64_u", ImmNone, "i64 -> f64"},
+	0xbb: {"f64.promote_f32", ImmNone, "f32 -> f64"},
+	0xbc: {"i32.reinterpret_f32", ImmNone, "f32 -> i32"},
+	0xbd: {"i64.reinterpret_f64", ImmNone, "f64 -> i64"},
+	0xbe: {"f32.reinterpret_i32", ImmNone, "i32 -> f32"},
+	0xbf: {"f64.reinterpret_i64", ImmNone, "i64 -> f64"},
+
+	0xc0: {"i32.extend8_s", ImmNone, "i32 -> i32"},
+	0xc1: {"i32.extend16_s", ImmNone, "i32 -> i32"},
+	0xc2: {"i64.extend8_s", ImmNone, "i64 -> i64"},
+	0xc3: {"i64.extend16_s", ImmNone, "i64 -> i64"},
+	0xc4: {"i64.extend32_s", ImmNone, "i64 -> i64"},
+
+	0xd0: {"ref.null", ImmRefType, ""},
+	0xd1: {"ref.is_null", ImmNone, ""},
+	0xd2: {"ref.func", ImmIndex, "-> funcref"},
+
+	// Prefixed op codes are stored as 0xfcNN, where NN is the sub op code.
+	0xfc00: {"i32.trunc_sat_f32_s", ImmNone, "f32 -> i32"},
+	0xfc01: {"i32.trunc_sat_f32_u", ImmNone, "f32 -> i32"},
+	0xfc02: {"i32.trunc_sat_f64_s", ImmNone, "f64 -> i32"},
+	0xfc03: {"i32.trunc_sat_f64_u", ImmNone, "f64 -> i32"},
+	0xfc04: {"i64.trunc_sat_f32_s", ImmNone, "f32 -> i64"},
+	0xfc05: {"i64.trunc_sat_f32_u", ImmNone, "f32 -> i64"},
+	0xfc06: {"i64.trunc_sat_f64_s", ImmNone, "f64 -> i64"},
+	0xfc07: {"i64.trunc_sat_f64_u", ImmNone, "f64 -> i64"},
+	0xfc08: {"memory.init", ImmIndex2, "i32 i32 i32 ->"},
+	0xfc09: {"data.drop", ImmIndex, "->"},
+	0xfc0a: {"memory.copy", ImmMemory2, "i32 i32 i32 ->"},
+	0xfc0b: {"memory.fill", ImmMemory, "i32 i32 i32 ->"},
+	0xfc0c: {"table.init", ImmIndex2, "i32 i32 i32 ->"},
+	0xfc0d: {"elem.drop", ImmIndex, "->"},
+	0xfc0e: {"table.copy", ImmIndex2, "i32 i32 i32 ->"},
+	0xfc0f: {"table.grow", ImmIndex, ""},
+	0xfc10: {"table.size", ImmIndex, "-> i32"},
+	0xfc11: {"table.fill", ImmIndex, ""},
+}
+
+// legacyNames contains the mnemonics of the op codes that were renamed in
+// 2018, before the 1.0 release of the spec. They are still used by older tools
+// and tutorials.
+//
+// https://github.com/WebAssembly/spec/issues/884
+var legacyNames = map[Opcode]string{
+	0x20: "get_local",
+	0x21: "set_local",
+	0x22: "tee_local",
+	0x23: "get_global",
+	0x24: "set_global",
+	0x3f: "current_memory",
+	0x40: "grow_memory",
+
+	0xa7: "i32.wrap/i64",
+	0xa8: "i32.trunc_s/f32",
+	0xa9: "i32.trunc_u/f32",
+	0xaa: "i32.trunc_s/f64",
+	0xab: "i32.trunc_u/f64",
+	0xac: "i64.extend_s/i32",
+	0xad: "i64.extend_u/i32",
+	0xae: "i64.trunc_s/f32",
+	0xaf: "i64.trunc_u/f32",
+	0xb0: "i64.trunc_s/f64",
+	0xb1: "i64.trunc_u/f64",
+	0xb2: "f32.convert_s/i32",
+	0xb3: "f32.convert_u/i32",
+	0xb4: "f32.convert_s/i64",
+	0xb5: "f32.convert_u/i64",
+	0xb6: "f32.demote/f64",
+	0xb7: "f64.convert_s/i32",
+	0xb8: "f64.convert_u/i32",
+	0xb9: "f64.convert_s/i64",
+	0xba: "f64.convert_u/i64",
+	0xbb: "f64.promote/f32",
+	0xbc: "i32.reinterpret/f32",
+	0xbd: "i64.reinterpret/f64",
+	0xbe: "f32.reinterpret/i32",
+	0xbf: "f64.reinterpret/i64",
+
+	0xfc00: "i32.trunc_s:sat/f32",
+	0xfc01: "i32.trunc_u:sat/f32",
+	0xfc02: "i32.trunc_s:sat/f64",
+	0xfc03: "i32.trunc_u:sat/f64",
+	0xfc04: "i64.trunc_s:sat/f32",
+	0xfc05: "i64.trunc_u:sat/f32",
+	0xfc06: "i64.trunc_s:sat/f64",
+	0xfc07: "i64.trunc_u:sat/f64",
+}
+
+// opcodesByName maps the current and legacy mnemonics to op codes. Names that
+// are shared by several op codes, such as select, map to the lowest one.
+var opcodesByName = make(map[string]Opcode)
+
+func init() {
+	add := func(name string, op Opcode) {
+		if prev, ok := opcodesByName[name]; !ok || op < prev {
+			opcodesByName[name] = op
+		}
+	}
+	for op, info := range opcodes {
+		add(info.name, op)
+	}
+	for op, name := range legacyNames {
+		add(name, op)
+	}
+}
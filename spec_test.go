@@ -0,0 +1,65 @@
+package wasm
+
+//go:generate go run ./internal/specgen
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var spec = flag.Bool("spec", false, "Fail if the spec test suite is missing")
+
+// TestSpec parses the modules of the WebAssembly spec test suite, written to
+// testdata/spec by go generate. Valid modules must parse, and malformed ones
+// must be rejected in strict mode.
+func TestSpec(t *testing.T) {
+	dir := filepath.Join("testdata", "spec")
+	scripts, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, script := range scripts {
+		if script.IsDir() {
+			found = true
+		}
+	}
+	if !found {
+		// testdata/spec only holds the pinned COMMIT until the suite is
+		// generated.
+		if *spec {
+			t.Fatal("spec test suite not found, run go generate to download it")
+		}
+		t.Skip("spec test suite not found, run go generate to download it and test with -spec to require it")
+	}
+
+	for _, script := range scripts {
+		if !script.IsDir() {
+			continue
+		}
+		t.Run(script.Name(), func(t *testing.T) {
+			files, err := filepath.Glob(filepath.Join(dir, script.Name(), "*.wasm"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range files {
+				b, err := ioutil.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				name := filepath.Base(file)
+				_, err = ParseBytesWithOptions(b, ParseOptions{Strict: true})
+				switch {
+				case strings.HasSuffix(name, ".valid.wasm") && err != nil:
+					t.Errorf("%s: %v", name, err)
+				case strings.HasSuffix(name, ".malformed.wasm") && err == nil:
+					t.Errorf("%s: expected malformed module to be rejected", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package wasm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/akupila/go-wasm/internal/leb128"
+)
+
+// Stats contains statistics of a module, as returned by Module.Stats.
+type Stats struct {
+	// Sections contains the size of every section, in the order they
+	// appear in the module.
+	Sections []SectionStats
+
+	// Size is the sum of the sizes of the sections.
+	Size int
+
+	Types             int
+	Imports           int
+	ImportedFunctions int
+	Functions         int // functions defined in the module
+	Exports           int
+	Globals           int // globals defined in the module
+	DataSegments      int
+
+	// CodeSize is the sum of the sizes of the function bodies, including
+	// their locals.
+	CodeSize int
+
+	// AverageBodySize is the average size of the function bodies.
+	AverageBodySize float64
+
+	// Instructions is the number of instructions in the function bodies.
+	Instructions int
+
+	// Opcodes contains the number of times every op code is used, the most
+	// frequent first.
+	Opcodes []OpcodeCount
+
+	// LargestFunctions contains the largest function bodies, the largest
+	// first.
+	LargestFunctions []FunctionSize
+}
+
+// SectionStats is the size of a section.
+type SectionStats struct {
+	// Name is the name of the section, or the name of a custom section.
+	Name string
+
+	// Size is the size of the payload of the section. It's the size in the
+	// input for parsed sections, and the encoded size otherwise.
+	Size int
+}
+
+// OpcodeCount is the number of times an op code is used.
+type OpcodeCount struct {
+	Op    Opcode
+	Name  string
+	Count int
+}
+
+// FunctionSize is the size of a function body.
+type FunctionSize struct {
+	// Index is the index of the function in the function index space.
+	Index uint32
+
+	// Name is the name of the function from the name section, if any.
+	Name string
+
+	Size int
+}
+
+// numLargestFunctions is the number of functions in Stats.LargestFunctions.
+const numLargestFunctions = 10
+
+// Stats returns statistics of the module: the sizes of its sections, the
+// number of definitions of every kind, how often every op code is used and
+// the largest functions. It returns an error if a function body can't be
+// decoded.
+func (m *Module) Stats() (*Stats, error) {
+	st := &Stats{}
+	var bodies []FunctionBody
+	for _, s := range m.Sections {
+		ss := SectionStats{Name: s.Name(), Size: int(s.Size())}
+		switch s := s.(type) {
+		case *SectionCustom:
+			ss.Name = s.SectionName
+		case *SectionName:
+			ss.Name = s.SectionName
+		case *SectionType:
+			st.Types += len(s.Entries)
+		case *SectionImport:
+			st.Imports += len(s.Entries)
+			for _, e := range s.Entries {
+				if e.Kind == ExtKindFunction {
+					st.ImportedFunctions++
+				}
+			}
+		case *SectionFunction:
+			st.Functions += len(s.Types)
+		case *SectionExport:
+			st.Exports += len(s.Entries)
+		case *SectionGlobal:
+			st.Globals += len(s.Globals)
+		case *SectionCode:
+			bodies = append(bodies, s.Bodies...)
+		case *SectionData:
+			st.DataSegments += len(s.Entries)
+		}
+		if s.Offset() == 0 {
+			es, err := encodeSection(s)
+			if err != nil {
+				return nil, err
+			}
+			ss.Size = len(es.content)
+		}
+		st.Sections = append(st.Sections, ss)
+		st.Size += ss.Size
+	}
+
+	names := make(map[uint32]string)
+	if s := m.NameSection(); s != nil && s.Functions != nil {
+		for _, n := range s.Functions.Names {
+			names[n.Index] = n.Name
+		}
+	}
+
+	counts := make(map[Opcode]int)
+	sizes := make([]FunctionSize, len(bodies))
+	for i, body := range bodies {
+		fi := uint32(st.ImportedFunctions + i)
+		size := len(body.Code) + leb128.UintSize(uint64(len(body.Locals)))
+		for _, l := range body.Locals {
+			size += leb128.UintSize(uint64(l.Count)) + 1
+		}
+		sizes[i] = FunctionSize{Index: fi, Name: names[fi], Size: size}
+		st.CodeSize += size
+
+		r := NewCodeReader(body.Code)
+		for {
+			in, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("function %d: %v", fi, err)
+			}
+			counts[in.Op]++
+			st.Instructions++
+		}
+	}
+	if len(bodies) > 0 {
+		st.AverageBodySize = float64(st.CodeSize) / float64(len(bodies))
+	}
+
+	for op, n := range counts {
+		st.Opcodes = append(st.Opcodes, OpcodeCount{Op: op, Name: op.Name(TextCurrent), Count: n})
+	}
+	sort.Slice(st.Opcodes, func(i, j int) bool {
+		a, b := st.Opcodes[i], st.Opcodes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Op < b.Op
+	})
+
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].Size > sizes[j].Size
+	})
+	if len(sizes) > numLargestFunctions {
+		sizes = sizes[:numLargestFunctions]
+	}
+	st.LargestFunctions = sizes
+	return st, nil
+}
@@ -0,0 +1,66 @@
+package wasm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	st, err := instrumentModule().Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Types != 2 || st.Imports != 1 || st.ImportedFunctions != 1 || st.Functions != 2 || st.Exports != 1 || st.Globals != 1 {
+		t.Errorf("Unexpected counts %+v", st)
+	}
+	// Bodies: call 2, end; call 0, ref.func 1, drop, end. Both have no
+	// locals, which takes a byte.
+	if st.Instructions != 6 || st.CodeSize != 11 || st.AverageBodySize != 5.5 {
+		t.Errorf("Unexpected code stats %+v", st)
+	}
+	expected := []OpcodeCount{
+		{Op: 0x0b, Name: "end", Count: 2},
+		{Op: 0x10, Name: "call", Count: 2},
+		{Op: 0x1a, Name: "drop", Count: 1},
+		{Op: 0xd2, Name: "ref.func", Count: 1},
+	}
+	if !reflect.DeepEqual(st.Opcodes, expected) {
+		t.Errorf("Expected op codes %+v, got %+v", expected, st.Opcodes)
+	}
+	if expected := []FunctionSize{{2, "b", 7}, {1, "a", 4}}; !reflect.DeepEqual(st.LargestFunctions, expected) {
+		t.Errorf("Expected largest functions %+v, got %+v", expected, st.LargestFunctions)
+	}
+	if s := st.Sections[0]; s.Name != "Type" || s.Size != 8 {
+		t.Errorf("Expected encoded size 8 of type section, got %+v", s)
+	}
+	if s := st.Sections[len(st.Sections)-1]; s.Name != "name" {
+		t.Errorf("Expected name section last, got %+v", s)
+	}
+}
+
+func TestStatsHelloWorld(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := mod.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Functions != 1586 || st.ImportedFunctions != 14 || st.DataSegments != 7 {
+		t.Errorf("Unexpected counts %+v", st)
+	}
+	if s := st.Sections[0]; s.Name != "go.buildid" || s.Size != 114 {
+		t.Errorf("Unexpected first section %+v", s)
+	}
+	if len(st.LargestFunctions) != numLargestFunctions {
+		t.Fatalf("Expected %d largest functions, got %d", numLargestFunctions, len(st.LargestFunctions))
+	}
+	for i := 1; i < len(st.LargestFunctions); i++ {
+		if st.LargestFunctions[i].Size > st.LargestFunctions[i-1].Size {
+			t.Errorf("Largest functions not sorted: %+v", st.LargestFunctions)
+		}
+	}
+}
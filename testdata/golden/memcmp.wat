@@ -0,0 +1,43 @@
+  (func $memcmp (;58;) (type 3) (param i32 i32 i32) (result i32)
+    (local i32 i32)
+    get_local 2
+    if (result i32)
+      loop
+        get_local 0
+        i32.load8_s align=1
+        tee_local 3
+        get_local 1
+        i32.load8_s align=1
+        tee_local 4
+        i32.eq
+        if
+          get_local 0
+          i32.const 1
+          i32.add
+          set_local 0
+          get_local 1
+          i32.const 1
+          i32.add
+          set_local 1
+          i32.const 0
+          get_local 2
+          i32.const -1
+          i32.add
+          tee_local 2
+          i32.eqz
+          br_if 3
+          drop
+          br 1
+        end
+      end
+      get_local 3
+      i32.const 255
+      i32.and
+      get_local 4
+      i32.const 255
+      i32.and
+      i32.sub
+    else
+      i32.const 0
+    end
+    return)
@@ -0,0 +1,18 @@
+(module
+  (type (;0;) (func))
+  (type (;1;) (func (param i32)))
+  (import "env" "f" (func $f (;0;) (type 0)))
+  (func $a (;1;) (type 0)
+    call 2)
+  (func $b (;2;) (type 0)
+    (local i32 i32 i64)
+    call 0
+    ref.func 1
+    drop)
+  (table (;0;) 2 funcref)
+  (global (;0;) i32 (i32.const 2))
+  (export "b" (func 2))
+  (start 1)
+  (elem (;0;) (i32.const 0) func 1 2)
+  (data (;0;) (i32.const 1024) "hello\0a\22\00")
+)
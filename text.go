@@ -0,0 +1,375 @@
+package wasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TextOptions controls the output of WriteText and WriteFunctionText.
+type TextOptions struct {
+	// Version selects the mnemonics of the instructions.
+	Version TextVersion
+
+	// NoCode leaves out the locals and code of the functions and the
+	// contents of the data segments, which prints a skeleton of the
+	// module: its types, imports, exports and the signatures of its
+	// functions.
+	NoCode bool
+
+	// Comment, if set, is called for every instruction of function fi and
+	// returns a comment to print after it, such as the source location of
+	// the instruction, or an empty string.
+	Comment func(fi uint32, in Instruction) string
+}
+
+// WriteText writes m to w in the text format (WAT). Functions are named after
+// the name section, and every definition is annotated with its index in a
+// comment, such as (;3;). Instructions refer to functions and other
+// definitions by index. Custom sections are left out.
+func WriteText(w io.Writer, m *Module, opts TextOptions) error {
+	p := newTextPrinter(w, m, opts)
+	p.module()
+	return p.flush()
+}
+
+// WriteFunctionText writes the function with index fi in the function index
+// space to w in the text format, as WriteText does.
+func WriteFunctionText(w io.Writer, m *Module, fi uint32, opts TextOptions) error {
+	p := newTextPrinter(w, m, opts)
+	if int(fi) >= len(p.funcTypes) {
+		return fmt.Errorf("function %d out of range, the module has %d", fi, len(p.funcTypes))
+	}
+	if int(fi) < p.imported {
+		return fmt.Errorf("function %d is imported", fi)
+	}
+	p.function(fi)
+	return p.flush()
+}
+
+// textPrinter writes a module in the text format. The first error is kept in
+// err, after which nothing more is written.
+type textPrinter struct {
+	w    *bufio.Writer
+	m    *Module
+	opts TextOptions
+	err  error
+
+	types     []FuncType
+	funcTypes []uint32 // type index of every function
+	imported  int      // number of imported functions
+	ids       map[uint32]string
+}
+
+func newTextPrinter(w io.Writer, m *Module, opts TextOptions) *textPrinter {
+	p := &textPrinter{
+		w:    bufio.NewWriter(w),
+		m:    m,
+		opts: opts,
+		ids:  make(map[uint32]string),
+	}
+	if s := m.TypeSection(); s != nil {
+		p.types = s.Entries
+	}
+	if s := m.ImportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind == ExtKindFunction {
+				p.funcTypes = append(p.funcTypes, e.FunctionType.Index)
+			}
+		}
+	}
+	p.imported = len(p.funcTypes)
+	if s := m.FunctionSection(); s != nil {
+		p.funcTypes = append(p.funcTypes, s.Types...)
+	}
+
+	// Names that aren't unique can't be used as identifiers.
+	if s := m.NameSection(); s != nil && s.Functions != nil {
+		used := make(map[string]bool)
+		for _, n := range s.Functions.Names {
+			id := textID(n.Name)
+			if id == "" || used[id] {
+				continue
+			}
+			used[id] = true
+			p.ids[n.Index] = id
+		}
+	}
+	return p
+}
+
+func (p *textPrinter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func (p *textPrinter) flush() error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+func (p *textPrinter) module() {
+	m := p.m
+	p.printf("(module\n")
+	for i, t := range p.types {
+		p.printf("  (type (;%d;) (func%s))\n", i, signatureText(t))
+	}
+
+	var tables, memories, globals int
+	if s := m.ImportSection(); s != nil {
+		var funcs int
+		for _, e := range s.Entries {
+			p.printf("  (import %s %s ", quoteText([]byte(e.Module)), quoteText([]byte(e.Field)))
+			switch e.Kind {
+			case ExtKindFunction:
+				p.printf("(func%s (;%d;) (type %d))", p.funcID(uint32(funcs)), funcs, e.FunctionType.Index)
+				funcs++
+			case ExtKindTable:
+				p.printf("(table (;%d;) %s %s)", tables, limitsText(e.TableType.Limits), e.TableType.ElemType.String())
+				tables++
+			case ExtKindMemory:
+				p.printf("(memory (;%d;) %s)", memories, limitsText(e.MemoryType.Limits))
+				memories++
+			case ExtKindGlobal:
+				p.printf("(global (;%d;) %s)", globals, globalTypeText(*e.GlobalType))
+				globals++
+			}
+			p.printf(")\n")
+		}
+	}
+
+	for fi := p.imported; fi < len(p.funcTypes); fi++ {
+		p.function(uint32(fi))
+	}
+	if s := m.TableSection(); s != nil {
+		for _, t := range s.Entries {
+			p.printf("  (table (;%d;) %s %s)\n", tables, limitsText(t.Limits), t.ElemType.String())
+			tables++
+		}
+	}
+	if s := m.MemorySection(); s != nil {
+		for _, mem := range s.Entries {
+			p.printf("  (memory (;%d;) %s)\n", memories, limitsText(mem.Limits))
+			memories++
+		}
+	}
+	if s := m.GlobalSection(); s != nil {
+		for _, g := range s.Globals {
+			p.printf("  (global (;%d;) %s %s)\n", globals, globalTypeText(g.Type), p.initExpr(g.Init))
+			globals++
+		}
+	}
+	if s := m.ExportSection(); s != nil {
+		for _, e := range s.Entries {
+			p.printf("  (export %s (%s %d))\n", quoteText([]byte(e.Field)), kindText(e.Kind), e.Index)
+		}
+	}
+	if s := m.StartSection(); s != nil {
+		p.printf("  (start %d)\n", s.Index)
+	}
+	if s := m.ElementSection(); s != nil {
+		for i, e := range s.Entries {
+			p.printf("  (elem (;%d;) ", i)
+			if e.Index != 0 {
+				p.printf("(table %d) ", e.Index)
+			}
+			p.printf("%s func", p.initExpr(e.Offset))
+			for _, fi := range e.Elems {
+				p.printf(" %d", fi)
+			}
+			p.printf(")\n")
+		}
+	}
+	if s := m.DataSection(); s != nil {
+		for i, d := range s.Entries {
+			p.printf("  (data (;%d;) ", i)
+			if d.Index != 0 {
+				p.printf("(memory %d) ", d.Index)
+			}
+			p.printf("%s", p.initExpr(d.Offset))
+			if p.opts.NoCode {
+				p.printf(" (; %d bytes ;))\n", len(d.Data))
+			} else {
+				p.printf(" %s)\n", quoteText(d.Data))
+			}
+		}
+	}
+	p.printf(")\n")
+}
+
+// function prints the function fi, which is defined in the module.
+func (p *textPrinter) function(fi uint32) {
+	ti := p.funcTypes[fi]
+	p.printf("  (func%s (;%d;) (type %d)", p.funcID(fi), fi, ti)
+	if int(ti) < len(p.types) {
+		p.printf("%s", signatureText(p.types[ti]))
+	}
+
+	code := p.m.CodeSection()
+	bi := int(fi) - p.imported
+	if p.opts.NoCode || code == nil || bi >= len(code.Bodies) {
+		p.printf(")\n")
+		return
+	}
+	body := code.Bodies[bi]
+
+	if len(body.Locals) > 0 {
+		p.printf("\n    (local")
+		for _, l := range body.Locals {
+			for i := uint32(0); i < l.Count; i++ {
+				p.printf(" %s", l.Type.String())
+			}
+		}
+		p.printf(")")
+	}
+
+	// The end of the function is left out, the closing parenthesis takes
+	// its place.
+	depth := 1
+	r := NewCodeReader(body.Code)
+	for {
+		in, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if p.err == nil {
+				p.err = fmt.Errorf("function %d: %v", fi, err)
+			}
+			return
+		}
+		indent := depth
+		switch in.Op {
+		case opBlock, opLoop, opIf:
+			depth++
+		case opElse:
+			indent--
+		case opEnd:
+			depth--
+			indent--
+		}
+		if depth == 0 {
+			break
+		}
+		p.printf("\n%s%s", strings.Repeat("  ", indent+1), in.Format(p.opts.Version))
+		if p.opts.Comment != nil {
+			if c := p.opts.Comment(fi, in); c != "" {
+				p.printf("  ;; %s", c)
+			}
+		}
+	}
+	p.printf(")\n")
+}
+
+// funcID returns the identifier of function fi preceded by a space, or an
+// empty string if the function has no name.
+func (p *textPrinter) funcID(fi uint32) string {
+	if id, ok := p.ids[fi]; ok {
+		return " " + id
+	}
+	return ""
+}
+
+// initExpr returns the instructions of an init expression, each in
+// parentheses.
+func (p *textPrinter) initExpr(expr []byte) string {
+	ins, err := Disassemble(expr)
+	if err != nil {
+		if p.err == nil {
+			p.err = fmt.Errorf("init expression: %v", err)
+		}
+		return ""
+	}
+	var s []string
+	for _, in := range ins {
+		if in.Op != opEnd {
+			s = append(s, "("+in.Format(p.opts.Version)+")")
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+const opElse = 0x05
+
+// signatureText returns the params and results of t, each preceded by a space.
+func signatureText(t FuncType) string {
+	var s string
+	if len(t.Params) > 0 {
+		s += " (param " + strings.Join(valueTypeList(t.Params), " ") + ")"
+	}
+	if len(t.ReturnTypes) > 0 {
+		s += " (result " + strings.Join(valueTypeList(t.ReturnTypes), " ") + ")"
+	}
+	return s
+}
+
+func valueTypeList(types []ValueType) []string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = t.String()
+	}
+	return s
+}
+
+func limitsText(l ResizableLimits) string {
+	s := fmt.Sprint(l.Initial)
+	if l.HasMaximum {
+		s += fmt.Sprintf(" %d", l.Maximum)
+	}
+	if l.Shared {
+		s += " shared"
+	}
+	return s
+}
+
+func globalTypeText(t GlobalType) string {
+	if t.Mutable {
+		return "(mut " + t.ContentType.String() + ")"
+	}
+	return t.ContentType.String()
+}
+
+func kindText(k ExternalKind) string {
+	if k == ExtKindFunction {
+		return "func"
+	}
+	return kindNames[k]
+}
+
+// textID returns name as an identifier, with the characters that aren't
+// allowed in identifiers replaced by underscores.
+func textID(name string) string {
+	if name == "" {
+		return ""
+	}
+	id := []byte("$" + name)
+	for i := 1; i < len(id); i++ {
+		c := id[i]
+		if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			strings.IndexByte("!#$%&'*+-./:<=>?@\\^_`|~", c) >= 0 {
+			continue
+		}
+		id[i] = '_'
+	}
+	return string(id)
+}
+
+// quoteText returns b as a string in the text format. Printable ASCII
+// characters are written as is, other bytes are escaped as hex.
+func quoteText(b []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, c := range b {
+		if c >= 0x20 && c < 0x7f && c != '"' && c != '\\' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "\\%02x", c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
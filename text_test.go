@@ -0,0 +1,66 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	mod := instrumentModule()
+	mod.Sections = append(mod.Sections, &SectionData{Entries: []DataSegment{
+		{Offset: []byte{0x41, 0x80, 0x08, 0x0b}, Data: []byte("hello\n\"\x00")},
+	}})
+	mod.CodeSection().Bodies[1].Locals = []LocalEntry{{Count: 2, Type: 0x7f}, {Count: 1, Type: 0x7e}}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, mod, TextOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, buf.Bytes(), "golden/module.wat")
+
+	buf.Reset()
+	if err := WriteText(&buf, mod, TextOptions{NoCode: true}); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); strings.Contains(s, "local") || strings.Contains(s, "ref.func") || strings.Contains(s, "hello") {
+		t.Errorf("Expected no code or data, got\n%s", s)
+	}
+
+	buf.Reset()
+	comment := func(fi uint32, in Instruction) string {
+		if in.Op != opCall {
+			return ""
+		}
+		return fmt.Sprintf("func %d at %d", fi, in.Offset)
+	}
+	if err := WriteFunctionText(&buf, mod, 2, TextOptions{Comment: comment}); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); !strings.Contains(s, "call 0  ;; func 2 at 0\n") || strings.Count(s, ";;") != 1 {
+		t.Errorf("Expected comment after call, got\n%s", s)
+	}
+}
+
+func TestWriteFunctionText(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFunctionText(&buf, mod, 58, TextOptions{Version: TextLegacy}); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, buf.Bytes(), "golden/memcmp.wat")
+
+	if err := WriteFunctionText(&buf, mod, 3, TextOptions{}); err == nil {
+		t.Error("Expected error for imported function")
+	}
+	if err := WriteFunctionText(&buf, mod, 100000, TextOptions{}); err == nil {
+		t.Error("Expected error for function out of range")
+	}
+}
@@ -0,0 +1,158 @@
+package wasm
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TraceKind is the kind of a TraceEvent.
+type TraceKind int
+
+// Trace event kinds.
+const (
+	// TraceRead reports a value read from the input.
+	TraceRead TraceKind = iota
+
+	// TraceBegin reports the start of a structure, such as a section or an
+	// entry in a section. It's followed by the events of the contents of
+	// the structure and a matching TraceEnd.
+	TraceBegin
+
+	// TraceEnd reports the end of a structure.
+	TraceEnd
+)
+
+// A TraceEvent describes a step taken by the parser. Trace events are reported
+// to ParseOptions.Trace.
+type TraceEvent struct {
+	Kind TraceKind
+
+	// Offset is the position in the input of the first byte read, or the
+	// position where the structure begins or ends.
+	Offset int
+
+	// Name is the name of the structure for TraceBegin and TraceEnd, for
+	// example "section", "Type" or "entry 3". For TraceRead it's the
+	// encoding of the value, for example "varuint32".
+	Name string
+
+	// Path contains the names of the structures enclosing the event,
+	// outermost first.
+	Path []string
+
+	// Bytes contains the bytes read. It's only set for TraceRead.
+	Bytes []byte
+
+	// Value is the value decoded from Bytes. It's only set for TraceRead.
+	//
+	// If the parser fails, the bytes read by the failing step are reported
+	// in a TraceRead event named "error" with the error as the value.
+	Value interface{}
+}
+
+// String formats the event on a single line.
+func (e TraceEvent) String() string {
+	path := strings.Join(append(append([]string(nil), e.Path...), e.Name), "/")
+	switch e.Kind {
+	case TraceBegin:
+		return fmt.Sprintf("0x%06x begin %s", e.Offset, path)
+	case TraceEnd:
+		return fmt.Sprintf("0x%06x end %s", e.Offset, path)
+	}
+	b := e.Bytes
+	more := ""
+	if len(b) > 8 {
+		b, more = b[:8], " ..."
+	}
+	return fmt.Sprintf("0x%06x %s [% x%s] %s", e.Offset, path, b, more, formatTraceValue(e.Value))
+}
+
+// formatTraceValue formats short printable byte slices, such as names, as
+// quoted strings and other byte slices by their length.
+func formatTraceValue(v interface{}) string {
+	b, ok := v.([]byte)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	if len(b) <= 64 && utf8.Valid(b) && strings.IndexFunc(string(b), func(r rune) bool { return !unicode.IsPrint(r) }) < 0 {
+		return strconv.Quote(string(b))
+	}
+	return fmt.Sprintf("%d bytes", len(b))
+}
+
+// traced returns r as a *reader if reads from r are traced, otherwise nil.
+func traced(r io.Reader) *reader {
+	if r, ok := r.(*reader); ok && r.trace != nil {
+		return r
+	}
+	return nil
+}
+
+// traceValue reports the bytes read from r since the previous event as the
+// value v encoded as name, if r is traced.
+func traceValue(r io.Reader, name string, v interface{}) {
+	if r := traced(r); r != nil {
+		r.emit(TraceRead, name, v)
+	}
+}
+
+// emit reports an event to the trace function. Pending bytes are passed to
+// read events.
+func (r *reader) emit(kind TraceKind, name string, v interface{}) {
+	e := TraceEvent{
+		Kind:   kind,
+		Offset: r.i,
+		Name:   name,
+		Path:   append([]string(nil), r.path...),
+	}
+	if kind == TraceRead {
+		e.Offset = r.start
+		e.Bytes = r.pending
+		e.Value = v
+		r.pending = nil
+	}
+	r.start = r.i
+	r.trace(e)
+}
+
+// begin starts a traced structure called name.
+func (r *reader) begin(name string) {
+	if r.trace == nil {
+		return
+	}
+	if len(r.pending) > 0 {
+		r.emit(TraceRead, "bytes", nil)
+	}
+	r.emit(TraceBegin, name, nil)
+	r.path = append(r.path, name)
+}
+
+// end ends the innermost traced structure.
+func (r *reader) end() {
+	if r.trace == nil {
+		return
+	}
+	if len(r.pending) > 0 {
+		r.emit(TraceRead, "bytes", nil)
+	}
+	name := r.path[len(r.path)-1]
+	r.path = r.path[:len(r.path)-1]
+	r.emit(TraceEnd, name, nil)
+}
+
+// fail reports the bytes read by a failed step and ends all open structures.
+func (r *reader) fail(err error) {
+	if r.trace == nil {
+		return
+	}
+	if len(r.pending) > 0 {
+		r.emit(TraceRead, "error", err)
+	}
+	for len(r.path) > 0 {
+		r.end()
+	}
+}
@@ -0,0 +1,83 @@
+package wasm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTrace(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var read []byte
+	var open []string
+	_, err = ParseWithOptions(bytes.NewReader(b), ParseOptions{
+		Trace: func(e TraceEvent) {
+			if e.Kind == TraceEnd {
+				if len(open) == 0 || open[len(open)-1] != e.Name {
+					t.Fatalf("Unexpected end of %q at 0x%x", e.Name, e.Offset)
+				}
+				open = open[:len(open)-1]
+			}
+			if len(e.Path) != len(open) || len(open) > 0 && !reflect.DeepEqual(e.Path, open) {
+				t.Fatalf("Path of event at 0x%x does not match; expected %v, actual %v", e.Offset, open, e.Path)
+			}
+			switch e.Kind {
+			case TraceRead:
+				if e.Offset != len(read) {
+					t.Fatalf("Offset does not match; expected 0x%x, actual 0x%x", len(read), e.Offset)
+				}
+				read = append(read, e.Bytes...)
+			case TraceBegin:
+				open = append(open, e.Name)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(open) > 0 {
+		t.Errorf("Structures not ended: %v", open)
+	}
+	if !bytes.Equal(read, b) {
+		t.Errorf("Traced bytes do not match input; read %d of %d bytes", len(read), len(b))
+	}
+}
+
+func TestParseTraceError(t *testing.T) {
+	// Type section with a function type that is missing its result type.
+	b := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x05, 0x01, 0x60, 0x00, 0x01}
+
+	var lines []string
+	_, err := ParseWithOptions(bytes.NewReader(b), ParseOptions{
+		Trace: func(e TraceEvent) {
+			lines = append(lines, e.String())
+		},
+	})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	expected := []string{
+		"0x00000b section/Type/entry 0/value type [60] func",
+		"0x00000c section/Type/entry 0/varuint32 [00] 0",
+		"0x00000d section/Type/entry 0/varuint32 [01] 1",
+		"0x00000e end section/Type/entry 0",
+		"0x00000e end section/Type",
+		"0x00000e end section",
+	}
+	if len(lines) < len(expected) {
+		t.Fatalf("Expected at least %d events, got %d", len(expected), len(lines))
+	}
+	actual := lines[len(lines)-len(expected):]
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Trace does not match; expected\n%s\nactual\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
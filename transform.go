@@ -0,0 +1,129 @@
+package wasm
+
+import (
+	"fmt"
+)
+
+// RenameExport renames the export old to new. It returns an error if there is
+// no export named old, or if another export is already named new.
+func (m *Module) RenameExport(old, new string) error {
+	s := m.ExportSection()
+	if s == nil {
+		return fmt.Errorf("no export named %q", old)
+	}
+	i := -1
+	for j, e := range s.Entries {
+		switch e.Field {
+		case old:
+			i = j
+		case new:
+			return fmt.Errorf("export %q already exists", new)
+		}
+	}
+	if i < 0 {
+		return fmt.Errorf("no export named %q", old)
+	}
+	s.Entries[i].Field = new
+	return nil
+}
+
+// RewriteImportModule changes the module name of all imports from oldModule
+// to newModule, for example to map "env" imports to "wasi_snapshot_preview1".
+// It returns the number of imports that were changed.
+func (m *Module) RewriteImportModule(oldModule, newModule string) int {
+	s := m.ImportSection()
+	if s == nil {
+		return 0
+	}
+	n := 0
+	for i := range s.Entries {
+		if s.Entries[i].Module == oldModule {
+			s.Entries[i].Module = newModule
+			n++
+		}
+	}
+	return n
+}
+
+// AddExport exports the function, table, memory or global with the given index
+// in its index space as name. An export section is added if the module doesn't
+// have one. It returns an error if the index is out of range or an export with
+// the name already exists.
+func (m *Module) AddExport(kind ExternalKind, index uint32, name string) error {
+	n, err := m.indexSpaceSize(kind)
+	if err != nil {
+		return err
+	}
+	if int(index) >= n {
+		return fmt.Errorf("%s index %d out of range, the module has %d", kindNames[kind], index, n)
+	}
+
+	s := m.ExportSection()
+	if s == nil {
+		s = &SectionExport{}
+		m.insertSection(s)
+	}
+	for _, e := range s.Entries {
+		if e.Field == name {
+			return fmt.Errorf("export %q already exists", name)
+		}
+	}
+	s.Entries = append(s.Entries, ExportEntry{Field: name, Kind: kind, Index: index})
+	return nil
+}
+
+var kindNames = map[ExternalKind]string{
+	ExtKindFunction: "function",
+	ExtKindTable:    "table",
+	ExtKindMemory:   "memory",
+	ExtKindGlobal:   "global",
+}
+
+// indexSpaceSize returns the number of items in the index space of kind: the
+// imports of the kind followed by the definitions in the module.
+func (m *Module) indexSpaceSize(kind ExternalKind) (int, error) {
+	if _, ok := kindNames[kind]; !ok {
+		return 0, fmt.Errorf("unknown external kind %d", kind)
+	}
+	n := 0
+	if s := m.ImportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind == kind {
+				n++
+			}
+		}
+	}
+	switch kind {
+	case ExtKindFunction:
+		if s := m.FunctionSection(); s != nil {
+			n += len(s.Types)
+		}
+	case ExtKindTable:
+		if s := m.TableSection(); s != nil {
+			n += len(s.Entries)
+		}
+	case ExtKindMemory:
+		if s := m.MemorySection(); s != nil {
+			n += len(s.Entries)
+		}
+	case ExtKindGlobal:
+		if s := m.GlobalSection(); s != nil {
+			n += len(s.Globals)
+		}
+	}
+	return n, nil
+}
+
+// insertSection inserts the known section s directly after the last known
+// section that must precede it, or first if there is none.
+func (m *Module) insertSection(s Section) {
+	i := 0
+	for j, t := range m.Sections {
+		if t.ID() != uint8(secCustom) && t.ID() < s.ID() {
+			i = j + 1
+		}
+	}
+	m.Sections = append(m.Sections, nil)
+	copy(m.Sections[i+1:], m.Sections[i:])
+	m.Sections[i] = s
+}
@@ -0,0 +1,125 @@
+package wasm
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRenameExport(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mod.RenameExport("run", "start"); err != nil {
+		t.Fatal(err)
+	}
+	if e := mod.ExportSection().Entries[0]; e.Field != "start" || e.Index != 864 {
+		t.Errorf("Expected run renamed to start, got %+v", e)
+	}
+	if err := mod.RenameExport("run", "x"); err == nil {
+		t.Error("Expected error renaming missing export")
+	}
+	if err := mod.RenameExport("start", "mem"); err == nil {
+		t.Error("Expected error renaming to existing export")
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameModule(t, mod, actual)
+}
+
+func TestRewriteImportModule(t *testing.T) {
+	mod := &Module{Sections: []Section{
+		&SectionImport{Entries: []ImportEntry{
+			{Module: "env", Field: "fd_write", Kind: ExtKindFunction, FunctionType: &FunctionType{}},
+			{Module: "host", Field: "log", Kind: ExtKindFunction, FunctionType: &FunctionType{}},
+			{Module: "env", Field: "proc_exit", Kind: ExtKindFunction, FunctionType: &FunctionType{}},
+		}},
+	}}
+
+	if n := mod.RewriteImportModule("env", "wasi_snapshot_preview1"); n != 2 {
+		t.Errorf("Expected 2 imports rewritten, got %d", n)
+	}
+	var modules []string
+	for _, e := range mod.ImportSection().Entries {
+		modules = append(modules, e.Module)
+	}
+	if expected := []string{"wasi_snapshot_preview1", "host", "wasi_snapshot_preview1"}; !reflect.DeepEqual(modules, expected) {
+		t.Errorf("Expected %q, got %q", expected, modules)
+	}
+	if n := (&Module{}).RewriteImportModule("env", "x"); n != 0 {
+		t.Errorf("Expected no imports rewritten, got %d", n)
+	}
+}
+
+func TestAddExport(t *testing.T) {
+	mod := &Module{Sections: []Section{
+		&SectionType{Entries: []FuncType{{Form: 0x60}}},
+		&SectionImport{Entries: []ImportEntry{
+			{Module: "env", Field: "f", Kind: ExtKindFunction, FunctionType: &FunctionType{}},
+			{Module: "env", Field: "memory", Kind: ExtKindMemory, MemoryType: &MemoryType{}},
+		}},
+		&SectionFunction{Types: []uint32{0}},
+		&SectionCustom{SectionName: "a"},
+		&SectionCode{Bodies: []FunctionBody{{Code: []byte{0x0b}}}},
+		&SectionCustom{SectionName: "b"},
+	}}
+
+	if err := mod.AddExport(ExtKindFunction, 1, "run"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mod.AddExport(ExtKindMemory, 0, "memory"); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range mod.Sections {
+		names = append(names, s.Name())
+	}
+	if expected := []string{"Type", "Import", "Function", "Export", "Custom", "Code", "Custom"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected sections %q, got %q", expected, names)
+	}
+	expected := []ExportEntry{
+		{Field: "run", Kind: ExtKindFunction, Index: 1},
+		{Field: "memory", Kind: ExtKindMemory, Index: 0},
+	}
+	if !reflect.DeepEqual(mod.ExportSection().Entries, expected) {
+		t.Errorf("Expected exports %+v, got %+v", expected, mod.ExportSection().Entries)
+	}
+
+	for _, tc := range []struct {
+		kind  ExternalKind
+		index uint32
+		name  string
+	}{
+		{ExtKindFunction, 2, "f"},
+		{ExtKindGlobal, 0, "g"},
+		{ExtKindFunction, 0, "run"},
+		{ExternalKind(9), 0, "x"},
+	} {
+		if err := mod.AddExport(tc.kind, tc.index, tc.name); err == nil {
+			t.Errorf("Expected error adding export %q", tc.name)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, mod); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual.ExportSection().Entries, expected) {
+		t.Errorf("Expected exports %+v after encoding, got %+v", expected, actual.ExportSection().Entries)
+	}
+}
@@ -0,0 +1,926 @@
+package wasm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A ValidationError describes why a module is invalid.
+type ValidationError struct {
+	// Section is the name of the section that contains the problem.
+	Section string
+
+	// Offset is the offset of the section in the input, or 0 if the module
+	// wasn't parsed.
+	Offset int64
+
+	// Entry is the index of the entry of the section that contains the
+	// problem, such as the index of a function body in the code section, or
+	// -1 if the problem concerns the section as a whole.
+	Entry int
+
+	// CodeOffset is the offset of the invalid instruction in the function
+	// body or init expression, or -1.
+	CodeOffset int
+
+	// Msg describes the problem.
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	where := e.Section
+	if e.Entry >= 0 {
+		where += fmt.Sprintf(" entry %d", e.Entry)
+	}
+	if e.CodeOffset >= 0 {
+		where += fmt.Sprintf(", instruction at 0x%x", e.CodeOffset)
+	}
+	return fmt.Sprintf("[0x%06x] %s: %s", e.Offset, where, e.Msg)
+}
+
+// maxPages is the maximum number of pages of 64 KiB of a memory.
+const maxPages = 65536
+
+// maxLocals limits the number of locals of a function, as engines do.
+const maxLocals = 50000
+
+// Validate checks that the module is valid as defined by the WebAssembly
+// specification: that the indices refer to existing types, functions,
+// tables, memories and globals, that the limits are within range, that the
+// init expressions are constant and have the right type, and that the
+// instructions of every function body type check.
+//
+// Parsing only checks that the module is well-formed. A module that parses
+// but doesn't validate is rejected by engines when it's compiled.
+//
+// Validate returns a *ValidationError describing the first problem found, or
+// nil if the module is valid.
+func (m *Module) Validate() error {
+	v := &validator{m: m}
+	return v.validate()
+}
+
+// validator validates a module. The index spaces are collected while the
+// sections are validated, in the order they appear in a module.
+type validator struct {
+	m *Module
+
+	types    []FuncType
+	funcs    []uint32    // type index of every function
+	tables   []ValueType // element type of every table
+	memories int
+	globals  []GlobalType
+	imported int // number of imported globals
+	elems    int
+	data     int
+
+	// The section being validated, for errors.
+	section Section
+}
+
+func (v *validator) errorf(entry, codeOffset int, format string, args ...interface{}) error {
+	e := &ValidationError{
+		Section:    v.section.Name(),
+		Offset:     v.section.Offset(),
+		Entry:      entry,
+		CodeOffset: codeOffset,
+		Msg:        fmt.Sprintf(format, args...),
+	}
+	if s, ok := v.section.(*SectionName); ok {
+		e.Section = s.SectionName
+	}
+	return e
+}
+
+func (v *validator) validate() error {
+	m := v.m
+	if s := m.ElementSection(); s != nil {
+		v.elems = len(s.Entries)
+	}
+	if s := m.DataSection(); s != nil {
+		v.data = len(s.Entries)
+	}
+
+	for _, s := range m.Sections {
+		v.section = s
+		var err error
+		switch s := s.(type) {
+		case *SectionType:
+			err = v.typeSection(s)
+		case *SectionImport:
+			err = v.importSection(s)
+		case *SectionFunction:
+			err = v.functionSection(s)
+		case *SectionTable:
+			for i, t := range s.Entries {
+				if !isRefType(t.ElemType) {
+					err = v.errorf(i, -1, "invalid table element type 0x%02x", uint8(t.ElemType))
+					break
+				}
+				if err = v.limits(i, t.Limits, 1<<32-1); err != nil {
+					break
+				}
+				v.tables = append(v.tables, t.ElemType)
+			}
+		case *SectionMemory:
+			for i, mem := range s.Entries {
+				if err = v.memory(i, mem.Limits); err != nil {
+					break
+				}
+			}
+		case *SectionGlobal:
+			err = v.globalSection(s)
+		case *SectionExport:
+			err = v.exportSection(s)
+		case *SectionStart:
+			err = v.startSection(s)
+		case *SectionElement:
+			err = v.elementSection(s)
+		case *SectionCode:
+			err = v.codeSection(s)
+		case *SectionData:
+			err = v.dataSection(s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.CodeSection() == nil && len(v.funcs) > v.importedFuncs() {
+		v.section = m.FunctionSection()
+		return v.errorf(-1, -1, "%d functions declared but there is no code section", len(v.funcs)-v.importedFuncs())
+	}
+	return nil
+}
+
+func (v *validator) importedFuncs() int {
+	return int(v.m.importedFunctions())
+}
+
+func isValueType(t ValueType) bool {
+	switch t {
+	case I32, I64, F32, F64, V128, FuncRef, ExternRef:
+		return true
+	}
+	return false
+}
+
+func isRefType(t ValueType) bool {
+	return t == FuncRef || t == ExternRef
+}
+
+func (v *validator) typeSection(s *SectionType) error {
+	for i, t := range s.Entries {
+		if t.Form != 0x60 {
+			return v.errorf(i, -1, "invalid type form 0x%02x", uint8(t.Form))
+		}
+		for _, vt := range append(t.Params[:len(t.Params):len(t.Params)], t.ReturnTypes...) {
+			if !isValueType(vt) {
+				return v.errorf(i, -1, "invalid value type 0x%02x", uint8(vt))
+			}
+		}
+	}
+	v.types = s.Entries
+	return nil
+}
+
+func (v *validator) importSection(s *SectionImport) error {
+	for i, e := range s.Entries {
+		switch e.Kind {
+		case ExtKindFunction:
+			if int(e.FunctionType.Index) >= len(v.types) {
+				return v.errorf(i, -1, "import %s.%s: unknown type %d", e.Module, e.Field, e.FunctionType.Index)
+			}
+			v.funcs = append(v.funcs, e.FunctionType.Index)
+		case ExtKindTable:
+			if !isRefType(e.TableType.ElemType) {
+				return v.errorf(i, -1, "import %s.%s: invalid table element type 0x%02x", e.Module, e.Field, uint8(e.TableType.ElemType))
+			}
+			if err := v.limits(i, e.TableType.Limits, 1<<32-1); err != nil {
+				return err
+			}
+			v.tables = append(v.tables, e.TableType.ElemType)
+		case ExtKindMemory:
+			if err := v.memory(i, e.MemoryType.Limits); err != nil {
+				return err
+			}
+		case ExtKindGlobal:
+			if !isValueType(e.GlobalType.ContentType) {
+				return v.errorf(i, -1, "import %s.%s: invalid global type 0x%02x", e.Module, e.Field, uint8(e.GlobalType.ContentType))
+			}
+			v.globals = append(v.globals, *e.GlobalType)
+			v.imported++
+		default:
+			return v.errorf(i, -1, "import %s.%s: unknown kind %d", e.Module, e.Field, e.Kind)
+		}
+	}
+	return nil
+}
+
+func (v *validator) functionSection(s *SectionFunction) error {
+	for i, ti := range s.Types {
+		if int(ti) >= len(v.types) {
+			return v.errorf(i, -1, "unknown type %d", ti)
+		}
+	}
+	v.funcs = append(v.funcs, s.Types...)
+	return nil
+}
+
+func (v *validator) limits(entry int, l ResizableLimits, max uint64) error {
+	if uint64(l.Initial) > max {
+		return v.errorf(entry, -1, "minimum size %d exceeds %d", l.Initial, max)
+	}
+	if l.HasMaximum {
+		if uint64(l.Maximum) > max {
+			return v.errorf(entry, -1, "maximum size %d exceeds %d", l.Maximum, max)
+		}
+		if l.Initial > l.Maximum {
+			return v.errorf(entry, -1, "minimum size %d exceeds maximum size %d", l.Initial, l.Maximum)
+		}
+	}
+	return nil
+}
+
+func (v *validator) memory(entry int, l ResizableLimits) error {
+	if v.memories > 0 {
+		return v.errorf(entry, -1, "multiple memories")
+	}
+	if err := v.limits(entry, l, maxPages); err != nil {
+		return err
+	}
+	if l.Shared && !l.HasMaximum {
+		return v.errorf(entry, -1, "shared memory must have a maximum")
+	}
+	v.memories++
+	return nil
+}
+
+func (v *validator) globalSection(s *SectionGlobal) error {
+	for i, g := range s.Globals {
+		if !isValueType(g.Type.ContentType) {
+			return v.errorf(i, -1, "invalid global type 0x%02x", uint8(g.Type.ContentType))
+		}
+		if err := v.constExpr(i, g.Init, g.Type.ContentType); err != nil {
+			return err
+		}
+		v.globals = append(v.globals, g.Type)
+	}
+	return nil
+}
+
+// constExpr validates an init expression of type want. The expression is
+// validated before the global it initializes is added, so it can't refer to
+// that global or the ones following it.
+func (v *validator) constExpr(entry int, expr []byte, want ValueType) error {
+	r := NewCodeReader(expr)
+	var stack []ValueType
+	for {
+		in, err := r.Next()
+		if err == io.EOF {
+			return v.errorf(entry, r.Offset(), "init expression not terminated by end")
+		}
+		if err != nil {
+			return v.errorf(entry, r.Offset(), "%v", err)
+		}
+		switch in.Op {
+		case opI32Const, opI64Const, opF32Const, opF64Const, opRefFunc:
+			info, _ := in.Op.Info()
+			stack = append(stack, info.Pushes[0])
+			if in.Op == opRefFunc && int(in.Immediates[0]) >= len(v.funcs) {
+				return v.errorf(entry, in.Offset, "unknown function %d", in.Immediates[0])
+			}
+		case opRefNull:
+			stack = append(stack, ValueType(in.Immediates[0]))
+		case opGetGlobal:
+			gi := in.Immediates[0]
+			if gi >= uint64(len(v.globals)) {
+				return v.errorf(entry, in.Offset, "unknown global %d", gi)
+			}
+			if int(gi) >= v.imported || v.globals[gi].Mutable {
+				return v.errorf(entry, in.Offset, "constant expression required: global %d is not an immutable import", gi)
+			}
+			stack = append(stack, v.globals[gi].ContentType)
+		case opEnd:
+			if r.Offset() != len(expr) {
+				return v.errorf(entry, r.Offset(), "instructions after end of init expression")
+			}
+			if len(stack) != 1 || stack[0] != want {
+				return v.errorf(entry, in.Offset, "type mismatch: init expression has type [%s], expected [%s]", typeListText(stack), typeText(want))
+			}
+			return nil
+		default:
+			return v.errorf(entry, in.Offset, "constant expression required: %s not allowed", in.Op)
+		}
+	}
+}
+
+func (v *validator) exportSection(s *SectionExport) error {
+	names := make(map[string]bool)
+	for i, e := range s.Entries {
+		if names[e.Field] {
+			return v.errorf(i, -1, "duplicate export name %q", e.Field)
+		}
+		names[e.Field] = true
+
+		var n int
+		switch e.Kind {
+		case ExtKindFunction:
+			n = len(v.funcs)
+		case ExtKindTable:
+			n = len(v.tables)
+		case ExtKindMemory:
+			n = v.memories
+		case ExtKindGlobal:
+			n = len(v.globals)
+		default:
+			return v.errorf(i, -1, "export %q: unknown kind %d", e.Field, e.Kind)
+		}
+		if int(e.Index) >= n {
+			return v.errorf(i, -1, "export %q: unknown %s %d", e.Field, kindNames[e.Kind], e.Index)
+		}
+	}
+	return nil
+}
+
+func (v *validator) startSection(s *SectionStart) error {
+	if int(s.Index) >= len(v.funcs) {
+		return v.errorf(-1, -1, "unknown function %d", s.Index)
+	}
+	if t := v.types[v.funcs[s.Index]]; len(t.Params) > 0 || len(t.ReturnTypes) > 0 {
+		return v.errorf(-1, -1, "start function %d must have type () -> (), has %s", s.Index, t)
+	}
+	return nil
+}
+
+func (v *validator) elementSection(s *SectionElement) error {
+	for i, e := range s.Entries {
+		if int(e.Index) >= len(v.tables) {
+			return v.errorf(i, -1, "unknown table %d", e.Index)
+		}
+		if v.tables[e.Index] != FuncRef {
+			return v.errorf(i, -1, "table %d is not a funcref table", e.Index)
+		}
+		if err := v.constExpr(i, e.Offset, I32); err != nil {
+			return err
+		}
+		for _, fi := range e.Elems {
+			if int(fi) >= len(v.funcs) {
+				return v.errorf(i, -1, "unknown function %d", fi)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *validator) dataSection(s *SectionData) error {
+	for i, d := range s.Entries {
+		if int(d.Index) >= v.memories {
+			return v.errorf(i, -1, "unknown memory %d", d.Index)
+		}
+		if err := v.constExpr(i, d.Offset, I32); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) codeSection(s *SectionCode) error {
+	imported := v.importedFuncs()
+	if len(s.Bodies) != len(v.funcs)-imported {
+		return v.errorf(-1, -1, "%d function bodies but %d functions declared", len(s.Bodies), len(v.funcs)-imported)
+	}
+	for i, body := range s.Bodies {
+		fi := imported + i
+		if off, err := v.function(v.types[v.funcs[fi]], body); err != nil {
+			return v.errorf(i, off, "function %d: %v", fi, err)
+		}
+	}
+	return nil
+}
+
+// typeUnknown is the type of an operand popped from the stack in unreachable
+// code, which matches every type.
+const typeUnknown ValueType = 0
+
+// A ctrlFrame is a block on the control stack of the function being
+// validated.
+type ctrlFrame struct {
+	op          Opcode
+	params      []ValueType
+	results     []ValueType
+	height      int
+	unreachable bool
+}
+
+// labelTypes returns the types of the operands a branch to the frame takes.
+func (f *ctrlFrame) labelTypes() []ValueType {
+	if f.op == opLoop {
+		return f.params
+	}
+	return f.results
+}
+
+// funcValidator type checks a function body with the algorithm from the
+// appendix of the specification.
+type funcValidator struct {
+	v      *validator
+	locals []ValueType
+	vals   []ValueType
+	ctrls  []ctrlFrame
+}
+
+func (f *funcValidator) push(t ValueType) {
+	f.vals = append(f.vals, t)
+}
+
+func (f *funcValidator) pushAll(types []ValueType) {
+	f.vals = append(f.vals, types...)
+}
+
+func (f *funcValidator) pop() (ValueType, error) {
+	top := &f.ctrls[len(f.ctrls)-1]
+	if len(f.vals) == top.height {
+		if top.unreachable {
+			return typeUnknown, nil
+		}
+		return 0, fmt.Errorf("type mismatch: operand stack is empty")
+	}
+	t := f.vals[len(f.vals)-1]
+	f.vals = f.vals[:len(f.vals)-1]
+	return t, nil
+}
+
+func (f *funcValidator) popExpect(want ValueType) (ValueType, error) {
+	t, err := f.pop()
+	if err != nil {
+		return 0, fmt.Errorf("type mismatch: expected %s, operand stack is empty", typeText(want))
+	}
+	if t != want && t != typeUnknown && want != typeUnknown {
+		return 0, fmt.Errorf("type mismatch: expected %s, got %s", typeText(want), typeText(t))
+	}
+	return t, nil
+}
+
+func (f *funcValidator) popAll(types []ValueType) error {
+	for i := len(types) - 1; i >= 0; i-- {
+		if _, err := f.popExpect(types[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *funcValidator) pushCtrl(op Opcode, params, results []ValueType) {
+	f.ctrls = append(f.ctrls, ctrlFrame{op: op, params: params, results: results, height: len(f.vals)})
+	f.pushAll(params)
+}
+
+func (f *funcValidator) popCtrl() (ctrlFrame, error) {
+	top := f.ctrls[len(f.ctrls)-1]
+	if err := f.popAll(top.results); err != nil {
+		return top, err
+	}
+	if len(f.vals) != top.height {
+		return top, fmt.Errorf("type mismatch: %d values remaining on the operand stack at end of block", len(f.vals)-top.height)
+	}
+	f.ctrls = f.ctrls[:len(f.ctrls)-1]
+	return top, nil
+}
+
+func (f *funcValidator) setUnreachable() {
+	top := &f.ctrls[len(f.ctrls)-1]
+	f.vals = f.vals[:top.height]
+	top.unreachable = true
+}
+
+func (f *funcValidator) label(depth uint64) (*ctrlFrame, error) {
+	if depth >= uint64(len(f.ctrls)) {
+		return nil, fmt.Errorf("unknown label %d", depth)
+	}
+	return &f.ctrls[len(f.ctrls)-1-int(depth)], nil
+}
+
+// function type checks a function body with the type t. It returns the
+// offset of the instruction with an error.
+func (v *validator) function(t FuncType, body FunctionBody) (int, error) {
+	f := &funcValidator{v: v}
+	n := uint64(len(t.Params))
+	for _, l := range body.Locals {
+		n += uint64(l.Count)
+		if n > maxLocals {
+			return -1, fmt.Errorf("too many locals")
+		}
+		if !isValueType(l.Type) {
+			return -1, fmt.Errorf("invalid local type 0x%02x", uint8(l.Type))
+		}
+	}
+	f.locals = make([]ValueType, 0, n)
+	f.locals = append(f.locals, t.Params...)
+	for _, l := range body.Locals {
+		for i := uint32(0); i < l.Count; i++ {
+			f.locals = append(f.locals, l.Type)
+		}
+	}
+	f.pushCtrl(opBlock, nil, t.ReturnTypes)
+
+	r := NewCodeReader(body.Code)
+	for {
+		in, err := r.Next()
+		if err == io.EOF {
+			return len(body.Code), fmt.Errorf("unexpected end of code, missing end")
+		}
+		if err != nil {
+			return r.Offset(), err
+		}
+		if len(f.ctrls) == 0 {
+			return in.Offset, fmt.Errorf("instructions after end of function")
+		}
+		if err := f.instruction(in); err != nil {
+			return in.Offset, fmt.Errorf("%s: %v", in.Op, err)
+		}
+		if len(f.ctrls) == 0 && r.Offset() == len(body.Code) {
+			return -1, nil
+		}
+	}
+}
+
+// Op codes with operands that depend on their immediates or context.
+const (
+	opUnreachable Opcode = 0x00
+	opReturn      Opcode = 0x0f
+	opDrop        Opcode = 0x1a
+	opLocalGet    Opcode = 0x20
+	opLocalSet    Opcode = 0x21
+	opLocalTee    Opcode = 0x22
+	opGlobalSet   Opcode = 0x24
+	opTableGet    Opcode = 0x25
+	opTableSet    Opcode = 0x26
+	opRefNull     Opcode = 0xd0
+	opRefIsNull   Opcode = 0xd1
+	opMemoryInit  Opcode = 0xfc08
+	opDataDrop    Opcode = 0xfc09
+	opTableInit   Opcode = 0xfc0c
+	opElemDrop    Opcode = 0xfc0d
+	opTableCopy   Opcode = 0xfc0e
+	opTableGrow   Opcode = 0xfc0f
+	opTableSize   Opcode = 0xfc10
+	opTableFill   Opcode = 0xfc11
+)
+
+func (f *funcValidator) instruction(in Instruction) error {
+	v := f.v
+	info, _ := in.Op.Info()
+	imm := in.Immediates
+
+	switch info.Immediates {
+	case ImmMemArg, ImmMemory, ImmMemory2:
+		if v.memories == 0 {
+			return fmt.Errorf("unknown memory 0")
+		}
+		if info.Immediates == ImmMemArg {
+			if size := accessSize(info.Name); imm[0] >= 64 || 1<<imm[0] > size {
+				return fmt.Errorf("alignment must not be larger than natural")
+			}
+		}
+	}
+
+	switch in.Op {
+	case opUnreachable:
+		f.setUnreachable()
+
+	case opBlock, opLoop, opIf:
+		params, results, err := f.blockType(int64(imm[0]))
+		if err != nil {
+			return err
+		}
+		if in.Op == opIf {
+			if _, err := f.popExpect(I32); err != nil {
+				return err
+			}
+		}
+		if err := f.popAll(params); err != nil {
+			return err
+		}
+		f.pushCtrl(in.Op, params, results)
+
+	case opElse:
+		frame, err := f.popCtrl()
+		if err != nil {
+			return err
+		}
+		if frame.op != opIf {
+			return fmt.Errorf("else without if")
+		}
+		f.pushCtrl(opElse, frame.params, frame.results)
+
+	case opEnd:
+		frame, err := f.popCtrl()
+		if err != nil {
+			return err
+		}
+		if frame.op == opIf && !sameValueTypes(frame.params, frame.results) {
+			return fmt.Errorf("type mismatch: if without else must leave its parameters as results")
+		}
+		f.pushAll(frame.results)
+
+	case opBr:
+		l, err := f.label(imm[0])
+		if err != nil {
+			return err
+		}
+		if err := f.popAll(l.labelTypes()); err != nil {
+			return err
+		}
+		f.setUnreachable()
+
+	case opBrIf:
+		if _, err := f.popExpect(I32); err != nil {
+			return err
+		}
+		l, err := f.label(imm[0])
+		if err != nil {
+			return err
+		}
+		if err := f.popAll(l.labelTypes()); err != nil {
+			return err
+		}
+		f.pushAll(l.labelTypes())
+
+	case opBrTable:
+		if _, err := f.popExpect(I32); err != nil {
+			return err
+		}
+		def, err := f.label(imm[len(imm)-1])
+		if err != nil {
+			return err
+		}
+		arity := len(def.labelTypes())
+		for _, depth := range imm[:len(imm)-1] {
+			l, err := f.label(depth)
+			if err != nil {
+				return err
+			}
+			types := l.labelTypes()
+			if len(types) != arity {
+				return fmt.Errorf("type mismatch: label %d takes %d operands, default label takes %d", depth, len(types), arity)
+			}
+			// Check the operands against the label without consuming
+			// them.
+			saved := append([]ValueType(nil), f.vals...)
+			if err := f.popAll(types); err != nil {
+				return err
+			}
+			f.vals = saved
+		}
+		if err := f.popAll(def.labelTypes()); err != nil {
+			return err
+		}
+		f.setUnreachable()
+
+	case opReturn:
+		if err := f.popAll(f.ctrls[0].results); err != nil {
+			return err
+		}
+		f.setUnreachable()
+
+	case opCall:
+		if imm[0] >= uint64(len(v.funcs)) {
+			return fmt.Errorf("unknown function %d", imm[0])
+		}
+		return f.call(v.types[v.funcs[imm[0]]])
+
+	case opCallIndirect:
+		if imm[1] >= uint64(len(v.tables)) {
+			return fmt.Errorf("unknown table %d", imm[1])
+		}
+		if v.tables[imm[1]] != FuncRef {
+			return fmt.Errorf("table %d is not a funcref table", imm[1])
+		}
+		if imm[0] >= uint64(len(v.types)) {
+			return fmt.Errorf("unknown type %d", imm[0])
+		}
+		if _, err := f.popExpect(I32); err != nil {
+			return err
+		}
+		return f.call(v.types[imm[0]])
+
+	case opDrop:
+		_, err := f.pop()
+		return err
+
+	case opSelect, opSelectTyped:
+		want := typeUnknown
+		if in.Op == opSelectTyped {
+			if len(imm) != 1 {
+				return fmt.Errorf("invalid result arity %d", len(imm))
+			}
+			want = ValueType(imm[0])
+		}
+		if _, err := f.popExpect(I32); err != nil {
+			return err
+		}
+		t1, err := f.popExpect(want)
+		if err != nil {
+			return err
+		}
+		t2, err := f.popExpect(want)
+		if err != nil {
+			return err
+		}
+		if in.Op == opSelect {
+			if isRefType(t1) || isRefType(t2) || t1 == V128 || t2 == V128 {
+				return fmt.Errorf("type mismatch: select without type requires numeric operands")
+			}
+			if t1 != t2 && t1 != typeUnknown && t2 != typeUnknown {
+				return fmt.Errorf("type mismatch: operands of type %s and %s", typeText(t2), typeText(t1))
+			}
+			if t1 == typeUnknown {
+				t1 = t2
+			}
+			f.push(t1)
+		} else {
+			f.push(want)
+		}
+
+	case opLocalGet, opLocalSet, opLocalTee:
+		if imm[0] >= uint64(len(f.locals)) {
+			return fmt.Errorf("unknown local %d", imm[0])
+		}
+		t := f.locals[imm[0]]
+		if in.Op != opLocalGet {
+			if _, err := f.popExpect(t); err != nil {
+				return err
+			}
+		}
+		if in.Op != opLocalSet {
+			f.push(t)
+		}
+
+	case opGetGlobal, opGlobalSet:
+		if imm[0] >= uint64(len(v.globals)) {
+			return fmt.Errorf("unknown global %d", imm[0])
+		}
+		g := v.globals[imm[0]]
+		if in.Op == opGetGlobal {
+			f.push(g.ContentType)
+			break
+		}
+		if !g.Mutable {
+			return fmt.Errorf("global %d is immutable", imm[0])
+		}
+		_, err := f.popExpect(g.ContentType)
+		return err
+
+	case opTableGet, opTableSet, opTableGrow, opTableSize, opTableFill:
+		if imm[0] >= uint64(len(v.tables)) {
+			return fmt.Errorf("unknown table %d", imm[0])
+		}
+		t := v.tables[imm[0]]
+		switch in.Op {
+		case opTableGet:
+			if _, err := f.popExpect(I32); err != nil {
+				return err
+			}
+			f.push(t)
+		case opTableSet:
+			return f.popAll([]ValueType{I32, t})
+		case opTableGrow:
+			if err := f.popAll([]ValueType{t, I32}); err != nil {
+				return err
+			}
+			f.push(I32)
+		case opTableSize:
+			f.push(I32)
+		case opTableFill:
+			return f.popAll([]ValueType{I32, t, I32})
+		}
+
+	case opTableCopy:
+		for _, ti := range imm {
+			if ti >= uint64(len(v.tables)) {
+				return fmt.Errorf("unknown table %d", ti)
+			}
+		}
+		if v.tables[imm[0]] != v.tables[imm[1]] {
+			return fmt.Errorf("type mismatch: tables %d and %d have different element types", imm[0], imm[1])
+		}
+		return f.popAll(info.Pops)
+
+	case opTableInit, opElemDrop:
+		if imm[0] >= uint64(v.elems) {
+			return fmt.Errorf("unknown element segment %d", imm[0])
+		}
+		if in.Op == opTableInit {
+			if imm[1] >= uint64(len(v.tables)) {
+				return fmt.Errorf("unknown table %d", imm[1])
+			}
+			if v.tables[imm[1]] != FuncRef {
+				return fmt.Errorf("type mismatch: table %d is not a funcref table", imm[1])
+			}
+		}
+		return f.popAll(info.Pops)
+
+	case opMemoryInit, opDataDrop:
+		if imm[0] >= uint64(v.data) {
+			return fmt.Errorf("unknown data segment %d", imm[0])
+		}
+		if in.Op == opMemoryInit && v.memories == 0 {
+			return fmt.Errorf("unknown memory 0")
+		}
+		return f.popAll(info.Pops)
+
+	case opRefNull:
+		t := ValueType(imm[0])
+		if !isRefType(t) {
+			return fmt.Errorf("invalid reference type 0x%02x", uint8(t))
+		}
+		f.push(t)
+
+	case opRefIsNull:
+		t, err := f.pop()
+		if err != nil {
+			return err
+		}
+		if t != typeUnknown && !isRefType(t) {
+			return fmt.Errorf("type mismatch: expected a reference, got %s", typeText(t))
+		}
+		f.push(I32)
+
+	case opRefFunc:
+		if imm[0] >= uint64(len(v.funcs)) {
+			return fmt.Errorf("unknown function %d", imm[0])
+		}
+		f.push(FuncRef)
+
+	default:
+		if info.Polymorphic {
+			return fmt.Errorf("not supported by the validator")
+		}
+		if err := f.popAll(info.Pops); err != nil {
+			return err
+		}
+		f.pushAll(info.Pushes)
+	}
+	return nil
+}
+
+func (f *funcValidator) call(t FuncType) error {
+	if err := f.popAll(t.Params); err != nil {
+		return err
+	}
+	f.pushAll(t.ReturnTypes)
+	return nil
+}
+
+// blockType returns the parameters and results of a block with the block
+// type bt.
+func (f *funcValidator) blockType(bt int64) ([]ValueType, []ValueType, error) {
+	switch {
+	case bt == -0x40:
+		return nil, nil, nil
+	case bt < 0:
+		t := ValueType(bt & 0x7f)
+		if !isValueType(t) {
+			return nil, nil, fmt.Errorf("invalid block type 0x%02x", uint8(t))
+		}
+		return nil, []ValueType{t}, nil
+	case bt >= int64(len(f.v.types)):
+		return nil, nil, fmt.Errorf("unknown type %d", bt)
+	}
+	t := f.v.types[bt]
+	return t.Params, t.ReturnTypes, nil
+}
+
+// accessSize returns the number of bytes read or written by the memory
+// instruction with the given name, such as 2 for i64.load16_s.
+func accessSize(name string) uint64 {
+	op := name[strings.IndexByte(name, '.')+1:]
+	switch {
+	case strings.Contains(op, "8"):
+		return 1
+	case strings.Contains(op, "16"):
+		return 2
+	case strings.Contains(op, "32"):
+		return 4
+	case strings.HasPrefix(name, "i64") || strings.HasPrefix(name, "f64"):
+		return 8
+	}
+	return 4
+}
+
+func typeText(t ValueType) string {
+	if t == typeUnknown {
+		return "unknown"
+	}
+	return t.String()
+}
+
+func typeListText(types []ValueType) string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = typeText(t)
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,200 @@
+package wasm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := instrumentModule().Validate(); err != nil {
+		t.Fatalf("Expected valid module, got %v", err)
+	}
+	shared := instrumentModule()
+	shared.Sections = append(shared.Sections, &SectionMemory{Entries: []MemoryType{{Limits: ResizableLimits{Maximum: 0, HasMaximum: true, Shared: true}}}})
+	if err := shared.Validate(); err != nil {
+		t.Fatalf("Expected shared memory with maximum 0 to be valid, got %v", err)
+	}
+
+	setCode := func(m *Module, code ...byte) {
+		m.CodeSection().Bodies[0].Code = code
+	}
+	tt := []struct {
+		name   string
+		modify func(m *Module)
+		err    string
+	}{
+		{
+			name: "unknown type",
+			modify: func(m *Module) {
+				m.FunctionSection().Types[1] = 5
+			},
+			err: "Function entry 1: unknown type 5",
+		},
+		{
+			name: "missing body",
+			modify: func(m *Module) {
+				m.CodeSection().Bodies = m.CodeSection().Bodies[:1]
+			},
+			err: "1 function bodies but 2 functions declared",
+		},
+		{
+			name: "duplicate export",
+			modify: func(m *Module) {
+				s := m.ExportSection()
+				s.Entries = append(s.Entries, ExportEntry{Field: "b", Kind: ExtKindFunction, Index: 1})
+			},
+			err: `Export entry 1: duplicate export name "b"`,
+		},
+		{
+			name: "unknown export",
+			modify: func(m *Module) {
+				m.ExportSection().Entries[0] = ExportEntry{Field: "g", Kind: ExtKindGlobal, Index: 1}
+			},
+			err: `export "g": unknown global 1`,
+		},
+		{
+			name: "start type",
+			modify: func(m *Module) {
+				m.FunctionSection().Types[0] = 1
+			},
+			err: "start function 1 must have type () -> ()",
+		},
+		{
+			name: "elem function",
+			modify: func(m *Module) {
+				m.ElementSection().Entries[0].Elems[1] = 3
+			},
+			err: "Element entry 0: unknown function 3",
+		},
+		{
+			name: "global init type",
+			modify: func(m *Module) {
+				m.GlobalSection().Globals[0].Init = []byte{0x42, 0x02, 0x0b}
+			},
+			err: "init expression has type [i64], expected [i32]",
+		},
+		{
+			name: "global init not constant",
+			modify: func(m *Module) {
+				m.GlobalSection().Globals[0].Init = []byte{0x41, 0x02, 0x41, 0x01, 0x6a, 0x0b}
+			},
+			err: "instruction at 0x4: constant expression required: i32.add not allowed",
+		},
+		{
+			name: "memory limits",
+			modify: func(m *Module) {
+				m.Sections = append(m.Sections, &SectionMemory{Entries: []MemoryType{{Limits: ResizableLimits{Initial: 2, Maximum: 1, HasMaximum: true}}}})
+			},
+			err: "minimum size 2 exceeds maximum size 1",
+		},
+		{
+			name: "memory maximum 0",
+			modify: func(m *Module) {
+				m.Sections = append(m.Sections, &SectionMemory{Entries: []MemoryType{{Limits: ResizableLimits{Initial: 1, Maximum: 0, HasMaximum: true}}}})
+			},
+			err: "minimum size 1 exceeds maximum size 0",
+		},
+		{
+			name: "type mismatch",
+			modify: func(m *Module) {
+				setCode(m, 0x42, 0x00, 0x10, 0x00, 0x0b) // i64.const 0, call 0, end
+			},
+			err: "Code entry 0, instruction at 0x4: function 1: end: type mismatch: 1 values remaining",
+		},
+		{
+			name: "call params",
+			modify: func(m *Module) {
+				m.ImportSection().Entries[0].FunctionType.Index = 1
+			},
+			err: "Code entry 1, instruction at 0x0: function 2: call: type mismatch: expected i32, operand stack is empty",
+		},
+		{
+			name: "unknown local",
+			modify: func(m *Module) {
+				setCode(m, 0x20, 0x00, 0x1a, 0x0b) // local.get 0, drop, end
+			},
+			err: "local.get: unknown local 0",
+		},
+		{
+			name: "immutable global",
+			modify: func(m *Module) {
+				setCode(m, 0x41, 0x00, 0x24, 0x00, 0x0b) // i32.const 0, global.set 0, end
+			},
+			err: "global.set: global 0 is immutable",
+		},
+		{
+			name: "unknown label",
+			modify: func(m *Module) {
+				setCode(m, 0x0c, 0x01, 0x0b) // br 1, end
+			},
+			err: "br: unknown label 1",
+		},
+		{
+			name: "no memory",
+			modify: func(m *Module) {
+				setCode(m, 0x41, 0x00, 0x28, 0x02, 0x00, 0x1a, 0x0b) // i32.const 0, i32.load, drop, end
+			},
+			err: "i32.load: unknown memory 0",
+		},
+		{
+			name: "missing end",
+			modify: func(m *Module) {
+				setCode(m, 0x02, 0x40, 0x0b) // block, end
+			},
+			err: "instruction at 0x3: function 1: unexpected end of code, missing end",
+		},
+		{
+			name: "after end",
+			modify: func(m *Module) {
+				setCode(m, 0x0b, 0x01) // end, nop
+			},
+			err: "function 1: instructions after end of function",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			mod := instrumentModule()
+			tc.modify(mod)
+			err := mod.Validate()
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if _, ok := err.(*ValidationError); !ok {
+				t.Errorf("Expected a *ValidationError, got %T", err)
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("Expected error containing %q, got %q", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateUnreachable(t *testing.T) {
+	mod := instrumentModule()
+	mod.CodeSection().Bodies[0].Code = []byte{
+		0x02, 0x7f, // block i32
+		0x00,       // unreachable
+		0x6a,       // i32.add
+		0x0b,       // end
+		0x41, 0x00, // i32.const 0
+		0x0d, 0x00, // br_if 0
+		0x1a, // drop
+		0x0b, // end
+	}
+	if err := mod.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateHelloWorld(t *testing.T) {
+	f, done := open(t, "helloworld.wasm")
+	defer done()
+	mod, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mod.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,270 @@
+// Package wasmtest provides helpers for testing the output of compilers that
+// produce WebAssembly.
+//
+// AssertFunctions pins the signature and disassembly of selected functions in
+// golden files. This makes it possible to notice when a compiler upgrade
+// changes the code generated for performance critical functions:
+//
+//	func TestHotFunctions(t *testing.T) {
+//		f, err := os.Open("app.wasm")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		defer f.Close()
+//		mod, err := wasm.Parse(f)
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		wasmtest.AssertFunctions(t, mod, "testdata/functions", "main.hash", "main.compress")
+//	}
+//
+// Run the tests with -wasmtest.update to write the golden files.
+package wasmtest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+var update = flag.Bool("wasmtest.update", false, "update wasmtest golden files")
+
+// AssertFunctions compares a snapshot of each of the named functions in m with
+// the golden file in dir. The test fails if a snapshot differs from the golden
+// file.
+//
+// Functions are looked up by their name in the name section or the name they
+// are exported with. A function may also be referred to by its index in the
+// function index space.
+//
+// The golden files are written instead of compared if the tests are run with
+// -wasmtest.update.
+func AssertFunctions(t testing.TB, m *wasm.Module, dir string, funcs ...string) {
+	t.Helper()
+	AssertFunctionsWithOptions(t, m, dir, Options{}, funcs...)
+}
+
+// Options control the snapshots taken by AssertFunctionsWithOptions.
+type Options struct {
+	// Normalize normalizes the disassembly with wasm.Normalize, so the
+	// golden files don't change when a compiler upgrade only changes the
+	// encoding of the code, such as by adding nops or blocks.
+	Normalize bool
+}
+
+// AssertFunctionsWithOptions is like AssertFunctions, with options for the
+// snapshots.
+func AssertFunctionsWithOptions(t testing.TB, m *wasm.Module, dir string, opts Options, funcs ...string) {
+	t.Helper()
+
+	index, err := newFuncIndex(m)
+	if err != nil {
+		t.Fatalf("wasmtest: %v", err)
+	}
+
+	for _, name := range funcs {
+		fi, ok := index.lookup(name)
+		if !ok {
+			t.Errorf("wasmtest: function %q not found", name)
+			continue
+		}
+
+		s, err := index.snapshot(fi, opts)
+		if err != nil {
+			t.Errorf("wasmtest: function %q: %v", name, err)
+			continue
+		}
+
+		assertGolden(t, filepath.Join(dir, filename(name)), []byte(s))
+	}
+}
+
+// Snapshot returns the snapshot of the function with index fi in the function
+// index space. The snapshot contains the function signature, locals and the
+// disassembled function body, one instruction per line.
+func Snapshot(m *wasm.Module, fi uint32) (string, error) {
+	index, err := newFuncIndex(m)
+	if err != nil {
+		return "", err
+	}
+	return index.snapshot(fi, Options{})
+}
+
+// funcIndex resolves functions in a module.
+type funcIndex struct {
+	graph    *wasm.CallGraph
+	types    []wasm.FuncType
+	sigs     []uint32 // type index of every function
+	imported uint32   // number of imported functions
+	bodies   []wasm.FunctionBody
+	names    map[string]uint32
+}
+
+func newFuncIndex(m *wasm.Module) (*funcIndex, error) {
+	g, err := m.CallGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	index := &funcIndex{
+		graph: g,
+		names: make(map[string]uint32),
+	}
+	for _, n := range g.Funcs {
+		if n.Name != "" {
+			index.names[n.Name] = n.Index
+		}
+	}
+
+	for _, s := range m.Sections {
+		switch s := s.(type) {
+		case *wasm.SectionType:
+			index.types = s.Entries
+		case *wasm.SectionImport:
+			for _, e := range s.Entries {
+				if e.Kind == wasm.ExtKindFunction {
+					index.sigs = append(index.sigs, e.FunctionType.Index)
+					index.imported++
+				}
+			}
+		case *wasm.SectionFunction:
+			index.sigs = append(index.sigs, s.Types...)
+		case *wasm.SectionCode:
+			index.bodies = s.Bodies
+		case *wasm.SectionExport:
+			for _, e := range s.Entries {
+				if e.Kind != wasm.ExtKindFunction {
+					continue
+				}
+				if _, ok := index.names[e.Field]; !ok {
+					index.names[e.Field] = e.Index
+				}
+			}
+		}
+	}
+
+	return index, nil
+}
+
+func (index *funcIndex) lookup(name string) (uint32, bool) {
+	if fi, ok := index.names[name]; ok {
+		return fi, true
+	}
+	if fi, err := strconv.ParseUint(name, 10, 32); err == nil && int(fi) < len(index.graph.Funcs) {
+		return uint32(fi), true
+	}
+	return 0, false
+}
+
+func (index *funcIndex) snapshot(fi uint32, opts Options) (string, error) {
+	if int(fi) >= len(index.graph.Funcs) {
+		return "", fmt.Errorf("function index %d out of range", fi)
+	}
+	n := index.graph.Funcs[fi]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "func %d %q\n", fi, n.Name)
+	if int(fi) < len(index.sigs) && int(index.sigs[fi]) < len(index.types) {
+		fmt.Fprintf(&buf, "type %s\n", index.types[index.sigs[fi]])
+	}
+	if n.Imported {
+		fmt.Fprintf(&buf, "imported\n")
+		return buf.String(), nil
+	}
+
+	bi := int(fi) - int(index.imported)
+	if bi < 0 || bi >= len(index.bodies) {
+		return "", fmt.Errorf("function %d has no body", fi)
+	}
+	body := index.bodies[bi]
+	for _, l := range body.Locals {
+		fmt.Fprintf(&buf, "local %s\n", l)
+	}
+
+	ins, err := wasm.Disassemble(body.Code)
+	if err != nil {
+		return "", err
+	}
+	if opts.Normalize {
+		ins = wasm.Normalize(ins)
+	}
+	depth := 1
+	for _, in := range ins {
+		switch in.Op.String() {
+		case "end", "else":
+			depth--
+		}
+		fmt.Fprintf(&buf, "%s%s", strings.Repeat("  ", depth), in)
+		if in.Op.String() == "call" && int(in.Immediates[0]) < len(index.graph.Funcs) {
+			if name := index.graph.Funcs[in.Immediates[0]].Name; name != "" {
+				fmt.Fprintf(&buf, " ;; %s", name)
+			}
+		}
+		buf.WriteByte('\n')
+		switch in.Op.String() {
+		case "block", "loop", "if", "else":
+			depth++
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// filename returns a file name for the golden file of a function.
+func filename(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	return string(b) + ".golden"
+}
+
+func assertGolden(t testing.TB, file string, actual []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("wasmtest: %v", err)
+		}
+		if err := ioutil.WriteFile(file, actual, 0644); err != nil {
+			t.Fatalf("wasmtest: %v", err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Errorf("wasmtest: %v (run with -wasmtest.update to create)", err)
+		return
+	}
+
+	if bytes.Equal(expected, actual) {
+		return
+	}
+
+	el := strings.Split(string(expected), "\n")
+	al := strings.Split(string(actual), "\n")
+	for i := 0; i < len(el) || i < len(al); i++ {
+		var e, a string
+		if i < len(el) {
+			e = el[i]
+		}
+		if i < len(al) {
+			a = al[i]
+		}
+		if e != a {
+			t.Errorf("wasmtest: %s does not match, first difference on line %d:\n\texpected: %s\n\tactual:   %s", file, i+1, e, a)
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package wasmtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func parse(t *testing.T) *wasm.Module {
+	f, err := os.Open(filepath.Join("..", "testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mod, err := wasm.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mod
+}
+
+func TestAssertFunctions(t *testing.T) {
+	mod := parse(t)
+	AssertFunctions(t, mod, "testdata", "sync_atomic.AddInt32", "runtime.nanotime", "2")
+}
+
+// recorder records test failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertFunctionsMismatch(t *testing.T) {
+	if *update {
+		t.Skip("updating golden files")
+	}
+
+	mod := parse(t)
+	r := &recorder{TB: t}
+
+	// Compare a function to the golden file of another function.
+	fi, err := Snapshot(mod, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(r, filepath.Join("testdata", "runtime.nanotime.golden"), []byte(fi))
+	if len(r.errors) != 1 {
+		t.Errorf("Expected 1 error, got %v", r.errors)
+	}
+
+	r.errors = nil
+	AssertFunctions(r, mod, "testdata", "no.such.function")
+	if len(r.errors) != 1 {
+		t.Errorf("Expected 1 error, got %v", r.errors)
+	}
+}
+
+func TestSnapshotNoBody(t *testing.T) {
+	// A function without a code section.
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{{}}},
+		&wasm.SectionFunction{Types: []uint32{0}},
+	}}
+	_, err := Snapshot(mod, 0)
+	if err == nil || err.Error() != "function 0 has no body" {
+		t.Errorf("Expected function 0 has no body, got %v", err)
+	}
+}
+
+func TestAssertFunctionsNormalize(t *testing.T) {
+	mod := parse(t)
+	AssertFunctionsWithOptions(t, mod, filepath.Join("testdata", "normalized"), Options{Normalize: true}, "memcmp")
+}
@@ -0,0 +1,250 @@
+package wast
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// A node is an S-expression: an atom, a string or a list.
+type node struct {
+	kind nodeKind
+
+	// atom is the text of an atom, such as a keyword, a number or an
+	// identifier.
+	atom string
+
+	// str is the decoded content of a string.
+	str []byte
+
+	// list contains the elements of a list.
+	list []*node
+
+	// line is the line the node starts on. start and end locate the node in
+	// the source.
+	line       int
+	start, end int
+}
+
+type nodeKind uint8
+
+const (
+	nodeAtom nodeKind = iota
+	nodeString
+	nodeList
+)
+
+// keyword returns the atom at the start of a list, or an empty string if n is
+// not a list that starts with an atom.
+func (n *node) keyword() string {
+	if n.kind != nodeList || len(n.list) == 0 || n.list[0].kind != nodeAtom {
+		return ""
+	}
+	return n.list[0].atom
+}
+
+func (n *node) String() string {
+	switch n.kind {
+	case nodeAtom:
+		return n.atom
+	case nodeString:
+		return strconv.Quote(string(n.str))
+	}
+	return fmt.Sprintf("(%s ...)", n.keyword())
+}
+
+// lexer splits a script into S-expressions.
+type lexer struct {
+	src  []byte
+	pos  int
+	line int
+}
+
+// parseNodes parses all S-expressions in src.
+func parseNodes(src []byte) ([]*node, error) {
+	l := &lexer{src: src, line: 1}
+	var nodes []*node
+	for {
+		if err := l.skipSpace(); err != nil {
+			return nil, err
+		}
+		if l.pos == len(l.src) {
+			return nodes, nil
+		}
+		n, err := l.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips white space and comments.
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case l.hasPrefix(";;"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case l.hasPrefix("(;"):
+			if err := l.skipBlockComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// skipBlockComment skips a block comment, which may be nested.
+func (l *lexer) skipBlockComment() error {
+	line := l.line
+	depth := 0
+	for l.pos < len(l.src) {
+		switch {
+		case l.hasPrefix("(;"):
+			depth++
+			l.pos += 2
+		case l.hasPrefix(";)"):
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return nil
+			}
+		default:
+			if l.src[l.pos] == '\n' {
+				l.line++
+			}
+			l.pos++
+		}
+	}
+	return fmt.Errorf("line %d: unterminated block comment", line)
+}
+
+func (l *lexer) hasPrefix(s string) bool {
+	return len(l.src)-l.pos >= len(s) && string(l.src[l.pos:l.pos+len(s)]) == s
+}
+
+func (l *lexer) parseNode() (*node, error) {
+	n := &node{line: l.line, start: l.pos}
+	switch l.src[l.pos] {
+	case '(':
+		n.kind = nodeList
+		l.pos++
+		for {
+			if err := l.skipSpace(); err != nil {
+				return nil, err
+			}
+			if l.pos == len(l.src) {
+				return nil, fmt.Errorf("line %d: unclosed list", n.line)
+			}
+			if l.src[l.pos] == ')' {
+				l.pos++
+				break
+			}
+			child, err := l.parseNode()
+			if err != nil {
+				return nil, err
+			}
+			n.list = append(n.list, child)
+		}
+	case ')':
+		return nil, l.errorf("unexpected )")
+	case '"':
+		n.kind = nodeString
+		s, err := l.parseString()
+		if err != nil {
+			return nil, err
+		}
+		n.str = s
+	default:
+		n.kind = nodeAtom
+		for l.pos < len(l.src) && !isDelimiter(l.src[l.pos]) {
+			l.pos++
+		}
+		n.atom = string(l.src[n.start:l.pos])
+	}
+	n.end = l.pos
+	return n, nil
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '(', ')', '"', ';':
+		return true
+	}
+	return false
+}
+
+// parseString parses a string with its escape sequences. Strings may hold
+// arbitrary bytes, such as binary modules.
+func (l *lexer) parseString() ([]byte, error) {
+	l.pos++ // opening quote
+	var s []byte
+	for {
+		if l.pos == len(l.src) || l.src[l.pos] == '\n' {
+			return nil, l.errorf("unterminated string")
+		}
+		c := l.src[l.pos]
+		l.pos++
+		switch c {
+		case '"':
+			return s, nil
+		case '\\':
+		default:
+			s = append(s, c)
+			continue
+		}
+
+		if l.pos == len(l.src) {
+			return nil, l.errorf("unterminated string")
+		}
+		c = l.src[l.pos]
+		l.pos++
+		switch c {
+		case 't':
+			s = append(s, '\t')
+		case 'n':
+			s = append(s, '\n')
+		case 'r':
+			s = append(s, '\r')
+		case '"', '\'', '\\':
+			s = append(s, c)
+		case 'u':
+			end := l.pos
+			for end < len(l.src) && l.src[end] != '}' {
+				end++
+			}
+			if l.src[l.pos] != '{' || end == len(l.src) {
+				return nil, l.errorf("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(string(l.src[l.pos+1:end]), 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return nil, l.errorf("invalid unicode escape")
+			}
+			s = append(s, string(rune(r))...)
+			l.pos = end + 1
+		default:
+			if l.pos == len(l.src) {
+				return nil, l.errorf("unterminated string")
+			}
+			b, err := strconv.ParseUint(string(l.src[l.pos-1:l.pos+1]), 16, 8)
+			if err != nil {
+				return nil, l.errorf("invalid escape \\%c", c)
+			}
+			s = append(s, byte(b))
+			l.pos++
+		}
+	}
+}
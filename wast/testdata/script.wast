@@ -0,0 +1,34 @@
+;; A script exercising the commands used by the spec test suite.
+
+(module $M
+  (func (export "add") (param i32 i32) (result i32)
+    (i32.add (local.get 0) (local.get 1)))
+  (; a (; nested ;) block comment ;)
+)
+
+(register "m" $M)
+
+(assert_return (invoke "add" (i32.const 1) (i32.const -2)) (i32.const -1))
+(assert_return (invoke $M "add" (i32.const 0xffff_ffff) (i32.const 1)) (i32.const 0))
+(assert_return (invoke "f" (f32.const -0x1p-1) (f64.const 1.5e3)) (f32.const nan:canonical))
+(assert_return (invoke "g" (i64.const -9223372036854775808)) (f64.const -inf) (f32.const nan:0x200000))
+(assert_return (invoke "r" (ref.null func)) (ref.extern 1) (either (i32.const 1) (i32.const 2)))
+(assert_trap (invoke "div" (i32.const 1) (i32.const 0)) "integer divide by zero")
+(get "global")
+
+(module binary
+  "\00asm" "\01\00\00\00"
+)
+(assert_malformed
+  (module binary "\00asm\02\00\00\00")
+  "unknown binary version"
+)
+(assert_malformed
+  (module quote "(func (result i32)" "(i32.const 0x))")
+  "unknown operator"
+)
+(assert_invalid
+  (module (func (result i32) (nop)))
+  "type mismatch"
+)
+(assert_unknown (module))
@@ -0,0 +1,498 @@
+// Package wast parses the .wast scripts of the WebAssembly spec test suite.
+//
+// A script is a sequence of commands that define modules, invoke their
+// exports and assert the results:
+//
+//	(module $M (func (export "add") (param i32 i32) (result i32) ...))
+//	(assert_return (invoke "add" (i32.const 1) (i32.const 2)) (i32.const 3))
+//	(assert_malformed (module binary "\00asm\02\00\00\00") "unknown binary version")
+//
+// The package only parses the scripts. Binary modules are decoded to bytes
+// that can be parsed with the wasm package, modules in the text format are
+// returned as source text.
+//
+// https://github.com/WebAssembly/spec/tree/main/interpreter#scripts
+package wast
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// A Script is a parsed .wast script.
+type Script struct {
+	Commands []Command
+}
+
+// A Command is a command in a script.
+type Command struct {
+	// Type is the keyword of the command, such as "module", "register",
+	// "invoke", "assert_return" or "assert_malformed". Commands the package
+	// doesn't know have only Type and Line set.
+	Type string
+
+	// Line is the line the command starts on.
+	Line int
+
+	// Module is the module of a "module" command and of the assertions on
+	// modules, such as "assert_malformed" and "assert_invalid".
+	Module *Module
+
+	// Action is the action of an "invoke" or "get" command, and of the
+	// assertions on actions, such as "assert_return" and "assert_trap".
+	Action *Action
+
+	// Expected contains the expected results of "assert_return".
+	Expected []Value
+
+	// Message is the expected error message of an assertion that fails, such
+	// as "assert_trap" or "assert_invalid".
+	Message string
+
+	// Name is the name a module is registered under by "register", and
+	// ModuleName the name of the module, or empty for the last module.
+	Name       string
+	ModuleName string
+}
+
+// A Module is a module defined in a script.
+type Module struct {
+	// Name is the name of the module, such as "$M", or empty if the module
+	// has no name.
+	Name string
+
+	// Binary is the binary encoding of a module given with "binary".
+	Binary []byte
+
+	// Text is the source of a module in the text format. For a module given
+	// with "quote" the strings are joined with spaces, and Quote is set.
+	Text  string
+	Quote bool
+
+	// Line is the line the module starts on.
+	Line int
+}
+
+// IsBinary reports whether the module is given in the binary format.
+func (m *Module) IsBinary() bool {
+	return m.Binary != nil
+}
+
+// An Action invokes an exported function or gets an exported global.
+type Action struct {
+	// Type is "invoke" or "get".
+	Type string
+
+	// Module is the name of the module, or empty for the last module.
+	Module string
+
+	// Field is the name of the export.
+	Field string
+
+	// Args contains the arguments of "invoke".
+	Args []Value
+}
+
+// A Value is an argument or an expected result.
+type Value struct {
+	// Type is the type of the value: "i32", "i64", "f32" or "f64", or the
+	// keyword of other values, such as "ref.null" or "v128.const".
+	Type string
+
+	// Bits holds the bits of a number. Integers are stored in two's
+	// complement and floats in their IEEE 754 encoding. For references it
+	// holds the index or host value, if there is one.
+	Bits uint64
+
+	// NaN is "canonical" or "arithmetic" for an expected float that matches
+	// any NaN of that kind, and empty otherwise.
+	NaN string
+
+	// Text holds the immediates of values that aren't numbers, such as the
+	// lanes of a "v128.const" or the type of a "ref.null".
+	Text string
+}
+
+func (v Value) String() string {
+	switch {
+	case v.NaN != "":
+		return fmt.Sprintf("%s:nan:%s", v.Type, v.NaN)
+	case v.Type == "f32":
+		return fmt.Sprintf("f32:%v", math.Float32frombits(uint32(v.Bits)))
+	case v.Type == "f64":
+		return fmt.Sprintf("f64:%v", math.Float64frombits(v.Bits))
+	case v.Type == "i32" || v.Type == "i64":
+		return fmt.Sprintf("%s:%d", v.Type, v.Bits)
+	case v.Text != "":
+		return v.Type + " " + v.Text
+	}
+	return v.Type
+}
+
+// Parse parses a script.
+func Parse(r io.Reader) (*Script, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(src)
+}
+
+// ParseBytes parses a script from a byte slice.
+func ParseBytes(src []byte) (*Script, error) {
+	nodes, err := parseNodes(src)
+	if err != nil {
+		return nil, err
+	}
+	s := &Script{}
+	for _, n := range nodes {
+		if n.kind != nodeList {
+			return nil, fmt.Errorf("line %d: expected command, got %s", n.line, n)
+		}
+		cmd, err := parseCommand(src, n)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %v", n.line, n.keyword(), err)
+		}
+		s.Commands = append(s.Commands, *cmd)
+	}
+	return s, nil
+}
+
+func parseCommand(src []byte, n *node) (*Command, error) {
+	cmd := &Command{Type: n.keyword(), Line: n.line}
+	args := n.list[1:]
+	var err error
+	switch cmd.Type {
+	case "module":
+		cmd.Module, err = parseModule(src, n)
+	case "register":
+		if len(args) == 0 || args[0].kind != nodeString {
+			return nil, fmt.Errorf("expected name")
+		}
+		cmd.Name = string(args[0].str)
+		if len(args) > 1 {
+			cmd.ModuleName = args[1].atom
+		}
+	case "invoke", "get":
+		cmd.Action, err = parseAction(n)
+	case "assert_return":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expected action")
+		}
+		if cmd.Action, err = parseAction(args[0]); err != nil {
+			return nil, err
+		}
+		for _, a := range args[1:] {
+			v, err := parseValue(src, a)
+			if err != nil {
+				return nil, err
+			}
+			cmd.Expected = append(cmd.Expected, v)
+		}
+	case "assert_trap", "assert_exhaustion", "assert_malformed", "assert_invalid",
+		"assert_unlinkable", "assert_uninstantiable":
+		if len(args) != 2 || args[1].kind != nodeString {
+			return nil, fmt.Errorf("expected module or action and message")
+		}
+		if args[0].keyword() == "module" {
+			cmd.Module, err = parseModule(src, args[0])
+		} else {
+			cmd.Action, err = parseAction(args[0])
+		}
+		cmd.Message = string(args[1].str)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// parseModule parses a module in one of the forms:
+//
+//	(module $name? binary "..."*)
+//	(module $name? quote "..."*)
+//	(module $name? field*)
+func parseModule(src []byte, n *node) (*Module, error) {
+	if n.keyword() != "module" {
+		return nil, fmt.Errorf("expected module, got %s", n)
+	}
+	m := &Module{Line: n.line}
+	args := n.list[1:]
+	if len(args) > 0 && args[0].kind == nodeAtom && strings.HasPrefix(args[0].atom, "$") {
+		m.Name = args[0].atom
+		args = args[1:]
+	}
+
+	if len(args) == 0 || args[0].kind != nodeAtom || args[0].atom != "binary" && args[0].atom != "quote" {
+		m.Text = string(src[n.start:n.end])
+		return m, nil
+	}
+
+	binary := args[0].atom == "binary"
+	var parts []string
+	for _, a := range args[1:] {
+		if a.kind != nodeString {
+			return nil, fmt.Errorf("expected string, got %s", a)
+		}
+		if binary {
+			m.Binary = append(m.Binary, a.str...)
+		} else {
+			parts = append(parts, string(a.str))
+		}
+	}
+	if binary && m.Binary == nil {
+		m.Binary = []byte{}
+	}
+	if !binary {
+		m.Text = strings.Join(parts, " ")
+		m.Quote = true
+	}
+	return m, nil
+}
+
+// parseAction parses an action:
+//
+//	(invoke $name? "field" value*)
+//	(get $name? "field")
+func parseAction(n *node) (*Action, error) {
+	a := &Action{Type: n.keyword()}
+	if a.Type != "invoke" && a.Type != "get" {
+		return nil, fmt.Errorf("expected action, got %s", n)
+	}
+	args := n.list[1:]
+	if len(args) > 0 && args[0].kind == nodeAtom && strings.HasPrefix(args[0].atom, "$") {
+		a.Module = args[0].atom
+		args = args[1:]
+	}
+	if len(args) == 0 || args[0].kind != nodeString {
+		return nil, fmt.Errorf("%s: expected field name", a.Type)
+	}
+	a.Field = string(args[0].str)
+	for _, arg := range args[1:] {
+		v, err := parseValue(nil, arg)
+		if err != nil {
+			return nil, err
+		}
+		a.Args = append(a.Args, v)
+	}
+	if a.Type == "get" && len(a.Args) > 0 {
+		return nil, fmt.Errorf("get: unexpected arguments")
+	}
+	return a, nil
+}
+
+// parseValue parses a constant such as (i32.const 1). Values that aren't
+// numbers keep their immediates as text. src is the source of the script,
+// used for the text of values that are not understood, and may be nil.
+func parseValue(src []byte, n *node) (Value, error) {
+	kw := n.keyword()
+	if kw == "" {
+		return Value{}, fmt.Errorf("expected value, got %s", n)
+	}
+
+	var imm []string
+	for _, a := range n.list[1:] {
+		if a.kind != nodeAtom {
+			if src == nil {
+				return Value{}, fmt.Errorf("%s: unexpected %s", kw, a)
+			}
+			// Values such as (either ...) are kept as they are.
+			return Value{Type: kw, Text: strings.TrimSpace(string(src[n.list[0].end : n.end-1]))}, nil
+		}
+		imm = append(imm, a.atom)
+	}
+
+	v := Value{Type: strings.TrimSuffix(kw, ".const")}
+	switch kw {
+	case "i32.const", "i64.const", "f32.const", "f64.const":
+		if len(imm) != 1 {
+			return Value{}, fmt.Errorf("%s: expected 1 immediate, got %d", kw, len(imm))
+		}
+		var err error
+		switch v.Type {
+		case "i32":
+			v.Bits, err = parseInt(imm[0], 32)
+		case "i64":
+			v.Bits, err = parseInt(imm[0], 64)
+		case "f32", "f64":
+			if imm[0] == "nan:canonical" || imm[0] == "nan:arithmetic" {
+				v.NaN = imm[0][len("nan:"):]
+				break
+			}
+			if v.Type == "f32" {
+				v.Bits, err = parseFloat(imm[0], 32)
+			} else {
+				v.Bits, err = parseFloat(imm[0], 64)
+			}
+		}
+		if err != nil {
+			return Value{}, fmt.Errorf("%s: %v", kw, err)
+		}
+	default:
+		v.Type = kw
+		v.Text = strings.Join(imm, " ")
+		if len(imm) == 1 {
+			if bits, err := parseInt(imm[0], 64); err == nil {
+				v.Bits = bits
+			}
+		}
+	}
+	return v, nil
+}
+
+// parseInt parses an integer literal of the given size. The literal may be
+// signed or unsigned, decimal or hexadecimal, and contain underscores between
+// digits. Negative values are returned in two's complement.
+func parseInt(s string, bits uint) (uint64, error) {
+	lit := s
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	base := 10
+	if strings.HasPrefix(s, "0x") {
+		base = 16
+		s = s[2:]
+	}
+	if s == "" || strings.HasPrefix(s, "_") || strings.HasSuffix(s, "_") || strings.Contains(s, "__") {
+		return 0, fmt.Errorf("invalid integer %q", lit)
+	}
+	v, err := strconv.ParseUint(strings.Replace(s, "_", "", -1), base, int(bits))
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q", lit)
+	}
+	mask := uint64(1)<<bits - 1
+	if bits == 64 {
+		mask = math.MaxUint64
+	}
+	if neg {
+		if v > 1<<(bits-1) {
+			return 0, fmt.Errorf("integer %q out of range", lit)
+		}
+		v = -v & mask
+	}
+	return v, nil
+}
+
+// parseFloat parses a float literal of the given size and returns its bits.
+// The literal may be decimal or hexadecimal, inf, nan or nan:0x with a
+// payload.
+func parseFloat(s string, bits int) (uint64, error) {
+	lit := s
+	sign := uint64(0)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = 1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	mantBits, expMask := uint(52), uint64(0x7ff0000000000000)
+	if bits == 32 {
+		mantBits, expMask = 23, 0x7f800000
+	}
+	sign <<= uint(bits) - 1
+
+	switch {
+	case s == "inf":
+		return sign | expMask, nil
+	case s == "nan":
+		return sign | expMask | 1<<(mantBits-1), nil
+	case strings.HasPrefix(s, "nan:0x"):
+		payload, err := strconv.ParseUint(strings.Replace(s[len("nan:0x"):], "_", "", -1), 16, 64)
+		if err != nil || payload == 0 || payload >= 1<<mantBits {
+			return 0, fmt.Errorf("invalid NaN payload %q", lit)
+		}
+		return sign | expMask | payload, nil
+	}
+
+	if s == "" || strings.HasPrefix(s, "_") || strings.HasSuffix(s, "_") || strings.Contains(s, "__") {
+		return 0, fmt.Errorf("invalid float %q", lit)
+	}
+	s = strings.Replace(s, "_", "", -1)
+
+	var f float64
+	var err error
+	if strings.HasPrefix(s, "0x") {
+		f, err = parseHexFloat(s[2:], bits)
+	} else if strings.Trim(s, "0123456789.eE+-") != "" {
+		err = fmt.Errorf("invalid float %q", lit)
+	} else if f, err = strconv.ParseFloat(s, bits); err != nil {
+		err = fmt.Errorf("invalid float %q", lit)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if bits == 32 {
+		return sign | uint64(math.Float32bits(float32(f))), nil
+	}
+	return sign | math.Float64bits(f), nil
+}
+
+// parseHexFloat parses the unsigned hexadecimal float s, without the 0x prefix,
+// such as 1.8p-3. The result is rounded to the nearest float of the given
+// size.
+func parseHexFloat(s string, bits int) (float64, error) {
+	lit := "0x" + s
+	mant := new(big.Int)
+	digits, frac := 0, -1
+	i := 0
+	for ; i < len(s) && s[i] != 'p' && s[i] != 'P'; i++ {
+		if s[i] == '.' && frac < 0 {
+			frac = 0
+			continue
+		}
+		d, err := strconv.ParseUint(s[i:i+1], 16, 8)
+		if err != nil {
+			return 0, fmt.Errorf("invalid float %q", lit)
+		}
+		mant.Lsh(mant, 4).Or(mant, big.NewInt(int64(d)))
+		digits++
+		if frac >= 0 {
+			frac++
+		}
+	}
+	if digits == 0 {
+		return 0, fmt.Errorf("invalid float %q", lit)
+	}
+
+	exp := 0
+	if i < len(s) {
+		var err error
+		if exp, err = strconv.Atoi(s[i+1:]); err != nil || exp > 1<<20 {
+			return 0, fmt.Errorf("invalid float %q", lit)
+		}
+		if exp < -1<<20 {
+			// The value underflows to zero.
+			return 0, nil
+		}
+	}
+	if frac > 0 {
+		exp -= 4 * frac
+	}
+
+	// The conversion from the exact value rounds once, also to subnormals.
+	x := new(big.Float).SetInt(mant)
+	x.SetMantExp(x, exp)
+	var f float64
+	if bits == 32 {
+		f32, _ := x.Float32()
+		f = float64(f32)
+	} else {
+		f, _ = x.Float64()
+	}
+	if math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid float %q", lit)
+	}
+	return f, nil
+}
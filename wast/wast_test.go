@@ -0,0 +1,178 @@
+package wast
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "script.wast"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	for _, c := range s.Commands {
+		types = append(types, c.Type)
+	}
+	expected := []string{
+		"module", "register",
+		"assert_return", "assert_return", "assert_return", "assert_return", "assert_return",
+		"assert_trap", "get",
+		"module", "assert_malformed", "assert_malformed", "assert_invalid", "assert_unknown",
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatalf("Expected commands\n%v\ngot\n%v", expected, types)
+	}
+
+	c := s.Commands
+	if m := c[0].Module; m.Name != "$M" || m.IsBinary() || !strings.HasPrefix(m.Text, "(module $M") || !strings.HasSuffix(m.Text, ")") || m.Line != 3 {
+		t.Errorf("Unexpected text module %+v", m)
+	}
+	if c[1].Name != "m" || c[1].ModuleName != "$M" {
+		t.Errorf("Unexpected register %+v", c[1])
+	}
+
+	a := c[2].Action
+	if a.Type != "invoke" || a.Field != "add" || a.Module != "" {
+		t.Errorf("Unexpected action %+v", a)
+	}
+	if expected := []Value{{Type: "i32", Bits: 1}, {Type: "i32", Bits: 0xfffffffe}}; !reflect.DeepEqual(a.Args, expected) {
+		t.Errorf("Expected args %v, got %v", expected, a.Args)
+	}
+	if expected := []Value{{Type: "i32", Bits: 0xffffffff}}; !reflect.DeepEqual(c[2].Expected, expected) {
+		t.Errorf("Expected results %v, got %v", expected, c[2].Expected)
+	}
+	if a := c[3].Action; a.Module != "$M" || a.Args[0].Bits != 0xffffffff {
+		t.Errorf("Unexpected action %+v", a)
+	}
+
+	if expected := []Value{
+		{Type: "f32", Bits: uint64(math.Float32bits(-0.5))},
+		{Type: "f64", Bits: math.Float64bits(1500)},
+	}; !reflect.DeepEqual(c[4].Action.Args, expected) {
+		t.Errorf("Expected args %v, got %v", expected, c[4].Action.Args)
+	}
+	if expected := []Value{{Type: "f32", NaN: "canonical"}}; !reflect.DeepEqual(c[4].Expected, expected) {
+		t.Errorf("Expected results %v, got %v", expected, c[4].Expected)
+	}
+
+	if expected := []Value{{Type: "i64", Bits: 1 << 63}}; !reflect.DeepEqual(c[5].Action.Args, expected) {
+		t.Errorf("Expected args %v, got %v", expected, c[5].Action.Args)
+	}
+	if expected := []Value{
+		{Type: "f64", Bits: math.Float64bits(math.Inf(-1))},
+		{Type: "f32", Bits: 0x7fa00000},
+	}; !reflect.DeepEqual(c[5].Expected, expected) {
+		t.Errorf("Expected results %v, got %v", expected, c[5].Expected)
+	}
+
+	if expected := []Value{{Type: "ref.null", Text: "func"}}; !reflect.DeepEqual(c[6].Action.Args, expected) {
+		t.Errorf("Expected args %v, got %v", expected, c[6].Action.Args)
+	}
+	if expected := []Value{
+		{Type: "ref.extern", Bits: 1, Text: "1"},
+		{Type: "either", Text: "(i32.const 1) (i32.const 2)"},
+	}; !reflect.DeepEqual(c[6].Expected, expected) {
+		t.Errorf("Expected results %v, got %v", expected, c[6].Expected)
+	}
+
+	if c[7].Message != "integer divide by zero" || c[7].Action.Field != "div" {
+		t.Errorf("Unexpected assert_trap %+v", c[7])
+	}
+	if a := c[8].Action; a.Type != "get" || a.Field != "global" {
+		t.Errorf("Unexpected get %+v", a)
+	}
+
+	if m := c[9].Module; string(m.Binary) != "\x00asm\x01\x00\x00\x00" {
+		t.Errorf("Unexpected binary module %q", m.Binary)
+	}
+	if c[10].Message != "unknown binary version" || string(c[10].Module.Binary) != "\x00asm\x02\x00\x00\x00" {
+		t.Errorf("Unexpected assert_malformed %+v", c[10])
+	}
+	if m := c[11].Module; !m.Quote || m.Text != "(func (result i32) (i32.const 0x))" {
+		t.Errorf("Unexpected quoted module %+v", m)
+	}
+	if m := c[12].Module; m.IsBinary() || m.Text != "(module (func (result i32) (nop)))" || c[12].Message != "type mismatch" {
+		t.Errorf("Unexpected assert_invalid %+v", c[12])
+	}
+	if c[13].Module != nil || c[13].Line != 34 {
+		t.Errorf("Unexpected unknown command %+v", c[13])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		err    string
+	}{
+		{`(module`, "line 1: unclosed list"},
+		{`)`, "line 1: unexpected )"},
+		{"\n(; comment", "line 2: unterminated block comment"},
+		{`(module binary "\00asm`, "line 1: unterminated string"},
+		{`(module binary "\zz")`, `line 1: invalid escape \z`},
+		{`module`, "line 1: expected command, got module"},
+		{`(assert_return (invoke "f" (i32.const 0x1_0000_0000)))`, `line 1: assert_return: i32.const: invalid integer "0x1_0000_0000"`},
+		{`(assert_return (invoke "f" (i32.const -0x8000_0001)))`, `line 1: assert_return: i32.const: integer "-0x8000_0001" out of range`},
+		{`(assert_return (invoke "f" (i32.const 1__0)))`, `line 1: assert_return: i32.const: invalid integer "1__0"`},
+		{`(assert_return (invoke "f" (f32.const 1e39)))`, `line 1: assert_return: f32.const: invalid float "1e39"`},
+		{`(assert_return (invoke "f" (f32.const 0x1p128)))`, `line 1: assert_return: f32.const: invalid float "0x1p128"`},
+		{`(assert_return (invoke "f" (f64.const Infinity)))`, `line 1: assert_return: f64.const: invalid float "Infinity"`},
+		{`(assert_return (invoke "f" (f32.const nan:0x800000)))`, `line 1: assert_return: f32.const: invalid NaN payload "nan:0x800000"`},
+		{`(assert_return (module))`, "line 1: assert_return: expected action, got (module ...)"},
+		{`(assert_trap (invoke "f"))`, "line 1: assert_trap: expected module or action and message"},
+		{`(register $M)`, "line 1: register: expected name"},
+	}
+	for _, tt := range tests {
+		_, err := ParseBytes([]byte(tt.script))
+		if err == nil {
+			t.Errorf("%s: expected error %q", tt.script, tt.err)
+			continue
+		}
+		if err.Error() != tt.err {
+			t.Errorf("%s: expected error %q, got %q", tt.script, tt.err, err)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		lit  string
+		bits int
+		want uint64
+	}{
+		{"0", 32, 0},
+		{"-0", 32, 0x80000000},
+		{"+1.5", 64, math.Float64bits(1.5)},
+		{"0x1.8", 64, math.Float64bits(1.5)},
+		{"0x1p+127", 32, uint64(math.Float32bits(float32(math.Ldexp(1, 127))))},
+		{"0x1.fffffep127", 32, uint64(math.Float32bits(math.MaxFloat32))},
+		{"0x1p-149", 32, 1},
+		{"0x.8", 64, math.Float64bits(0.5)},
+		{"0x1P-1_0", 64, math.Float64bits(math.Ldexp(1, -10))},
+		{"1_000.5", 64, math.Float64bits(1000.5)},
+		{"inf", 32, 0x7f800000},
+		{"-nan", 64, 0xfff8000000000000},
+		{"nan:0x1", 64, 0x7ff0000000000001},
+	}
+	for _, tt := range tests {
+		got, err := parseFloat(tt.lit, tt.bits)
+		if err != nil {
+			t.Errorf("%s: %v", tt.lit, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected 0x%x, got 0x%x", tt.lit, tt.want, got)
+		}
+	}
+}
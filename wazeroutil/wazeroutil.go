@@ -0,0 +1,264 @@
+// Package wazeroutil describes the imports and exports of a parsed module in
+// the shapes used by the embedder API of wazero
+// (https://github.com/tetratelabs/wazero), so a module can be inspected before
+// it's compiled or instantiated, for example to build the host modules it
+// imports from.
+//
+// wazero's api.ValueType and api.ExternType are aliases for byte that hold the
+// binary encoding, like the types in this package, so the values can be used
+// with wazero directly and the package doesn't depend on it:
+//
+//	for _, f := range imports {
+//		builder := r.NewHostModuleBuilder(f.ModuleName)
+//		builder.NewFunctionBuilder().
+//			WithGoModuleFunction(hostFunc(f), f.ParamTypes, f.ResultTypes).
+//			Export(f.Name)
+//	}
+package wazeroutil
+
+import (
+	"fmt"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+// ValueType is the type of a value, with the same encoding as wazero's
+// api.ValueType.
+type ValueType = byte
+
+// Value types.
+const (
+	ValueTypeI32       ValueType = 0x7f
+	ValueTypeI64       ValueType = 0x7e
+	ValueTypeF32       ValueType = 0x7d
+	ValueTypeF64       ValueType = 0x7c
+	ValueTypeV128      ValueType = 0x7b
+	ValueTypeFuncref   ValueType = 0x70
+	ValueTypeExternref ValueType = 0x6f
+)
+
+// ExternType is the kind of an import or export, with the same encoding as
+// wazero's api.ExternType.
+type ExternType = byte
+
+// Extern types.
+const (
+	ExternTypeFunc   ExternType = 0x00
+	ExternTypeTable  ExternType = 0x01
+	ExternTypeMemory ExternType = 0x02
+	ExternTypeGlobal ExternType = 0x03
+)
+
+// An Import is an import of a module.
+type Import struct {
+	ModuleName string
+	Name       string
+	Type       ExternType
+}
+
+// A FunctionDefinition describes an imported or exported function, like
+// wazero's api.FunctionDefinition.
+type FunctionDefinition struct {
+	// Index is the index of the function in the function index space.
+	Index uint32
+
+	// ModuleName and Name are the module and field names of an imported
+	// function, and Imported is true.
+	ModuleName string
+	Name       string
+	Imported   bool
+
+	// ExportNames contains the names the function is exported as, in the
+	// order of the exports.
+	ExportNames []string
+
+	// DebugName is the name of the function in the name section, or empty.
+	DebugName string
+
+	ParamTypes  []ValueType
+	ResultTypes []ValueType
+}
+
+// A MemoryDefinition describes an imported or exported memory, like wazero's
+// api.MemoryDefinition. The limits are in pages of 64 KiB.
+type MemoryDefinition struct {
+	// Index is the index of the memory in the memory index space.
+	Index uint32
+
+	// ModuleName and Name are the module and field names of an imported
+	// memory, and Imported is true.
+	ModuleName string
+	Name       string
+	Imported   bool
+
+	// ExportNames contains the names the memory is exported as, in the
+	// order of the exports.
+	ExportNames []string
+
+	Min    uint32
+	Max    uint32
+	HasMax bool
+}
+
+// Imports returns the imports of m in the order they are declared.
+func Imports(m *wasm.Module) []Import {
+	s := m.ImportSection()
+	if s == nil {
+		return nil
+	}
+	imports := make([]Import, len(s.Entries))
+	for i, e := range s.Entries {
+		imports[i] = Import{ModuleName: e.Module, Name: e.Field, Type: ExternType(e.Kind)}
+	}
+	return imports
+}
+
+// ImportedFunctions returns the functions imported by m, in the order of the
+// function index space.
+func ImportedFunctions(m *wasm.Module) ([]FunctionDefinition, error) {
+	funcs, err := functions(m)
+	if err != nil {
+		return nil, err
+	}
+	var imported []FunctionDefinition
+	for _, f := range funcs {
+		if f.Imported {
+			imported = append(imported, f)
+		}
+	}
+	return imported, nil
+}
+
+// ExportedFunctions returns the functions exported by m, keyed by export name.
+func ExportedFunctions(m *wasm.Module) (map[string]FunctionDefinition, error) {
+	funcs, err := functions(m)
+	if err != nil {
+		return nil, err
+	}
+	exported := make(map[string]FunctionDefinition)
+	for _, f := range funcs {
+		for _, name := range f.ExportNames {
+			exported[name] = f
+		}
+	}
+	return exported, nil
+}
+
+// ImportedMemories returns the memories imported by m.
+func ImportedMemories(m *wasm.Module) []MemoryDefinition {
+	var imported []MemoryDefinition
+	for _, mem := range memories(m) {
+		if mem.Imported {
+			imported = append(imported, mem)
+		}
+	}
+	return imported
+}
+
+// ExportedMemories returns the memories exported by m, keyed by export name.
+func ExportedMemories(m *wasm.Module) map[string]MemoryDefinition {
+	exported := make(map[string]MemoryDefinition)
+	for _, mem := range memories(m) {
+		for _, name := range mem.ExportNames {
+			exported[name] = mem
+		}
+	}
+	return exported
+}
+
+// functions returns the definitions of all functions in the function index
+// space.
+func functions(m *wasm.Module) ([]FunctionDefinition, error) {
+	var types []wasm.FuncType
+	if s := m.TypeSection(); s != nil {
+		types = s.Entries
+	}
+	var funcs []FunctionDefinition
+	add := func(ti uint32, f FunctionDefinition) error {
+		if int(ti) >= len(types) {
+			return fmt.Errorf("function %d: type %d out of range", f.Index, ti)
+		}
+		f.ParamTypes = valueTypes(types[ti].Params)
+		f.ResultTypes = valueTypes(types[ti].ReturnTypes)
+		funcs = append(funcs, f)
+		return nil
+	}
+
+	if s := m.ImportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind != wasm.ExtKindFunction {
+				continue
+			}
+			f := FunctionDefinition{Index: uint32(len(funcs)), ModuleName: e.Module, Name: e.Field, Imported: true}
+			if err := add(e.FunctionType.Index, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s := m.FunctionSection(); s != nil {
+		for _, ti := range s.Types {
+			if err := add(ti, FunctionDefinition{Index: uint32(len(funcs))}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if s := m.ExportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind != wasm.ExtKindFunction {
+				continue
+			}
+			if int(e.Index) >= len(funcs) {
+				return nil, fmt.Errorf("export %s: function %d out of range", e.Field, e.Index)
+			}
+			funcs[e.Index].ExportNames = append(funcs[e.Index].ExportNames, e.Field)
+		}
+	}
+	if s := m.NameSection(); s != nil && s.Functions != nil {
+		for _, n := range s.Functions.Names {
+			if int(n.Index) < len(funcs) {
+				funcs[n.Index].DebugName = n.Name
+			}
+		}
+	}
+	return funcs, nil
+}
+
+// memories returns the definitions of all memories in the memory index space.
+func memories(m *wasm.Module) []MemoryDefinition {
+	var mems []MemoryDefinition
+	add := func(l wasm.ResizableLimits, mem MemoryDefinition) {
+		mem.Index = uint32(len(mems))
+		mem.Min = l.Initial
+		mem.Max, mem.HasMax = l.Maximum, l.HasMaximum
+		mems = append(mems, mem)
+	}
+	if s := m.ImportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind == wasm.ExtKindMemory {
+				add(e.MemoryType.Limits, MemoryDefinition{ModuleName: e.Module, Name: e.Field, Imported: true})
+			}
+		}
+	}
+	if s := m.MemorySection(); s != nil {
+		for _, mem := range s.Entries {
+			add(mem.Limits, MemoryDefinition{})
+		}
+	}
+	if s := m.ExportSection(); s != nil {
+		for _, e := range s.Entries {
+			if e.Kind == wasm.ExtKindMemory && int(e.Index) < len(mems) {
+				mems[e.Index].ExportNames = append(mems[e.Index].ExportNames, e.Field)
+			}
+		}
+	}
+	return mems
+}
+
+func valueTypes(types []wasm.ValueType) []ValueType {
+	vt := make([]ValueType, len(types))
+	for i, t := range types {
+		vt[i] = ValueType(t)
+	}
+	return vt
+}
@@ -0,0 +1,117 @@
+package wazeroutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	wasm "github.com/akupila/go-wasm"
+)
+
+func TestFunctions(t *testing.T) {
+	mod := &wasm.Module{Sections: []wasm.Section{
+		&wasm.SectionType{Entries: []wasm.FuncType{
+			{Form: 0x60, Params: []wasm.ValueType{0x7f, 0x7e}, ReturnTypes: []wasm.ValueType{0x7c}},
+			{Form: 0x60},
+		}},
+		&wasm.SectionImport{Entries: []wasm.ImportEntry{
+			{Module: "env", Field: "mem", Kind: wasm.ExtKindMemory, MemoryType: &wasm.MemoryType{Limits: wasm.ResizableLimits{Initial: 1, Maximum: 2, HasMaximum: true}}},
+			{Module: "env", Field: "f", Kind: wasm.ExtKindFunction, FunctionType: &wasm.FunctionType{Index: 0}},
+		}},
+		&wasm.SectionFunction{Types: []uint32{1}},
+		&wasm.SectionExport{Entries: []wasm.ExportEntry{
+			{Field: "run", Kind: wasm.ExtKindFunction, Index: 1},
+			{Field: "_start", Kind: wasm.ExtKindFunction, Index: 1},
+			{Field: "memory", Kind: wasm.ExtKindMemory, Index: 0},
+		}},
+		&wasm.SectionName{SectionName: "name", Functions: &wasm.NameMap{Names: []wasm.Naming{{Index: 1, Name: "main.run"}}}},
+	}}
+
+	imports := []Import{
+		{ModuleName: "env", Name: "mem", Type: ExternTypeMemory},
+		{ModuleName: "env", Name: "f", Type: ExternTypeFunc},
+	}
+	if got := Imports(mod); !reflect.DeepEqual(got, imports) {
+		t.Errorf("Expected imports %v, got %v", imports, got)
+	}
+
+	imported, err := ImportedFunctions(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FunctionDefinition{{
+		ModuleName:  "env",
+		Name:        "f",
+		Imported:    true,
+		ParamTypes:  []ValueType{ValueTypeI32, ValueTypeI64},
+		ResultTypes: []ValueType{ValueTypeF64},
+	}}
+	if !reflect.DeepEqual(imported, expected) {
+		t.Errorf("Expected imported functions %+v, got %+v", expected, imported)
+	}
+
+	exported, err := ExportedFunctions(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := FunctionDefinition{
+		Index:       1,
+		ExportNames: []string{"run", "_start"},
+		DebugName:   "main.run",
+		ParamTypes:  []ValueType{},
+		ResultTypes: []ValueType{},
+	}
+	if expected := map[string]FunctionDefinition{"run": run, "_start": run}; !reflect.DeepEqual(exported, expected) {
+		t.Errorf("Expected exported functions %+v, got %+v", expected, exported)
+	}
+
+	mem := MemoryDefinition{ModuleName: "env", Name: "mem", Imported: true, ExportNames: []string{"memory"}, Min: 1, Max: 2, HasMax: true}
+	if got := ImportedMemories(mod); !reflect.DeepEqual(got, []MemoryDefinition{mem}) {
+		t.Errorf("Expected imported memories %+v, got %+v", mem, got)
+	}
+	if got := ExportedMemories(mod); !reflect.DeepEqual(got, map[string]MemoryDefinition{"memory": mem}) {
+		t.Errorf("Expected exported memories %+v, got %+v", mem, got)
+	}
+
+	mod.Sections[2] = &wasm.SectionFunction{Types: []uint32{2}}
+	if _, err := ExportedFunctions(mod); err == nil {
+		t.Error("Expected error for type out of range")
+	}
+}
+
+func TestFunctionsGo(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "testdata", "helloworld.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mod, err := wasm.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := ImportedFunctions(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 14 {
+		t.Errorf("Expected 14 imported functions, got %d", len(imported))
+	}
+	for _, f := range imported {
+		if f.ModuleName != "go" || !reflect.DeepEqual(f.ParamTypes, []ValueType{ValueTypeI32}) {
+			t.Errorf("Unexpected import %+v", f)
+		}
+	}
+
+	exported, err := ExportedFunctions(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run, ok := exported["run"]; !ok || run.Index != 864 || len(run.ParamTypes) != 2 {
+		t.Errorf("Unexpected run export %+v", run)
+	}
+	if _, ok := ExportedMemories(mod)["mem"]; !ok {
+		t.Error("Expected exported memory mem")
+	}
+}